@@ -1,24 +1,22 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter06/db"
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter06/handlers"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/client"
 	"github.com/cucumber/godog"
 )
 
 // contextKey is used to pass information between test steps.
 type contextKey struct {
-	UsersURL string
-	User     db.User
+	Client *client.Client
+	User   db.User
 }
 
 func theBookSwapAppIsUp(ctx context.Context) (context.Context, error) {
@@ -26,13 +24,16 @@ func theBookSwapAppIsUp(ctx context.Context) (context.Context, error) {
 	if err != nil {
 		return ctx, fmt.Errorf("incorrect config:%v", err)
 	}
-	resp, err := http.Get(*url)
-	if err != nil || resp.StatusCode != http.StatusOK {
+	c, err := client.NewClient(*url, nil)
+	if err != nil {
+		return ctx, fmt.Errorf("incorrect config:%v", err)
+	}
+	if err := c.Do(ctx, http.MethodGet, "/", nil, nil); err != nil {
 		return ctx, fmt.Errorf("bookswap not up:%v", err)
 	}
 
 	return context.WithValue(ctx, contextKey{}, contextKey{
-		UsersURL: *url + "/users",
+		Client: c,
 	}), nil
 }
 
@@ -56,23 +57,9 @@ func sentToTheUsersEndpoint(ctx context.Context) (context.Context, error) {
 	if !ok {
 		return ctx, errors.New("config missing")
 	}
-	userPayload, err := json.Marshal(config.User)
-	if err != nil {
-		return ctx, fmt.Errorf("error marshalling user:%v", err)
-	}
-	r, err := http.Post(config.UsersURL, "application/json", bytes.NewBuffer(userPayload))
-	if err != nil || r.StatusCode != http.StatusOK {
-		return ctx, fmt.Errorf("error creating user :%v", err)
-	}
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return ctx, fmt.Errorf("error reading body:%v", err)
-	}
-	r.Body.Close()
 	var resp handlers.Response
-	err = json.Unmarshal(body, &resp)
-	if err != nil {
-		return ctx, fmt.Errorf("error unmarshalling body:%v", err)
+	if err := config.Client.Do(ctx, http.MethodPost, "/users", config.User, &resp); err != nil {
+		return ctx, fmt.Errorf("error creating user :%v", err)
 	}
 	if resp.User == nil {
 		return ctx, errors.New("no user in users reponse")
@@ -90,19 +77,9 @@ func aNewUserProfileIsCreated(ctx context.Context) (context.Context, error) {
 	if !ok {
 		return ctx, errors.New("config missing")
 	}
-	r, err := http.Get(fmt.Sprintf("%s/%s", config.UsersURL, config.User.ID))
-	if err != nil || r.StatusCode != http.StatusOK {
-		return ctx, fmt.Errorf("error getting user :%v", err)
-	}
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return ctx, fmt.Errorf("error reading body:%v", err)
-	}
-	r.Body.Close()
 	var resp handlers.Response
-	err = json.Unmarshal(body, &resp)
-	if err != nil {
-		return ctx, fmt.Errorf("error unmarshalling body:%v", err)
+	if err := config.Client.Do(ctx, http.MethodGet, "/users/"+config.User.ID, nil, &resp); err != nil {
+		return ctx, fmt.Errorf("error getting user :%v", err)
 	}
 	if resp.User == nil {
 		return ctx, errors.New("no user in users reponse")
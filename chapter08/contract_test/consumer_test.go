@@ -1,12 +1,14 @@
 package contract_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"testing"
 
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter05/handlers"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/client"
 	"github.com/pact-foundation/pact-go/dsl"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -27,13 +29,12 @@ func TestConsumerIndex_Local(t *testing.T) {
 	var test = func() (err error) {
 		baseURL, ok := os.LookupEnv("BOOKSWAP_BASE_URL")
 		require.True(t, ok)
-		url := fmt.Sprintf("%s:%d/", baseURL, pact.Server.Port)
-		req, err := http.NewRequest("GET", url, nil)
+		c, err := client.NewClient(fmt.Sprintf("%s:%d", baseURL, pact.Server.Port), nil)
+		require.Nil(t, err)
+		var resp handlers.Response
+		err = c.Do(context.Background(), http.MethodGet, "/", nil, &resp)
 		assert.Nil(t, err)
-		req.Header.Set("Content-Type", "application/json")
-		resp, err := http.DefaultClient.Do(req)
-		assert.Nil(t, err)
-		assert.NotNil(t, resp)
+		assert.NotEmpty(t, resp.Message)
 		return
 	}
 
@@ -46,7 +47,7 @@ func TestConsumerIndex_Local(t *testing.T) {
 				Method: "GET",
 				Path:   dsl.String("/"),
 				Headers: dsl.MapMatcher{
-					"Content-Type": dsl.String("application/json"),
+					"Accept": dsl.String("application/json"),
 				},
 			}).
 			WillRespondWith(dsl.Response{
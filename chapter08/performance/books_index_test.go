@@ -1,21 +1,26 @@
 package perf
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"testing"
 
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter08/handlers"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/client"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func BenchmarkGetIndex(b *testing.B) {
-	endpoint := getTestEndpoint(b)
+	c, err := client.NewClient(getTestEndpoint(b), nil)
+	require.Nil(b, err)
 	for x := 0; x < b.N; x++ {
-		bks, err := http.Get(endpoint)
+		var resp handlers.Response
+		err := c.Do(context.Background(), http.MethodGet, "/", nil, &resp)
 		assert.Nil(b, err)
-		assert.NotNil(b, bks)
+		assert.NotEmpty(b, resp.Message)
 	}
 }
 
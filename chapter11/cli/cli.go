@@ -0,0 +1,186 @@
+// Package cli implements the bookswap command line tool,
+// which operates a BookSwap service through its REST API.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/client"
+)
+
+const defaultURL = "http://localhost:3000"
+
+// errParse is returned once the flag package has reported invalid flags.
+var errParse = errors.New("bookswap: invalid flags")
+
+// usageError is returned for invalid command lines.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...any) error {
+	return usageError{msg: "bookswap: " + fmt.Sprintf(format, args...)}
+}
+
+// command is a subcommand of the CLI, which is named by one or more words.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, a *app, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{name: "users create", args: "-name NAME [flags]", summary: "Create a user", run: createUser},
+	{name: "users list", summary: "List the users", run: listUsers},
+	{name: "books import", args: "[-owner ID] FILE", summary: "Create the books of a CSV file", run: importBooks},
+	{name: "swap", args: "-user ID book|magazine ID", summary: "Swap a book or magazine to a user", run: swap},
+	{name: "history", args: "book|magazine|user ID", summary: "Show the history of an item or of a user's items",
+		run: history},
+	{name: "migrate", args: "[-db URL] up|down|version", summary: "Run the database migrations", run: migrateDB},
+}
+
+// app contains the dependencies shared by all the commands.
+type app struct {
+	client *client.Client
+	out    printer
+}
+
+// Run executes the command line given by args and returns its exit code:
+// 0 on success, 1 if the command failed and 2 if the command line is invalid.
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("bookswap", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	baseURL := fs.String("url", "",
+		"base `URL` of the BookSwap service (default $BOOKSWAP_BASE_URL:$BOOKSWAP_PORT or "+defaultURL+")")
+	output := fs.String("output", "table", "output `format`: table or json")
+	community := fs.String("community", "",
+		"`slug` of the community to use, instead of the community of the URL's host (default $BOOKSWAP_COMMUNITY)")
+	fs.Usage = func() { usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "bookswap: unknown output format %q\n", *output)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		usage(fs)
+		return 2
+	}
+	if fs.Arg(0) == "help" {
+		fs.SetOutput(stdout)
+		usage(fs)
+		return 0
+	}
+	cmd, rest, ok := lookup(fs.Args())
+	if !ok {
+		fmt.Fprintf(stderr, "bookswap: unknown command %q\n", strings.Join(fs.Args(), " "))
+		fmt.Fprintln(stderr, "Run 'bookswap help' for the list of commands.")
+		return 2
+	}
+	if *baseURL == "" {
+		*baseURL = envURL()
+	}
+	c, err := client.NewClient(*baseURL, nil)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *community == "" {
+		*community = os.Getenv("BOOKSWAP_COMMUNITY")
+	}
+	c.WithCommunity(*community)
+
+	cfs := flag.NewFlagSet("bookswap "+cmd.name, flag.ContinueOnError)
+	cfs.SetOutput(stderr)
+	cfs.Usage = func() {
+		fmt.Fprintf(cfs.Output(), "Usage: %s\n\n%s.\n", strings.TrimSpace("bookswap "+cmd.name+" "+cmd.args), cmd.summary)
+		var flags int
+		cfs.VisitAll(func(*flag.Flag) { flags++ })
+		if flags > 0 {
+			fmt.Fprint(cfs.Output(), "\nFlags:\n")
+			cfs.PrintDefaults()
+		}
+	}
+	err = cmd.run(ctx, &app{client: c, out: printer{w: stdout, json: *output == "json"}}, cfs, rest)
+	var uerr usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errParse):
+		return 2
+	case errors.As(err, &uerr):
+		fmt.Fprintln(stderr, err)
+		cfs.Usage()
+		return 2
+	default:
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+}
+
+// lookup returns the command named by the first words of args, and the rest of args.
+func lookup(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) < len(words) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+// parse parses the flags of a command, which are reported by the flag package if invalid.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errParse
+	}
+	return nil
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprint(w, "Usage: bookswap [flags] <command> [args]\n\nCommands:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprint(w, "\nFlags:\n")
+	fs.PrintDefaults()
+	fmt.Fprint(w, "\nRun 'bookswap <command> -h' for the flags of a command.\n")
+}
+
+// envURL returns the URL of the service configured by the environment, which is also used by the tests.
+func envURL() string {
+	baseURL, ok := os.LookupEnv("BOOKSWAP_BASE_URL")
+	if !ok {
+		return defaultURL
+	}
+	port, ok := os.LookupEnv("BOOKSWAP_PORT")
+	if !ok {
+		return baseURL
+	}
+	return fmt.Sprintf("%s:%s", baseURL, port)
+}
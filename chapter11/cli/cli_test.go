@@ -0,0 +1,166 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/cli"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+var (
+	createdAt = time.Date(2023, 4, 1, 12, 30, 0, 0, time.UTC)
+	swappedAt = createdAt.Add(time.Hour)
+	ann       = db.User{ID: "u1", Name: "Ann", Email: "ann@example.com", Address: "1 London Road", PostCode: "N1",
+		Country: "United Kingdom", CreatedAt: createdAt, UpdatedAt: createdAt}
+	bob = db.User{ID: "u2", Name: "Bob", Address: "2 Rue de Rivoli", PostCode: "75001", Country: "France",
+		CreatedAt: createdAt, UpdatedAt: createdAt}
+	events = []db.ItemEvent{
+		{ID: 1, ItemID: "b1", ItemType: db.BookItem, Type: db.ItemCreated, ActorID: "u1", OwnerID: "u1",
+			CreatedAt: createdAt},
+		{ID: 2, ItemID: "b1", ItemType: db.BookItem, Type: db.ItemSwapped, ActorID: "u2", OwnerID: "u2",
+			PreviousOwnerID: "u1", CreatedAt: swappedAt},
+	}
+)
+
+// fakeAPI serves canned responses for the requests made by the CLI.
+func fakeAPI(t *testing.T) *httptest.Server {
+	var created int
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /users":
+			writeJSON(t, w, http.StatusOK, handlers.Response[db.User]{Items: []db.User{ann, bob}})
+		case "POST /users":
+			var u db.User
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&u))
+			u.ID = "u3"
+			u.CreatedAt, u.UpdatedAt = createdAt, createdAt
+			writeJSON(t, w, http.StatusOK, handlers.Response[db.Book]{User: &u})
+		case "POST /books":
+			var b db.Book
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&b))
+			if b.OwnerID != ann.ID {
+				writeJSON(t, w, http.StatusBadRequest, handlers.Response[db.Book]{Error: "no user found for id " + b.OwnerID})
+				return
+			}
+			created++
+			b.ID = "b" + string(rune('0'+created))
+			b.CreatedAt, b.UpdatedAt = createdAt, createdAt
+			writeJSON(t, w, http.StatusOK, handlers.Response[db.Book]{Items: []db.Book{b}})
+		case "POST /books/b1":
+			if r.URL.Query().Get("user") != bob.ID {
+				writeJSON(t, w, http.StatusForbidden, handlers.Response[db.Book]{Error: "user is not allowed to swap this item"})
+				return
+			}
+			writeJSON(t, w, http.StatusOK, handlers.Response[db.Book]{User: &bob, Items: []db.Book{
+				{ID: "b1", Name: "Dune", Author: "Frank Herbert", OwnerID: bob.ID, Status: db.InTransit,
+					CreatedAt: createdAt, UpdatedAt: swappedAt},
+			}})
+		case "POST /magazines/m1":
+			writeJSON(t, w, http.StatusOK, handlers.Response[db.Magazine]{User: &bob, Items: []db.Magazine{
+				{ID: "m1", Name: "Wired", IssueNumber: 7, OwnerID: bob.ID, Status: db.InTransit,
+					CreatedAt: createdAt, UpdatedAt: swappedAt},
+			}})
+		case "GET /books/b1/history", "GET /users/u2/history":
+			writeJSON(t, w, http.StatusOK, handlers.Response[db.ItemEvent]{Items: events})
+		default:
+			writeJSON(t, w, http.StatusNotFound, handlers.Response[db.Book]{Error: "not found"})
+		}
+	}))
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv("BOOKSWAP_DB_URL", "")
+	tests := map[string]struct {
+		args []string
+		code int
+	}{
+		"help":                 {args: []string{"help"}},
+		"no_command":           {code: 2},
+		"unknown_command":      {args: []string{"users", "delete"}, code: 2},
+		"unknown_output":       {args: []string{"-output", "xml", "users", "list"}, code: 2},
+		"users_list":           {args: []string{"users", "list"}},
+		"users_list_json":      {args: []string{"-output", "json", "users", "list"}},
+		"users_create":         {args: []string{"users", "create", "-name", "Cat", "-post-code", "E1", "-country", "United Kingdom"}},
+		"users_create_no_name": {args: []string{"users", "create", "-country", "France"}, code: 2},
+		"books_import":         {args: []string{"books", "import", "-owner", "u1", "testdata/books.csv"}},
+		"books_import_json":    {args: []string{"-output", "json", "books", "import", "-owner", "u1", "testdata/books.csv"}},
+		"books_import_invalid": {args: []string{"books", "import", "testdata/books.csv"}, code: 1},
+		"books_import_failed":  {args: []string{"books", "import", "-owner", "u2", "testdata/books_owned.csv"}, code: 1},
+		"swap_book":            {args: []string{"swap", "-user", "u2", "book", "b1"}},
+		"swap_magazine_json":   {args: []string{"-output", "json", "swap", "-user", "u2", "magazine", "m1"}},
+		"swap_forbidden":       {args: []string{"swap", "-user", "u1", "book", "b1"}, code: 1},
+		"swap_not_found":       {args: []string{"swap", "-user", "u2", "book", "unknown"}, code: 1},
+		"swap_no_user":         {args: []string{"swap", "book", "b1"}, code: 2},
+		"history_book":         {args: []string{"history", "book", "b1"}},
+		"history_user_json":    {args: []string{"-output", "json", "history", "user", "u2"}},
+		"history_unknown_type": {args: []string{"history", "shelf", "s1"}, code: 2},
+		"migrate_no_db":        {args: []string{"migrate", "up"}, code: 2},
+		"migrate_unknown":      {args: []string{"migrate", "-db", "postgres://localhost/books", "sideways"}, code: 2},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			svr := fakeAPI(t)
+			var stdout, stderr bytes.Buffer
+
+			// Act
+			code := cli.Run(context.Background(), append([]string{"-url", svr.URL}, tc.args...), &stdout, &stderr)
+
+			// Assert
+			assert.Equal(t, tc.code, code, stderr.String())
+			golden(t, name+".golden", stdout.Bytes())
+			golden(t, name+".stderr.golden", stderr.Bytes())
+		})
+	}
+}
+
+// golden compares got with the contents of a golden file, which is rewritten when the tests
+// are run with -update. Golden files of empty outputs are left out.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if len(got) == 0 {
+			require.Nil(t, removeIfExists(path))
+			return
+		}
+		require.Nil(t, os.WriteFile(path, got, 0644))
+	}
+	want, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		assert.Empty(t, string(got), "no golden file %s", path)
+		return
+	}
+	require.Nil(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, status int, v any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	require.Nil(t, json.NewEncoder(w).Encode(v))
+}
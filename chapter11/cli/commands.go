@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+func createUser(ctx context.Context, a *app, fs *flag.FlagSet, args []string) error {
+	var u db.User
+	fs.StringVar(&u.Name, "name", "", "`name` of the user")
+	fs.StringVar(&u.Email, "email", "", "email `address` of the user")
+	fs.StringVar(&u.Address, "address", "", "postal `address` of the user")
+	fs.StringVar(&u.PostCode, "post-code", "", "post `code` of the user")
+	fs.StringVar(&u.Country, "country", "", "`country` of the user")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected arguments %q", fs.Args())
+	}
+	if u.Name == "" {
+		return usagef("no name given")
+	}
+
+	u, err := a.client.CreateUser(ctx, u)
+	if err != nil {
+		return err
+	}
+	return printItem(a.out, u, userColumns)
+}
+
+func listUsers(ctx context.Context, a *app, fs *flag.FlagSet, args []string) error {
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected arguments %q", fs.Args())
+	}
+
+	users, err := a.client.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	return printList(a.out, users, userColumns)
+}
+
+// importBooks creates the books of a CSV file with a header row naming its name, author and
+// owner_id columns. The owner column may be left out if a default owner is given.
+// Every row is checked before any book is created.
+func importBooks(ctx context.Context, a *app, fs *flag.FlagSet, args []string) error {
+	owner := fs.String("owner", "", "`ID` of the owner of the books without an owner_id")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected a single file, or - for stdin")
+	}
+	var r io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("bookswap: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	books, err := readBooks(r, *owner)
+	if err != nil {
+		return err
+	}
+
+	imported := make([]db.Book, 0, len(books))
+	for i, b := range books {
+		b, err := a.client.UpsertBook(ctx, b)
+		if err != nil {
+			// Show what was imported, so that the file can be fixed up and the rest imported.
+			if perr := printList(a.out, imported, bookColumns); perr != nil {
+				return perr
+			}
+			return fmt.Errorf("%w (row %d, %d of %d books imported)", err, i+2, len(imported), len(books))
+		}
+		imported = append(imported, b)
+	}
+	return printList(a.out, imported, bookColumns)
+}
+
+// readBooks reads and validates the books of a CSV file.
+func readBooks(r io.Reader, owner string) ([]db.Book, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("bookswap: no header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bookswap: %v", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		switch name {
+		case "name", "author", "owner_id":
+			cols[name] = i
+		default:
+			return nil, fmt.Errorf("bookswap: unknown column %q", name)
+		}
+	}
+	if _, ok := cols["name"]; !ok {
+		return nil, fmt.Errorf("bookswap: no name column")
+	}
+	cell := func(record []string, name string) string {
+		if i, ok := cols[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	var books []db.Book
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return books, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bookswap: %v", err)
+		}
+		row, _ := cr.FieldPos(0)
+		b := db.Book{
+			Name:    cell(record, "name"),
+			Author:  cell(record, "author"),
+			OwnerID: cell(record, "owner_id"),
+		}
+		if b.OwnerID == "" {
+			b.OwnerID = owner
+		}
+		if b.Name == "" {
+			return nil, fmt.Errorf("bookswap: row %d: no name", row)
+		}
+		if b.OwnerID == "" {
+			return nil, fmt.Errorf("bookswap: row %d: no owner_id and no -owner given", row)
+		}
+		books = append(books, b)
+	}
+}
+
+func swap(ctx context.Context, a *app, fs *flag.FlagSet, args []string) error {
+	user := fs.String("user", "", "`ID` of the user the item is swapped to")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *user == "" {
+		return usagef("no user given")
+	}
+	if fs.NArg() != 2 {
+		return usagef("expected an item type and ID")
+	}
+
+	switch id := fs.Arg(1); fs.Arg(0) {
+	case "book":
+		books, err := a.client.SwapBook(ctx, id, *user)
+		if err != nil {
+			return err
+		}
+		return printList(a.out, books, bookColumns)
+	case "magazine":
+		mags, err := a.client.SwapMagazine(ctx, id, *user)
+		if err != nil {
+			return err
+		}
+		return printList(a.out, mags, magazineColumns)
+	default:
+		return usagef("unknown item type %q", fs.Arg(0))
+	}
+}
+
+func history(ctx context.Context, a *app, fs *flag.FlagSet, args []string) error {
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usagef("expected book, magazine or user and an ID")
+	}
+
+	var events []db.ItemEvent
+	var err error
+	switch id := fs.Arg(1); fs.Arg(0) {
+	case "book":
+		events, err = a.client.BookHistory(ctx, id)
+	case "magazine":
+		events, err = a.client.MagazineHistory(ctx, id)
+	case "user":
+		events, err = a.client.UserHistory(ctx, id)
+	default:
+		return usagef("unknown history type %q", fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	return printList(a.out, events, eventColumns)
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// defaultMigrations is the location of the migrations, relative to the root of the repository.
+const defaultMigrations = "file://chapter11/db/migrations"
+
+// migrationStatus is the version of the database schema.
+type migrationStatus struct {
+	Version uint `json:"version"`
+	Dirty   bool `json:"dirty"`
+}
+
+var migrationColumns = columns[migrationStatus]{
+	header: []string{"VERSION", "DIRTY"},
+	row: func(s migrationStatus) []string {
+		return []string{strconv.FormatUint(uint64(s.Version), 10), strconv.FormatBool(s.Dirty)}
+	},
+}
+
+// migrateDB migrates the database directly, rather than through the API,
+// so that it can be run before the service is started.
+// Migrating down only rolls back the latest migration.
+func migrateDB(ctx context.Context, a *app, fs *flag.FlagSet, args []string) error {
+	dbURL := fs.String("db", "", "`URL` of the Postgres database (default $BOOKSWAP_DB_URL)")
+	source := fs.String("source", defaultMigrations, "`URL` of the migrations")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected up, down or version")
+	}
+	direction := fs.Arg(0)
+	if direction != "up" && direction != "down" && direction != "version" {
+		return usagef("unknown migration %q", direction)
+	}
+	if *dbURL == "" {
+		*dbURL = os.Getenv("BOOKSWAP_DB_URL")
+	}
+	if *dbURL == "" {
+		return usagef("no database given")
+	}
+
+	m, err := migrate.New(*source, *dbURL)
+	if err != nil {
+		return fmt.Errorf("bookswap: migrate:%v", err)
+	}
+	defer m.Close()
+	switch direction {
+	case "up":
+		err = m.Up()
+	case "down":
+		err = m.Steps(-1)
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("bookswap: migration %s:%v", direction, err)
+	}
+
+	var s migrationStatus
+	s.Version, s.Dirty, err = m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("bookswap: migration version:%v", err)
+	}
+	return printItem(a.out, s, migrationColumns)
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// printer writes the results of commands as aligned tables or as indented JSON.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// columns describes how values of type T are printed as table rows.
+type columns[T any] struct {
+	header []string
+	row    func(T) []string
+}
+
+var userColumns = columns[db.User]{
+	header: []string{"ID", "NAME", "EMAIL", "ADDRESS", "POST CODE", "COUNTRY"},
+	row: func(u db.User) []string {
+		return []string{u.ID, u.Name, u.Email, u.Address, u.PostCode, u.Country}
+	},
+}
+
+var bookColumns = columns[db.Book]{
+	header: []string{"ID", "NAME", "AUTHOR", "OWNER", "STATUS"},
+	row: func(b db.Book) []string {
+		return []string{b.ID, b.Name, b.Author, b.OwnerID, b.Status.String()}
+	},
+}
+
+var magazineColumns = columns[db.Magazine]{
+	header: []string{"ID", "NAME", "ISSUE", "OWNER", "STATUS"},
+	row: func(m db.Magazine) []string {
+		return []string{m.ID, m.Name, strconv.Itoa(m.IssueNumber), m.OwnerID, m.Status.String()}
+	},
+}
+
+var eventColumns = columns[db.ItemEvent]{
+	header: []string{"ID", "TIME", "TYPE", "ITEM TYPE", "ITEM", "ACTOR", "OWNER", "PREVIOUS OWNER"},
+	row: func(e db.ItemEvent) []string {
+		return []string{strconv.FormatInt(e.ID, 10), e.CreatedAt.UTC().Format(time.RFC3339), string(e.Type),
+			string(e.ItemType), e.ItemID, e.ActorID, e.OwnerID, e.PreviousOwnerID}
+	},
+}
+
+// printList prints items as a JSON array or as a table with a row per item,
+// in which empty values are shown as a dash.
+func printList[T any](p printer, items []T, c columns[T]) error {
+	if p.json {
+		if items == nil {
+			items = []T{}
+		}
+		return p.encode(items)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(c.header, "\t"))
+	for _, item := range items {
+		row := c.row(item)
+		for i := range row {
+			if row[i] == "" {
+				row[i] = "-"
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// printItem prints a single item as a JSON object or as a table with a single row.
+func printItem[T any](p printer, item T, c columns[T]) error {
+	if p.json {
+		return p.encode(item)
+	}
+	return printList(p, []T{item}, c)
+}
+
+func (p printer) encode(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
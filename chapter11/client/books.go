@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/handlers"
+)
+
+// Index returns the welcome message of the API and the available books.
+func (c *Client) Index(ctx context.Context) (string, []db.Book, error) {
+	var resp handlers.Response[db.Book]
+	if err := c.Do(ctx, http.MethodGet, "/", nil, &resp); err != nil {
+		return "", nil, err
+	}
+	return resp.Message, resp.Items, nil
+}
+
+// ListBooks returns the available books.
+func (c *Client) ListBooks(ctx context.Context) ([]db.Book, error) {
+	return list[db.Book](ctx, c, http.MethodGet, "/books", nil)
+}
+
+// GetBook returns a given book.
+func (c *Client) GetBook(ctx context.Context, id string) (db.Book, error) {
+	return one[db.Book](ctx, c, http.MethodGet, pathf("/books/%s", id), nil)
+}
+
+// GetBookAt returns a given book as it was at a point in time.
+func (c *Client) GetBookAt(ctx context.Context, id string, at time.Time) (db.Book, error) {
+	path := pathf("/books/%s", id) + "?at=" + url.QueryEscape(at.Format(time.RFC3339))
+	return one[db.Book](ctx, c, http.MethodGet, path, nil)
+}
+
+// UpsertBook creates a book, or updates it if it has an ID.
+func (c *Client) UpsertBook(ctx context.Context, b db.Book) (db.Book, error) {
+	return one[db.Book](ctx, c, http.MethodPost, "/books", b)
+}
+
+// SwapBook swaps a book to a user and returns the user's books.
+func (c *Client) SwapBook(ctx context.Context, bookID, userID string) ([]db.Book, error) {
+	return c.bookTransition(ctx, "/books/%s", bookID, userID)
+}
+
+// ConfirmBookDelivery confirms that a user received a book and returns the user's books.
+func (c *Client) ConfirmBookDelivery(ctx context.Context, bookID, userID string) ([]db.Book, error) {
+	return c.bookTransition(ctx, "/books/%s/delivery", bookID, userID)
+}
+
+// RelistBook puts a user's book back on the catalogue and returns the user's books.
+func (c *Client) RelistBook(ctx context.Context, bookID, userID string) ([]db.Book, error) {
+	return c.bookTransition(ctx, "/books/%s/relist", bookID, userID)
+}
+
+// WithdrawBook takes a user's book off the catalogue and returns the user's books.
+func (c *Client) WithdrawBook(ctx context.Context, bookID, userID string) ([]db.Book, error) {
+	return c.bookTransition(ctx, "/books/%s/withdraw", bookID, userID)
+}
+
+func (c *Client) bookTransition(ctx context.Context, format, bookID, userID string) ([]db.Book, error) {
+	resp, err := owned[db.Book](ctx, c, http.MethodPost, pathf(format, bookID)+"?user="+url.QueryEscape(userID))
+	return resp.Items, err
+}
+
+// QuoteBook returns the cost and delivery time of posting a book to a user.
+func (c *Client) QuoteBook(ctx context.Context, bookID, userID string) (db.ShippingQuote, error) {
+	path := pathf("/books/%s/quote", bookID) + "?user=" + url.QueryEscape(userID)
+	return one[db.ShippingQuote](ctx, c, http.MethodGet, path, nil)
+}
+
+// ReviewBook reviews the other side of the latest delivered swap of a book the reviewer took part in.
+func (c *Client) ReviewBook(ctx context.Context, bookID, reviewerID string, r db.Review) (db.Review, error) {
+	path := pathf("/books/%s/reviews", bookID) + "?user=" + url.QueryEscape(reviewerID)
+	return one[db.Review](ctx, c, http.MethodPost, path, r)
+}
+
+// UploadBookImage uploads a JPEG, PNG or GIF image of a user's book and returns the book with its new image.
+func (c *Client) UploadBookImage(ctx context.Context, bookID, userID string, image []byte) (db.Book, error) {
+	path := pathf("/books/%s/images", bookID) + "?user=" + url.QueryEscape(userID)
+	return upload[db.Book](ctx, c, path, image)
+}
+
+// BookHistory returns the history of a given book, oldest event first.
+func (c *Client) BookHistory(ctx context.Context, id string) ([]db.ItemEvent, error) {
+	return list[db.ItemEvent](ctx, c, http.MethodGet, pathf("/books/%s/history", id), nil)
+}
@@ -0,0 +1,246 @@
+// Package client provides a typed Go client for the BookSwap REST API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/handlers"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBackoff     = 100 * time.Millisecond
+	// maxResponseSize limits how much of a response body is read.
+	maxResponseSize = 10 << 20
+)
+
+// Client calls the BookSwap API of a given server.
+// It is safe for concurrent use.
+type Client struct {
+	baseURL     *url.URL
+	httpClient  *http.Client
+	token       string
+	community   string
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewClient initialises a Client for the server at baseURL, such as http://localhost:3000.
+// The default HTTP client is used if httpClient is nil.
+func NewClient(baseURL string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("bookswap: invalid base url %q", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:     u,
+		httpClient:  httpClient,
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultBackoff,
+	}, nil
+}
+
+// WithToken configures a bearer token which is sent with every request.
+func (c *Client) WithToken(token string) *Client {
+	c.token = token
+	return c
+}
+
+// WithCommunity configures the slug of the community which every request is made to,
+// for servers which are not reached through the host of the community.
+func (c *Client) WithCommunity(slug string) *Client {
+	c.community = slug
+	return c
+}
+
+// WithRetries configures how many times a request is attempted and the delay before the first retry.
+// Only requests which are safe to repeat are retried, after network errors or when the server is unavailable.
+func (c *Client) WithRetries(maxAttempts int, backoff time.Duration) *Client {
+	c.maxAttempts = maxAttempts
+	c.backoff = backoff
+	return c
+}
+
+// Do sends a request with a JSON body, if body is not nil, to the given path of the API.
+// The JSON response is decoded into out, if out is not nil.
+// Responses with an error status are returned as an *APIError.
+func (c *Client) Do(ctx context.Context, method, path string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("bookswap: encoding request:%v", err)
+		}
+	}
+
+	attempts := 1
+	if retryable(method) && c.maxAttempts > 1 {
+		attempts = c.maxAttempts
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.backoff << (attempt - 1)):
+			}
+		}
+		var retry bool
+		retry, err = c.do(ctx, method, path, "application/json", payload, out)
+		if !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// do makes a single attempt at a request and returns whether it may be retried.
+// The content type is only sent with a payload.
+func (c *Client) do(ctx context.Context, method, path, contentType string, payload []byte, out any) (bool, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, body)
+	if err != nil {
+		return false, fmt.Errorf("bookswap: creating request:%v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.community != "" {
+		req.Header.Set(handlers.CommunityHeader, c.community)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return true, fmt.Errorf("bookswap: %s %s:%w", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return true, fmt.Errorf("bookswap: reading response of %s %s:%w", method, path, err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(data)),
+		}
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			apiErr.Message = e.Error
+		}
+		return unavailable(resp.StatusCode), apiErr
+	}
+	if out == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("bookswap: decoding response of %s %s:%v", method, path, err)
+	}
+	return false, nil
+}
+
+// retryable returns whether requests with the given method can safely be sent more than once.
+func retryable(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// unavailable returns whether a status means that the server may succeed later.
+func unavailable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// list sends a request and returns the items of its response.
+func list[T handlers.ResponseItemType](ctx context.Context, c *Client, method, path string, body any) ([]T, error) {
+	var resp handlers.Response[T]
+	if err := c.Do(ctx, method, path, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Items, nil
+}
+
+// one sends a request and returns the single item of its response.
+func one[T handlers.ResponseItemType](ctx context.Context, c *Client, method, path string, body any) (T, error) {
+	items, err := list[T](ctx, c, method, path, body)
+	if err != nil {
+		var empty T
+		return empty, err
+	}
+	if len(items) != 1 {
+		var empty T
+		return empty, fmt.Errorf("bookswap: %s %s returned %d items, want 1", method, path, len(items))
+	}
+	return items[0], nil
+}
+
+// upload posts a file, such as an image, and returns the single item of its response.
+// The content type of the file is sniffed from its data. Uploads are never retried.
+func upload[T handlers.ResponseItemType](ctx context.Context, c *Client, path string, data []byte) (T, error) {
+	var resp handlers.Response[T]
+	var empty T
+	if _, err := c.do(ctx, http.MethodPost, path, http.DetectContentType(data), data, &resp); err != nil {
+		return empty, err
+	}
+	if len(resp.Items) != 1 {
+		return empty, fmt.Errorf("bookswap: POST %s returned %d items, want 1", path, len(resp.Items))
+	}
+	return resp.Items[0], nil
+}
+
+// owned sends a request and returns the user and items of its response.
+func owned[T handlers.ResponseItemType](ctx context.Context, c *Client, method, path string) (handlers.Response[T], error) {
+	var resp handlers.Response[T]
+	if err := c.Do(ctx, method, path, nil, &resp); err != nil {
+		return resp, err
+	}
+	if resp.User == nil {
+		return resp, errNoUser(method, path)
+	}
+	return resp, nil
+}
+
+func errNoUser(method, path string) error {
+	return fmt.Errorf("bookswap: %s %s returned no user", method, path)
+}
+
+// pathf formats a path, escaping each of its arguments.
+func pathf(format string, args ...string) string {
+	escaped := make([]any, 0, len(args))
+	for _, a := range args {
+		escaped = append(escaped, url.PathEscape(a))
+	}
+	return fmt.Sprintf(format, escaped...)
+}
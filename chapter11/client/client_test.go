@@ -0,0 +1,431 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/client"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newClient starts a server with the given handler and returns a client for it.
+func newClient(t *testing.T, h http.HandlerFunc) *client.Client {
+	t.Helper()
+	svr := httptest.NewServer(h)
+	t.Cleanup(svr.Close)
+	c, err := client.NewClient(svr.URL, svr.Client())
+	require.Nil(t, err)
+	return c.WithRetries(3, time.Millisecond)
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	require.Nil(t, json.NewEncoder(w).Encode(v))
+}
+
+func TestNewClient(t *testing.T) {
+	for _, baseURL := range []string{"", "localhost:3000", "ftp://localhost", "http://"} {
+		_, err := client.NewClient(baseURL, nil)
+		assert.NotNil(t, err, baseURL)
+	}
+	_, err := client.NewClient("http://localhost:3000/", nil)
+	assert.Nil(t, err)
+}
+
+func TestCreateUser(t *testing.T) {
+	// Arrange
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/users", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var u db.User
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&u))
+		assert.Empty(t, u.ID)
+		u.ID = "new-id"
+		writeJSON(t, w, http.StatusOK, handlers.Response[db.Book]{User: &u})
+	})
+
+	// Act
+	u, err := c.CreateUser(context.Background(), db.User{ID: "ignored", Name: "Ann", PostCode: "N1"})
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, db.User{ID: "new-id", Name: "Ann", PostCode: "N1"}, u)
+}
+
+func TestSwapBook(t *testing.T) {
+	// Arrange
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/books/a%2Fb", r.URL.EscapedPath())
+		assert.Equal(t, "u&1", r.URL.Query().Get("user"))
+		writeJSON(t, w, http.StatusOK, handlers.Response[db.Book]{
+			User:  &db.User{ID: "u&1"},
+			Items: []db.Book{{ID: "a/b", OwnerID: "u&1", Status: db.InTransit}},
+		})
+	})
+
+	// Act
+	books, err := c.SwapBook(context.Background(), "a/b", "u&1")
+
+	// Assert
+	require.Nil(t, err)
+	require.Equal(t, 1, len(books))
+	assert.Equal(t, db.InTransit, books[0].Status)
+}
+
+func TestQuoteBook(t *testing.T) {
+	// Arrange
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/books/b1/quote", r.URL.EscapedPath())
+		assert.Equal(t, "u1", r.URL.Query().Get("user"))
+		writeJSON(t, w, http.StatusOK, handlers.Response[db.ShippingQuote]{
+			Items: []db.ShippingQuote{{ItemType: db.BookItem, ItemID: "b1", UserID: "u1", Cost: 950, Currency: "GBP"}},
+		})
+	})
+
+	// Act
+	quote, err := c.QuoteBook(context.Background(), "b1", "u1")
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, 950, quote.Cost)
+	assert.Equal(t, "GBP", quote.Currency)
+}
+
+func TestSuspendUser(t *testing.T) {
+	// Arrange
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/admin/users/u1/suspend", r.URL.EscapedPath())
+		assert.Equal(t, "a&1", r.URL.Query().Get("user"))
+		var note db.ModerationNote
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&note))
+		assert.Equal(t, "Spam", note.Reason)
+		writeJSON(t, w, http.StatusOK, handlers.Response[db.User]{Items: []db.User{{ID: "u1", Suspended: true}}})
+	})
+
+	// Act
+	u, err := c.SuspendUser(context.Background(), "u1", "a&1", "Spam")
+
+	// Assert
+	require.Nil(t, err)
+	assert.True(t, u.Suspended)
+}
+
+func TestSwapHold(t *testing.T) {
+	// Arrange
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/holds/h%2F1/swap", r.URL.EscapedPath())
+		assert.Equal(t, "u1", r.URL.Query().Get("user"))
+		writeJSON(t, w, http.StatusOK, handlers.Response[db.Hold]{
+			Items: []db.Hold{{ID: "h/1", ItemType: db.BookItem, ItemID: "b1", UserID: "u1", Status: db.HoldConverted}},
+		})
+	})
+
+	// Act
+	h, err := c.SwapHold(context.Background(), "h/1", "u1")
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, db.HoldConverted, h.Status)
+}
+
+func TestUploadBookImage(t *testing.T) {
+	// Arrange
+	// A GIF header is enough for the content type to be sniffed.
+	gif := []byte("GIF89a\x01\x00\x01\x00")
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/books/b1/images", r.URL.EscapedPath())
+		assert.Equal(t, "u1", r.URL.Query().Get("user"))
+		assert.Equal(t, "image/gif", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+		assert.Equal(t, gif, body)
+		writeJSON(t, w, http.StatusUnsupportedMediaType, handlers.Response[db.Book]{Error: "unsupported image"})
+	})
+
+	// Act
+	_, err := c.UploadBookImage(context.Background(), "b1", "u1", gif)
+
+	// Assert
+	var apiErr *client.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusUnsupportedMediaType, apiErr.StatusCode)
+	assert.Equal(t, "unsupported image", apiErr.Message)
+}
+
+func TestGetUser(t *testing.T) {
+	// Arrange
+	user := &db.User{ID: "u1", Name: "Ann"}
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/u1/books":
+			writeJSON(t, w, http.StatusOK, handlers.Response[db.Book]{User: user, Items: []db.Book{{ID: "b1"}}})
+		case "/users/u1/magazines":
+			writeJSON(t, w, http.StatusOK, handlers.Response[db.Magazine]{User: user, Items: []db.Magazine{{ID: "m1"}}})
+		default:
+			writeJSON(t, w, http.StatusNotFound, handlers.Response[db.Book]{Error: "no user found"})
+		}
+	})
+
+	// Act
+	p, err := c.GetUser(context.Background(), "u1")
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, *user, p.User)
+	assert.Equal(t, []db.Book{{ID: "b1"}}, p.Books)
+	assert.Equal(t, []db.Magazine{{ID: "m1"}}, p.Magazines)
+
+	_, err = c.GetUser(context.Background(), "unknown")
+	assert.ErrorIs(t, err, client.ErrNotFound)
+}
+
+func TestErrors(t *testing.T) {
+	tests := map[string]struct {
+		status int
+		body   string
+		want   error
+	}{
+		"bad request":   {status: http.StatusBadRequest, body: `{"error":"invalid input"}`, want: client.ErrBadRequest},
+		"unprocessable": {status: http.StatusUnprocessableEntity, body: `{"error":"invalid body"}`, want: client.ErrBadRequest},
+		"unauthorized":  {status: http.StatusUnauthorized, body: "missing token", want: client.ErrUnauthorized},
+		"payment required": {status: http.StatusPaymentRequired, body: `{"error":"insufficient credits"}`,
+			want: client.ErrInsufficientCredits},
+		"forbidden": {status: http.StatusForbidden, body: `{"error":"user is not the owner"}`, want: client.ErrForbidden},
+		"not found": {status: http.StatusNotFound, body: `{"error":"no book found"}`, want: client.ErrNotFound},
+		"conflict":  {status: http.StatusConflict, body: `{"error":"invalid status transition"}`, want: client.ErrConflict},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			})
+
+			// Act
+			_, err := c.ConfirmBookDelivery(context.Background(), "b1", "u1")
+
+			// Assert
+			assert.ErrorIs(t, err, tc.want)
+			var apiErr *client.APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tc.status, apiErr.StatusCode)
+			assert.NotContains(t, apiErr.Message, `"error"`)
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	t.Run("safe requests are retried", func(t *testing.T) {
+		// Arrange
+		var calls int32
+		c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeJSON(t, w, http.StatusOK, handlers.Response[db.Book]{Items: []db.Book{{ID: "b1"}}})
+		})
+
+		// Act
+		books, err := c.ListBooks(context.Background())
+
+		// Assert
+		require.Nil(t, err)
+		assert.Equal(t, 1, len(books))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("attempts run out", func(t *testing.T) {
+		// Arrange
+		var calls int32
+		c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadGateway)
+		})
+
+		// Act
+		_, err := c.GetBook(context.Background(), "b1")
+
+		// Assert
+		assert.ErrorIs(t, err, client.ErrUnavailable)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("unsafe requests are not retried", func(t *testing.T) {
+		// Arrange
+		var calls int32
+		c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+
+		// Act
+		_, err := c.SwapBook(context.Background(), "b1", "u1")
+
+		// Assert
+		assert.ErrorIs(t, err, client.ErrUnavailable)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		// Arrange
+		var calls int32
+		c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		// Act
+		_, err := c.GetBook(context.Background(), "b1")
+
+		// Assert
+		assert.ErrorIs(t, err, client.ErrNotFound)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		// Arrange
+		ctx, cancel := context.WithCancel(context.Background())
+		c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			cancel()
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		c.WithRetries(3, time.Hour)
+
+		// Act
+		_, err := c.ListMagazines(ctx)
+
+		// Assert
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestWithToken(t *testing.T) {
+	// Arrange
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		assert.Equal(t, "/webhooks/w1?user=admin", r.URL.RequestURI())
+		writeJSON(t, w, http.StatusOK, handlers.Response[db.WebhookSubscription]{Message: "Webhook deleted."})
+	}).WithToken("secret")
+
+	// Act
+	err := c.DeleteWebhook(context.Background(), "w1", "admin")
+
+	// Assert
+	assert.Nil(t, err)
+}
+
+func TestWithCommunity(t *testing.T) {
+	// Arrange
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "office", r.Header.Get(handlers.CommunityHeader))
+		writeJSON(t, w, http.StatusOK, handlers.Response[db.Book]{Items: []db.Book{{ID: "b1"}}})
+	}).WithCommunity("office")
+
+	// Act
+	books, err := c.ListBooks(context.Background())
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, []db.Book{{ID: "b1"}}, books)
+}
+
+func TestDo(t *testing.T) {
+	// Arrange
+	c := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+		assert.JSONEq(t, `{"name":"raw"}`, string(body))
+		writeJSON(t, w, http.StatusOK, map[string]string{"message": "ok"})
+	})
+
+	// Act
+	var resp struct {
+		Message string `json:"message"`
+	}
+	err := c.Do(context.Background(), "PUT", "/custom", map[string]string{"name": "raw"}, &resp)
+
+	// Assert
+	require.Nil(t, err)
+	assert.Equal(t, "ok", resp.Message)
+}
+
+func TestClientIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestClientIntegration in short mode.")
+	}
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	// Arrange
+	ps := db.NewPostingService()
+	bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	ha := handlers.NewHandler(bs, us, ms, db.NewHistoryService(testDB, nil, nil), nil, nil, nil, nil, nil,
+		db.NewCreditService(testDB, nil, nil, nil), nil, nil, nil, nil, nil, nil)
+	svr := httptest.NewServer(handlers.ConfigureServer(ha))
+	defer svr.Close()
+	c, err := client.NewClient(svr.URL, svr.Client())
+	require.Nil(t, err)
+	ctx := context.Background()
+
+	// Act & Assert
+	owner, err := c.CreateUser(ctx, db.User{Name: "Owner"})
+	require.Nil(t, err)
+	swapper, err := c.CreateUser(ctx, db.User{Name: "Swapper"})
+	require.Nil(t, err)
+	book, err := c.UpsertBook(ctx, db.Book{Name: "Client book", OwnerID: owner.ID})
+	require.Nil(t, err)
+	assert.Equal(t, db.Available, book.Status)
+
+	books, err := c.SwapBook(ctx, book.ID, swapper.ID)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(books))
+	assert.Equal(t, db.InTransit, books[0].Status)
+	_, err = c.ConfirmBookDelivery(ctx, book.ID, owner.ID)
+	assert.ErrorIs(t, err, client.ErrForbidden)
+	_, err = c.ConfirmBookDelivery(ctx, book.ID, swapper.ID)
+	require.Nil(t, err)
+	_, err = c.ConfirmBookDelivery(ctx, book.ID, swapper.ID)
+	assert.ErrorIs(t, err, client.ErrConflict)
+
+	profile, err := c.GetUser(ctx, swapper.ID)
+	require.Nil(t, err)
+	assert.Equal(t, swapper, profile.User)
+	require.Equal(t, 1, len(profile.Books))
+	assert.Equal(t, db.Swapped, profile.Books[0].Status)
+	history, err := c.BookHistory(ctx, book.ID)
+	require.Nil(t, err)
+	assert.Equal(t, db.ItemDelivered, history[len(history)-1].Type)
+	_, err = c.GetBook(ctx, "unknown")
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	credits, err := c.Credits(ctx, swapper.ID)
+	require.Nil(t, err)
+	assert.Equal(t, db.StartingCredits-db.SwapCredits, credits.Balance)
+	other, err := c.UpsertBook(ctx, db.Book{Name: "Unaffordable book", OwnerID: owner.ID})
+	require.Nil(t, err)
+	_, err = c.SwapBook(ctx, other.ID, swapper.ID)
+	assert.ErrorIs(t, err, client.ErrInsufficientCredits)
+}
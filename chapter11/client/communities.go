@@ -0,0 +1,19 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// ListCommunities returns the communities of the server, ordered by name.
+func (c *Client) ListCommunities(ctx context.Context) ([]db.Community, error) {
+	return list[db.Community](ctx, c, http.MethodGet, "/communities", nil)
+}
+
+// UpsertCommunity creates a community, or updates it if it has an ID, on behalf of an admin.
+func (c *Client) UpsertCommunity(ctx context.Context, adminID string, community db.Community) (db.Community, error) {
+	return one[db.Community](ctx, c, http.MethodPost, "/communities?user="+url.QueryEscape(adminID), community)
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrBadRequest is matched by errors for requests which the API rejected as invalid.
+	ErrBadRequest = errors.New("bad request")
+	// ErrUnauthorized is matched by errors for requests without valid credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is matched by errors for operations the user is not allowed to perform.
+	ErrForbidden = errors.New("forbidden")
+	// ErrInsufficientCredits is matched by errors for operations the user cannot afford.
+	ErrInsufficientCredits = errors.New("insufficient credits")
+	// ErrNotFound is matched by errors for resources which do not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is matched by errors for operations which conflict with the status of an item.
+	ErrConflict = errors.New("conflict")
+	// ErrUnavailable is matched by errors for requests which may succeed if they are sent again later.
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// APIError is returned when the API responds with an error status.
+// It matches the error of its status class with errors.Is.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("bookswap: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Unwrap returns the error of the status class of e, if there is one.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrBadRequest
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusPaymentRequired:
+		return ErrInsufficientCredits
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	}
+	if unavailable(e.StatusCode) {
+		return ErrUnavailable
+	}
+	return nil
+}
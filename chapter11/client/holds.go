@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// HoldBook reserves a book for a user while they decide whether to swap it.
+func (c *Client) HoldBook(ctx context.Context, bookID, userID string) (db.Hold, error) {
+	path := pathf("/books/%s/hold", bookID) + "?user=" + url.QueryEscape(userID)
+	return one[db.Hold](ctx, c, http.MethodPost, path, nil)
+}
+
+// HoldMagazine reserves a magazine for a user while they decide whether to swap it.
+func (c *Client) HoldMagazine(ctx context.Context, magID, userID string) (db.Hold, error) {
+	path := pathf("/magazines/%s/hold", magID) + "?user=" + url.QueryEscape(userID)
+	return one[db.Hold](ctx, c, http.MethodPost, path, nil)
+}
+
+// ListHolds returns the active holds of a user, soonest to expire first.
+func (c *Client) ListHolds(ctx context.Context, userID string) ([]db.Hold, error) {
+	return list[db.Hold](ctx, c, http.MethodGet, pathf("/users/%s/holds", userID), nil)
+}
+
+// ReleaseHold ends a user's hold and puts the item back on the catalogue.
+func (c *Client) ReleaseHold(ctx context.Context, holdID, userID string) (db.Hold, error) {
+	path := pathf("/holds/%s/release", holdID) + "?user=" + url.QueryEscape(userID)
+	return one[db.Hold](ctx, c, http.MethodPost, path, nil)
+}
+
+// SwapHold swaps a held item to the user who holds it.
+func (c *Client) SwapHold(ctx context.Context, holdID, userID string) (db.Hold, error) {
+	path := pathf("/holds/%s/swap", holdID) + "?user=" + url.QueryEscape(userID)
+	return one[db.Hold](ctx, c, http.MethodPost, path, nil)
+}
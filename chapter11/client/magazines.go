@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// ListMagazines returns the available magazines.
+func (c *Client) ListMagazines(ctx context.Context) ([]db.Magazine, error) {
+	return list[db.Magazine](ctx, c, http.MethodGet, "/magazines", nil)
+}
+
+// GetMagazine returns a given magazine.
+func (c *Client) GetMagazine(ctx context.Context, id string) (db.Magazine, error) {
+	return one[db.Magazine](ctx, c, http.MethodGet, pathf("/magazines/%s", id), nil)
+}
+
+// GetMagazineAt returns a given magazine as it was at a point in time.
+func (c *Client) GetMagazineAt(ctx context.Context, id string, at time.Time) (db.Magazine, error) {
+	path := pathf("/magazines/%s", id) + "?at=" + url.QueryEscape(at.Format(time.RFC3339))
+	return one[db.Magazine](ctx, c, http.MethodGet, path, nil)
+}
+
+// UpsertMagazine creates a magazine, or updates it if it has an ID.
+func (c *Client) UpsertMagazine(ctx context.Context, m db.Magazine) (db.Magazine, error) {
+	return one[db.Magazine](ctx, c, http.MethodPost, "/magazines", m)
+}
+
+// SwapMagazine swaps a magazine to a user and returns the user's magazines.
+func (c *Client) SwapMagazine(ctx context.Context, magID, userID string) ([]db.Magazine, error) {
+	return c.magazineTransition(ctx, "/magazines/%s", magID, userID)
+}
+
+// ConfirmMagazineDelivery confirms that a user received a magazine and returns the user's magazines.
+func (c *Client) ConfirmMagazineDelivery(ctx context.Context, magID, userID string) ([]db.Magazine, error) {
+	return c.magazineTransition(ctx, "/magazines/%s/delivery", magID, userID)
+}
+
+// RelistMagazine puts a user's magazine back on the catalogue and returns the user's magazines.
+func (c *Client) RelistMagazine(ctx context.Context, magID, userID string) ([]db.Magazine, error) {
+	return c.magazineTransition(ctx, "/magazines/%s/relist", magID, userID)
+}
+
+// WithdrawMagazine takes a user's magazine off the catalogue and returns the user's magazines.
+func (c *Client) WithdrawMagazine(ctx context.Context, magID, userID string) ([]db.Magazine, error) {
+	return c.magazineTransition(ctx, "/magazines/%s/withdraw", magID, userID)
+}
+
+func (c *Client) magazineTransition(ctx context.Context, format, magID, userID string) ([]db.Magazine, error) {
+	resp, err := owned[db.Magazine](ctx, c, http.MethodPost, pathf(format, magID)+"?user="+url.QueryEscape(userID))
+	return resp.Items, err
+}
+
+// ReviewMagazine reviews the other side of the latest delivered swap of a magazine the reviewer took part in.
+func (c *Client) ReviewMagazine(ctx context.Context, magID, reviewerID string, r db.Review) (db.Review, error) {
+	path := pathf("/magazines/%s/reviews", magID) + "?user=" + url.QueryEscape(reviewerID)
+	return one[db.Review](ctx, c, http.MethodPost, path, r)
+}
+
+// UploadMagazineImage uploads a JPEG, PNG or GIF image of a user's magazine and returns the magazine with its new image.
+func (c *Client) UploadMagazineImage(ctx context.Context, magID, userID string, image []byte) (db.Magazine, error) {
+	path := pathf("/magazines/%s/images", magID) + "?user=" + url.QueryEscape(userID)
+	return upload[db.Magazine](ctx, c, path, image)
+}
+
+// MagazineHistory returns the history of a given magazine, oldest event first.
+func (c *Client) MagazineHistory(ctx context.Context, id string) ([]db.ItemEvent, error) {
+	return list[db.ItemEvent](ctx, c, http.MethodGet, pathf("/magazines/%s/history", id), nil)
+}
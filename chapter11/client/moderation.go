@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// Report reports an item or a user to the admins on behalf of a user.
+func (c *Client) Report(ctx context.Context, reporterID string, r db.Report) (db.Report, error) {
+	return one[db.Report](ctx, c, http.MethodPost, "/reports?user="+url.QueryEscape(reporterID), r)
+}
+
+// ListReports returns the reports with a given status, or all of them if it is empty, on behalf of an admin.
+func (c *Client) ListReports(ctx context.Context, adminID string, status db.ReportStatus) ([]db.Report, error) {
+	query := url.Values{"user": {adminID}}
+	if status != "" {
+		query.Set("status", string(status))
+	}
+	return list[db.Report](ctx, c, http.MethodGet, "/admin/reports?"+query.Encode(), nil)
+}
+
+// FlagBook hides a book from the catalogue until it is moderated, on behalf of an admin.
+func (c *Client) FlagBook(ctx context.Context, bookID, adminID, reason string) (db.Book, error) {
+	path := pathf("/admin/books/%s/flag", bookID) + "?user=" + url.QueryEscape(adminID)
+	return one[db.Book](ctx, c, http.MethodPost, path, db.ModerationNote{Reason: reason})
+}
+
+// RemoveBook takes a book down for good, on behalf of an admin.
+func (c *Client) RemoveBook(ctx context.Context, bookID, adminID, reason string) (db.Book, error) {
+	path := pathf("/admin/books/%s/remove", bookID) + "?user=" + url.QueryEscape(adminID)
+	return one[db.Book](ctx, c, http.MethodPost, path, db.ModerationNote{Reason: reason})
+}
+
+// SuspendUser stops a user listing and swapping items, on behalf of an admin.
+func (c *Client) SuspendUser(ctx context.Context, userID, adminID, reason string) (db.User, error) {
+	path := pathf("/admin/users/%s/suspend", userID) + "?user=" + url.QueryEscape(adminID)
+	return one[db.User](ctx, c, http.MethodPost, path, db.ModerationNote{Reason: reason})
+}
+
+// ReactivateUser lets a suspended user list and swap items again, on behalf of an admin.
+func (c *Client) ReactivateUser(ctx context.Context, userID, adminID, reason string) (db.User, error) {
+	path := pathf("/admin/users/%s/reactivate", userID) + "?user=" + url.QueryEscape(adminID)
+	return one[db.User](ctx, c, http.MethodPost, path, db.ModerationNote{Reason: reason})
+}
+
+// ListAdminActions returns the audit log of admin actions, newest first, on behalf of an admin.
+// Only the actions taken on a given subject are returned, if it is not empty.
+func (c *Client) ListAdminActions(ctx context.Context, adminID, subjectID string) ([]db.AdminAction, error) {
+	query := url.Values{"user": {adminID}}
+	if subjectID != "" {
+		query.Set("subject", subjectID)
+	}
+	return list[db.AdminAction](ctx, c, http.MethodGet, "/admin/actions?"+query.Encode(), nil)
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/handlers"
+)
+
+// CreateUser signs up a new user. Any ID of the given user is ignored.
+func (c *Client) CreateUser(ctx context.Context, u db.User) (db.User, error) {
+	u.ID = ""
+	return c.UpsertUser(ctx, u)
+}
+
+// UpsertUser creates a user, or updates it if it has an ID.
+func (c *Client) UpsertUser(ctx context.Context, u db.User) (db.User, error) {
+	var resp handlers.Response[db.Book]
+	if err := c.Do(ctx, http.MethodPost, "/users", u, &resp); err != nil {
+		return db.User{}, err
+	}
+	if resp.User == nil {
+		return db.User{}, errNoUser(http.MethodPost, "/users")
+	}
+	return *resp.User, nil
+}
+
+// ListUsers returns all the users, ordered by name.
+func (c *Client) ListUsers(ctx context.Context) ([]db.User, error) {
+	return list[db.User](ctx, c, http.MethodGet, "/users", nil)
+}
+
+// GetUser returns a given user, together with their books, magazines and reputation.
+func (c *Client) GetUser(ctx context.Context, id string) (*db.UserProfile, error) {
+	books, err := owned[db.Book](ctx, c, http.MethodGet, pathf("/users/%s/books", id))
+	if err != nil {
+		return nil, err
+	}
+	mags, err := owned[db.Magazine](ctx, c, http.MethodGet, pathf("/users/%s/magazines", id))
+	if err != nil {
+		return nil, err
+	}
+	profile := &db.UserProfile{
+		User:      *books.User,
+		Books:     books.Items,
+		Magazines: mags.Items,
+	}
+	if books.Reputation != nil {
+		profile.Reputation = *books.Reputation
+	}
+	return profile, nil
+}
+
+// UserHistory returns the history of the items a given user owns or used to own.
+func (c *Client) UserHistory(ctx context.Context, id string) ([]db.ItemEvent, error) {
+	return list[db.ItemEvent](ctx, c, http.MethodGet, pathf("/users/%s/history", id), nil)
+}
+
+// Wishlist returns the wishlist of a given user.
+func (c *Client) Wishlist(ctx context.Context, userID string) ([]db.WishlistItem, error) {
+	return list[db.WishlistItem](ctx, c, http.MethodGet, pathf("/users/%s/wishlist", userID), nil)
+}
+
+// AddToWishlist adds an item to the wishlist of a given user.
+func (c *Client) AddToWishlist(ctx context.Context, userID string, item db.WishlistItem) (db.WishlistItem, error) {
+	return one[db.WishlistItem](ctx, c, http.MethodPost, pathf("/users/%s/wishlist", userID), item)
+}
+
+// RemoveFromWishlist removes an item from the wishlist of a given user and returns the rest of it.
+func (c *Client) RemoveFromWishlist(ctx context.Context, userID, itemID string) ([]db.WishlistItem, error) {
+	return list[db.WishlistItem](ctx, c, http.MethodDelete, pathf("/users/%s/wishlist/%s", userID, itemID), nil)
+}
+
+// Notifications returns the notifications of a given user, newest first.
+func (c *Client) Notifications(ctx context.Context, userID string) ([]db.Notification, error) {
+	return list[db.Notification](ctx, c, http.MethodGet, pathf("/users/%s/notifications", userID), nil)
+}
+
+// Credits returns the credit balance and ledger of a given user.
+func (c *Client) Credits(ctx context.Context, userID string) (db.CreditAccount, error) {
+	return one[db.CreditAccount](ctx, c, http.MethodGet, pathf("/users/%s/credits", userID), nil)
+}
+
+// AdjustCredits changes the credit balance of a given user on behalf of an admin.
+func (c *Client) AdjustCredits(ctx context.Context, userID, adminID string, adj db.CreditAdjustment) (db.CreditAccount, error) {
+	path := pathf("/users/%s/credits", userID) + "?user=" + url.QueryEscape(adminID)
+	return one[db.CreditAccount](ctx, c, http.MethodPost, path, adj)
+}
+
+// Reviews returns the reviews a given user has received, newest first, together with their reputation.
+// Flagged reviews are left out until they are moderated.
+func (c *Client) Reviews(ctx context.Context, userID string) ([]db.Review, db.Reputation, error) {
+	resp, err := owned[db.Review](ctx, c, http.MethodGet, pathf("/users/%s/reviews", userID))
+	if err != nil {
+		return nil, db.Reputation{}, err
+	}
+	var rep db.Reputation
+	if resp.Reputation != nil {
+		rep = *resp.Reputation
+	}
+	return resp.Items, rep, nil
+}
+
+// FlagReview flags a review for moderation on behalf of a given user.
+func (c *Client) FlagReview(ctx context.Context, reviewID, userID string, f db.ReviewFlag) (db.Review, error) {
+	path := pathf("/reviews/%s/flag", reviewID) + "?user=" + url.QueryEscape(userID)
+	return one[db.Review](ctx, c, http.MethodPost, path, f)
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// ListWebhooks returns the webhook subscriptions, without their secrets, on behalf of an admin.
+func (c *Client) ListWebhooks(ctx context.Context, adminID string) ([]db.WebhookSubscription, error) {
+	return list[db.WebhookSubscription](ctx, c, http.MethodGet, "/webhooks?user="+url.QueryEscape(adminID), nil)
+}
+
+// CreateWebhook subscribes a webhook to item events on behalf of an admin. The returned subscription
+// contains the secret its payloads are signed with, which is not returned again.
+func (c *Client) CreateWebhook(ctx context.Context, adminID string, s db.WebhookSubscription) (db.WebhookSubscription,
+	error) {
+	return one[db.WebhookSubscription](ctx, c, http.MethodPost, "/webhooks?user="+url.QueryEscape(adminID), s)
+}
+
+// GetWebhook returns a given webhook subscription, without its secret, on behalf of an admin.
+func (c *Client) GetWebhook(ctx context.Context, id, adminID string) (db.WebhookSubscription, error) {
+	path := pathf("/webhooks/%s", id) + "?user=" + url.QueryEscape(adminID)
+	return one[db.WebhookSubscription](ctx, c, http.MethodGet, path, nil)
+}
+
+// DeleteWebhook unsubscribes a webhook on behalf of an admin.
+func (c *Client) DeleteWebhook(ctx context.Context, id, adminID string) error {
+	return c.Do(ctx, http.MethodDelete, pathf("/webhooks/%s", id)+"?user="+url.QueryEscape(adminID), nil, nil)
+}
+
+// WebhookDeliveries returns the delivery log of a given webhook, newest first, on behalf of an admin.
+func (c *Client) WebhookDeliveries(ctx context.Context, id, adminID string) ([]db.WebhookDelivery, error) {
+	path := pathf("/webhooks/%s/deliveries", id) + "?user=" + url.QueryEscape(adminID)
+	return list[db.WebhookDelivery](ctx, c, http.MethodGet, path, nil)
+}
+
+// ReplayWebhookDelivery queues a previous delivery of a webhook to be sent again, on behalf of an admin.
+func (c *Client) ReplayWebhookDelivery(ctx context.Context, id, deliveryID, adminID string) (db.WebhookDelivery, error) {
+	path := pathf("/webhooks/%s/deliveries/%s/replay", id, deliveryID) + "?user=" + url.QueryEscape(adminID)
+	return one[db.WebhookDelivery](ctx, c, http.MethodPost, path, nil)
+}
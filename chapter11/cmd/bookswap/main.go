@@ -0,0 +1,18 @@
+// Command bookswap operates a BookSwap service from the command line.
+// Run it with no arguments for the list of commands.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/cli"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := cli.Run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
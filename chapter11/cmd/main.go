@@ -1,13 +1,25 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/events"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/grpcserver"
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/handlers"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/holds"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/notify"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/webhooks"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -38,14 +50,172 @@ func main() {
 	if err != nil {
 		log.Fatalf("db open:%v", err)
 	}
+	if err := db.ScopeCommunities(dbConn); err != nil {
+		log.Fatalf("db scope communities:%v", err)
+	}
 
+	clock, ids := db.SystemClock{}, db.UUIDGenerator{}
 	ps := db.NewPostingService()
-	b := db.NewBookService(dbConn, ps)
-	ms := db.NewMagazineService(dbConn, ps)
-	u := db.NewUserService(dbConn, b, ms)
-	h := handlers.NewHandler(b, u, ms)
+	eb := events.NewBroker(events.DefaultBuffer)
+	cache := catalogueCache(clock)
+	b := db.NewBookService(dbConn, ps, eb, metadataProvider(), clock, ids).WithCache(cache)
+	ms := db.NewMagazineService(dbConn, ps, eb, clock, ids).WithCache(cache)
+	expvar.Publish("cache", expvar.Func(func() any {
+		return map[string]db.CacheStats{"books": b.CacheStats(), "magazines": ms.CacheStats()}
+	}))
+	u := db.NewUserService(dbConn, b, ms, clock, ids)
+	hs := db.NewHistoryService(dbConn, clock, ids)
+	ws := db.NewWishlistService(dbConn, clock, ids)
+	ns := db.NewNotificationService(dbConn, clock, ids)
+	whs := db.NewWebhookService(dbConn, clock, ids)
+	cs := db.NewCatalogueService(dbConn, b, ms, clock, ids)
+	ss := db.NewShippingService(dbConn, shippingRates(), clock, ids)
+	crs := db.NewCreditService(dbConn, adminIDs(), clock, ids)
+	rs := db.NewReviewService(dbConn, clock, ids)
+	is := db.NewImageService(dbConn, b, ms, imageStore(), clock, ids)
+	cms := db.NewCommunityService(dbConn, adminIDs(), clock, ids)
+	mds := db.NewModerationService(dbConn, b, ms, adminIDs(), clock, ids)
+	hds := db.NewHoldService(dbConn, b, ms, holdPeriod(), clock, ids)
+	h := handlers.NewHandler(b, u, ms, hs, ws, ns, whs, cs, ss, crs, rs, is, cms, mds, hds, eb)
+
+	wd := webhooks.NewDispatcher(whs, webhooks.NewPublicClient(10*time.Second), clock)
+	go wd.Run(context.Background(), 5*time.Second)
+
+	go holds.NewSweeper(hds).Run(context.Background(), time.Minute)
+
+	if channels := notificationChannels(clock); len(channels) > 0 {
+		d := notify.NewDispatcher(db.NewDeliveryService(dbConn, clock, ids), clock, channels...)
+		go d.Run(context.Background(), 10*time.Second)
+	}
+
+	if grpcPort, ok := os.LookupEnv("BOOKSWAP_GRPC_PORT"); ok {
+		token, ok := os.LookupEnv("BOOKSWAP_GRPC_TOKEN")
+		if !ok {
+			log.Fatal("env variable BOOKSWAP_GRPC_TOKEN not found")
+		}
+		l, err := net.Listen("tcp", fmt.Sprint(":", grpcPort))
+		if err != nil {
+			log.Fatalf("grpc listen:%v", err)
+		}
+		gs := grpcserver.NewServer(b, u, ms, eb).WithCommunities(cms,
+			func(communityID string) (grpcserver.BookOperations, grpcserver.UserOperations, grpcserver.MagazineOperations) {
+				return b.InCommunity(communityID), u.InCommunity(communityID), ms.InCommunity(communityID)
+			})
+		g := grpcserver.NewGRPCServer(gs, []string{token})
+		log.Printf("Serving gRPC on :%s...\n", grpcPort)
+		go func() {
+			log.Fatal(g.Serve(l))
+		}()
+	}
 
 	router := handlers.ConfigureServer(h)
 	log.Printf("Listening on :%s...\n", port)
 	log.Fatal(http.ListenAndServe(fmt.Sprint(":", port), router))
 }
+
+// metadataProvider configures where the details of new books are looked up by ISBN.
+// Books are only filled in from a local fixture file, if one is configured.
+func metadataProvider() db.MetadataProvider {
+	path, ok := os.LookupEnv("BOOKSWAP_METADATA_FIXTURE")
+	if !ok {
+		return nil
+	}
+	p, err := db.NewFixtureMetadataProvider(path)
+	if err != nil {
+		log.Fatalf("metadata fixture:%v", err)
+	}
+	return p
+}
+
+// catalogueCache configures the cache which books and magazines are read through.
+// It holds 1000 items for 30 seconds by default, and caching is turned off if its size is 0.
+func catalogueCache(clock db.Clock) db.Cache {
+	size, ttl := 1000, 30*time.Second
+	if s, ok := os.LookupEnv("BOOKSWAP_CACHE_SIZE"); ok {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			log.Fatalf("env variable BOOKSWAP_CACHE_SIZE must be a non-negative integer:%v", s)
+		}
+		size = n
+	}
+	if s, ok := os.LookupEnv("BOOKSWAP_CACHE_TTL"); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("env variable BOOKSWAP_CACHE_TTL:%v", err)
+		}
+		ttl = d
+	}
+	if size == 0 {
+		return nil
+	}
+	return db.NewLRUCache(size, ttl, clock)
+}
+
+// holdPeriod configures how long items are held for, which is a day by default.
+func holdPeriod() time.Duration {
+	s, ok := os.LookupEnv("BOOKSWAP_HOLD_PERIOD")
+	if !ok {
+		return 24 * time.Hour
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		log.Fatalf("env variable BOOKSWAP_HOLD_PERIOD must be a positive duration:%v", s)
+	}
+	return d
+}
+
+// imageStore configures where uploaded images are stored.
+// Images are kept in a temporary directory, unless a directory is configured.
+func imageStore() db.BlobStore {
+	dir, ok := os.LookupEnv("BOOKSWAP_IMAGE_DIR")
+	if !ok {
+		dir = filepath.Join(os.TempDir(), "bookswap-images")
+	}
+	store, err := db.NewFileBlobStore(dir)
+	if err != nil {
+		log.Fatalf("image store:%v", err)
+	}
+	return store
+}
+
+// shippingRates loads the zone/rate table shipping quotes are computed from.
+func shippingRates() *db.ShippingRates {
+	path, ok := os.LookupEnv("BOOKSWAP_SHIPPING_RATES")
+	if !ok {
+		path = "chapter11/db/shipping_rates.json"
+	}
+	rates, err := db.LoadShippingRates(path)
+	if err != nil {
+		log.Fatalf("shipping rates:%v", err)
+	}
+	return rates
+}
+
+// adminIDs returns the IDs of the users who can perform admin operations, such as adjusting credits,
+// managing communities and moderating items and users.
+func adminIDs() []string {
+	var ids []string
+	for _, id := range strings.Split(os.Getenv("BOOKSWAP_ADMIN_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// notificationChannels configures the channels notifications are delivered through.
+// Notifications are only shown in the app when none are configured.
+func notificationChannels(clock db.Clock) []notify.Channel {
+	var channels []notify.Channel
+	if addr, ok := os.LookupEnv("BOOKSWAP_SMTP_ADDR"); ok {
+		from, ok := os.LookupEnv("BOOKSWAP_SMTP_FROM")
+		if !ok {
+			log.Fatal("env variable BOOKSWAP_SMTP_FROM not found")
+		}
+		channels = append(channels, notify.NewEmailChannel(addr, from, nil, clock))
+	}
+	if url, ok := os.LookupEnv("BOOKSWAP_NOTIFY_WEBHOOK_URL"); ok {
+		channels = append(channels, notify.NewWebhookChannel(url, &http.Client{Timeout: 10 * time.Second}))
+	}
+	return channels
+}
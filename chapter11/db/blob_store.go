@@ -0,0 +1,90 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// blobKeyPattern matches the keys which can be stored, so that keys cannot escape the store.
+var blobKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// BlobStore interface wraps around the storage of files, such as item images.
+type BlobStore interface {
+	// Put stores the contents of r under the given key, replacing any blob stored under it.
+	Put(key, contentType string, r io.Reader) error
+	// Open returns the contents of the blob stored under the given key, or ErrRecordNotFound.
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under the given key, if there is one.
+	Delete(key string) error
+}
+
+// FileBlobStore is a BlobStore which keeps blobs as files in a local directory.
+type FileBlobStore struct {
+	dir string
+}
+
+// NewFileBlobStore initialises a FileBlobStore, creating its directory if it does not exist.
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileBlobStore{dir: dir}, nil
+}
+
+// Put writes a blob to a temporary file and renames it into place, so that it is never read half written.
+// The content type is not kept, as it is given by the extension of the key.
+func (fs *FileBlobStore) Put(key, contentType string, r io.Reader) error {
+	path, err := fs.path(key)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(fs.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Open opens the file of a blob.
+func (fs *FileBlobStore) Open(key string) (io.ReadCloser, error) {
+	path, err := fs.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no blob found for key %s:%w", key, ErrRecordNotFound)
+	}
+	return f, err
+}
+
+// Delete removes the file of a blob.
+func (fs *FileBlobStore) Delete(key string) error {
+	path, err := fs.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// path returns the path of the file of a blob, rejecting keys which are not plain file names.
+func (fs *FileBlobStore) path(key string) (string, error) {
+	if !blobKeyPattern.MatchString(key) {
+		return "", fmt.Errorf("invalid blob key %q:%w", key, ErrRecordNotFound)
+	}
+	return filepath.Join(fs.dir, key), nil
+}
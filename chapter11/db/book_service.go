@@ -1,37 +1,89 @@
 package db
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // Book contains all the fields for representing a book.
+// Images are only added by uploading them, so they are kept when a book is updated.
+// Flagged books are hidden from the catalogue until an admin unflags or removes them.
+// The timestamps are set with the clock of the service whenever a book is saved.
 type Book struct {
-	ID      string `json:"id" gorm:"primaryKey"`
-	Name    string `json:"name"`
-	Author  string `json:"author"`
-	OwnerID string `json:"owner_id"`
-	Status  string `json:"status"`
+	ID        string        `json:"id" gorm:"primaryKey"`
+	Name      string        `json:"name"`
+	Author    string        `json:"author"`
+	ISBN      string        `json:"isbn,omitempty"`
+	Publisher string        `json:"publisher,omitempty"`
+	Year      int           `json:"year,omitempty"`
+	Edition   string        `json:"edition,omitempty"`
+	Condition ItemCondition `json:"condition,omitempty"`
+	Language  string        `json:"language,omitempty"`
+	Tags      Tags          `json:"tags,omitempty"`
+	Images    ItemImages    `json:"images,omitempty"`
+	OwnerID   string        `json:"owner_id"`
+	Status    BookStatus    `json:"status"`
+	Flagged   bool          `json:"flagged,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
 }
 
 // BookService contains all the functionality and dependencies for managing books.
 type BookService struct {
-	DB *gorm.DB
-	ps PostingService
+	DB    *gorm.DB
+	ps    PostingService
+	pub   EventPublisher
+	md    MetadataProvider
+	cache *readThrough
 }
 
 // NewBookService initialises a BookService given its dependencies.
-func NewBookService(db *gorm.DB, ps PostingService) *BookService {
+// The publisher and the metadata provider are optional.
+func NewBookService(db *gorm.DB, ps PostingService, pub EventPublisher, md MetadataProvider,
+	clock Clock, ids IDGenerator) *BookService {
 	return &BookService{
-		DB: db,
-		ps: ps,
+		DB:  withClock(db, clock, ids),
+		ps:  ps,
+		pub: pub,
+		md:  md,
 	}
 }
 
-// Get returns a given book or error if none exists.
+// WithCache configures the cache which the available books and single books are read through.
+// Cached books are invalidated whenever a change to them is published.
+func (bs *BookService) WithCache(c Cache) *BookService {
+	bs.cache = newReadThrough(c)
+	return bs
+}
+
+// CacheStats returns how many reads were served by the cache, if there is one.
+func (bs *BookService) CacheStats() CacheStats {
+	return bs.cache.stats()
+}
+
+// InCommunity returns a copy of the service which only sees and changes the books of a community.
+// The copy reads through the same cache, under keys of its own.
+func (bs *BookService) InCommunity(communityID string) *BookService {
+	c := *bs
+	c.DB = InCommunity(bs.DB, communityID)
+	return &c
+}
+
+// Get returns a given book or error if none exists. It is read through the cache, if there is one.
 func (bs *BookService) Get(id string) (*Book, error) {
+	return cached(bs.cache, scopedCacheKey(bs.DB, bookCacheKey(id)), func() (*Book, error) {
+		return bs.get(id)
+	})
+}
+
+// get reads a book from the database, for changes which must not start from a stale copy.
+func (bs *BookService) get(id string) (*Book, error) {
+	if !isValidID(id) {
+		return nil, gorm.ErrRecordNotFound
+	}
 	var b Book
 	if r := bs.DB.Where("id = ?", id).First(&b); r.Error != nil {
 		return nil, r.Error
@@ -40,21 +92,84 @@ func (bs *BookService) Get(id string) (*Book, error) {
 	return &b, nil
 }
 
-// Upsert creates or updates a book.
-func (bs *BookService) Upsert(b Book) Book {
+// Upsert creates or updates a book. ISBNs, conditions, languages and tags are validated and normalized,
+// and the details of new books which are left empty are filled in from the metadata of their ISBN.
+// Updates cannot change the owner of a book, which only changes hands by being swapped.
+func (bs *BookService) Upsert(b Book) (Book, error) {
+	if b.ISBN != "" {
+		isbn, err := NormalizeISBN(b.ISBN)
+		if err != nil {
+			return Book{}, err
+		}
+		b.ISBN = isbn
+	}
+	if err := normalizeDetails(&b.Condition, &b.Language, &b.Tags); err != nil {
+		return Book{}, err
+	}
+	if err := checkActive(bs.DB, b.OwnerID); err != nil {
+		return Book{}, err
+	}
 	var eb Book
-	if r := bs.DB.Where("id = ?", b.ID).First(&eb); r.Error != nil {
-		b.ID = uuid.NewString()
-		b.Status = Available.String()
+	var prev *Book
+	eventType := ItemUpdated
+	if !isValidID(b.ID) || bs.DB.Where("id = ?", b.ID).First(&eb).Error != nil {
+		if err := checkMember(bs.DB, b.OwnerID); err != nil {
+			return Book{}, err
+		}
+		if err := bs.enrich(&b); err != nil {
+			return Book{}, err
+		}
+		b.ID = idsOf(bs.DB).NewID()
+		b.Status = Available
+		b.Images = nil
+		b.Flagged = false
+		b.CreatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+		eventType = ItemCreated
+	} else {
+		// Items only change hands within the community of their owner, and only by being swapped.
+		if err := checkSameCommunity(bs.DB, eb.OwnerID, b.OwnerID); err != nil {
+			return Book{}, err
+		}
+		if b.OwnerID != eb.OwnerID {
+			return Book{}, fmt.Errorf("book %s:%w %s", b.ID, ErrNotOwner, b.OwnerID)
+		}
+		prev = &eb
+		// The status and flag only change through the lifecycle transitions and moderation.
+		b.Status = eb.Status
+		b.Images = eb.Images
+		b.Flagged = eb.Flagged
+		b.CreatedAt = eb.CreatedAt
+	}
+	if err := bs.save(prev, &b, bookEvent(b, eventType, b.OwnerID)); err != nil {
+		return Book{}, err
 	}
-	bs.DB.Save(&b)
-	return b
+	return b, nil
 }
 
-// List returns the list of available books.
+// List returns the list of available books, leaving out flagged books and the books of suspended users.
+// It is read through the cache, if there is one.
 func (bs *BookService) List() ([]Book, error) {
+	return cached(bs.cache, scopedCacheKey(bs.DB, availableBooksKey), bs.list)
+}
+
+func (bs *BookService) list() ([]Book, error) {
+	var items []Book
+	q := bs.DB.Where("status = ? AND NOT flagged", Available).Where(activeOwners)
+	if result := q.Find(&items); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return items, nil
+}
+
+// ListByISBN returns all the books of an edition, whatever their status, given its ISBN in any valid spelling.
+func (bs *BookService) ListByISBN(isbn string) ([]Book, error) {
+	isbn, err := NormalizeISBN(isbn)
+	if err != nil {
+		return nil, err
+	}
 	var items []Book
-	if result := bs.DB.Where("status = ?", Available.String()).Find(&items); result.Error != nil {
+	if result := bs.DB.Where("isbn = ?", isbn).Find(&items); result.Error != nil {
 		return nil, result.Error
 	}
 
@@ -71,21 +186,299 @@ func (bs *BookService) ListByUser(userID string) ([]Book, error) {
 	return items, nil
 }
 
-// SwapBook checks whether a book is available and, if possible, marks it as swapped.
+// ListByUsers returns the books of all the given users, in a single query.
+func (bs *BookService) ListByUsers(userIDs []string) ([]Book, error) {
+	var items []Book
+	ids := validIDs(userIDs)
+	if len(ids) == 0 {
+		return items, nil
+	}
+	if result := bs.DB.Where("owner_id IN ?", ids).Find(&items); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return items, nil
+}
+
+// SwapBook checks whether a book is available and, if possible, sends it to its new owner.
+// The book stays in transit until the new owner confirms its delivery. Of two concurrent swaps
+// of the same book, only the first one goes through.
 func (bs *BookService) SwapBook(bookID, userID string) (*Book, error) {
+	if !isValidID(bookID) {
+		return nil, fmt.Errorf("no book found for id %s:%w", bookID, ErrRecordNotFound)
+	}
 	var b Book
 	if r := bs.DB.Where("id = ?", bookID).First(&b); r.Error != nil {
-		return nil, fmt.Errorf("no book found for id %s:%v", bookID, r.Error)
+		return nil, fmt.Errorf("no book found for id %s:%w", bookID, r.Error)
+	}
+	if b.OwnerID == userID {
+		return nil, fmt.Errorf("%w: user %s already owns book %s", ErrInvalidInput, userID, bookID)
+	}
+	if err := checkTransition(b.Status, InTransit); err != nil {
+		return nil, fmt.Errorf("book %s is not available for swapping:%w", bookID, err)
+	}
+	if b.Flagged {
+		return nil, fmt.Errorf("book %s:%w", bookID, ErrFlagged)
+	}
+	if err := checkSameCommunity(bs.DB, b.OwnerID, userID); err != nil {
+		return nil, fmt.Errorf("book %s:%w", bookID, err)
+	}
+	if err := checkActive(bs.DB, b.OwnerID, userID); err != nil {
+		return nil, fmt.Errorf("book %s:%w", bookID, err)
 	}
-	if b.Status != Available.String() {
-		return nil, fmt.Errorf("book %s is not available for swapping", bookID)
+	// Reserved books are only swapped by their holder, which converts the hold.
+	if b.Status == Reserved {
+		if err := checkHolder(bs.DB, b.ID, userID); err != nil {
+			return nil, fmt.Errorf("book %s:%w", bookID, err)
+		}
 	}
+	read := b
 	b.OwnerID = userID
-	b.Status = Swapped.String()
-	sb := bs.Upsert(b)
-	if err := bs.ps.NewBookOrder(sb); err != nil {
+	b.Status = InTransit
+	e := bookEvent(b, ItemSwapped, userID)
+	e.PreviousOwnerID = read.OwnerID
+	if err := bs.save(&read, &b, e); err != nil {
+		return nil, err
+	}
+	if err := bs.ps.NewBookOrder(b); err != nil {
+		// The book never left its previous owner, so it goes back on the catalogue.
+		swapped := b
+		b.OwnerID = read.OwnerID
+		b.Status = Available
+		if serr := bs.save(&swapped, &b, bookEvent(b, ItemPostingFailed, userID)); serr != nil {
+			return nil, serr
+		}
+		return nil, err
+	}
+	posted := bookEvent(b, ItemPosted, userID)
+	if err := bs.DB.Transaction(func(tx *gorm.DB) error {
+		return bs.record(tx, b, &posted)
+	}); err != nil {
+		return nil, err
+	}
+	bs.publish(posted)
+
+	return &b, nil
+}
+
+// ConfirmDelivery marks a book in transit as swapped, once its new owner has received it.
+func (bs *BookService) ConfirmDelivery(bookID, userID string) (*Book, error) {
+	return bs.transition(bookID, userID, Swapped, ItemDelivered)
+}
+
+// Relist makes a swapped, withdrawn or held book available for swapping again.
+// Relisting a held book releases its hold.
+func (bs *BookService) Relist(bookID, userID string) (*Book, error) {
+	return bs.transition(bookID, userID, Available, ItemRelisted)
+}
+
+// Withdraw takes a book off the catalogue, without swapping it.
+func (bs *BookService) Withdraw(bookID, userID string) (*Book, error) {
+	return bs.transition(bookID, userID, Withdrawn, ItemWithdrawn)
+}
+
+// transition moves a book owned by the given user to the next status.
+func (bs *BookService) transition(bookID, userID string, next BookStatus, t ItemEventType) (*Book, error) {
+	b, err := bs.get(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("no book found for id %s:%w", bookID, err)
+	}
+	if b.OwnerID != userID {
+		return nil, fmt.Errorf("book %s:%w %s", bookID, ErrNotOwner, userID)
+	}
+	if err := checkTransition(b.Status, next); err != nil {
+		return nil, fmt.Errorf("book %s:%w", bookID, err)
+	}
+	// Suspended owners cannot put their books back on the catalogue.
+	if next == Available {
+		if err := checkActive(bs.DB, userID); err != nil {
+			return nil, fmt.Errorf("book %s:%w", bookID, err)
+		}
+	}
+	read := *b
+	b.Status = next
+	if err := bs.save(&read, b, bookEvent(*b, t, userID)); err != nil {
 		return nil, err
 	}
 
-	return &sb, nil
+	return b, nil
+}
+
+// moderate applies the change of an admin to a book, recording it both in the history of the book
+// and in the audit log of admin actions, in a single transaction.
+func (bs *BookService) moderate(bookID string, t ItemEventType, a AdminAction,
+	change func(*Book) error) (*Book, error) {
+	b, err := bs.get(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("no book found for id %s:%w", bookID, err)
+	}
+	read := *b
+	if err := change(b); err != nil {
+		return nil, fmt.Errorf("book %s:%w", bookID, err)
+	}
+	e := bookEvent(*b, t, a.AdminID)
+	if err := bs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockUnchanged(tx, "books", read.ID, read.Status, read.OwnerID, read.Flagged); err != nil {
+			return err
+		}
+		if r := tx.Save(b); r.Error != nil {
+			return r.Error
+		}
+		if err := bs.record(tx, *b, &e); err != nil {
+			return err
+		}
+		return recordAdminAction(tx, &a)
+	}); err != nil {
+		return nil, err
+	}
+	bs.publish(e)
+
+	return b, nil
+}
+
+// hold moves a book to the next status as a hold on it starts or ends, saving the hold
+// together with the book and its history in a single transaction.
+func (bs *BookService) hold(h *Hold, next BookStatus, t ItemEventType) error {
+	b, err := bs.get(h.ItemID)
+	if err != nil {
+		return fmt.Errorf("no book found for id %s:%w", h.ItemID, err)
+	}
+	if err := checkTransition(b.Status, next); err != nil {
+		return fmt.Errorf("book %s:%w", h.ItemID, err)
+	}
+	if next == Reserved {
+		if err := checkHoldable(bs.DB, b.OwnerID, h.UserID, b.Flagged); err != nil {
+			return fmt.Errorf("book %s:%w", h.ItemID, err)
+		}
+	}
+	read := *b
+	b.Status = next
+	e := bookEvent(*b, t, h.UserID)
+	if err := bs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockUnchanged(tx, "books", read.ID, read.Status, read.OwnerID, read.Flagged); err != nil {
+			return err
+		}
+		if r := tx.Save(b); r.Error != nil {
+			return r.Error
+		}
+		if r := tx.Save(h); r.Error != nil {
+			return r.Error
+		}
+		return bs.record(tx, *b, &e)
+	}); err != nil {
+		return err
+	}
+	bs.publish(e)
+	return nil
+}
+
+// save stores a book and appends the given event to its history in a single transaction.
+// prev is the book as it was read before the change, or nil for a new book, and the change fails
+// if the book has changed since. b is stamped with the time it was saved at.
+// The event is only published once the transaction has been committed.
+func (bs *BookService) save(prev *Book, b *Book, e ItemEvent) error {
+	if err := bs.DB.Transaction(func(tx *gorm.DB) error {
+		if prev != nil {
+			if err := lockUnchanged(tx, "books", prev.ID, prev.Status, prev.OwnerID, prev.Flagged); err != nil {
+				return err
+			}
+		}
+		if r := tx.Save(b); r.Error != nil {
+			return r.Error
+		}
+		return bs.record(tx, *b, &e)
+	}); err != nil {
+		return err
+	}
+	bs.publish(e)
+	return nil
+}
+
+// record appends an event to the history of a book and notifies the users it concerns.
+// Newly created books are matched against the wishlists of other users.
+// Swaps make the new owner pay the previous owner in credits, which are refunded if the posting fails.
+func (bs *BookService) record(tx *gorm.DB, b Book, e *ItemEvent) error {
+	if err := recordEvent(tx, e, b); err != nil {
+		return err
+	}
+	switch e.Type {
+	case ItemCreated:
+		return notifyWishlists(tx, BookItem, b.ID, b.OwnerID, b.Name, b.Author)
+	case ItemSwapped:
+		if err := transferCredits(tx, *e); err != nil {
+			return err
+		}
+		return notifySwap(tx, *e, b.Name)
+	case ItemPosted:
+		return notifySwap(tx, *e, b.Name)
+	case ItemPostingFailed:
+		return refundCredits(tx, *e)
+	}
+	return nil
+}
+
+// publish tells the publisher, if any, about a recorded event.
+// The cached copies of the book and of the available books, as they are and within the community
+// of its owner, are invalidated first, as they may be stale.
+func (bs *BookService) publish(e ItemEvent) {
+	if bs.cache != nil {
+		bs.cache.invalidate(communityCacheKeys(bs.DB, e.OwnerID, bookCacheKey(e.ItemID), availableBooksKey)...)
+	}
+	if bs.pub != nil {
+		bs.pub.Publish(e)
+	}
+}
+
+// forgetAvailable invalidates the cached available books, as they are and within the community
+// of a user, once the user has been suspended or reactivated.
+func (bs *BookService) forgetAvailable(userID string) {
+	if bs.cache != nil {
+		bs.cache.invalidate(communityCacheKeys(bs.DB, userID, availableBooksKey)...)
+	}
+}
+
+// enrich fills in the empty details of a new book from the metadata of its ISBN, if it is known.
+func (bs *BookService) enrich(b *Book) error {
+	if b.ISBN == "" || bs.md == nil {
+		return nil
+	}
+	m, err := bs.md.Lookup(b.ISBN)
+	if errors.Is(err, ErrMetadataNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("looking up isbn %s:%v", b.ISBN, err)
+	}
+	if b.Name == "" {
+		b.Name = m.Title
+	}
+	if b.Author == "" {
+		b.Author = m.Author
+	}
+	if b.Publisher == "" {
+		b.Publisher = m.Publisher
+	}
+	if b.Year == 0 {
+		b.Year = m.Year
+	}
+	return nil
+}
+
+// availableBooksKey is the cache key of the available books.
+const availableBooksKey = "books:available"
+
+// bookCacheKey returns the cache key of a book.
+func bookCacheKey(id string) string {
+	return "books:" + id
+}
+
+// bookEvent initialises a ledger event of the given type for a book.
+func bookEvent(b Book, t ItemEventType, actorID string) ItemEvent {
+	return ItemEvent{
+		ItemID:   b.ID,
+		ItemType: BookItem,
+		Type:     t,
+		ActorID:  actorID,
+		OwnerID:  b.OwnerID,
+	}
 }
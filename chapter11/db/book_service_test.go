@@ -2,7 +2,10 @@ package db_test
 
 import (
 	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
@@ -15,12 +18,15 @@ import (
 func TestGetBook(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
 	t.Run("initial books", func(t *testing.T) {
-		bs := db.NewBookService(testDB, nil)
-		eb := bs.Upsert(db.Book{
-			Name:   "New Book",
-			Status: db.Available.String(),
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		eb, err := bs.Upsert(db.Book{
+			Name:    "New Book",
+			Status:  db.Available,
+			OwnerID: owner.ID,
 		})
+		require.Nil(t, err)
 		assert.NotNil(t, eb)
 
 		tests := map[string]struct {
@@ -47,7 +53,7 @@ func TestGetBook(t *testing.T) {
 	})
 
 	t.Run("invalid id", func(t *testing.T) {
-		bs := db.NewBookService(testDB, nil)
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
 		b, err := bs.Get("invalid-id")
 		assert.Equal(t, db.ErrRecordNotFound, err)
 		assert.Nil(t, b)
@@ -59,34 +65,198 @@ func TestUpsertBook(t *testing.T) {
 	defer cleaner()
 	newBook := db.Book{
 		Name:    "New book",
-		OwnerID: uuid.New().String(),
+		OwnerID: db.CreateTestUser(t, testDB).ID,
 	}
 	t.Run("new book", func(t *testing.T) {
-		bs := db.NewBookService(testDB, nil)
-		b := bs.Upsert(newBook)
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		b, err := bs.Upsert(newBook)
+		require.Nil(t, err)
 		assert.Equal(t, newBook.Name, b.Name)
 		assert.Equal(t, newBook.OwnerID, b.OwnerID)
 		assert.NotEmpty(t, b.ID)
-		assert.Equal(t, db.Available.String(), b.Status)
+		assert.Equal(t, db.Available, b.Status)
 	})
 
 	t.Run("duplicate book", func(t *testing.T) {
-		bs := db.NewBookService(testDB, nil)
-		b1 := bs.Upsert(newBook)
-		b2 := bs.Upsert(b1)
+		clock := db.NewFakeClock(time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC))
+		bs := db.NewBookService(testDB, nil, nil, nil, clock, nil)
+		b1, err := bs.Upsert(newBook)
+		require.Nil(t, err)
+		clock.Advance(time.Hour)
+		b2, err := bs.Upsert(b1)
+		require.Nil(t, err)
+		assert.True(t, b1.CreatedAt.Equal(b2.CreatedAt))
+		assert.Equal(t, clock.Now(), b2.UpdatedAt)
+		b2.CreatedAt, b2.UpdatedAt = b1.CreatedAt, b1.UpdatedAt
 		assert.Equal(t, b1, b2)
 	})
+
+	t.Run("change of owner", func(t *testing.T) {
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		b, err := bs.Upsert(newBook)
+		require.Nil(t, err)
+		b.OwnerID = db.CreateTestUser(t, testDB).ID
+		_, err = bs.Upsert(b)
+		assert.ErrorIs(t, err, db.ErrNotOwner)
+		got, err := bs.Get(b.ID)
+		require.Nil(t, err)
+		assert.Equal(t, newBook.OwnerID, got.OwnerID)
+	})
+
+	t.Run("unknown owner", func(t *testing.T) {
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		_, err := bs.Upsert(db.Book{
+			Name:    "Orphan book",
+			OwnerID: uuid.New().String(),
+		})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("invalid isbn", func(t *testing.T) {
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		_, err := bs.Upsert(db.Book{
+			Name:    "Misprinted book",
+			ISBN:    "978-0-441-17271-0",
+			OwnerID: newBook.OwnerID,
+		})
+		assert.ErrorIs(t, err, db.ErrInvalidInput)
+	})
+
+	t.Run("details", func(t *testing.T) {
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		b, err := bs.Upsert(db.Book{
+			Name:      "Dune",
+			Edition:   "First",
+			Condition: " like_new",
+			Language:  "EN",
+			Tags:      db.Tags{"Sci-Fi", "classic", "sci-fi "},
+			Images:    db.ItemImages{{ID: "ignored"}},
+			OwnerID:   newBook.OwnerID,
+		})
+		require.Nil(t, err)
+		assert.Equal(t, "First", b.Edition)
+		assert.Equal(t, db.ConditionLikeNew, b.Condition)
+		assert.Equal(t, "en", b.Language)
+		assert.Equal(t, db.Tags{"classic", "sci-fi"}, b.Tags)
+		assert.Empty(t, b.Images)
+		got, err := bs.Get(b.ID)
+		require.Nil(t, err)
+		assert.Equal(t, b, *got)
+	})
+
+	t.Run("invalid details", func(t *testing.T) {
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		tests := map[string]db.Book{
+			"unknown condition": {Condition: "MINT"},
+			"invalid language":  {Language: "eng"},
+			"empty tag":         {Tags: db.Tags{" "}},
+			"long tag":          {Tags: db.Tags{strings.Repeat("a", 31)}},
+			"too many tags":     {Tags: db.Tags{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}},
+		}
+		for name, b := range tests {
+			t.Run(name, func(t *testing.T) {
+				b.Name = "Dune"
+				b.OwnerID = newBook.OwnerID
+				_, err := bs.Upsert(b)
+				assert.ErrorIs(t, err, db.ErrInvalidInput)
+			})
+		}
+	})
+
+	t.Run("new book by isbn", func(t *testing.T) {
+		md := mocks.NewMetadataProvider(t)
+		md.On("Lookup", "9780441172719").Return(&db.BookMetadata{
+			ISBN:      "9780441172719",
+			Title:     "Dune",
+			Author:    "Frank Herbert",
+			Publisher: "Ace Books",
+			Year:      1990,
+		}, nil).Once()
+		bs := db.NewBookService(testDB, nil, nil, md, nil, nil)
+		b, err := bs.Upsert(db.Book{
+			ISBN:    "0-441-17271-7",
+			Author:  "F. Herbert",
+			OwnerID: newBook.OwnerID,
+		})
+		require.Nil(t, err)
+		assert.Equal(t, "9780441172719", b.ISBN)
+		assert.Equal(t, "Dune", b.Name)
+		assert.Equal(t, "F. Herbert", b.Author)
+		assert.Equal(t, "Ace Books", b.Publisher)
+		assert.Equal(t, 1990, b.Year)
+	})
+
+	t.Run("unknown isbn", func(t *testing.T) {
+		md := mocks.NewMetadataProvider(t)
+		md.On("Lookup", "9780141439587").Return(nil, db.ErrMetadataNotFound).Once()
+		bs := db.NewBookService(testDB, nil, nil, md, nil, nil)
+		b, err := bs.Upsert(db.Book{
+			Name:    "Emma",
+			ISBN:    "9780141439587",
+			OwnerID: newBook.OwnerID,
+		})
+		require.Nil(t, err)
+		assert.Equal(t, "Emma", b.Name)
+		assert.Empty(t, b.Publisher)
+	})
+
+	t.Run("metadata lookup failure", func(t *testing.T) {
+		md := mocks.NewMetadataProvider(t)
+		md.On("Lookup", "9780141439587").Return(nil, errors.New("catalogue unavailable")).Once()
+		bs := db.NewBookService(testDB, nil, nil, md, nil, nil)
+		_, err := bs.Upsert(db.Book{
+			ISBN:    "9780141439587",
+			OwnerID: newBook.OwnerID,
+		})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestListBooksByISBN(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	dune, err := bs.Upsert(db.Book{Name: "Dune", ISBN: "9780441172719", OwnerID: owner.ID})
+	require.Nil(t, err)
+	_, err = bs.Upsert(db.Book{Name: "Emma", ISBN: "9780141439587", OwnerID: owner.ID})
+	require.Nil(t, err)
+
+	tests := map[string]struct {
+		isbn    string
+		want    []db.Book
+		wantErr error
+	}{
+		"isbn-13":      {isbn: "978-0-441-17271-9", want: []db.Book{dune}},
+		"isbn-10":      {isbn: "0441172717", want: []db.Book{dune}},
+		"no books":     {isbn: "9780804429573", want: []db.Book{}},
+		"invalid isbn": {isbn: "12345", wantErr: db.ErrInvalidInput},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			books, err := bs.ListByISBN(tc.isbn)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.Nil(t, err)
+			assert.ElementsMatch(t, tc.want, books)
+		})
+	}
 }
 
 func TestListBooks(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
 	t.Run("existing books", func(t *testing.T) {
-		bs := db.NewBookService(testDB, nil)
-		eb := bs.Upsert(db.Book{
-			Name:   "Existing book",
-			Status: db.Available.String(),
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		eb, err := bs.Upsert(db.Book{
+			Name:    "Existing book",
+			Status:  db.Available,
+			OwnerID: owner.ID,
 		})
+		require.Nil(t, err)
 		books, err := bs.List()
 		require.Nil(t, err)
 		assert.NotEmpty(t, books)
@@ -94,16 +264,19 @@ func TestListBooks(t *testing.T) {
 	})
 
 	t.Run("new book", func(t *testing.T) {
-		bs := db.NewBookService(testDB, nil)
-		eb := bs.Upsert(db.Book{
-			Name:   "Existing book",
-			Status: db.Available.String(),
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		eb, err := bs.Upsert(db.Book{
+			Name:    "Existing book",
+			Status:  db.Available,
+			OwnerID: owner.ID,
 		})
+		require.Nil(t, err)
 		newBook := db.Book{
 			Name:    "New book",
-			OwnerID: uuid.New().String(),
+			OwnerID: owner.ID,
 		}
-		b := bs.Upsert(newBook)
+		b, err := bs.Upsert(newBook)
+		require.Nil(t, err)
 		books, err := bs.List()
 		require.Nil(t, err)
 		assert.NotEmpty(t, books)
@@ -116,12 +289,13 @@ func TestListBooksByUser(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
 	t.Run("existing mag", func(t *testing.T) {
-		bs := db.NewBookService(testDB, nil)
-		eb := bs.Upsert(db.Book{
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		eb, err := bs.Upsert(db.Book{
 			Name:    "Existing book",
-			Status:  db.Available.String(),
-			OwnerID: uuid.New().String(),
+			Status:  db.Available,
+			OwnerID: db.CreateTestUser(t, testDB).ID,
 		})
+		require.Nil(t, err)
 		books, err := bs.ListByUser(eb.OwnerID)
 		require.Nil(t, err)
 		assert.Equal(t, 1, len(books))
@@ -131,16 +305,18 @@ func TestListBooksByUser(t *testing.T) {
 	t.Run("multiple books", func(t *testing.T) {
 		testDB, cleaner := db.OpenDB(t)
 		defer cleaner()
-		bs := db.NewBookService(testDB, nil)
-		eb := bs.Upsert(db.Book{
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+		eb, err := bs.Upsert(db.Book{
 			Name:    "Existing book",
-			Status:  db.Available.String(),
-			OwnerID: uuid.New().String(),
+			Status:  db.Available,
+			OwnerID: db.CreateTestUser(t, testDB).ID,
 		})
-		b := bs.Upsert(db.Book{
+		require.Nil(t, err)
+		b, err := bs.Upsert(db.Book{
 			Name:    "New book",
 			OwnerID: eb.OwnerID,
 		})
+		require.Nil(t, err)
 		books, err := bs.ListByUser(b.OwnerID)
 		require.Nil(t, err)
 		assert.Equal(t, 2, len(books))
@@ -149,7 +325,7 @@ func TestListBooksByUser(t *testing.T) {
 	})
 
 	t.Run("no books for user", func(t *testing.T) {
-		bs := db.NewBookService(testDB, nil)
+		bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
 		books, err := bs.ListByUser(uuid.New().String())
 		require.Nil(t, err)
 		assert.Empty(t, books)
@@ -159,32 +335,36 @@ func TestListBooksByUser(t *testing.T) {
 func TestSwapBook(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
-	eb := db.Book{
-		Name:    "Existing book",
-		Status:  db.Available.String(),
-		OwnerID: uuid.New().String(),
+	owner := db.CreateTestUser(t, testDB)
+	newExistingBook := func(t *testing.T, bs *db.BookService) db.Book {
+		eb, err := bs.Upsert(db.Book{
+			Name:    "Existing book",
+			OwnerID: owner.ID,
+		})
+		require.Nil(t, err)
+		return eb
 	}
 	t.Run("existing book", func(t *testing.T) {
 		ps := mocks.NewPostingService(t)
-		bs := db.NewBookService(testDB, ps)
+		bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+		eb := newExistingBook(t, bs)
 		ps.On("NewBookOrder", mock.MatchedBy(func(b db.Book) bool {
 			return b.ID == eb.ID
 		})).Return(nil).Once()
-		eb = bs.Upsert(eb)
-		newOwner := uuid.New().String()
+		newOwner := db.CreateTestUser(t, testDB).ID
 		book, err := bs.SwapBook(eb.ID, newOwner)
 		assert.NotNil(t, book)
 		assert.Nil(t, err)
 		assert.Equal(t, eb.ID, book.ID)
 		assert.Equal(t, newOwner, book.OwnerID)
-		assert.Equal(t, db.Swapped.String(), book.Status)
+		assert.Equal(t, db.InTransit, book.Status)
 		ps.AssertExpectations(t)
 	})
 
 	t.Run("unknown book", func(t *testing.T) {
 		ps := mocks.NewPostingService(t)
-		bs := db.NewBookService(testDB, ps)
-		eb = bs.Upsert(eb)
+		bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+		newExistingBook(t, bs)
 		book, err := bs.SwapBook(uuid.New().String(), uuid.New().String())
 		assert.Nil(t, book)
 		assert.NotNil(t, err)
@@ -194,7 +374,7 @@ func TestSwapBook(t *testing.T) {
 
 	t.Run("empty list", func(t *testing.T) {
 		ps := mocks.NewPostingService(t)
-		bs := db.NewBookService(testDB, ps)
+		bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
 		book, err := bs.SwapBook(uuid.New().String(), uuid.New().String())
 		assert.Nil(t, book)
 		assert.NotNil(t, err)
@@ -202,39 +382,144 @@ func TestSwapBook(t *testing.T) {
 		ps.AssertNotCalled(t, "NewBookOrder", mock.AnythingOfType("db.Book"))
 	})
 
+	t.Run("invalid id", func(t *testing.T) {
+		ps := mocks.NewPostingService(t)
+		bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+		book, err := bs.SwapBook("invalid-id", db.CreateTestUser(t, testDB).ID)
+		assert.Nil(t, book)
+		assert.ErrorIs(t, err, db.ErrRecordNotFound)
+		ps.AssertNotCalled(t, "NewBookOrder", mock.AnythingOfType("db.Book"))
+	})
+
 	t.Run("unavailable book", func(t *testing.T) {
 		ps := mocks.NewPostingService(t)
-		bs := db.NewBookService(testDB, ps)
-		eb = bs.Upsert(eb)
+		bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+		eb := newExistingBook(t, bs)
 		ps.On("NewBookOrder", mock.MatchedBy(func(b db.Book) bool {
 			return b.ID == eb.ID
 		})).Return(nil).Once()
-		newOwner := uuid.New().String()
+		newOwner := db.CreateTestUser(t, testDB).ID
 		book, err := bs.SwapBook(eb.ID, newOwner)
 		assert.NotNil(t, book)
 		assert.Nil(t, err)
 		assert.Equal(t, eb.ID, book.ID)
 		assert.Equal(t, newOwner, book.OwnerID)
-		assert.Equal(t, db.Swapped.String(), book.Status)
-		book, err = bs.SwapBook(eb.ID, uuid.New().String())
+		assert.Equal(t, db.InTransit, book.Status)
+		book, err = bs.SwapBook(eb.ID, db.CreateTestUser(t, testDB).ID)
 		assert.Nil(t, book)
 		assert.NotNil(t, err)
 		assert.Contains(t, err.Error(), "not available")
+		assert.ErrorIs(t, err, db.ErrInvalidTransition)
+		ps.AssertExpectations(t)
+	})
+
+	t.Run("own book", func(t *testing.T) {
+		ps := mocks.NewPostingService(t)
+		bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+		eb := newExistingBook(t, bs)
+		book, err := bs.SwapBook(eb.ID, owner.ID)
+		assert.Nil(t, book)
+		assert.ErrorIs(t, err, db.ErrInvalidInput)
+		ps.AssertNotCalled(t, "NewBookOrder", mock.AnythingOfType("db.Book"))
+	})
+
+	t.Run("concurrent swaps", func(t *testing.T) {
+		ps := mocks.NewPostingService(t)
+		bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+		eb := newExistingBook(t, bs)
+		ps.On("NewBookOrder", mock.AnythingOfType("db.Book")).Return(nil).Once()
+		swappers := []string{db.CreateTestUser(t, testDB).ID, db.CreateTestUser(t, testDB).ID,
+			db.CreateTestUser(t, testDB).ID}
+		errs := make(chan error, len(swappers))
+		var wg sync.WaitGroup
+		for _, userID := range swappers {
+			wg.Add(1)
+			go func(userID string) {
+				defer wg.Done()
+				_, err := bs.SwapBook(eb.ID, userID)
+				errs <- err
+			}(userID)
+		}
+		wg.Wait()
+		close(errs)
+		swapped := 0
+		for err := range errs {
+			if err == nil {
+				swapped++
+				continue
+			}
+			assert.ErrorIs(t, err, db.ErrInvalidTransition)
+		}
+		assert.Equal(t, 1, swapped)
 		ps.AssertExpectations(t)
 	})
 
 	t.Run("error posting", func(t *testing.T) {
 		postingErr := errors.New("posting error")
 		ps := mocks.NewPostingService(t)
-		bs := db.NewBookService(testDB, ps)
-		eb = bs.Upsert(eb)
+		bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+		eb := newExistingBook(t, bs)
 		ps.On("NewBookOrder", mock.MatchedBy(func(b db.Book) bool {
 			return b.ID == eb.ID
 		})).Return(postingErr).Once()
-		newOwner := uuid.New().String()
+		newOwner := db.CreateTestUser(t, testDB).ID
 		book, err := bs.SwapBook(eb.ID, newOwner)
 		assert.Nil(t, book)
 		assert.Equal(t, postingErr, err)
+		b, err := bs.Get(eb.ID)
+		require.Nil(t, err)
+		// Putting the book back on the catalogue is an update of its own.
+		b.UpdatedAt = eb.UpdatedAt
+		assert.Equal(t, eb, *b)
 		ps.AssertExpectations(t)
 	})
 }
+
+func TestBookLifecycle(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
+	newOwner := db.CreateTestUser(t, testDB)
+	ps := mocks.NewPostingService(t)
+	ps.On("NewBookOrder", mock.AnythingOfType("db.Book")).Return(nil)
+	bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+	eb, err := bs.Upsert(db.Book{
+		Name:    "Existing book",
+		OwnerID: owner.ID,
+	})
+	require.Nil(t, err)
+
+	steps := []struct {
+		name       string
+		op         func(id, userID string) (*db.Book, error)
+		userID     string
+		wantStatus db.BookStatus
+		wantErr    error
+	}{
+		{name: "withdraw by non-owner", op: bs.Withdraw, userID: newOwner.ID, wantErr: db.ErrNotOwner},
+		{name: "withdraw", op: bs.Withdraw, userID: owner.ID, wantStatus: db.Withdrawn},
+		{name: "withdraw twice", op: bs.Withdraw, userID: owner.ID, wantErr: db.ErrInvalidTransition},
+		{name: "relist withdrawn", op: bs.Relist, userID: owner.ID, wantStatus: db.Available},
+		{name: "deliver available", op: bs.ConfirmDelivery, userID: owner.ID, wantErr: db.ErrInvalidTransition},
+		{name: "swap", op: bs.SwapBook, userID: newOwner.ID, wantStatus: db.InTransit},
+		{name: "relist in transit", op: bs.Relist, userID: newOwner.ID, wantErr: db.ErrInvalidTransition},
+		{name: "deliver by previous owner", op: bs.ConfirmDelivery, userID: owner.ID, wantErr: db.ErrNotOwner},
+		{name: "deliver", op: bs.ConfirmDelivery, userID: newOwner.ID, wantStatus: db.Swapped},
+		{name: "swap swapped", op: bs.SwapBook, userID: owner.ID, wantErr: db.ErrInvalidTransition},
+		{name: "relist by previous owner", op: bs.Relist, userID: owner.ID, wantErr: db.ErrNotOwner},
+		{name: "relist swapped", op: bs.Relist, userID: newOwner.ID, wantStatus: db.Available},
+		{name: "swap relisted", op: bs.SwapBook, userID: owner.ID, wantStatus: db.InTransit},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			b, err := step.op(eb.ID, step.userID)
+			if step.wantErr != nil {
+				assert.ErrorIs(t, err, step.wantErr)
+				assert.Nil(t, b)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, step.wantStatus, b.Status)
+		})
+	}
+}
@@ -1,13 +1,144 @@
 package db
 
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
 // BooksStatus contains the different types of Book status.
 type BookStatus int
 
+// MagazineStatus contains the different types of Magazine status.
+// Magazines share the lifecycle of books.
+type MagazineStatus = BookStatus
+
 const (
 	Available BookStatus = iota
 	Swapped
+	Reserved
+	InTransit
+	Withdrawn
+	Removed
 )
 
+var bookStatusNames = [...]string{"AVAILABLE", "SWAPPED", "RESERVED", "IN_TRANSIT", "WITHDRAWN", "REMOVED"}
+
 func (o BookStatus) String() string {
-	return [...]string{"AVAILABLE", "SWAPPED"}[o]
+	if !o.valid() {
+		return fmt.Sprintf("BookStatus(%d)", int(o))
+	}
+	return bookStatusNames[o]
+}
+
+// BookStatusNames returns the names of all the statuses, in order.
+func BookStatusNames() []string {
+	return append([]string(nil), bookStatusNames[:]...)
+}
+
+// ParseBookStatus returns the status with the given name or an error if none exists.
+func ParseBookStatus(s string) (BookStatus, error) {
+	for i, name := range bookStatusNames {
+		if name == s {
+			return BookStatus(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%w %q", ErrUnknownStatus, s)
+}
+
+// MarshalText implements encoding.TextMarshaler, so statuses are marshalled by name.
+func (o BookStatus) MarshalText() ([]byte, error) {
+	if !o.valid() {
+		return nil, fmt.Errorf("%w %d", ErrUnknownStatus, int(o))
+	}
+	return []byte(o.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (o *BookStatus) UnmarshalText(text []byte) error {
+	s, err := ParseBookStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*o = s
+	return nil
+}
+
+// Value implements driver.Valuer, so statuses are stored by name.
+func (o BookStatus) Value() (driver.Value, error) {
+	if !o.valid() {
+		return nil, fmt.Errorf("%w %d", ErrUnknownStatus, int(o))
+	}
+	return o.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (o *BookStatus) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return o.UnmarshalText([]byte(v))
+	case []byte:
+		return o.UnmarshalText(v)
+	default:
+		return fmt.Errorf("%w of type %T", ErrUnknownStatus, src)
+	}
+}
+
+func (o BookStatus) valid() bool {
+	return o >= 0 && int(o) < len(bookStatusNames)
+}
+
+// transitions contains the statuses that each status can legally move to.
+// It is the single source of truth for the lifecycle of books and magazines.
+// Admins remove items which are not in transit, and removed items never come back.
+var transitions = map[BookStatus][]BookStatus{
+	// Available items can be held, swapped or taken off the catalogue by their owner.
+	Available: {Reserved, InTransit, Withdrawn, Removed},
+	// Reserved items are released, swapped by the holder or withdrawn by their owner.
+	Reserved: {Available, InTransit, Withdrawn, Removed},
+	// Items in transit are delivered to their new owner or, if posting fails, go back on the catalogue.
+	InTransit: {Swapped, Available},
+	// Swapped items can be re-listed by their new owner.
+	Swapped: {Available, Removed},
+	// Withdrawn items can be re-listed by their owner.
+	Withdrawn: {Available, Removed},
+}
+
+// CanTransitionTo returns whether an item with this status can move to the next status.
+func (o BookStatus) CanTransitionTo(next BookStatus) bool {
+	for _, s := range transitions[o] {
+		if s == next {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransition returns an error if an item with the given status cannot move to the next status.
+func checkTransition(from, next BookStatus) error {
+	if !from.CanTransitionTo(next) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, from, next)
+	}
+	return nil
+}
+
+// lockUnchanged locks the row of an item until the end of a transaction and returns ErrInvalidTransition
+// if its status, owner or flag changed since it was read. Concurrent changes of an item, such as two users
+// swapping it at once, are then applied one at a time, and the ones which started from a stale copy fail.
+func lockUnchanged(tx *gorm.DB, table, id string, status BookStatus, ownerID string, flagged bool) error {
+	var current struct {
+		Status  BookStatus
+		OwnerID string
+		Flagged bool
+	}
+	if r := tx.Table(table).Clauses(clause.Locking{Strength: "UPDATE"}).Select("status", "owner_id", "flagged").
+		Where("id = ?", id).Take(&current); r.Error != nil {
+		return r.Error
+	}
+	if current.Status != status || current.OwnerID != ownerID || current.Flagged != flagged {
+		return fmt.Errorf("%w: item %s changed while it was being updated", ErrInvalidTransition, id)
+	}
+	return nil
 }
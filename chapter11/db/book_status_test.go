@@ -0,0 +1,110 @@
+package db_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBookStatus(t *testing.T) {
+	tests := map[string]struct {
+		input   string
+		want    db.BookStatus
+		wantErr error
+	}{
+		"available":  {input: "AVAILABLE", want: db.Available},
+		"reserved":   {input: "RESERVED", want: db.Reserved},
+		"in transit": {input: "IN_TRANSIT", want: db.InTransit},
+		"swapped":    {input: "SWAPPED", want: db.Swapped},
+		"withdrawn":  {input: "WITHDRAWN", want: db.Withdrawn},
+		"removed":    {input: "REMOVED", want: db.Removed},
+		"lower case": {input: "available", wantErr: db.ErrUnknownStatus},
+		"unknown":    {input: "LOST", wantErr: db.ErrUnknownStatus},
+		"empty":      {input: "", wantErr: db.ErrUnknownStatus},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := db.ParseBookStatus(tc.input)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.input, got.String())
+		})
+	}
+}
+
+func TestBookStatusOutOfRange(t *testing.T) {
+	for _, s := range []db.BookStatus{-1, 6, 99} {
+		t.Run(fmt.Sprint(int(s)), func(t *testing.T) {
+			assert.NotPanics(t, func() {
+				assert.Equal(t, fmt.Sprintf("BookStatus(%d)", int(s)), s.String())
+			})
+			_, err := s.MarshalText()
+			assert.ErrorIs(t, err, db.ErrUnknownStatus)
+			_, err = s.Value()
+			assert.ErrorIs(t, err, db.ErrUnknownStatus)
+		})
+	}
+}
+
+func TestBookStatusMarshalling(t *testing.T) {
+	t.Run("json round trip", func(t *testing.T) {
+		b := db.Book{Name: "Book", Status: db.InTransit}
+		body, err := json.Marshal(b)
+		require.Nil(t, err)
+		assert.Contains(t, string(body), `"status":"IN_TRANSIT"`)
+		var got db.Book
+		require.Nil(t, json.Unmarshal(body, &got))
+		assert.Equal(t, b, got)
+	})
+	t.Run("json unknown status", func(t *testing.T) {
+		var m db.Magazine
+		err := json.Unmarshal([]byte(`{"status":"LOST"}`), &m)
+		assert.ErrorIs(t, err, db.ErrUnknownStatus)
+	})
+	t.Run("sql round trip", func(t *testing.T) {
+		v, err := db.Withdrawn.Value()
+		require.Nil(t, err)
+		assert.Equal(t, "WITHDRAWN", v)
+		var s db.BookStatus
+		require.Nil(t, s.Scan(v))
+		assert.Equal(t, db.Withdrawn, s)
+		require.Nil(t, s.Scan([]byte("SWAPPED")))
+		assert.Equal(t, db.Swapped, s)
+	})
+	t.Run("sql unknown status", func(t *testing.T) {
+		var s db.BookStatus
+		assert.ErrorIs(t, s.Scan("LOST"), db.ErrUnknownStatus)
+		assert.ErrorIs(t, s.Scan(42), db.ErrUnknownStatus)
+		assert.ErrorIs(t, s.Scan(nil), db.ErrUnknownStatus)
+	})
+}
+
+func TestBookStatusTransitions(t *testing.T) {
+	statuses := []db.BookStatus{db.Available, db.Reserved, db.InTransit, db.Swapped, db.Withdrawn, db.Removed}
+	legal := map[db.BookStatus][]db.BookStatus{
+		db.Available: {db.Reserved, db.InTransit, db.Withdrawn, db.Removed},
+		db.Reserved:  {db.Available, db.InTransit, db.Withdrawn, db.Removed},
+		db.InTransit: {db.Swapped, db.Available},
+		db.Swapped:   {db.Available, db.Removed},
+		db.Withdrawn: {db.Available, db.Removed},
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := false
+			for _, s := range legal[from] {
+				want = want || s == to
+			}
+			t.Run(fmt.Sprintf("%s to %s", from, to), func(t *testing.T) {
+				assert.Equal(t, want, from.CanTransitionTo(to))
+			})
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache interface wraps around a key/value cache of encoded values, such as the in-memory LRUCache
+// or a cache shared between instances of the application.
+type Cache interface {
+	// Get returns the value cached under a key, unless it is missing or has expired.
+	Get(key string) ([]byte, bool)
+	// Set caches a value under a key, replacing any value cached under it.
+	Set(key string, value []byte)
+	// Delete removes the values cached under the given keys, if there are any.
+	Delete(keys ...string)
+}
+
+// LRUCache is an in-memory Cache which holds a limited number of values,
+// evicting the least recently used value to make room for new ones.
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries *list.List
+	index   map[string]*list.Element
+	clock   Clock
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUCache initialises an LRUCache holding at most size values, which expire ttl after they are set
+// by the given clock. Values never expire if the ttl is not positive.
+func NewLRUCache(size int, ttl time.Duration, clock Clock) *LRUCache {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &LRUCache{
+		size:    size,
+		ttl:     ttl,
+		entries: list.New(),
+		index:   make(map[string]*list.Element, size),
+		clock:   clock,
+	}
+}
+
+// Get returns a cached value and marks it as the most recently used. Expired values are removed.
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.index[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if c.ttl > 0 && !c.clock.Now().Before(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.entries.MoveToFront(el)
+	return entry.value, true
+}
+
+// Set caches a value as the most recently used, evicting the least recently used value if the cache is full.
+func (c *LRUCache) Set(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.clock.Now().Add(c.ttl)
+	if el, ok := c.index[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		c.entries.MoveToFront(el)
+		return
+	}
+	c.index[key] = c.entries.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.entries.Len() > c.size {
+		c.remove(c.entries.Back())
+	}
+}
+
+// Delete removes cached values.
+func (c *LRUCache) Delete(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := c.index[key]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+// Len returns the number of cached values, including those which have expired but have not been removed yet.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	c.entries.Remove(el)
+	delete(c.index, el.Value.(*lruEntry).key)
+}
+
+// CacheStats counts the reads of a service which were served by its cache.
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// readThrough reads values through a Cache, loading and caching them on a miss.
+// Values are cached as JSON, so that every read returns a copy which callers are free to change.
+type readThrough struct {
+	cache  Cache
+	hits   atomic.Int64
+	misses atomic.Int64
+
+	mu    sync.Mutex
+	loads map[string]*pendingLoad
+}
+
+// pendingLoad counts the loads of a key which are in progress and the invalidations of the key
+// since the first of them started. Values loaded before an invalidation are not cached.
+type pendingLoad struct {
+	loading    int
+	generation uint64
+}
+
+// newReadThrough returns a readThrough for the given cache, or nil if there is no cache.
+func newReadThrough(c Cache) *readThrough {
+	if c == nil {
+		return nil
+	}
+	return &readThrough{cache: c, loads: make(map[string]*pendingLoad)}
+}
+
+// stats returns the hits and misses of the cache so far.
+func (rt *readThrough) stats() CacheStats {
+	if rt == nil {
+		return CacheStats{}
+	}
+	return CacheStats{Hits: rt.hits.Load(), Misses: rt.misses.Load()}
+}
+
+// invalidate removes the values which a change has made stale.
+// Loads of the keys which are in progress will not cache the values they load, as they may be stale too.
+func (rt *readThrough) invalidate(keys ...string) {
+	if rt == nil {
+		return
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for _, key := range keys {
+		if l, ok := rt.loads[key]; ok {
+			l.generation++
+		}
+	}
+	rt.cache.Delete(keys...)
+}
+
+// startLoad registers a load of a key and returns the generation of the key it starts at.
+func (rt *readThrough) startLoad(key string) uint64 {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	l, ok := rt.loads[key]
+	if !ok {
+		l = &pendingLoad{}
+		rt.loads[key] = l
+	}
+	l.loading++
+	return l.generation
+}
+
+// finishLoad caches a loaded value, unless the key has been invalidated since the load started.
+// A nil value is not cached.
+func (rt *readThrough) finishLoad(key string, generation uint64, data []byte) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	l := rt.loads[key]
+	if data != nil && l.generation == generation {
+		rt.cache.Set(key, data)
+	}
+	if l.loading--; l.loading == 0 {
+		delete(rt.loads, key)
+	}
+}
+
+// cached returns the value cached under a key, or loads and caches it. Errors are never cached.
+// Values are always loaded when there is no cache.
+func cached[T any](rt *readThrough, key string, load func() (T, error)) (T, error) {
+	if rt == nil {
+		return load()
+	}
+	if data, ok := rt.cache.Get(key); ok {
+		var v T
+		if err := json.Unmarshal(data, &v); err == nil {
+			rt.hits.Add(1)
+			return v, nil
+		}
+	}
+	rt.misses.Add(1)
+	generation := rt.startLoad(key)
+	v, err := load()
+	var data []byte
+	if err == nil {
+		data, _ = json.Marshal(v)
+	}
+	rt.finishLoad(key, generation, data)
+	return v, err
+}
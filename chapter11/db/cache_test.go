@@ -0,0 +1,255 @@
+package db_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestLRUCache(t *testing.T) {
+	t.Run("evicts the least recently used", func(t *testing.T) {
+		// Arrange
+		c := db.NewLRUCache(2, time.Minute, nil)
+		c.Set("a", []byte("1"))
+		c.Set("b", []byte("2"))
+		_, ok := c.Get("a")
+		require.True(t, ok)
+
+		// Act
+		c.Set("c", []byte("3"))
+
+		// Assert
+		assert.Equal(t, 2, c.Len())
+		_, ok = c.Get("b")
+		assert.False(t, ok)
+		got, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), got)
+		got, ok = c.Get("c")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("3"), got)
+	})
+
+	t.Run("replaces values", func(t *testing.T) {
+		c := db.NewLRUCache(2, time.Minute, nil)
+		c.Set("a", []byte("1"))
+		c.Set("a", []byte("2"))
+		got, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("2"), got)
+		assert.Equal(t, 1, c.Len())
+	})
+
+	t.Run("deletes values", func(t *testing.T) {
+		c := db.NewLRUCache(3, time.Minute, nil)
+		c.Set("a", []byte("1"))
+		c.Set("b", []byte("2"))
+		c.Delete("a", "b", "unknown")
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("expires values", func(t *testing.T) {
+		clock := db.NewFakeClock(time.Now())
+		c := db.NewLRUCache(2, time.Minute, clock)
+		c.Set("a", []byte("1"))
+		clock.Advance(59 * time.Second)
+		_, ok := c.Get("a")
+		require.True(t, ok)
+		clock.Advance(time.Second)
+		_, ok = c.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("never expires without ttl", func(t *testing.T) {
+		clock := db.NewFakeClock(time.Now())
+		c := db.NewLRUCache(2, 0, clock)
+		c.Set("a", []byte("1"))
+		clock.Advance(24 * time.Hour)
+		_, ok := c.Get("a")
+		assert.True(t, ok)
+	})
+}
+
+func TestBookCache(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	ps := mocks.NewPostingService(t)
+	ps.On("NewBookOrder", mock.AnythingOfType("db.Book")).Return(nil).Once()
+	bs := db.NewBookService(testDB, ps, nil, nil, nil, nil).WithCache(db.NewLRUCache(10, time.Minute, nil))
+	owner := db.CreateTestUser(t, testDB)
+	swapper := db.CreateTestUser(t, testDB)
+	book, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+	require.Nil(t, err)
+
+	t.Run("get", func(t *testing.T) {
+		// Act
+		first, err := bs.Get(book.ID)
+		require.Nil(t, err)
+		first.Name = "Changed by the caller"
+		second, err := bs.Get(book.ID)
+
+		// Assert
+		require.Nil(t, err)
+		assert.Equal(t, book, *second)
+		assert.Equal(t, db.CacheStats{Hits: 1, Misses: 1}, bs.CacheStats())
+	})
+
+	t.Run("list", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			books, err := bs.List()
+			require.Nil(t, err)
+			assert.Contains(t, books, book)
+		}
+		assert.Equal(t, db.CacheStats{Hits: 2, Misses: 2}, bs.CacheStats())
+	})
+
+	t.Run("invalidated on upsert", func(t *testing.T) {
+		book.Name = "Dune Messiah"
+		_, err := bs.Upsert(book)
+		require.Nil(t, err)
+		got, err := bs.Get(book.ID)
+		require.Nil(t, err)
+		assert.Equal(t, "Dune Messiah", got.Name)
+		books, err := bs.List()
+		require.Nil(t, err)
+		assert.Contains(t, books, book)
+	})
+
+	t.Run("invalidated on swap", func(t *testing.T) {
+		_, err := bs.SwapBook(book.ID, swapper.ID)
+		require.Nil(t, err)
+		got, err := bs.Get(book.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.InTransit, got.Status)
+		books, err := bs.List()
+		require.Nil(t, err)
+		assert.NotContains(t, books, book)
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		stats := bs.CacheStats()
+		for i := 0; i < 2; i++ {
+			_, err := bs.Get("invalid-id")
+			assert.NotNil(t, err)
+		}
+		assert.Equal(t, stats.Misses+2, bs.CacheStats().Misses)
+	})
+
+	t.Run("loads overlapping an invalidation are not cached", func(t *testing.T) {
+		loaded, err := bs.Upsert(db.Book{Name: "Emma", OwnerID: owner.ID})
+		require.Nil(t, err)
+		renamed := loaded
+		renamed.Name = "Persuasion"
+		// The book is renamed once it has been read, but before the read is cached.
+		armed := true
+		name := "rename_after_query"
+		require.Nil(t, testDB.Callback().Query().After("gorm:query").Register(name, func(*gorm.DB) {
+			if armed {
+				armed = false
+				_, err := bs.Upsert(renamed)
+				require.Nil(t, err)
+			}
+		}))
+		stale, err := bs.Get(loaded.ID)
+		require.Nil(t, err)
+		require.Nil(t, testDB.Callback().Query().Remove(name))
+		assert.Equal(t, "Emma", stale.Name)
+
+		// Act
+		got, err := bs.Get(loaded.ID)
+
+		// Assert
+		require.Nil(t, err)
+		assert.Equal(t, "Persuasion", got.Name)
+	})
+}
+
+func TestMagazineCache(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil).WithCache(db.NewLRUCache(10, time.Minute, nil))
+	owner := db.CreateTestUser(t, testDB)
+	mag, err := ms.Upsert(db.Magazine{Name: "Wired", IssueNumber: 7, OwnerID: owner.ID})
+	require.Nil(t, err)
+	_, err = ms.Get(mag.ID)
+	require.Nil(t, err)
+	_, err = ms.List()
+	require.Nil(t, err)
+
+	// Act
+	_, err = ms.Withdraw(mag.ID, owner.ID)
+
+	// Assert
+	require.Nil(t, err)
+	got, err := ms.Get(mag.ID)
+	require.Nil(t, err)
+	assert.Equal(t, db.Withdrawn, got.Status)
+	mags, err := ms.List()
+	require.Nil(t, err)
+	assert.NotContains(t, mags, mag)
+	assert.Equal(t, db.CacheStats{Misses: 4}, ms.CacheStats())
+}
+
+func TestSharedCache(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	// Shared caches may fail, in which case reads fall through to the database.
+	c := mocks.NewCache(t)
+	c.On("Get", "books:available").Return(nil, false).Once()
+	c.On("Set", "books:available", mock.AnythingOfType("[]uint8")).Once()
+	c.On("Get", "books:available").Return([]byte("not json"), true).Once()
+	c.On("Set", "books:available", mock.AnythingOfType("[]uint8")).Once()
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil).WithCache(c)
+
+	for i := 0; i < 2; i++ {
+		_, err := bs.List()
+		require.Nil(t, err)
+	}
+	assert.Equal(t, db.CacheStats{Misses: 2}, bs.CacheStats())
+}
+
+// BenchmarkListBooks compares reading the available books from the database with reading them through a cache.
+func BenchmarkListBooks(b *testing.B) {
+	testDB, cleaner := db.OpenDB(b)
+	defer cleaner()
+	owner := db.CreateTestUser(b, testDB)
+	seed := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	for i := 0; i < 100; i++ {
+		_, err := seed.Upsert(db.Book{Name: fmt.Sprintf("Book %d", i), OwnerID: owner.ID})
+		require.Nil(b, err)
+	}
+	benchmarks := map[string]*db.BookService{
+		"uncached": db.NewBookService(testDB, nil, nil, nil, nil, nil),
+		"cached":   db.NewBookService(testDB, nil, nil, nil, nil, nil).WithCache(db.NewLRUCache(10, time.Minute, nil)),
+	}
+	for name, bs := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := bs.List(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLRUCache(b *testing.B) {
+	c := db.NewLRUCache(1000, time.Minute, nil)
+	value := []byte(`{"id":"1","name":"Dune"}`)
+	for i := 0; i < b.N; i++ {
+		key := fmt.Sprint(i % 2000)
+		if _, ok := c.Get(key); !ok {
+			c.Set(key, value)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package db
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of items read at a time when exporting the catalogue.
+const exportBatchSize = 500
+
+// The lengths, in characters, of the longest names, authors, publishers and editions items can be stored with.
+const (
+	maxNameLength      = 50
+	maxAuthorLength    = 50
+	maxPublisherLength = 255
+	maxEditionLength   = 255
+)
+
+// CatalogueItem is a book or magazine, as it is imported and exported in bulk.
+type CatalogueItem struct {
+	ItemType    ItemType      `json:"item_type"`
+	ID          string        `json:"id,omitempty"`
+	Name        string        `json:"name"`
+	Author      string        `json:"author,omitempty"`
+	ISBN        string        `json:"isbn,omitempty"`
+	Publisher   string        `json:"publisher,omitempty"`
+	Year        int           `json:"year,omitempty"`
+	Edition     string        `json:"edition,omitempty"`
+	IssueNumber int           `json:"issue_number,omitempty"`
+	Condition   ItemCondition `json:"condition,omitempty"`
+	Language    string        `json:"language,omitempty"`
+	Tags        Tags          `json:"tags,omitempty"`
+	OwnerID     string        `json:"owner_id"`
+	Status      BookStatus    `json:"status"`
+}
+
+// ImportRow is a row of a bulk import, numbered as in the imported file.
+// Rows which could not be read carry their error.
+type ImportRow struct {
+	Row  int
+	Item CatalogueItem
+	Err  error
+}
+
+// ImportResult contains the outcome of importing a row.
+type ImportResult struct {
+	Row      int      `json:"row"`
+	ItemType ItemType `json:"item_type,omitempty"`
+	ItemID   string   `json:"item_id,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// CatalogueService contains the functionality for importing and exporting
+// the books and magazines of the catalogue in bulk.
+type CatalogueService struct {
+	DB *gorm.DB
+	bs *BookService
+	ms *MagazineService
+}
+
+// NewCatalogueService initialises a CatalogueService given its dependencies.
+func NewCatalogueService(db *gorm.DB, bs *BookService, ms *MagazineService,
+	clock Clock, ids IDGenerator) *CatalogueService {
+	return &CatalogueService{
+		DB: withClock(db, clock, ids),
+		bs: bs,
+		ms: ms,
+	}
+}
+
+// InCommunity returns a copy of the service which only imports and exports the items of a community.
+func (cs *CatalogueService) InCommunity(communityID string) *CatalogueService {
+	c := *cs
+	c.DB = InCommunity(cs.DB, communityID)
+	c.bs = cs.bs.InCommunity(communityID)
+	c.ms = cs.ms.InCommunity(communityID)
+	return &c
+}
+
+// Import validates a batch of rows and creates their items in a single transaction,
+// or only validates them if dryRun is set. Invalid rows are reported and left out.
+// If the transaction fails, none of the batch is imported and every valid row reports the error.
+// Items are created available to swap, whatever ID or status they are given,
+// and are recorded in their history like any other new item. Books are filled in
+// from the metadata of their ISBN like books created one at a time, before they are validated,
+// so books may be imported by their ISBN alone. Lookups are made outside the transaction.
+func (cs *CatalogueService) Import(rows []ImportRow, dryRun bool) []ImportResult {
+	results := make([]ImportResult, len(rows))
+	owners, err := cs.owners(rows)
+	for i, r := range rows {
+		results[i] = ImportResult{Row: r.Row, ItemType: r.Item.ItemType}
+		switch {
+		case r.Err != nil:
+			results[i].Error = r.Err.Error()
+		case err != nil:
+			results[i].Error = err.Error()
+		default:
+			if verr := cs.prepare(&rows[i].Item, owners); verr != nil {
+				results[i].Error = verr.Error()
+			}
+		}
+	}
+	if dryRun {
+		return results
+	}
+
+	var events []ItemEvent
+	if err := cs.DB.Transaction(func(tx *gorm.DB) error {
+		for i, r := range rows {
+			if results[i].Error != "" {
+				continue
+			}
+			e, err := cs.create(tx, r.Item)
+			if err != nil {
+				return err
+			}
+			results[i].ItemID = e.ItemID
+			events = append(events, e)
+		}
+		return nil
+	}); err != nil {
+		for i := range results {
+			if results[i].Error == "" {
+				results[i].ItemID = ""
+				results[i].Error = fmt.Sprintf("batch not imported:%v", err)
+			}
+		}
+		return results
+	}
+	for _, e := range events {
+		if e.ItemType == BookItem {
+			cs.bs.publish(e)
+		} else {
+			cs.ms.publish(e)
+		}
+	}
+
+	return results
+}
+
+// create creates an imported item and records its creation.
+func (cs *CatalogueService) create(tx *gorm.DB, item CatalogueItem) (ItemEvent, error) {
+	if item.ItemType == BookItem {
+		b := Book{
+			ID:        idsOf(tx).NewID(),
+			Name:      item.Name,
+			Author:    item.Author,
+			ISBN:      item.ISBN,
+			Publisher: item.Publisher,
+			Year:      item.Year,
+			Edition:   item.Edition,
+			Condition: item.Condition,
+			Language:  item.Language,
+			Tags:      item.Tags,
+			OwnerID:   item.OwnerID,
+			Status:    Available,
+		}
+		if r := tx.Create(&b); r.Error != nil {
+			return ItemEvent{}, r.Error
+		}
+		e := bookEvent(b, ItemCreated, b.OwnerID)
+		return e, cs.bs.record(tx, b, &e)
+	}
+	m := Magazine{
+		ID:          idsOf(tx).NewID(),
+		Name:        item.Name,
+		IssueNumber: item.IssueNumber,
+		Condition:   item.Condition,
+		Language:    item.Language,
+		Tags:        item.Tags,
+		OwnerID:     item.OwnerID,
+		Status:      Available,
+	}
+	if r := tx.Create(&m); r.Error != nil {
+		return ItemEvent{}, r.Error
+	}
+	e := magazineEvent(m, ItemCreated, m.OwnerID)
+	return e, cs.ms.record(tx, m, &e)
+}
+
+// owners returns which of the owners of a batch of rows exist and can list items, in a single query.
+// Suspended owners are left out.
+func (cs *CatalogueService) owners(rows []ImportRow) (map[string]bool, error) {
+	ids := make([]string, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, r.Item.OwnerID)
+	}
+	owners := map[string]bool{}
+	ids = validIDs(ids)
+	if len(ids) == 0 {
+		return owners, nil
+	}
+	var found []string
+	if r := cs.DB.Model(&User{}).Where("id IN ? AND NOT suspended", ids).Pluck("id", &found); r.Error != nil {
+		return nil, r.Error
+	}
+	for _, id := range found {
+		owners[id] = true
+	}
+	return owners, nil
+}
+
+// prepare normalizes an imported item, fills in a book from the metadata of its ISBN and validates the item.
+func (cs *CatalogueService) prepare(item *CatalogueItem, owners map[string]bool) error {
+	if err := normalizeCatalogueItem(item); err != nil {
+		return err
+	}
+	if item.ItemType == BookItem {
+		b := Book{Name: item.Name, Author: item.Author, ISBN: item.ISBN, Publisher: item.Publisher, Year: item.Year}
+		if err := cs.bs.enrich(&b); err != nil {
+			return err
+		}
+		item.Name, item.Author, item.Publisher, item.Year = b.Name, b.Author, b.Publisher, b.Year
+	}
+	return validateCatalogueItem(*item, owners)
+}
+
+// normalizeCatalogueItem normalizes the ISBN, condition, language and tags of an imported item.
+func normalizeCatalogueItem(item *CatalogueItem) error {
+	if item.ISBN != "" {
+		isbn, err := NormalizeISBN(item.ISBN)
+		if err != nil {
+			return err
+		}
+		item.ISBN = isbn
+	}
+	return normalizeDetails(&item.Condition, &item.Language, &item.Tags)
+}
+
+// validateCatalogueItem checks that an imported item is complete, fits its columns and is owned by an active user.
+// Items which would not fit are reported on their own row, rather than failing the insert of the whole batch.
+func validateCatalogueItem(item CatalogueItem, owners map[string]bool) error {
+	switch {
+	case item.ItemType != BookItem && item.ItemType != MagazineItem:
+		return fmt.Errorf("%w: unknown item type %q", ErrInvalidInput, item.ItemType)
+	case item.Name == "":
+		return fmt.Errorf("%w: items need a name", ErrInvalidInput)
+	case utf8.RuneCountInString(item.Name) > maxNameLength:
+		return fmt.Errorf("%w: names are at most %d characters", ErrInvalidInput, maxNameLength)
+	case utf8.RuneCountInString(item.Author) > maxAuthorLength:
+		return fmt.Errorf("%w: authors are at most %d characters", ErrInvalidInput, maxAuthorLength)
+	case utf8.RuneCountInString(item.Publisher) > maxPublisherLength:
+		return fmt.Errorf("%w: publishers are at most %d characters", ErrInvalidInput, maxPublisherLength)
+	case utf8.RuneCountInString(item.Edition) > maxEditionLength:
+		return fmt.Errorf("%w: editions are at most %d characters", ErrInvalidInput, maxEditionLength)
+	case item.ItemType == MagazineItem && item.Author != "":
+		return fmt.Errorf("%w: magazines cannot have an author", ErrInvalidInput)
+	case item.ItemType == BookItem && item.IssueNumber != 0:
+		return fmt.Errorf("%w: books cannot have an issue number", ErrInvalidInput)
+	case item.ItemType == MagazineItem && (item.ISBN != "" || item.Publisher != "" || item.Year != 0 ||
+		item.Edition != ""):
+		return fmt.Errorf("%w: magazines cannot have an isbn, publisher, year or edition", ErrInvalidInput)
+	case item.IssueNumber < 0:
+		return fmt.Errorf("%w: invalid issue number %d", ErrInvalidInput, item.IssueNumber)
+	case !owners[item.OwnerID]:
+		return fmt.Errorf("%w: no active user found for owner id %q", ErrInvalidInput, item.OwnerID)
+	}
+	return nil
+}
+
+// Export calls fn with every book and then every magazine of the catalogue, whatever their status,
+// or only with the items of the given type if it is not empty. Items are read in batches,
+// so the catalogue is never held in memory. Export stops at the first error returned by fn.
+func (cs *CatalogueService) Export(itemType ItemType, fn func(CatalogueItem) error) error {
+	if itemType == "" || itemType == BookItem {
+		var books []Book
+		if r := cs.DB.FindInBatches(&books, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, b := range books {
+				if err := fn(CatalogueItem{ItemType: BookItem, ID: b.ID, Name: b.Name, Author: b.Author,
+					ISBN: b.ISBN, Publisher: b.Publisher, Year: b.Year, Edition: b.Edition,
+					Condition: b.Condition, Language: b.Language, Tags: b.Tags, OwnerID: b.OwnerID,
+					Status: b.Status}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); r.Error != nil {
+			return r.Error
+		}
+	}
+	if itemType == "" || itemType == MagazineItem {
+		var mags []Magazine
+		if r := cs.DB.FindInBatches(&mags, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, m := range mags {
+				if err := fn(CatalogueItem{ItemType: MagazineItem, ID: m.ID, Name: m.Name,
+					IssueNumber: m.IssueNumber, Condition: m.Condition, Language: m.Language, Tags: m.Tags,
+					OwnerID: m.OwnerID, Status: m.Status}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); r.Error != nil {
+			return r.Error
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package db_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImport(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	cs := db.NewCatalogueService(testDB, bs, ms, nil, nil)
+	owner := db.CreateTestUser(t, testDB)
+	rows := []db.ImportRow{
+		{Row: 2, Item: db.CatalogueItem{ItemType: db.BookItem, Name: "Dune", Author: "Frank Herbert", ISBN: "0-441-17271-7",
+			OwnerID: owner.ID}},
+		{Row: 3, Item: db.CatalogueItem{ItemType: db.MagazineItem, Name: "Wired", IssueNumber: 7, OwnerID: owner.ID,
+			Status: db.Swapped, Condition: "fair", Language: "en", Tags: db.Tags{"Tech"}}},
+		{Row: 4, Item: db.CatalogueItem{ItemType: db.BookItem, OwnerID: owner.ID}},
+		{Row: 5, Item: db.CatalogueItem{ItemType: db.MagazineItem, Name: "Wired", Author: "Someone", OwnerID: owner.ID}},
+		{Row: 6, Item: db.CatalogueItem{ItemType: db.BookItem, Name: "Emma", OwnerID: "unknown"}},
+		{Row: 7, Item: db.CatalogueItem{ItemType: "SHELF", Name: "Emma", OwnerID: owner.ID}},
+		{Row: 8, Err: errors.New("invalid row")},
+		{Row: 9, Item: db.CatalogueItem{ItemType: db.BookItem, Name: "Emma", ISBN: "12345", OwnerID: owner.ID}},
+		{Row: 10, Item: db.CatalogueItem{ItemType: db.MagazineItem, Name: "Wired", ISBN: "9780441172719",
+			OwnerID: owner.ID}},
+		{Row: 11, Item: db.CatalogueItem{ItemType: db.MagazineItem, Name: "Wired", Edition: "First",
+			OwnerID: owner.ID}},
+		{Row: 12, Item: db.CatalogueItem{ItemType: db.BookItem, Name: "Emma", Condition: "MINT", OwnerID: owner.ID}},
+		{Row: 13, Item: db.CatalogueItem{ItemType: db.BookItem, Name: strings.Repeat("é", 51), OwnerID: owner.ID}},
+		{Row: 14, Item: db.CatalogueItem{ItemType: db.BookItem, Name: "Emma", Author: strings.Repeat("a", 51),
+			OwnerID: owner.ID}},
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		results := cs.Import(rows, true)
+
+		require.Equal(t, len(rows), len(results))
+		for i, res := range results {
+			assert.Equal(t, rows[i].Row, res.Row)
+			assert.Empty(t, res.ItemID)
+		}
+		assert.Empty(t, results[0].Error)
+		assert.Empty(t, results[1].Error)
+		for _, res := range results[2:] {
+			assert.NotEmpty(t, res.Error, "row %d", res.Row)
+		}
+		books, err := bs.ListByUser(owner.ID)
+		require.Nil(t, err)
+		assert.Empty(t, books)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		results := cs.Import(rows, false)
+
+		require.Equal(t, len(rows), len(results))
+		book, err := bs.Get(results[0].ItemID)
+		require.Nil(t, err)
+		assert.Equal(t, "Dune", book.Name)
+		assert.Equal(t, "9780441172719", book.ISBN)
+		assert.Equal(t, db.Available, book.Status)
+		mag, err := ms.Get(results[1].ItemID)
+		require.Nil(t, err)
+		assert.Equal(t, 7, mag.IssueNumber)
+		assert.Equal(t, db.Available, mag.Status)
+		assert.Equal(t, db.ConditionFair, mag.Condition)
+		assert.Equal(t, "en", mag.Language)
+		assert.Equal(t, db.Tags{"tech"}, mag.Tags)
+		for _, res := range results[2:] {
+			assert.Empty(t, res.ItemID)
+			assert.NotEmpty(t, res.Error, "row %d", res.Row)
+		}
+		history, err := db.NewHistoryService(testDB, nil, nil).ListByItem(db.BookItem, book.ID)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(history))
+		assert.Equal(t, db.ItemCreated, history[0].Type)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		var items []db.CatalogueItem
+		err := cs.Export(db.MagazineItem, func(item db.CatalogueItem) error {
+			items = append(items, item)
+			return nil
+		})
+
+		require.Nil(t, err)
+		require.Equal(t, 1, len(items))
+		assert.Equal(t, "Wired", items[0].Name)
+		assert.Equal(t, db.MagazineItem, items[0].ItemType)
+		assert.Equal(t, db.Tags{"tech"}, items[0].Tags)
+
+		stop := errors.New("stop")
+		err = cs.Export("", func(item db.CatalogueItem) error { return stop })
+		assert.ErrorIs(t, err, stop)
+	})
+}
+
+func TestImportByISBN(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	md := mocks.NewMetadataProvider(t)
+	md.On("Lookup", "9780441172719").Return(&db.BookMetadata{
+		ISBN:      "9780441172719",
+		Title:     "Dune",
+		Author:    "Frank Herbert",
+		Publisher: "Ace Books",
+		Year:      1990,
+	}, nil).Once()
+	md.On("Lookup", "9780141439587").Return(nil, errors.New("catalogue unavailable")).Once()
+	md.On("Lookup", "9780141439518").Return(&db.BookMetadata{
+		ISBN:  "9780141439518",
+		Title: "Pride and Prejudice, Being the Complete and Unabridged Text",
+	}, nil).Once()
+	bs := db.NewBookService(testDB, nil, nil, md, nil, nil)
+	cs := db.NewCatalogueService(testDB, bs, db.NewMagazineService(testDB, nil, nil, nil, nil), nil, nil)
+	owner := db.CreateTestUser(t, testDB)
+	rows := []db.ImportRow{
+		{Row: 2, Item: db.CatalogueItem{ItemType: db.BookItem, ISBN: "0-441-17271-7", OwnerID: owner.ID}},
+		{Row: 3, Item: db.CatalogueItem{ItemType: db.BookItem, Name: "Emma", ISBN: "9780141439587",
+			OwnerID: owner.ID}},
+		{Row: 4, Item: db.CatalogueItem{ItemType: db.BookItem, ISBN: "9780141439518", OwnerID: owner.ID}},
+	}
+
+	results := cs.Import(rows, false)
+
+	require.Equal(t, len(rows), len(results))
+	require.Empty(t, results[0].Error)
+	book, err := bs.Get(results[0].ItemID)
+	require.Nil(t, err)
+	assert.Equal(t, "Dune", book.Name)
+	assert.Equal(t, "Frank Herbert", book.Author)
+	assert.Equal(t, "Ace Books", book.Publisher)
+	assert.Equal(t, 1990, book.Year)
+	// A failed lookup only fails its own row.
+	assert.Empty(t, results[1].ItemID)
+	assert.Contains(t, results[1].Error, "catalogue unavailable")
+	// So does a title from the metadata which is too long to store.
+	assert.Empty(t, results[2].ItemID)
+	assert.Contains(t, results[2].Error, "names are at most 50 characters")
+}
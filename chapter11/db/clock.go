@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Clock interface wraps around the current time, so that tests can control the passing of time.
+// Services which are given a nil Clock use the SystemClock.
+type Clock interface {
+	Now() time.Time
+}
+
+// IDGenerator interface wraps around the generation of record IDs, which must be valid UUIDs.
+// Services which are given a nil IDGenerator use the UUIDGenerator.
+type IDGenerator interface {
+	NewID() string
+}
+
+// SystemClock is the Clock which tells the time of the system.
+type SystemClock struct{}
+
+// Now returns the current time of the system.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// UUIDGenerator is the IDGenerator which generates random UUIDs.
+type UUIDGenerator struct{}
+
+// NewID returns a new random UUID.
+func (UUIDGenerator) NewID() string {
+	return uuid.NewString()
+}
+
+type clockKey struct{}
+
+type idsKey struct{}
+
+// withClock returns a connection which stamps the rows it creates and updates with the given clock
+// and carries the clock and ID generator to the helpers that are only given a transaction.
+// The system clock and random UUIDs are used if they are nil.
+func withClock(gdb *gorm.DB, clock Clock, ids IDGenerator) *gorm.DB {
+	if gdb == nil {
+		return nil
+	}
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	if ids == nil {
+		ids = UUIDGenerator{}
+	}
+	ctx := gdb.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = context.WithValue(context.WithValue(ctx, clockKey{}, clock), idsKey{}, ids)
+	// Postgres keeps timestamps to the microsecond, so rows are stamped at that precision
+	// and the saved copies match the rows read back.
+	now := func() time.Time { return clock.Now().Truncate(time.Microsecond) }
+	return gdb.Session(&gorm.Session{Context: ctx, NowFunc: now})
+}
+
+// clockOf returns the clock of a connection, which is the system clock unless a service configured one.
+func clockOf(gdb *gorm.DB) Clock {
+	if gdb.Statement.Context != nil {
+		if c, ok := gdb.Statement.Context.Value(clockKey{}).(Clock); ok {
+			return c
+		}
+	}
+	return SystemClock{}
+}
+
+// idsOf returns the ID generator of a connection, which generates random UUIDs unless a service configured one.
+func idsOf(gdb *gorm.DB) IDGenerator {
+	if gdb.Statement.Context != nil {
+		if ids, ok := gdb.Statement.Context.Value(idsKey{}).(IDGenerator); ok {
+			return ids
+		}
+	}
+	return UUIDGenerator{}
+}
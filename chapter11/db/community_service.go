@@ -0,0 +1,313 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultCommunityID is the ID of the community which users belong to unless they join another one,
+// and which serves the requests of hosts that no other community is configured for.
+const DefaultCommunityID = "00000000-0000-0000-0000-000000000001"
+
+// slugPattern is the pattern of community slugs, which are used in headers and hostnames.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Community is a separate swap community, such as an office or a school.
+// Users belong to a single community, and only see and swap the items of its members.
+type Community struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	Host      string    `json:"host,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CommunityService contains all the functionality and dependencies for managing communities.
+type CommunityService struct {
+	DB     *gorm.DB
+	admins map[string]bool
+}
+
+// NewCommunityService initialises a CommunityService given its dependencies.
+// Only the given admins can create and change communities.
+func NewCommunityService(db *gorm.DB, admins []string, clock Clock, ids IDGenerator) *CommunityService {
+	cs := &CommunityService{
+		DB:     withClock(db, clock, ids),
+		admins: make(map[string]bool, len(admins)),
+	}
+	for _, id := range admins {
+		cs.admins[id] = true
+	}
+	return cs
+}
+
+// List returns all the communities, ordered by name.
+func (cs *CommunityService) List() ([]Community, error) {
+	var items []Community
+	if r := cs.DB.Order("name").Find(&items); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return items, nil
+}
+
+// Upsert creates or updates a community on behalf of an admin.
+// Slugs and hosts are lowercased and must not be taken by another community.
+// The change is recorded in the audit log of admin actions.
+func (cs *CommunityService) Upsert(c Community, adminID string) (Community, error) {
+	if !cs.admins[adminID] {
+		return Community{}, fmt.Errorf("%w: %s", ErrNotAdmin, adminID)
+	}
+	c.Slug = strings.ToLower(strings.TrimSpace(c.Slug))
+	c.Name = strings.TrimSpace(c.Name)
+	c.Host = normalizeHost(c.Host)
+	switch {
+	case len(c.Slug) > 63 || !slugPattern.MatchString(c.Slug):
+		return Community{}, fmt.Errorf("%w: invalid slug %q", ErrInvalidInput, c.Slug)
+	case c.Name == "":
+		return Community{}, fmt.Errorf("%w: communities need a name", ErrInvalidInput)
+	}
+	var ec Community
+	if !isValidID(c.ID) || cs.DB.Where("id = ?", c.ID).First(&ec).Error != nil {
+		c.ID = idsOf(cs.DB).NewID()
+		c.CreatedAt = time.Time{}
+	} else {
+		c.CreatedAt = ec.CreatedAt
+	}
+	var taken int64
+	q := cs.DB.Model(&Community{}).Where("id <> ?", c.ID)
+	if c.Host != "" {
+		q = q.Where("slug = ? OR host = ?", c.Slug, c.Host)
+	} else {
+		q = q.Where("slug = ?", c.Slug)
+	}
+	if r := q.Count(&taken); r.Error != nil {
+		return Community{}, r.Error
+	}
+	if taken > 0 {
+		return Community{}, fmt.Errorf("%w: slug %q or host %q is taken", ErrInvalidInput, c.Slug, c.Host)
+	}
+	if err := cs.DB.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Save(&c); r.Error != nil {
+			return r.Error
+		}
+		a := AdminAction{AdminID: adminID, Action: ActionCommunitySaved, SubjectType: CommunitySubject, SubjectID: c.ID}
+		return recordAdminAction(tx, &a)
+	}); err != nil {
+		return Community{}, err
+	}
+
+	return c, nil
+}
+
+// Resolve returns the community a request is made to. A slug, such as the one given in a header,
+// takes precedence and must be known. Otherwise, the community is the one configured for the host
+// of the request, or the default community if there is none.
+func (cs *CommunityService) Resolve(slug, host string) (*Community, error) {
+	var c Community
+	if slug != "" {
+		r := cs.DB.Where("slug = ?", strings.ToLower(strings.TrimSpace(slug))).First(&c)
+		if errors.Is(r.Error, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("no community found for slug %s:%w", slug, ErrRecordNotFound)
+		}
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		return &c, nil
+	}
+	if host = normalizeHost(host); host != "" {
+		r := cs.DB.Where("host = ?", host).First(&c)
+		if r.Error == nil {
+			return &c, nil
+		}
+		if !errors.Is(r.Error, gorm.ErrRecordNotFound) {
+			return nil, r.Error
+		}
+	}
+	if r := cs.DB.Where("id = ?", DefaultCommunityID).First(&c); r.Error != nil {
+		return nil, r.Error
+	}
+	return &c, nil
+}
+
+// normalizeHost lowercases a host and strips its port, if it has one.
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// communityKey is the context key of the community a connection is scoped to.
+type communityKey struct{}
+
+// communityColumns are the tables which are scoped to a community, together with the column
+// holding the user they belong to. Users and webhooks belong to a community directly.
+var communityColumns = map[string]string{
+	"users":                 "community_id",
+	"books":                 "owner_id",
+	"magazines":             "owner_id",
+	"item_events":           "owner_id",
+	"wishlist_items":        "user_id",
+	"notifications":         "user_id",
+	"reviews":               "reviewee_id",
+	"credit_balances":       "user_id",
+	"credit_entries":        "user_id",
+	"reports":               "reporter_id",
+	"holds":                 "user_id",
+	"webhook_subscriptions": "community_id",
+	"webhook_deliveries":    "community_id",
+}
+
+// ScopeCommunities registers the callbacks which restrict the queries, updates and deletes
+// of connections returned by InCommunity to the rows of their community.
+// It must be called once on a connection before it is scoped.
+func ScopeCommunities(gdb *gorm.DB) error {
+	name := "bookswap:scope_community"
+	if err := gdb.Callback().Query().Before("gorm:query").Register(name, scopeCommunity); err != nil {
+		return err
+	}
+	if err := gdb.Callback().Row().Before("gorm:row").Register(name, scopeCommunity); err != nil {
+		return err
+	}
+	if err := gdb.Callback().Update().Before("gorm:update").Register(name, scopeCommunity); err != nil {
+		return err
+	}
+	return gdb.Callback().Delete().Before("gorm:delete").Register(name, scopeCommunity)
+}
+
+// InCommunity returns a connection which only reads, updates and deletes the rows
+// of the given community, once ScopeCommunities has been called. Rows are created as they are,
+// so services check that the users they create rows for are members of the community.
+func InCommunity(gdb *gorm.DB, communityID string) *gorm.DB {
+	return gdb.WithContext(context.WithValue(gdb.Statement.Context, communityKey{}, communityID))
+}
+
+// communityOf returns the community a connection is scoped to, if it is scoped to one.
+func communityOf(gdb *gorm.DB) (string, bool) {
+	if gdb.Statement.Context == nil {
+		return "", false
+	}
+	id, ok := gdb.Statement.Context.Value(communityKey{}).(string)
+	return id, ok
+}
+
+// scopeCommunity adds the condition which restricts a statement to the rows of its community.
+// Statements which are executed more than once, such as batched reads, are only restricted once.
+func scopeCommunity(gdb *gorm.DB) {
+	id, ok := communityOf(gdb)
+	if !ok {
+		return
+	}
+	column, ok := communityColumns[gdb.Statement.Table]
+	if !ok {
+		return
+	}
+	if where, ok := gdb.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+		for _, expr := range where.Exprs {
+			if _, ok := expr.(communityScope); ok {
+				return
+			}
+		}
+	}
+	gdb.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		communityScope{column: column, communityID: id},
+	}})
+}
+
+// communityScope is the condition which restricts the rows of a table to those of a community.
+type communityScope struct {
+	column      string
+	communityID string
+}
+
+func (s communityScope) Build(builder clause.Builder) {
+	column := clause.Column{Table: clause.CurrentTable, Name: s.column}
+	if s.column == "community_id" {
+		clause.Eq{Column: column, Value: s.communityID}.Build(builder)
+		return
+	}
+	clause.Expr{
+		SQL:  "? IN (SELECT id FROM users WHERE community_id = ?)",
+		Vars: []any{column, s.communityID},
+	}.Build(builder)
+}
+
+// checkMember returns ErrNotMember if a connection is scoped to a community which the given user
+// is not a member of, so that rows are not created for users outside of the community.
+func checkMember(gdb *gorm.DB, userID string) error {
+	if _, ok := communityOf(gdb); !ok {
+		return nil
+	}
+	var count int64
+	if isValidID(userID) {
+		if r := gdb.Model(&User{}).Where("id = ?", userID).Count(&count); r.Error != nil {
+			return r.Error
+		}
+	}
+	if count == 0 {
+		return fmt.Errorf("user %s:%w", userID, ErrNotMember)
+	}
+	return nil
+}
+
+// checkSameCommunity returns ErrNotMember unless both users are members of the same community,
+// which must also be the community of the connection if it is scoped to one.
+// Items only change hands within the community they are listed in.
+func checkSameCommunity(gdb *gorm.DB, ownerID, userID string) error {
+	if ownerID == userID {
+		return checkMember(gdb, userID)
+	}
+	var users []User
+	if ids := validIDs([]string{ownerID, userID}); len(ids) == 2 {
+		if r := gdb.Select("id", "community_id").Where("id IN ?", ids).Find(&users); r.Error != nil {
+			return r.Error
+		}
+	}
+	if len(users) != 2 || users[0].CommunityID != users[1].CommunityID {
+		return fmt.Errorf("user %s:%w of %s", userID, ErrNotMember, ownerID)
+	}
+	return nil
+}
+
+// communityCacheKeys returns the cache keys of a change to the items of a user, both as they are
+// and as they are cached by services scoped to the user's community.
+// Only the keys as they are are returned if the user's community cannot be found.
+func communityCacheKeys(gdb *gorm.DB, userID string, keys ...string) []string {
+	id, ok := communityOf(gdb)
+	if !ok {
+		var ids []string
+		if r := gdb.Model(&User{}).Where("id = ?", userID).Pluck("community_id", &ids); r.Error != nil || len(ids) == 0 {
+			return keys
+		}
+		id = ids[0]
+	}
+	all := append([]string(nil), keys...)
+	for _, key := range keys {
+		all = append(all, communityCacheKey(id, key))
+	}
+	return all
+}
+
+// scopedCacheKey returns the key a value is cached under by a connection,
+// which is prefixed by the community of the connection if it is scoped to one.
+func scopedCacheKey(gdb *gorm.DB, key string) string {
+	if id, ok := communityOf(gdb); ok {
+		return communityCacheKey(id, key)
+	}
+	return key
+}
+
+// communityCacheKey returns the key a value is cached under within a community.
+func communityCacheKey(communityID, key string) string {
+	return "communities:" + communityID + ":" + key
+}
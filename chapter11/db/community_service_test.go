@@ -0,0 +1,194 @@
+package db_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommunityIsolation(t *testing.T) {
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	office := db.CreateTestCommunity(t, testDB)
+	school := db.CreateTestCommunity(t, testDB)
+	officeDB := db.InCommunity(testDB, office.ID)
+	owner := db.CreateTestUser(t, officeDB)
+	colleague := db.CreateTestUser(t, officeDB)
+	pupil := db.CreateTestUser(t, db.InCommunity(testDB, school.ID))
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil).WithCache(db.NewLRUCache(10, time.Minute, nil))
+	us := db.NewUserService(testDB, bs, nil, nil, nil)
+	officeBooks, schoolBooks := bs.InCommunity(office.ID), bs.InCommunity(school.ID)
+	officeBook, err := officeBooks.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+	require.Nil(t, err)
+	schoolBook, err := schoolBooks.Upsert(db.Book{Name: "Matilda", OwnerID: pupil.ID})
+	require.Nil(t, err)
+
+	t.Run("users join the community they are created in", func(t *testing.T) {
+		assert.Equal(t, office.ID, owner.CommunityID)
+		assert.Equal(t, school.ID, pupil.CommunityID)
+		u, err := us.Upsert(db.User{Name: "Unscoped"})
+		require.Nil(t, err)
+		assert.Equal(t, db.DefaultCommunityID, u.CommunityID)
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		books, err := officeBooks.List()
+		require.Nil(t, err)
+		assert.Contains(t, ids(books), officeBook.ID)
+		assert.NotContains(t, ids(books), schoolBook.ID)
+		// The list cached for the office is not served to the school.
+		books, err = schoolBooks.List()
+		require.Nil(t, err)
+		assert.Contains(t, ids(books), schoolBook.ID)
+		assert.NotContains(t, ids(books), officeBook.ID)
+		users, err := us.InCommunity(office.ID).List()
+		require.Nil(t, err)
+		assert.Equal(t, 2, len(users))
+	})
+
+	t.Run("gets", func(t *testing.T) {
+		_, err := schoolBooks.Get(officeBook.ID)
+		assert.NotNil(t, err)
+		books, err := schoolBooks.ListByUser(owner.ID)
+		require.Nil(t, err)
+		assert.Empty(t, books)
+		assert.NotNil(t, us.InCommunity(school.ID).Exists(owner.ID))
+		got, err := bs.Get(schoolBook.ID)
+		require.Nil(t, err)
+		assert.Equal(t, schoolBook.ID, got.ID)
+	})
+
+	t.Run("items of other communities are not swapped", func(t *testing.T) {
+		_, err := officeBooks.SwapBook(schoolBook.ID, colleague.ID)
+		assert.NotNil(t, err)
+		_, err = bs.SwapBook(schoolBook.ID, colleague.ID)
+		assert.True(t, errors.Is(err, db.ErrNotMember))
+		got, err := bs.Get(schoolBook.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.Available, got.Status)
+		assert.Equal(t, pupil.ID, got.OwnerID)
+	})
+
+	t.Run("items are not created for users of other communities", func(t *testing.T) {
+		_, err := officeBooks.Upsert(db.Book{Name: "Holes", OwnerID: pupil.ID})
+		assert.True(t, errors.Is(err, db.ErrNotMember))
+		_, err = bs.Upsert(db.Book{ID: officeBook.ID, Name: "Dune", OwnerID: pupil.ID})
+		assert.True(t, errors.Is(err, db.ErrNotMember))
+	})
+
+	t.Run("wishlists only match items of the same community", func(t *testing.T) {
+		ws := db.NewWishlistService(testDB, nil, nil)
+		_, err := ws.Add(db.WishlistItem{UserID: pupil.ID, ItemType: db.BookItem, Name: "Emma"})
+		require.Nil(t, err)
+		_, err = ws.Add(db.WishlistItem{UserID: colleague.ID, ItemType: db.BookItem, Name: "Emma"})
+		require.Nil(t, err)
+		_, err = ws.InCommunity(office.ID).Add(db.WishlistItem{UserID: pupil.ID, ItemType: db.BookItem, Name: "Emma"})
+		assert.True(t, errors.Is(err, db.ErrNotMember))
+
+		_, err = officeBooks.Upsert(db.Book{Name: "Emma", OwnerID: owner.ID})
+		require.Nil(t, err)
+
+		ns := db.NewNotificationService(testDB, nil, nil)
+		notifications, err := ns.ListByUser(colleague.ID)
+		require.Nil(t, err)
+		assert.Equal(t, 1, len(notifications))
+		notifications, err = ns.ListByUser(pupil.ID)
+		require.Nil(t, err)
+		assert.Empty(t, notifications)
+	})
+}
+
+func TestCommunityResolve(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	c := db.CreateTestCommunity(t, testDB)
+	host := c.Slug + ".bookswap.test"
+	require.Nil(t, testDB.Model(&c).Update("host", host).Error)
+	cs := db.NewCommunityService(testDB, nil, nil, nil)
+	tests := map[string]struct {
+		slug    string
+		host    string
+		wantID  string
+		wantErr error
+	}{
+		"slug":           {slug: c.Slug, host: "localhost", wantID: c.ID},
+		"padded slug":    {slug: " " + c.Slug + " ", wantID: c.ID},
+		"host":           {host: host, wantID: c.ID},
+		"host with port": {host: host + ":3000", wantID: c.ID},
+		"uppercase host": {host: strings.ToUpper(host), wantID: c.ID},
+		"unknown host":   {host: "unknown.bookswap.test", wantID: db.DefaultCommunityID},
+		"no host":        {wantID: db.DefaultCommunityID},
+		"unknown slug":   {slug: "unknown-" + c.Slug, host: host, wantErr: db.ErrRecordNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := cs.Resolve(tc.slug, tc.host)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr))
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.wantID, got.ID)
+		})
+	}
+}
+
+func TestCommunityUpsert(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	admin := uuid.NewString()
+	cs := db.NewCommunityService(testDB, []string{admin}, nil, nil)
+	taken := db.CreateTestCommunity(t, testDB)
+	slug := "office-" + uuid.NewString()[:8]
+	tests := map[string]struct {
+		community db.Community
+		adminID   string
+		wantErr   error
+	}{
+		"not an admin": {community: db.Community{Slug: slug, Name: "Office"}, adminID: uuid.NewString(),
+			wantErr: db.ErrNotAdmin},
+		"invalid slug": {community: db.Community{Slug: "the office", Name: "Office"}, adminID: admin,
+			wantErr: db.ErrInvalidInput},
+		"no name": {community: db.Community{Slug: slug}, adminID: admin, wantErr: db.ErrInvalidInput},
+		"taken slug": {community: db.Community{Slug: taken.Slug, Name: "Office"}, adminID: admin,
+			wantErr: db.ErrInvalidInput},
+		"new community": {community: db.Community{Slug: " " + strings.ToUpper(slug), Name: "Office",
+			Host: slug + ".bookswap.test:443"}, adminID: admin},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := cs.Upsert(tc.community, tc.adminID)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr))
+				return
+			}
+			require.Nil(t, err)
+			assert.NotEmpty(t, got.ID)
+			assert.Equal(t, slug, got.Slug)
+			assert.Equal(t, slug+".bookswap.test", got.Host)
+			communities, err := cs.List()
+			require.Nil(t, err)
+			var slugs []string
+			for _, c := range communities {
+				slugs = append(slugs, c.Slug)
+			}
+			assert.Contains(t, slugs, slug)
+		})
+	}
+}
+
+// ids returns the IDs of the given books.
+func ids(books []db.Book) []string {
+	ids := make([]string, 0, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+	return ids
+}
@@ -0,0 +1,260 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// StartingCredits is the balance users are granted when their account is opened,
+	// so that they can receive an item before they have swapped any away.
+	StartingCredits = 1
+	// SwapCredits is how many credits the new owner of an item pays its previous owner in a swap.
+	SwapCredits = 1
+)
+
+// CreditReason contains the reasons a credit balance changes.
+type CreditReason string
+
+const (
+	CreditGranted  CreditReason = "GRANTED"
+	CreditEarned   CreditReason = "EARNED"
+	CreditSpent    CreditReason = "SPENT"
+	CreditRefunded CreditReason = "REFUNDED"
+	CreditReversed CreditReason = "REVERSED"
+	CreditAdjusted CreditReason = "ADJUSTED"
+)
+
+// CreditEntry is an entry in the append-only ledger of credit changes.
+// Balance contains the balance of the user after the entry.
+type CreditEntry struct {
+	ID        int64        `json:"id" gorm:"primaryKey"`
+	UserID    string       `json:"user_id"`
+	Amount    int          `json:"amount"`
+	Balance   int          `json:"balance"`
+	Reason    CreditReason `json:"reason"`
+	ItemType  ItemType     `json:"item_type,omitempty" gorm:"default:null"`
+	ItemID    string       `json:"item_id,omitempty" gorm:"default:null"`
+	ActorID   string       `json:"actor_id"`
+	Note      string       `json:"note,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// CreditBalance is the current balance of a user, kept in line with their ledger entries.
+type CreditBalance struct {
+	UserID    string `gorm:"primaryKey"`
+	Balance   int
+	UpdatedAt time.Time
+}
+
+// CreditAccount contains the balance of a user and the ledger entries it is made of.
+type CreditAccount struct {
+	UserID  string        `json:"user_id"`
+	Balance int           `json:"balance"`
+	Entries []CreditEntry `json:"entries"`
+}
+
+// CreditAdjustment is a change of balance made by an admin, such as a goodwill gesture.
+type CreditAdjustment struct {
+	Amount int    `json:"amount"`
+	Note   string `json:"note"`
+}
+
+// CreditService contains all the functionality and dependencies for managing credits.
+type CreditService struct {
+	DB     *gorm.DB
+	admins map[string]bool
+}
+
+// NewCreditService initialises a CreditService given its dependencies.
+// Only the given admins can adjust balances.
+func NewCreditService(db *gorm.DB, admins []string, clock Clock, ids IDGenerator) *CreditService {
+	cs := &CreditService{
+		DB:     withClock(db, clock, ids),
+		admins: make(map[string]bool, len(admins)),
+	}
+	for _, id := range admins {
+		cs.admins[id] = true
+	}
+	return cs
+}
+
+// InCommunity returns a copy of the service which only sees and changes the credit accounts of a community.
+func (cs *CreditService) InCommunity(communityID string) *CreditService {
+	c := *cs
+	c.DB = InCommunity(cs.DB, communityID)
+	return &c
+}
+
+// Get returns the balance and ledger of a given user, oldest entry first.
+// The account is opened with the starting credits if the user does not have one yet.
+func (cs *CreditService) Get(userID string) (*CreditAccount, error) {
+	if err := userExists(cs.DB, userID); err != nil {
+		return nil, err
+	}
+	var account *CreditAccount
+	if err := cs.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockBalances(tx, userID); err != nil {
+			return err
+		}
+		var err error
+		account, err = creditAccount(tx, userID)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// Adjust changes the balance of a user on behalf of an admin, who must explain why in a note.
+// The adjustment is also recorded in the audit log of admin actions.
+// Balances cannot be adjusted below zero.
+func (cs *CreditService) Adjust(userID, adminID string, adj CreditAdjustment) (*CreditAccount, error) {
+	if !cs.admins[adminID] {
+		return nil, fmt.Errorf("%w: %s", ErrNotAdmin, adminID)
+	}
+	if adj.Amount == 0 || adj.Note == "" {
+		return nil, fmt.Errorf("%w: adjustments need a non-zero amount and a note", ErrInvalidInput)
+	}
+	if err := userExists(cs.DB, userID); err != nil {
+		return nil, err
+	}
+	var account *CreditAccount
+	if err := cs.DB.Transaction(func(tx *gorm.DB) error {
+		balances, err := lockBalances(tx, userID)
+		if err != nil {
+			return err
+		}
+		e := CreditEntry{UserID: userID, Amount: adj.Amount, Reason: CreditAdjusted, ActorID: adminID, Note: adj.Note}
+		if err := changeBalance(tx, balances[userID], e, true); err != nil {
+			return err
+		}
+		a := AdminAction{AdminID: adminID, Action: ActionCreditsAdjusted, SubjectType: UserSubject, SubjectID: userID,
+			Reason: adj.Note}
+		if err := recordAdminAction(tx, &a); err != nil {
+			return err
+		}
+		account, err = creditAccount(tx, userID)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// transferCredits makes the new owner of a swapped item pay its previous owner.
+// It fails with ErrInsufficientCredits if the new owner cannot afford it.
+func transferCredits(tx *gorm.DB, e ItemEvent) error {
+	balances, err := lockBalances(tx, e.OwnerID, e.PreviousOwnerID)
+	if err != nil {
+		return err
+	}
+	spent := CreditEntry{UserID: e.OwnerID, Amount: -SwapCredits, Reason: CreditSpent,
+		ItemType: e.ItemType, ItemID: e.ItemID, ActorID: e.ActorID}
+	if err := changeBalance(tx, balances[e.OwnerID], spent, true); err != nil {
+		return err
+	}
+	earned := CreditEntry{UserID: e.PreviousOwnerID, Amount: SwapCredits, Reason: CreditEarned,
+		ItemType: e.ItemType, ItemID: e.ItemID, ActorID: e.ActorID}
+	return changeBalance(tx, balances[e.PreviousOwnerID], earned, false)
+}
+
+// refundCredits reverses the transfer of a swap whose item could not be posted.
+// The event's actor is the user who was refunded, and its owner is the owner the item went back to.
+func refundCredits(tx *gorm.DB, e ItemEvent) error {
+	balances, err := lockBalances(tx, e.ActorID, e.OwnerID)
+	if err != nil {
+		return err
+	}
+	refunded := CreditEntry{UserID: e.ActorID, Amount: SwapCredits, Reason: CreditRefunded,
+		ItemType: e.ItemType, ItemID: e.ItemID, ActorID: e.ActorID}
+	if err := changeBalance(tx, balances[e.ActorID], refunded, false); err != nil {
+		return err
+	}
+	// The owner may have spent the credit already, in which case their balance goes negative
+	// rather than leaving the ledger out of line with the swap.
+	reversed := CreditEntry{UserID: e.OwnerID, Amount: -SwapCredits, Reason: CreditReversed,
+		ItemType: e.ItemType, ItemID: e.ItemID, ActorID: e.ActorID}
+	return changeBalance(tx, balances[e.OwnerID], reversed, false)
+}
+
+// lockBalances locks the balances of the given users until the end of the transaction,
+// opening the accounts of users who do not have one yet. The balances are locked in the same order
+// by every transaction, so that concurrent swaps between the same users do not deadlock.
+func lockBalances(tx *gorm.DB, userIDs ...string) (map[string]*CreditBalance, error) {
+	ids := append([]string(nil), userIDs...)
+	sort.Strings(ids)
+	for _, id := range ids {
+		opened := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&CreditBalance{UserID: id, Balance: StartingCredits})
+		if opened.Error != nil {
+			return nil, opened.Error
+		}
+		if opened.RowsAffected == 0 {
+			continue
+		}
+		grant := CreditEntry{UserID: id, Amount: StartingCredits, Balance: StartingCredits,
+			Reason: CreditGranted, ActorID: id}
+		if r := tx.Create(&grant); r.Error != nil {
+			return nil, r.Error
+		}
+	}
+	var locked []CreditBalance
+	if r := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id IN ?", ids).
+		Order("user_id").Find(&locked); r.Error != nil {
+		return nil, r.Error
+	}
+	balances := make(map[string]*CreditBalance, len(locked))
+	for i := range locked {
+		balances[locked[i].UserID] = &locked[i]
+	}
+	return balances, nil
+}
+
+// changeBalance applies a ledger entry to a locked balance and appends it to the ledger.
+// Checked changes fail with ErrInsufficientCredits rather than leave the balance below zero.
+func changeBalance(tx *gorm.DB, b *CreditBalance, e CreditEntry, checked bool) error {
+	if checked && b.Balance+e.Amount < 0 {
+		return fmt.Errorf("%w: user %s has %d, needs %d", ErrInsufficientCredits, b.UserID, b.Balance, -e.Amount)
+	}
+	b.Balance += e.Amount
+	if r := tx.Model(b).Update("balance", b.Balance); r.Error != nil {
+		return r.Error
+	}
+	e.Balance = b.Balance
+	if r := tx.Create(&e); r.Error != nil {
+		return r.Error
+	}
+	return nil
+}
+
+// creditAccount reads the balance and ledger of an opened account.
+func creditAccount(tx *gorm.DB, userID string) (*CreditAccount, error) {
+	var b CreditBalance
+	if r := tx.Where("user_id = ?", userID).First(&b); r.Error != nil {
+		return nil, r.Error
+	}
+	account := &CreditAccount{UserID: userID, Balance: b.Balance, Entries: []CreditEntry{}}
+	if r := tx.Where("user_id = ?", userID).Order("id").Find(&account.Entries); r.Error != nil {
+		return nil, r.Error
+	}
+	return account, nil
+}
+
+// userExists returns ErrRecordNotFound if there is no user of the given ID.
+func userExists(tx *gorm.DB, userID string) error {
+	var count int64
+	if isValidID(userID) {
+		if r := tx.Model(&User{}).Where("id = ?", userID).Count(&count); r.Error != nil {
+			return r.Error
+		}
+	}
+	if count == 0 {
+		return fmt.Errorf("no user found for id %s:%w", userID, ErrRecordNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwapCredits(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	cs := db.NewCreditService(testDB, nil, nil, nil)
+
+	t.Run("swap", func(t *testing.T) {
+		// Arrange
+		ps := mocks.NewPostingService(t)
+		ps.On("NewBookOrder", mock.AnythingOfType("db.Book")).Return(nil).Once()
+		bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+		owner := db.CreateTestUser(t, testDB)
+		swapper := db.CreateTestUser(t, testDB)
+		eb, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+		require.Nil(t, err)
+
+		// Act
+		_, err = bs.SwapBook(eb.ID, swapper.ID)
+
+		// Assert
+		require.Nil(t, err)
+		spent, err := cs.Get(swapper.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.StartingCredits-db.SwapCredits, spent.Balance)
+		require.Equal(t, 2, len(spent.Entries))
+		assert.Equal(t, db.CreditGranted, spent.Entries[0].Reason)
+		assert.Equal(t, db.CreditSpent, spent.Entries[1].Reason)
+		assert.Equal(t, eb.ID, spent.Entries[1].ItemID)
+		earned, err := cs.Get(owner.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.StartingCredits+db.SwapCredits, earned.Balance)
+		assert.Equal(t, db.CreditEarned, earned.Entries[len(earned.Entries)-1].Reason)
+	})
+
+	t.Run("insufficient credits", func(t *testing.T) {
+		// Arrange
+		ps := mocks.NewPostingService(t)
+		ps.On("NewMagazineOrder", mock.AnythingOfType("db.Magazine")).Return(nil).Once()
+		ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+		owner := db.CreateTestUser(t, testDB)
+		swapper := db.CreateTestUser(t, testDB)
+		first, err := ms.Upsert(db.Magazine{Name: "Wired", IssueNumber: 1, OwnerID: owner.ID})
+		require.Nil(t, err)
+		second, err := ms.Upsert(db.Magazine{Name: "Wired", IssueNumber: 2, OwnerID: owner.ID})
+		require.Nil(t, err)
+		_, err = ms.SwapMagazine(first.ID, swapper.ID)
+		require.Nil(t, err)
+
+		// Act
+		m, err := ms.SwapMagazine(second.ID, swapper.ID)
+
+		// Assert
+		assert.ErrorIs(t, err, db.ErrInsufficientCredits)
+		assert.Nil(t, m)
+		unswapped, err := ms.Get(second.ID)
+		require.Nil(t, err)
+		assert.Equal(t, second, *unswapped)
+		account, err := cs.Get(swapper.ID)
+		require.Nil(t, err)
+		assert.Equal(t, 0, account.Balance)
+	})
+
+	t.Run("posting failure refund", func(t *testing.T) {
+		// Arrange
+		ps := mocks.NewPostingService(t)
+		ps.On("NewBookOrder", mock.AnythingOfType("db.Book")).Return(errors.New("posting error")).Once()
+		bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+		owner := db.CreateTestUser(t, testDB)
+		swapper := db.CreateTestUser(t, testDB)
+		eb, err := bs.Upsert(db.Book{Name: "Emma", OwnerID: owner.ID})
+		require.Nil(t, err)
+
+		// Act
+		_, err = bs.SwapBook(eb.ID, swapper.ID)
+
+		// Assert
+		require.NotNil(t, err)
+		refunded, err := cs.Get(swapper.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.StartingCredits, refunded.Balance)
+		assert.Equal(t, db.CreditRefunded, refunded.Entries[len(refunded.Entries)-1].Reason)
+		reversed, err := cs.Get(owner.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.StartingCredits, reversed.Balance)
+		assert.Equal(t, db.CreditReversed, reversed.Entries[len(reversed.Entries)-1].Reason)
+	})
+}
+
+func TestAdjustCredits(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	admin := db.CreateTestUser(t, testDB)
+	user := db.CreateTestUser(t, testDB)
+	cs := db.NewCreditService(testDB, []string{admin.ID}, nil, nil)
+
+	// The adjustments build on each other, so they run in order.
+	steps := []struct {
+		name            string
+		userID, adminID string
+		adj             db.CreditAdjustment
+		wantBalance     int
+		wantErr         error
+	}{
+		{name: "grant", userID: user.ID, adminID: admin.ID, adj: db.CreditAdjustment{Amount: 3, Note: "Welcome back"},
+			wantBalance: db.StartingCredits + 3},
+		{name: "deduct", userID: user.ID, adminID: admin.ID, adj: db.CreditAdjustment{Amount: -2, Note: "Duplicate grant"},
+			wantBalance: db.StartingCredits + 1},
+		{name: "below zero", userID: user.ID, adminID: admin.ID, adj: db.CreditAdjustment{Amount: -10, Note: "Too much"},
+			wantErr: db.ErrInsufficientCredits},
+		{name: "not an admin", userID: user.ID, adminID: user.ID, adj: db.CreditAdjustment{Amount: 10, Note: "For me"},
+			wantErr: db.ErrNotAdmin},
+		{name: "no note", userID: user.ID, adminID: admin.ID, adj: db.CreditAdjustment{Amount: 1},
+			wantErr: db.ErrInvalidInput},
+		{name: "zero amount", userID: user.ID, adminID: admin.ID, adj: db.CreditAdjustment{Note: "Nothing"},
+			wantErr: db.ErrInvalidInput},
+		{name: "unknown user", userID: uuid.NewString(), adminID: admin.ID, adj: db.CreditAdjustment{Amount: 1, Note: "Ghost"},
+			wantErr: db.ErrRecordNotFound},
+	}
+	for _, tc := range steps {
+		t.Run(tc.name, func(t *testing.T) {
+			account, err := cs.Adjust(tc.userID, tc.adminID, tc.adj)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Nil(t, account)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.wantBalance, account.Balance)
+			last := account.Entries[len(account.Entries)-1]
+			assert.Equal(t, db.CreditAdjusted, last.Reason)
+			assert.Equal(t, tc.adj.Amount, last.Amount)
+			assert.Equal(t, tc.adj.Note, last.Note)
+			assert.Equal(t, admin.ID, last.ActorID)
+			assert.Equal(t, tc.wantBalance, last.Balance)
+		})
+	}
+}
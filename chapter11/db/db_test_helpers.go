@@ -1,25 +1,28 @@
 package db
 
 import (
-	"errors"
+	"fmt"
 	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var ErrRecordNotFound = errors.New("record not found")
-
-func OpenDB(t *testing.T) (*gorm.DB, func()) {
+func OpenDB(t testing.TB) (*gorm.DB, func()) {
 	t.Helper()
 	postgresURL, ok := os.LookupEnv("BOOKSWAP_DB_URL")
 	require.True(t, ok)
 	db, err := gorm.Open(postgres.Open(postgresURL), &gorm.Config{})
 	require.Nil(t, err)
 	require.NotNil(t, db)
+	require.Nil(t, ScopeCommunities(db))
 	return db, cleanUpDB(db)
 }
 
@@ -33,15 +36,93 @@ func cleanUpDB(gdb *gorm.DB) func() {
 	gdb.Callback().Create().After("gorm:create").Register(name, func(d *gorm.DB) {
 		table := d.Statement.Schema.Table
 		model := reflect.ValueOf(d.Statement.Model)
-		id := reflect.Indirect(model).FieldByName("ID").String()
-		records = append(records, record{table: table, id: id})
+		id := reflect.Indirect(model).FieldByName("ID")
+		// Append-only tables, such as the item_events ledger, use numeric IDs and are never cleaned up.
+		if id.Kind() != reflect.String {
+			return
+		}
+		records = append(records, record{table: table, id: id.String()})
 	})
 
 	return func() {
 		defer gdb.Callback().Create().Remove(name)
 		tx := gdb.Begin()
-		for _, r := range records {
+		// Delete in reverse order of creation, so that items are removed before their owners.
+		for i := len(records) - 1; i >= 0; i-- {
+			r := records[i]
 			tx.Table(r.table).Where("id = ?", r.id).Delete("")
 		}
+		tx.Commit()
 	}
 }
+
+// CreateTestUser creates a new user, which can then be used as the owner of test items.
+func CreateTestUser(t testing.TB, gdb *gorm.DB) User {
+	t.Helper()
+	us := NewUserService(gdb, nil, nil, nil, nil)
+	u, err := us.Upsert(User{
+		Name: "Test user",
+	})
+	require.Nil(t, err)
+	return u
+}
+
+// CreateTestCommunity creates a new community. Test users are created in it
+// by passing CreateTestUser a connection scoped to it with InCommunity.
+func CreateTestCommunity(t testing.TB, gdb *gorm.DB) Community {
+	t.Helper()
+	id := uuid.NewString()
+	c := Community{
+		ID:   id,
+		Slug: "test-" + strings.ReplaceAll(id, "-", ""),
+		Name: "Test community",
+	}
+	require.Nil(t, gdb.Create(&c).Error)
+	return c
+}
+
+// FakeClock is a Clock which only moves when a test advances it.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock initialises a FakeClock stopped at the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time the clock is stopped at.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock on by the given duration.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SequentialIDs is an IDGenerator which numbers the IDs it generates, so that they are the same on every run.
+// The IDs start with a prefix, which keeps them apart from the IDs of tests sharing the database.
+type SequentialIDs struct {
+	mu     sync.Mutex
+	prefix uint32
+	next   uint64
+}
+
+// NewSequentialIDs initialises a SequentialIDs generating the IDs with the given prefix.
+func NewSequentialIDs(prefix uint32) *SequentialIDs {
+	return &SequentialIDs{prefix: prefix}
+}
+
+// NewID returns the next ID in the sequence, such as 0000002a-0000-4000-8000-000000000001.
+func (ids *SequentialIDs) NewID() string {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	ids.next++
+	return fmt.Sprintf("%08x-0000-4000-8000-%012x", ids.prefix, ids.next)
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeliveryStatus contains the different states of a notification delivery.
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "PENDING"
+	DeliverySent    DeliveryStatus = "SENT"
+	DeliveryFailed  DeliveryStatus = "FAILED"
+	DeliverySkipped DeliveryStatus = "SKIPPED"
+)
+
+// Delivery contains all the fields for representing a notification queued for sending through a channel.
+type Delivery struct {
+	ID             string         `json:"id" gorm:"primaryKey"`
+	NotificationID string         `json:"notification_id"`
+	Channel        string         `json:"channel"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	LastError      string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// TableName overrides the table name used by Delivery.
+func (Delivery) TableName() string {
+	return "notification_deliveries"
+}
+
+// QueuedDelivery is a due delivery together with the notification and recipient it is for.
+type QueuedDelivery struct {
+	Delivery     Delivery
+	Notification Notification
+	User         User
+}
+
+// DeliveryService contains all the functionality and dependencies for the notification delivery queue.
+type DeliveryService struct {
+	DB *gorm.DB
+}
+
+// NewDeliveryService initialises a DeliveryService given its dependencies.
+func NewDeliveryService(db *gorm.DB, clock Clock, ids IDGenerator) *DeliveryService {
+	return &DeliveryService{
+		DB: withClock(db, clock, ids),
+	}
+}
+
+// Enqueue queues a delivery through each of the given channels for every notification
+// that has not been dispatched yet.
+func (ds *DeliveryService) Enqueue(channels []string) error {
+	return ds.DB.Transaction(func(tx *gorm.DB) error {
+		var ns []Notification
+		if r := tx.Where("dispatched_at IS NULL").Order("created_at").Find(&ns); r.Error != nil {
+			return r.Error
+		}
+		if len(ns) == 0 {
+			return nil
+		}
+		now := clockOf(tx).Now()
+		ids := make([]string, 0, len(ns))
+		for _, n := range ns {
+			ids = append(ids, n.ID)
+			for _, c := range channels {
+				d := Delivery{
+					ID:             idsOf(tx).NewID(),
+					NotificationID: n.ID,
+					Channel:        c,
+					Status:         DeliveryPending,
+					NextAttemptAt:  now,
+				}
+				if r := tx.Create(&d); r.Error != nil {
+					return r.Error
+				}
+			}
+		}
+		return tx.Model(&Notification{}).Where("id IN ?", ids).Update("dispatched_at", now).Error
+	})
+}
+
+// Pending returns up to limit deliveries which are due to be attempted.
+func (ds *DeliveryService) Pending(limit int) ([]QueuedDelivery, error) {
+	var deliveries []Delivery
+	if r := ds.DB.Where("status = ? AND next_attempt_at <= ?", DeliveryPending, clockOf(ds.DB).Now()).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries); r.Error != nil {
+		return nil, r.Error
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+	notificationIDs := make([]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		notificationIDs = append(notificationIDs, d.NotificationID)
+	}
+	var ns []Notification
+	if r := ds.DB.Where("id IN ?", notificationIDs).Find(&ns); r.Error != nil {
+		return nil, r.Error
+	}
+	notifications := make(map[string]Notification, len(ns))
+	userIDs := make([]string, 0, len(ns))
+	for _, n := range ns {
+		notifications[n.ID] = n
+		userIDs = append(userIDs, n.UserID)
+	}
+	var us []User
+	if r := ds.DB.Where("id IN ?", userIDs).Find(&us); r.Error != nil {
+		return nil, r.Error
+	}
+	users := make(map[string]User, len(us))
+	for _, u := range us {
+		users[u.ID] = u
+	}
+
+	queued := make([]QueuedDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		n := notifications[d.NotificationID]
+		queued = append(queued, QueuedDelivery{
+			Delivery:     d,
+			Notification: n,
+			User:         users[n.UserID],
+		})
+	}
+	return queued, nil
+}
+
+// RecordAttempt stores the outcome of an attempt to send a delivery.
+// Deliveries which are still pending are attempted again at retryAt.
+func (ds *DeliveryService) RecordAttempt(id string, status DeliveryStatus, sendErr error, retryAt time.Time) error {
+	updates := map[string]any{
+		"status":          status,
+		"attempts":        gorm.Expr("attempts + 1"),
+		"next_attempt_at": retryAt,
+		"last_error":      "",
+	}
+	if sendErr != nil {
+		updates["last_error"] = sendErr.Error()
+	}
+	return ds.DB.Model(&Delivery{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// ListByNotification returns the deliveries queued for a given notification.
+func (ds *DeliveryService) ListByNotification(notificationID string) ([]Delivery, error) {
+	var items []Delivery
+	if !isValidID(notificationID) {
+		return items, nil
+	}
+	if r := ds.DB.Where("notification_id = ?", notificationID).Order("channel").Find(&items); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return items, nil
+}
@@ -0,0 +1,113 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwapNotifications(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
+	newOwner := db.CreateTestUser(t, testDB)
+	ps := mocks.NewPostingService(t)
+	ps.On("NewBookOrder", mock.AnythingOfType("db.Book")).Return(nil).Once()
+	bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+	ns := db.NewNotificationService(testDB, nil, nil)
+
+	eb, err := bs.Upsert(db.Book{
+		Name:    "Dune",
+		OwnerID: owner.ID,
+	})
+	require.Nil(t, err)
+	_, err = bs.SwapBook(eb.ID, newOwner.ID)
+	require.Nil(t, err)
+
+	tests := map[string]struct {
+		userID    string
+		wantTypes []db.NotificationType
+	}{
+		"previous owner": {userID: owner.ID, wantTypes: []db.NotificationType{db.SwapRequested}},
+		"new owner":      {userID: newOwner.ID, wantTypes: []db.NotificationType{db.SwapPosted, db.SwapAccepted}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			notifications, err := ns.ListByUser(tc.userID)
+			require.Nil(t, err)
+			var types []db.NotificationType
+			for _, n := range notifications {
+				types = append(types, n.Type)
+				assert.Equal(t, eb.ID, n.ItemID)
+				assert.Equal(t, "Dune", n.ItemName)
+			}
+			assert.ElementsMatch(t, tc.wantTypes, types)
+		})
+	}
+}
+
+func TestDeliveryQueue(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
+	reader := db.CreateTestUser(t, testDB)
+	ws := db.NewWishlistService(testDB, nil, nil)
+	ns := db.NewNotificationService(testDB, nil, nil)
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ds := db.NewDeliveryService(testDB, nil, nil)
+	_, err := ws.Add(db.WishlistItem{UserID: reader.ID, ItemType: db.BookItem, Name: "Emma"})
+	require.Nil(t, err)
+	_, err = bs.Upsert(db.Book{Name: "Emma", OwnerID: owner.ID})
+	require.Nil(t, err)
+	notifications, err := ns.ListByUser(reader.ID)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(notifications))
+	n := notifications[0]
+
+	require.Nil(t, ds.Enqueue([]string{"EMAIL", "WEBHOOK"}))
+	// Notifications are only queued once.
+	require.Nil(t, ds.Enqueue([]string{"EMAIL", "WEBHOOK"}))
+	deliveries, err := ds.ListByNotification(n.ID)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(deliveries))
+	assert.Equal(t, "EMAIL", deliveries[0].Channel)
+	assert.Equal(t, "WEBHOOK", deliveries[1].Channel)
+
+	pending := pendingFor(t, ds, n.ID)
+	require.Equal(t, 2, len(pending))
+	assert.Equal(t, reader.ID, pending[0].User.ID)
+	assert.Equal(t, n.ID, pending[0].Notification.ID)
+
+	require.Nil(t, ds.RecordAttempt(deliveries[0].ID, db.DeliverySent, nil, time.Now()))
+	require.Nil(t, ds.RecordAttempt(deliveries[1].ID, db.DeliveryPending, errors.New("timeout"), time.Now().Add(time.Hour)))
+	assert.Empty(t, pendingFor(t, ds, n.ID))
+
+	deliveries, err = ds.ListByNotification(n.ID)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(deliveries))
+	assert.Equal(t, db.DeliverySent, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, db.DeliveryPending, deliveries[1].Status)
+	assert.Equal(t, 1, deliveries[1].Attempts)
+	assert.Equal(t, "timeout", deliveries[1].LastError)
+}
+
+// pendingFor returns the pending deliveries of a given notification.
+func pendingFor(t *testing.T, ds *db.DeliveryService, notificationID string) []db.QueuedDelivery {
+	t.Helper()
+	pending, err := ds.Pending(1000)
+	require.Nil(t, err)
+	var filtered []db.QueuedDelivery
+	for _, q := range pending {
+		if q.Notification.ID == notificationID {
+			filtered = append(filtered, q)
+		}
+	}
+	return filtered
+}
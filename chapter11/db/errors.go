@@ -0,0 +1,47 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrRecordNotFound is returned when a record does not exist. It is the error gorm returns,
+	// so that records missing from the database and records with invalid IDs are reported alike.
+	ErrRecordNotFound = gorm.ErrRecordNotFound
+	// ErrInvalidTransition is returned when an item cannot move to the requested status.
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrUnknownStatus is returned when a status name or value is not part of the item lifecycle.
+	ErrUnknownStatus = errors.New("unknown status")
+	// ErrInvalidInput is returned when the input of an operation fails validation.
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrNotOwner is returned when a user operates on an item they do not own.
+	ErrNotOwner = errors.New("user is not the owner")
+	// ErrMetadataNotFound is returned when a metadata provider does not know an ISBN.
+	ErrMetadataNotFound = errors.New("no metadata found")
+	// ErrNotShippable is returned when an item cannot be posted between two users.
+	ErrNotShippable = errors.New("item cannot be shipped")
+	// ErrInsufficientCredits is returned when a user cannot afford an operation.
+	ErrInsufficientCredits = errors.New("insufficient credits")
+	// ErrNotAdmin is returned when a user who is not an admin performs an admin operation.
+	ErrNotAdmin = errors.New("user is not an admin")
+	// ErrNotMember is returned when a user operates on the items of a community they are not a member of.
+	ErrNotMember = errors.New("user is not a member of the community")
+	// ErrSuspended is returned when a suspended user lists or swaps items.
+	ErrSuspended = errors.New("user is suspended")
+	// ErrFlagged is returned when a user swaps an item which is flagged for moderation.
+	ErrFlagged = errors.New("item is flagged for moderation")
+	// ErrReserved is returned when a user swaps an item which is held by another user.
+	ErrReserved = errors.New("item is reserved by another user")
+	// ErrHoldExpired is returned when a user converts a hold into a swap after it has expired.
+	ErrHoldExpired = errors.New("hold has expired")
+	// ErrNotReviewable is returned when a user reviews an item they have not completed a swap of.
+	ErrNotReviewable = errors.New("no delivered swap to review")
+	// ErrAlreadyReviewed is returned when a user reviews the same swap twice.
+	ErrAlreadyReviewed = errors.New("swap already reviewed")
+	// ErrImageTooLarge is returned when an uploaded image exceeds the size or dimension limits.
+	ErrImageTooLarge = errors.New("image too large")
+	// ErrUnsupportedImage is returned when an uploaded file is not an image of a supported type.
+	ErrUnsupportedImage = errors.New("unsupported image type")
+)
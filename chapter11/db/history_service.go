@@ -0,0 +1,175 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ItemType contains the different types of swappable items.
+type ItemType string
+
+const (
+	BookItem     ItemType = "BOOK"
+	MagazineItem ItemType = "MAGAZINE"
+)
+
+// ItemEventType contains the different types of events in an item's history.
+type ItemEventType string
+
+const (
+	ItemCreated       ItemEventType = "CREATED"
+	ItemUpdated       ItemEventType = "UPDATED"
+	ItemSwapped       ItemEventType = "SWAPPED"
+	ItemPosted        ItemEventType = "POSTED"
+	ItemPostingFailed ItemEventType = "POSTING_FAILED"
+	ItemDelivered     ItemEventType = "DELIVERED"
+	ItemRelisted      ItemEventType = "RELISTED"
+	ItemWithdrawn     ItemEventType = "WITHDRAWN"
+	ItemFlagged       ItemEventType = "FLAGGED"
+	ItemUnflagged     ItemEventType = "UNFLAGGED"
+	ItemRemoved       ItemEventType = "REMOVED"
+	ItemHeld          ItemEventType = "HELD"
+	ItemReleased      ItemEventType = "RELEASED"
+	ItemHoldExpired   ItemEventType = "HOLD_EXPIRED"
+)
+
+// ItemEvent is an entry in the append-only ledger of item changes.
+// Snapshot contains the state of the item after the event.
+type ItemEvent struct {
+	ID              int64           `json:"id" gorm:"primaryKey"`
+	ItemID          string          `json:"item_id"`
+	ItemType        ItemType        `json:"item_type"`
+	Type            ItemEventType   `json:"type"`
+	ActorID         string          `json:"actor_id"`
+	OwnerID         string          `json:"owner_id"`
+	PreviousOwnerID string          `json:"previous_owner_id,omitempty" gorm:"default:null"`
+	Snapshot        json.RawMessage `json:"snapshot"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// EventPublisher is told about every event once it has been recorded in the ledger.
+type EventPublisher interface {
+	Publish(e ItemEvent)
+}
+
+// HistoryService contains all the functionality and dependencies for reading item histories.
+type HistoryService struct {
+	DB *gorm.DB
+}
+
+// NewHistoryService initialises a HistoryService given its dependencies.
+func NewHistoryService(db *gorm.DB, clock Clock, ids IDGenerator) *HistoryService {
+	return &HistoryService{
+		DB: withClock(db, clock, ids),
+	}
+}
+
+// InCommunity returns a copy of the service which only reads the item histories of a community.
+func (hs *HistoryService) InCommunity(communityID string) *HistoryService {
+	c := *hs
+	c.DB = InCommunity(hs.DB, communityID)
+	return &c
+}
+
+// ListByItem returns the history of a given item, oldest event first.
+func (hs *HistoryService) ListByItem(itemType ItemType, itemID string) ([]ItemEvent, error) {
+	var events []ItemEvent
+	if !isValidID(itemID) {
+		return events, nil
+	}
+	if r := hs.DB.Where("item_type = ? AND item_id = ?", itemType, itemID).
+		Order("id").Find(&events); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return events, nil
+}
+
+// ListByUser returns the events a given user has taken part in, oldest event first.
+func (hs *HistoryService) ListByUser(userID string) ([]ItemEvent, error) {
+	var events []ItemEvent
+	if !isValidID(userID) {
+		return events, nil
+	}
+	if r := hs.DB.Where("actor_id = ? OR owner_id = ? OR previous_owner_id = ?", userID, userID, userID).
+		Order("id").Find(&events); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return events, nil
+}
+
+// ListSince returns up to limit events recorded after the event with the given ID, oldest first.
+func (hs *HistoryService) ListSince(afterID int64, limit int) ([]ItemEvent, error) {
+	var events []ItemEvent
+	if r := hs.DB.Where("id > ?", afterID).Order("id").Limit(limit).Find(&events); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return events, nil
+}
+
+// BookAt returns the state of a given book at a point in time or error if it did not exist then.
+func (hs *HistoryService) BookAt(id string, at time.Time) (*Book, error) {
+	return itemAt[Book](hs.DB, BookItem, id, at)
+}
+
+// MagazineAt returns the state of a given magazine at a point in time or error if it did not exist then.
+func (hs *HistoryService) MagazineAt(id string, at time.Time) (*Magazine, error) {
+	return itemAt[Magazine](hs.DB, MagazineItem, id, at)
+}
+
+// itemAt reconstructs an item from the latest snapshot recorded at or before the given time.
+func itemAt[T Book | Magazine](db *gorm.DB, itemType ItemType, id string, at time.Time) (*T, error) {
+	if !isValidID(id) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var e ItemEvent
+	if r := db.Where("item_type = ? AND item_id = ? AND created_at <= ?", itemType, id, at).
+		Order("id DESC").First(&e); r.Error != nil {
+		return nil, r.Error
+	}
+	var item T
+	if err := json.Unmarshal(e.Snapshot, &item); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// recordEvent appends an event to the ledger, taking a snapshot of the item's current state,
+// ends the hold on the item if the event takes it out of reserve, and queues its delivery
+// to the webhooks subscribed to it.
+func recordEvent(tx *gorm.DB, e *ItemEvent, item any) error {
+	snapshot, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	e.Snapshot = snapshot
+	if r := tx.Create(e); r.Error != nil {
+		return r.Error
+	}
+	if err := endHolds(tx, *e); err != nil {
+		return err
+	}
+	return enqueueWebhooks(tx, *e)
+}
+
+// ItemEventTypes returns all the known item event types.
+func ItemEventTypes() []ItemEventType {
+	return []ItemEventType{ItemCreated, ItemUpdated, ItemSwapped, ItemPosted, ItemPostingFailed,
+		ItemDelivered, ItemRelisted, ItemWithdrawn, ItemFlagged, ItemUnflagged, ItemRemoved, ItemHeld, ItemReleased,
+		ItemHoldExpired}
+}
+
+// IsItemEventType returns whether t is one of the known item event types.
+func IsItemEventType(t ItemEventType) bool {
+	for _, known := range ItemEventTypes() {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookHistory(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
+	newOwner := db.CreateTestUser(t, testDB)
+	ps := mocks.NewPostingService(t)
+	ps.On("NewBookOrder", mock.AnythingOfType("db.Book")).Return(nil).Once()
+	pub := mocks.NewEventPublisher(t)
+	var published []db.ItemEvent
+	pub.On("Publish", mock.AnythingOfType("db.ItemEvent")).Run(func(args mock.Arguments) {
+		published = append(published, args.Get(0).(db.ItemEvent))
+	}).Times(4)
+	bs := db.NewBookService(testDB, ps, pub, nil, nil, nil)
+	hs := db.NewHistoryService(testDB, nil, nil)
+
+	eb, err := bs.Upsert(db.Book{
+		Name:    "Existing book",
+		OwnerID: owner.ID,
+	})
+	require.Nil(t, err)
+	eb.Author = "Existing author"
+	eb, err = bs.Upsert(eb)
+	require.Nil(t, err)
+	beforeSwap := time.Now()
+	_, err = bs.SwapBook(eb.ID, newOwner.ID)
+	require.Nil(t, err)
+
+	t.Run("item history", func(t *testing.T) {
+		events, err := hs.ListByItem(db.BookItem, eb.ID)
+		require.Nil(t, err)
+		require.Equal(t, 4, len(events))
+		wantTypes := []db.ItemEventType{db.ItemCreated, db.ItemUpdated, db.ItemSwapped, db.ItemPosted}
+		for i, e := range events {
+			assert.Equal(t, wantTypes[i], e.Type)
+			assert.Equal(t, eb.ID, e.ItemID)
+			assert.Equal(t, db.BookItem, e.ItemType)
+		}
+		// Every event is published once recorded, with its ledger ID.
+		require.Equal(t, len(events), len(published))
+		for i, e := range events {
+			assert.Equal(t, e.ID, published[i].ID)
+			assert.Equal(t, e.Type, published[i].Type)
+		}
+		assert.Equal(t, owner.ID, events[0].ActorID)
+		assert.Equal(t, newOwner.ID, events[2].ActorID)
+		assert.Equal(t, newOwner.ID, events[2].OwnerID)
+		assert.Equal(t, owner.ID, events[2].PreviousOwnerID)
+	})
+
+	t.Run("user history", func(t *testing.T) {
+		tests := map[string]struct {
+			userID    string
+			wantTypes []db.ItemEventType
+		}{
+			"previous owner": {userID: owner.ID, wantTypes: []db.ItemEventType{db.ItemCreated, db.ItemUpdated, db.ItemSwapped}},
+			"new owner":      {userID: newOwner.ID, wantTypes: []db.ItemEventType{db.ItemSwapped, db.ItemPosted}},
+			"unknown user":   {userID: uuid.New().String()},
+			"invalid id":     {userID: "invalid-id"},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				events, err := hs.ListByUser(tc.userID)
+				require.Nil(t, err)
+				var types []db.ItemEventType
+				for _, e := range events {
+					types = append(types, e.Type)
+				}
+				assert.Equal(t, tc.wantTypes, types)
+			})
+		}
+	})
+
+	t.Run("state at point in time", func(t *testing.T) {
+		b, err := hs.BookAt(eb.ID, beforeSwap)
+		require.Nil(t, err)
+		// Snapshots keep the timestamps of the item, though not their location.
+		assert.True(t, eb.UpdatedAt.Equal(b.UpdatedAt))
+		b.CreatedAt, b.UpdatedAt = eb.CreatedAt, eb.UpdatedAt
+		assert.Equal(t, eb, *b)
+
+		b, err = hs.BookAt(eb.ID, time.Now())
+		require.Nil(t, err)
+		assert.Equal(t, newOwner.ID, b.OwnerID)
+		assert.Equal(t, db.InTransit, b.Status)
+
+		b, err = hs.BookAt(eb.ID, beforeSwap.Add(-time.Hour))
+		assert.Equal(t, db.ErrRecordNotFound, err)
+		assert.Nil(t, b)
+	})
+}
+
+func TestMagazineHistory(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
+	newOwner := db.CreateTestUser(t, testDB)
+	postingErr := errors.New("posting error")
+	ps := mocks.NewPostingService(t)
+	ps.On("NewMagazineOrder", mock.AnythingOfType("db.Magazine")).Return(postingErr).Once()
+	ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+	hs := db.NewHistoryService(testDB, nil, nil)
+
+	em, err := ms.Upsert(db.Magazine{
+		Name:    "Existing mag",
+		OwnerID: owner.ID,
+	})
+	require.Nil(t, err)
+	_, err = ms.SwapMagazine(em.ID, newOwner.ID)
+	require.Equal(t, postingErr, err)
+
+	events, err := hs.ListByItem(db.MagazineItem, em.ID)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(events))
+	assert.Equal(t, db.ItemCreated, events[0].Type)
+	assert.Equal(t, db.ItemSwapped, events[1].Type)
+	assert.Equal(t, db.ItemPostingFailed, events[2].Type)
+
+	m, err := hs.MagazineAt(em.ID, events[0].CreatedAt)
+	require.Nil(t, err)
+	assert.True(t, em.UpdatedAt.Equal(m.UpdatedAt))
+	m.CreatedAt, m.UpdatedAt = em.CreatedAt, em.UpdatedAt
+	assert.Equal(t, em, *m)
+}
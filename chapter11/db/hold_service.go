@@ -0,0 +1,237 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HoldStatus contains the statuses of a hold, which is active until it is released, expires
+// or is converted into a swap.
+type HoldStatus string
+
+const (
+	HoldActive    HoldStatus = "ACTIVE"
+	HoldReleased  HoldStatus = "RELEASED"
+	HoldExpired   HoldStatus = "EXPIRED"
+	HoldConverted HoldStatus = "CONVERTED"
+)
+
+// Hold reserves an item for a user while they decide whether to swap it.
+// Held items are RESERVED, so they are off the catalogue and only the holder can swap them.
+type Hold struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	ItemType  ItemType   `json:"item_type"`
+	ItemID    string     `json:"item_id"`
+	UserID    string     `json:"user_id"`
+	Status    HoldStatus `json:"status"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// HoldService contains all the functionality and dependencies for holding items.
+type HoldService struct {
+	DB     *gorm.DB
+	bs     *BookService
+	ms     *MagazineService
+	period time.Duration
+}
+
+// NewHoldService initialises a HoldService given its dependencies.
+// Items are held for the given period, unless the holder releases them or swaps them sooner.
+func NewHoldService(db *gorm.DB, bs *BookService, ms *MagazineService, period time.Duration,
+	clock Clock, ids IDGenerator) *HoldService {
+	return &HoldService{
+		DB:     withClock(db, clock, ids),
+		bs:     bs,
+		ms:     ms,
+		period: period,
+	}
+}
+
+// InCommunity returns a copy of the service which only sees and changes the holds of a community.
+func (hs *HoldService) InCommunity(communityID string) *HoldService {
+	c := *hs
+	c.DB = InCommunity(hs.DB, communityID)
+	c.bs = hs.bs.InCommunity(communityID)
+	c.ms = hs.ms.InCommunity(communityID)
+	return &c
+}
+
+// Hold reserves an available item for a user until the hold period is over.
+// Users cannot hold their own items, flagged items or the items of other communities.
+func (hs *HoldService) Hold(itemType ItemType, itemID, userID string) (*Hold, error) {
+	now := clockOf(hs.DB).Now()
+	h := Hold{
+		ID:        idsOf(hs.DB).NewID(),
+		ItemType:  itemType,
+		ItemID:    itemID,
+		UserID:    userID,
+		Status:    HoldActive,
+		ExpiresAt: now.Add(hs.period),
+		CreatedAt: now,
+	}
+	if err := hs.change(&h, Reserved, ItemHeld); err != nil {
+		return nil, err
+	}
+
+	return &h, nil
+}
+
+// ListByUser returns the active holds of a user, soonest to expire first.
+func (hs *HoldService) ListByUser(userID string) ([]Hold, error) {
+	holds := []Hold{}
+	if !isValidID(userID) {
+		return holds, nil
+	}
+	if r := hs.DB.Where("user_id = ? AND status = ?", userID, HoldActive).Order("expires_at").
+		Find(&holds); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return holds, nil
+}
+
+// Release ends a hold before it expires and puts the item back on the catalogue.
+// Owners release the holds on their items by relisting or withdrawing them.
+func (hs *HoldService) Release(id, userID string) (*Hold, error) {
+	h, err := hs.active(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	h.Status = HoldReleased
+	if err := hs.change(h, Available, ItemReleased); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Convert swaps a held item to its holder, as long as the hold has not expired.
+func (hs *HoldService) Convert(id, userID string) (*Hold, error) {
+	h, err := hs.active(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !clockOf(hs.DB).Now().Before(h.ExpiresAt) {
+		return nil, fmt.Errorf("hold %s:%w", id, ErrHoldExpired)
+	}
+	// Swapping the item converts the hold in the same transaction.
+	switch h.ItemType {
+	case BookItem:
+		_, err = hs.bs.SwapBook(h.ItemID, userID)
+	case MagazineItem:
+		_, err = hs.ms.SwapMagazine(h.ItemID, userID)
+	default:
+		err = fmt.Errorf("%w: unknown item type %q", ErrInvalidInput, h.ItemType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.Status = HoldConverted
+
+	return h, nil
+}
+
+// ReleaseExpired puts the items of the holds which have expired back on the catalogue and returns the holds.
+// Every expired hold is attempted, and the first error is returned once they all have been.
+func (hs *HoldService) ReleaseExpired() ([]Hold, error) {
+	var due []Hold
+	if r := hs.DB.Where("status = ? AND expires_at <= ?", HoldActive, clockOf(hs.DB).Now()).Order("expires_at").
+		Find(&due); r.Error != nil {
+		return nil, r.Error
+	}
+	released := make([]Hold, 0, len(due))
+	var firstErr error
+	for _, h := range due {
+		h := h
+		h.Status = HoldExpired
+		if err := hs.change(&h, Available, ItemHoldExpired); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		released = append(released, h)
+	}
+
+	return released, firstErr
+}
+
+// active returns an active hold of the given user.
+func (hs *HoldService) active(id, userID string) (*Hold, error) {
+	if !isValidID(id) {
+		return nil, fmt.Errorf("no hold found for id %s:%w", id, ErrRecordNotFound)
+	}
+	var h Hold
+	if r := hs.DB.Where("id = ?", id).First(&h); r.Error != nil {
+		return nil, fmt.Errorf("no hold found for id %s:%w", id, r.Error)
+	}
+	if h.UserID != userID {
+		return nil, fmt.Errorf("hold %s:%w %s", id, ErrNotOwner, userID)
+	}
+	if h.Status != HoldActive {
+		return nil, fmt.Errorf("hold %s is %s:%w", id, strings.ToLower(string(h.Status)), ErrInvalidTransition)
+	}
+	return &h, nil
+}
+
+// change moves the held item to the next status, saving the hold together with the item.
+func (hs *HoldService) change(h *Hold, next BookStatus, t ItemEventType) error {
+	switch h.ItemType {
+	case BookItem:
+		return hs.bs.hold(h, next, t)
+	case MagazineItem:
+		return hs.ms.hold(h, next, t)
+	}
+	return fmt.Errorf("%w: unknown item type %q", ErrInvalidInput, h.ItemType)
+}
+
+// checkHoldable returns an error unless a user can hold an item of the given owner. The item must not
+// be flagged, and the holder and the owner must be different, active members of the same community.
+func checkHoldable(gdb *gorm.DB, ownerID, userID string, flagged bool) error {
+	if ownerID == userID {
+		return fmt.Errorf("%w: users cannot hold their own items", ErrInvalidInput)
+	}
+	if flagged {
+		return ErrFlagged
+	}
+	if err := checkSameCommunity(gdb, ownerID, userID); err != nil {
+		return err
+	}
+	return checkActive(gdb, ownerID, userID)
+}
+
+// checkHolder returns ErrReserved unless the given user holds the item. Holds stop counting once
+// they expire, even if the sweeper has not released them yet.
+func checkHolder(gdb *gorm.DB, itemID, userID string) error {
+	var count int64
+	if isValidID(userID) {
+		if r := gdb.Model(&Hold{}).Where("item_id = ? AND user_id = ? AND status = ? AND expires_at > ?",
+			itemID, userID, HoldActive, clockOf(gdb).Now()).Count(&count); r.Error != nil {
+			return r.Error
+		}
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %s", ErrReserved, userID)
+	}
+	return nil
+}
+
+// endHolds ends the active hold on an item once an event takes the item out of reserve
+// without going through the hold: a swap by the holder converts the hold, while the owner
+// relisting or withdrawing the item, or an admin removing it, releases it.
+func endHolds(tx *gorm.DB, e ItemEvent) error {
+	status := HoldReleased
+	switch e.Type {
+	case ItemSwapped:
+		status = HoldConverted
+	case ItemRelisted, ItemWithdrawn, ItemRemoved:
+	default:
+		return nil
+	}
+	return tx.Model(&Hold{}).Where("item_id = ? AND status = ?", e.ItemID, HoldActive).
+		Update("status", status).Error
+}
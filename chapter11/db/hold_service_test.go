@@ -0,0 +1,169 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHolds(t *testing.T) {
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
+	holder := db.CreateTestUser(t, testDB)
+	other := db.CreateTestUser(t, testDB)
+	ps := mocks.NewPostingService(t)
+	clock := db.NewFakeClock(time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC))
+	bs := db.NewBookService(testDB, ps, nil, nil, clock, nil)
+	ms := db.NewMagazineService(testDB, ps, nil, clock, nil)
+	hs := db.NewHoldService(testDB, bs, ms, time.Hour, clock, nil)
+	newBook := func(t *testing.T) db.Book {
+		b, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+		require.Nil(t, err)
+		return b
+	}
+
+	t.Run("hold", func(t *testing.T) {
+		book := newBook(t)
+
+		// Act
+		h, err := hs.Hold(db.BookItem, book.ID, holder.ID)
+
+		// Assert
+		require.Nil(t, err)
+		assert.Equal(t, db.HoldActive, h.Status)
+		assert.True(t, clock.Now().Add(time.Hour).Equal(h.ExpiresAt))
+		held, err := bs.Get(book.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.Reserved, held.Status)
+		holds, err := hs.ListByUser(holder.ID)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(holds))
+		assert.Equal(t, h.ID, holds[0].ID)
+		_, err = bs.SwapBook(book.ID, other.ID)
+		assert.True(t, errors.Is(err, db.ErrReserved))
+		_, err = hs.Hold(db.BookItem, book.ID, other.ID)
+		assert.True(t, errors.Is(err, db.ErrInvalidTransition))
+	})
+
+	t.Run("invalid holds", func(t *testing.T) {
+		book := newBook(t)
+		tests := map[string]struct {
+			itemType db.ItemType
+			userID   string
+			wantErr  string
+		}{
+			"own item":     {itemType: db.BookItem, userID: owner.ID, wantErr: db.ErrInvalidInput.Error()},
+			"unknown user": {itemType: db.BookItem, userID: "unknown", wantErr: db.ErrNotMember.Error()},
+			"wrong type":   {itemType: db.MagazineItem, userID: holder.ID, wantErr: "no magazine found"},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				_, err := hs.Hold(tc.itemType, book.ID, tc.userID)
+				require.NotNil(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+			})
+		}
+	})
+
+	t.Run("release", func(t *testing.T) {
+		book := newBook(t)
+		h, err := hs.Hold(db.BookItem, book.ID, holder.ID)
+		require.Nil(t, err)
+		_, err = hs.Release(h.ID, other.ID)
+		assert.True(t, errors.Is(err, db.ErrNotOwner))
+
+		released, err := hs.Release(h.ID, holder.ID)
+
+		require.Nil(t, err)
+		assert.Equal(t, db.HoldReleased, released.Status)
+		b, err := bs.Get(book.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.Available, b.Status)
+		_, err = hs.Release(h.ID, holder.ID)
+		assert.True(t, errors.Is(err, db.ErrInvalidTransition))
+	})
+
+	t.Run("owners release holds by withdrawing", func(t *testing.T) {
+		book := newBook(t)
+		h, err := hs.Hold(db.BookItem, book.ID, holder.ID)
+		require.Nil(t, err)
+
+		_, err = bs.Withdraw(book.ID, owner.ID)
+
+		require.Nil(t, err)
+		_, err = hs.Convert(h.ID, holder.ID)
+		assert.True(t, errors.Is(err, db.ErrInvalidTransition))
+	})
+
+	t.Run("convert into a swap", func(t *testing.T) {
+		mag, err := ms.Upsert(db.Magazine{Name: "Wired", OwnerID: owner.ID})
+		require.Nil(t, err)
+		h, err := hs.Hold(db.MagazineItem, mag.ID, holder.ID)
+		require.Nil(t, err)
+		ps.On("NewMagazineOrder", mock.MatchedBy(func(m db.Magazine) bool {
+			return m.ID == mag.ID
+		})).Return(nil).Once()
+		clock.Advance(59 * time.Minute)
+
+		converted, err := hs.Convert(h.ID, holder.ID)
+
+		require.Nil(t, err)
+		assert.Equal(t, db.HoldConverted, converted.Status)
+		m, err := ms.Get(mag.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.InTransit, m.Status)
+		assert.Equal(t, holder.ID, m.OwnerID)
+		holds, err := hs.ListByUser(holder.ID)
+		require.Nil(t, err)
+		for _, active := range holds {
+			assert.NotEqual(t, h.ID, active.ID)
+		}
+	})
+
+	t.Run("expired holds are released by the sweeper", func(t *testing.T) {
+		book := newBook(t)
+		h, err := hs.Hold(db.BookItem, book.ID, holder.ID)
+		require.Nil(t, err)
+		released, err := hs.ReleaseExpired()
+		require.Nil(t, err)
+		assert.NotContains(t, holdIDs(released), h.ID)
+		clock.Advance(time.Hour)
+		_, err = hs.Convert(h.ID, holder.ID)
+		assert.True(t, errors.Is(err, db.ErrHoldExpired))
+		_, err = bs.SwapBook(book.ID, holder.ID)
+		assert.True(t, errors.Is(err, db.ErrReserved))
+
+		// Act
+		released, err = hs.ReleaseExpired()
+
+		// Assert
+		require.Nil(t, err)
+		assert.Contains(t, holdIDs(released), h.ID)
+		b, err := bs.Get(book.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.Available, b.Status)
+		events, err := db.NewHistoryService(testDB, nil, nil).ListByItem(db.BookItem, book.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.ItemHoldExpired, events[len(events)-1].Type)
+		released, err = hs.ReleaseExpired()
+		require.Nil(t, err)
+		assert.NotContains(t, holdIDs(released), h.ID)
+	})
+}
+
+// holdIDs returns the IDs of the given holds.
+func holdIDs(holds []db.Hold) []string {
+	ids := make([]string, 0, len(holds))
+	for _, h := range holds {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
@@ -0,0 +1,20 @@
+package db
+
+import "github.com/google/uuid"
+
+// isValidID returns whether the given id can be stored in a UUID column.
+func isValidID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
+
+// validIDs returns the valid IDs out of the given ones.
+func validIDs(ids []string) []string {
+	valid := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if isValidID(id) {
+			valid = append(valid, id)
+		}
+	}
+	return valid
+}
@@ -0,0 +1,264 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxImageSize limits the size of an uploaded image, in bytes.
+	MaxImageSize = 5 << 20
+	// maxImageDimension limits the width and height of an uploaded image,
+	// so that small files cannot decode into huge images.
+	maxImageDimension = 8000
+	// maxItemImages limits the number of images of an item.
+	maxItemImages = 8
+	// ThumbnailSize is the size of the longest side of thumbnails.
+	ThumbnailSize = 256
+	// imagePathPrefix is the path the API serves images under.
+	imagePathPrefix = "/images/"
+)
+
+// imageExtensions maps the supported image types to the extensions of their keys.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// ImageService contains all the functionality and dependencies for managing the images of items.
+type ImageService struct {
+	DB    *gorm.DB
+	bs    *BookService
+	ms    *MagazineService
+	store BlobStore
+}
+
+// NewImageService initialises an ImageService given its dependencies.
+func NewImageService(db *gorm.DB, bs *BookService, ms *MagazineService, store BlobStore,
+	clock Clock, ids IDGenerator) *ImageService {
+	return &ImageService{
+		DB:    withClock(db, clock, ids),
+		bs:    bs,
+		ms:    ms,
+		store: store,
+	}
+}
+
+// InCommunity returns a copy of the service which only adds images to the items of a community.
+func (is *ImageService) InCommunity(communityID string) *ImageService {
+	c := *is
+	c.DB = InCommunity(is.DB, communityID)
+	c.bs = is.bs.InCommunity(communityID)
+	c.ms = is.ms.InCommunity(communityID)
+	return &c
+}
+
+// AddBookImage validates an image uploaded by the owner of a book,
+// stores it together with its thumbnail and adds it to the book.
+func (is *ImageService) AddBookImage(bookID, userID string, data []byte) (*Book, error) {
+	b, err := is.bs.get(bookID)
+	if err != nil {
+		return nil, fmt.Errorf("no book found for id %s:%w", bookID, ErrRecordNotFound)
+	}
+	if b.OwnerID != userID {
+		return nil, fmt.Errorf("book %s:%w %s", bookID, ErrNotOwner, userID)
+	}
+	if len(b.Images) >= maxItemImages {
+		return nil, fmt.Errorf("%w: books have at most %d images", ErrInvalidInput, maxItemImages)
+	}
+	img, err := is.put(data)
+	if err != nil {
+		return nil, err
+	}
+	read := *b
+	b.Images = append(b.Images, img)
+	if err := is.bs.save(&read, b, bookEvent(*b, ItemUpdated, userID)); err != nil {
+		is.discard(img)
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// AddMagazineImage validates an image uploaded by the owner of a magazine,
+// stores it together with its thumbnail and adds it to the magazine.
+func (is *ImageService) AddMagazineImage(magID, userID string, data []byte) (*Magazine, error) {
+	m, err := is.ms.get(magID)
+	if err != nil {
+		return nil, fmt.Errorf("no magazine found for id %s:%w", magID, ErrRecordNotFound)
+	}
+	if m.OwnerID != userID {
+		return nil, fmt.Errorf("magazine %s:%w %s", magID, ErrNotOwner, userID)
+	}
+	if len(m.Images) >= maxItemImages {
+		return nil, fmt.Errorf("%w: magazines have at most %d images", ErrInvalidInput, maxItemImages)
+	}
+	img, err := is.put(data)
+	if err != nil {
+		return nil, err
+	}
+	read := *m
+	m.Images = append(m.Images, img)
+	if err := is.ms.save(&read, m, magazineEvent(*m, ItemUpdated, userID)); err != nil {
+		is.discard(img)
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Open returns the contents and content type of a stored image or thumbnail.
+func (is *ImageService) Open(key string) (io.ReadCloser, string, error) {
+	contentType := ""
+	for t, ext := range imageExtensions {
+		if path.Ext(key) == ext {
+			contentType = t
+		}
+	}
+	if contentType == "" {
+		return nil, "", fmt.Errorf("no image found for key %s:%w", key, ErrRecordNotFound)
+	}
+	r, err := is.store.Open(key)
+	if err != nil {
+		return nil, "", err
+	}
+	return r, contentType, nil
+}
+
+// put validates an image, generates its thumbnail and stores them both.
+func (is *ImageService) put(data []byte) (ItemImage, error) {
+	img, src, err := decodeImage(data)
+	if err != nil {
+		return ItemImage{}, err
+	}
+	img.ID = idsOf(is.DB).NewID()
+	thumb, thumbType, err := encodeThumbnail(src, img.ContentType)
+	if err != nil {
+		return ItemImage{}, err
+	}
+	key := img.ID + imageExtensions[img.ContentType]
+	thumbKey := img.ID + "_thumb" + imageExtensions[thumbType]
+	if err := is.store.Put(key, img.ContentType, bytes.NewReader(data)); err != nil {
+		return ItemImage{}, err
+	}
+	if err := is.store.Put(thumbKey, thumbType, bytes.NewReader(thumb)); err != nil {
+		is.store.Delete(key)
+		return ItemImage{}, err
+	}
+	img.URL = imagePathPrefix + key
+	img.ThumbnailURL = imagePathPrefix + thumbKey
+	return img, nil
+}
+
+// discard removes the blobs of an image which could not be added to its item.
+func (is *ImageService) discard(img ItemImage) {
+	is.store.Delete(path.Base(img.URL))
+	is.store.Delete(path.Base(img.ThumbnailURL))
+}
+
+// decodeImage checks the size and type of an uploaded image and decodes it.
+// The type is sniffed from the data, whatever type the upload claims to be.
+func decodeImage(data []byte) (ItemImage, image.Image, error) {
+	if len(data) > MaxImageSize {
+		return ItemImage{}, nil, fmt.Errorf("%w: images are at most %d bytes", ErrImageTooLarge, MaxImageSize)
+	}
+	contentType := http.DetectContentType(data)
+	if _, ok := imageExtensions[contentType]; !ok {
+		return ItemImage{}, nil, fmt.Errorf("%w %q, use JPEG, PNG or GIF", ErrUnsupportedImage, contentType)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == nil && (cfg.Width == 0 || cfg.Height == 0) {
+		err = fmt.Errorf("empty image")
+	}
+	if err != nil {
+		return ItemImage{}, nil, fmt.Errorf("%w: invalid image:%v", ErrUnsupportedImage, err)
+	}
+	if cfg.Width > maxImageDimension || cfg.Height > maxImageDimension {
+		return ItemImage{}, nil, fmt.Errorf("%w: images are at most %dx%d pixels", ErrImageTooLarge,
+			maxImageDimension, maxImageDimension)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ItemImage{}, nil, fmt.Errorf("%w: invalid image:%v", ErrUnsupportedImage, err)
+	}
+	return ItemImage{
+		ContentType: contentType,
+		Size:        len(data),
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+	}, src, nil
+}
+
+// encodeThumbnail scales an image down to a thumbnail and encodes it.
+// Photos are encoded as JPEG, while other images are encoded as PNG to keep their sharp edges.
+func encodeThumbnail(src image.Image, contentType string) ([]byte, string, error) {
+	thumb := Thumbnail(src, ThumbnailSize)
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), contentType, nil
+	}
+	if err := png.Encode(&buf, thumb); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// Thumbnail scales an image down so that its longest side is at most size pixels, keeping its aspect ratio.
+// Each pixel of the thumbnail averages the pixels of the image it covers. Images which are small enough
+// are copied as they are.
+func Thumbnail(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	switch {
+	case w > size && w >= h:
+		tw, th = size, h*size/w
+	case h > size:
+		tw, th = w*size/h, size
+	}
+	if tw == 0 {
+		tw = 1
+	}
+	if th == 0 {
+		th = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := span(b.Min.Y, y, h, th)
+		for x := 0; x < tw; x++ {
+			x0, x1 := span(b.Min.X, x, w, tw)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// span returns the range of source pixels covered by the i-th of n thumbnail pixels along a side of length l.
+func span(origin, i, l, n int) (int, int) {
+	from, to := i*l/n, (i+1)*l/n
+	if to <= from {
+		to = from + 1
+	}
+	return origin + from, origin + to
+}
@@ -0,0 +1,217 @@
+package db_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnail(t *testing.T) {
+	tests := map[string]struct {
+		width, height         int
+		wantWidth, wantHeight int
+	}{
+		"landscape": {width: 1024, height: 512, wantWidth: 256, wantHeight: 128},
+		"portrait":  {width: 300, height: 600, wantWidth: 128, wantHeight: 256},
+		"square":    {width: 512, height: 512, wantWidth: 256, wantHeight: 256},
+		"small":     {width: 100, height: 50, wantWidth: 100, wantHeight: 50},
+		"thin":      {width: 2000, height: 2, wantWidth: 256, wantHeight: 1},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := image.NewRGBA(image.Rect(0, 0, tc.width, tc.height))
+			got := db.Thumbnail(src, db.ThumbnailSize)
+			assert.Equal(t, tc.wantWidth, got.Bounds().Dx())
+			assert.Equal(t, tc.wantHeight, got.Bounds().Dy())
+		})
+	}
+
+	t.Run("averages pixels", func(t *testing.T) {
+		// Arrange
+		src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		src.Set(0, 0, color.RGBA{R: 255, A: 255})
+		src.Set(1, 0, color.RGBA{R: 255, A: 255})
+		src.Set(0, 1, color.RGBA{B: 255, A: 255})
+		src.Set(1, 1, color.RGBA{B: 255, A: 255})
+
+		// Act
+		got := db.Thumbnail(src, 1)
+
+		// Assert
+		assert.Equal(t, color.RGBA{R: 127, B: 127, A: 255}, got.RGBAAt(0, 0))
+	})
+}
+
+func TestFileBlobStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "images")
+	store, err := db.NewFileBlobStore(dir)
+	require.Nil(t, err)
+
+	t.Run("put and open", func(t *testing.T) {
+		require.Nil(t, store.Put("a.png", "image/png", strings.NewReader("first")))
+		require.Nil(t, store.Put("a.png", "image/png", strings.NewReader("second")))
+		r, err := store.Open("a.png")
+		require.Nil(t, err)
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		require.Nil(t, err)
+		assert.Equal(t, "second", string(data))
+		files, err := os.ReadDir(dir)
+		require.Nil(t, err)
+		assert.Equal(t, 1, len(files))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.Nil(t, store.Put("b.png", "image/png", strings.NewReader("data")))
+		require.Nil(t, store.Delete("b.png"))
+		require.Nil(t, store.Delete("b.png"))
+		_, err := store.Open("b.png")
+		assert.ErrorIs(t, err, db.ErrRecordNotFound)
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, key := range []string{"", "../a.png", "dir/a.png", ".hidden"} {
+			_, err := store.Open(key)
+			assert.ErrorIs(t, err, db.ErrRecordNotFound, key)
+			assert.NotNil(t, store.Put(key, "image/png", strings.NewReader("data")), key)
+		}
+	})
+}
+
+func TestAddBookImage(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	store, err := db.NewFileBlobStore(t.TempDir())
+	require.Nil(t, err)
+	is := db.NewImageService(testDB, bs, ms, store, nil, nil)
+	owner := db.CreateTestUser(t, testDB)
+	stranger := db.CreateTestUser(t, testDB)
+	book, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+	require.Nil(t, err)
+	photo := encodeImage(t, "image/jpeg", 800, 600)
+	drawing := encodeImage(t, "image/png", 300, 900)
+
+	t.Run("photo", func(t *testing.T) {
+		// Act
+		got, err := is.AddBookImage(book.ID, owner.ID, photo)
+
+		// Assert
+		require.Nil(t, err)
+		require.Equal(t, 1, len(got.Images))
+		img := got.Images[0]
+		assert.Equal(t, "image/jpeg", img.ContentType)
+		assert.Equal(t, len(photo), img.Size)
+		assert.Equal(t, 800, img.Width)
+		assert.Equal(t, 600, img.Height)
+		assert.Equal(t, "/images/"+img.ID+".jpg", img.URL)
+		assert.Equal(t, "/images/"+img.ID+"_thumb.jpg", img.ThumbnailURL)
+		r, contentType, err := is.Open(filepath.Base(img.ThumbnailURL))
+		require.Nil(t, err)
+		defer r.Close()
+		assert.Equal(t, "image/jpeg", contentType)
+		cfg, err := jpeg.DecodeConfig(r)
+		require.Nil(t, err)
+		assert.Equal(t, db.ThumbnailSize, cfg.Width)
+		assert.Equal(t, 192, cfg.Height)
+	})
+
+	t.Run("drawing", func(t *testing.T) {
+		got, err := is.AddBookImage(book.ID, owner.ID, drawing)
+		require.Nil(t, err)
+		require.Equal(t, 2, len(got.Images))
+		assert.Equal(t, "image/png", got.Images[1].ContentType)
+		saved, err := bs.Get(book.ID)
+		require.Nil(t, err)
+		assert.Equal(t, got.Images, saved.Images)
+	})
+
+	t.Run("images are kept on upsert", func(t *testing.T) {
+		saved, err := bs.Get(book.ID)
+		require.Nil(t, err)
+		saved.Name = "Dune Messiah"
+		saved.Images = nil
+		got, err := bs.Upsert(*saved)
+		require.Nil(t, err)
+		assert.Equal(t, 2, len(got.Images))
+	})
+
+	tests := map[string]struct {
+		bookID, userID string
+		data           []byte
+		wantErr        error
+	}{
+		"not the owner": {bookID: book.ID, userID: stranger.ID, data: photo, wantErr: db.ErrNotOwner},
+		"unknown book":  {bookID: uuid.NewString(), userID: owner.ID, data: photo, wantErr: db.ErrRecordNotFound},
+		"not an image":  {bookID: book.ID, userID: owner.ID, data: []byte("Dune"), wantErr: db.ErrUnsupportedImage},
+		"corrupt image": {bookID: book.ID, userID: owner.ID, data: photo[:100], wantErr: db.ErrUnsupportedImage},
+		"too large":     {bookID: book.ID, userID: owner.ID, data: make([]byte, db.MaxImageSize+1), wantErr: db.ErrImageTooLarge},
+		"too many pixels": {bookID: book.ID, userID: owner.ID, data: encodeImage(t, "image/png", 9000, 1),
+			wantErr: db.ErrImageTooLarge},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := is.AddBookImage(tc.bookID, tc.userID, tc.data)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Nil(t, got)
+		})
+	}
+}
+
+func TestAddImageStoreFailure(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	store := mocks.NewBlobStore(t)
+	store.On("Put", mock.AnythingOfType("string"), "image/png", mock.Anything).Return(nil).Once()
+	store.On("Put", mock.AnythingOfType("string"), "image/png", mock.Anything).Return(os.ErrPermission).Once()
+	store.On("Delete", mock.AnythingOfType("string")).Return(nil).Once()
+	is := db.NewImageService(testDB, bs, ms, store, nil, nil)
+	owner := db.CreateTestUser(t, testDB)
+	mag, err := ms.Upsert(db.Magazine{Name: "Wired", IssueNumber: 7, OwnerID: owner.ID})
+	require.Nil(t, err)
+
+	// Act
+	got, err := is.AddMagazineImage(mag.ID, owner.ID, encodeImage(t, "image/png", 10, 10))
+
+	// Assert
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.Nil(t, got)
+	saved, err := ms.Get(mag.ID)
+	require.Nil(t, err)
+	assert.Empty(t, saved.Images)
+}
+
+// encodeImage encodes a JPEG or PNG image of the given size with a gradient, so that it is not trivially compressed.
+func encodeImage(t *testing.T, contentType string, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		require.Nil(t, jpeg.Encode(&buf, img, nil))
+	} else {
+		require.Nil(t, png.Encode(&buf, img))
+	}
+	return buf.Bytes()
+}
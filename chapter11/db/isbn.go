@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BookMetadata contains the bibliographic details of an edition of a book.
+type BookMetadata struct {
+	ISBN      string `json:"isbn"`
+	Title     string `json:"title"`
+	Author    string `json:"author"`
+	Publisher string `json:"publisher"`
+	Year      int    `json:"year"`
+}
+
+// MetadataProvider interface wraps around external book metadata lookups.
+type MetadataProvider interface {
+	// Lookup returns the metadata of a normalized ISBN, or ErrMetadataNotFound if it is unknown.
+	Lookup(isbn string) (*BookMetadata, error)
+}
+
+// NormalizeISBN validates the checksum of an ISBN-10 or ISBN-13, ignoring hyphens and spaces,
+// and returns it as the 13 digits of its ISBN-13, so that every edition has a single spelling.
+func NormalizeISBN(isbn string) (string, error) {
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+	switch {
+	case len(digits) == 10 && isISBN10(digits):
+		return isbn13("978" + digits[:9]), nil
+	case len(digits) == 13 && isISBN13(digits):
+		return digits, nil
+	}
+	return "", fmt.Errorf("%w: invalid isbn %q", ErrInvalidInput, isbn)
+}
+
+// isISBN10 returns whether s has 9 digits followed by a digit or X, with a valid checksum.
+func isISBN10(s string) bool {
+	sum := 0
+	for i, c := range s {
+		var d int
+		switch {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c == 'X' && i == 9:
+			d = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * d
+	}
+	return sum%11 == 0
+}
+
+// isISBN13 returns whether s has 13 digits with a Bookland prefix and a valid checksum.
+func isISBN13(s string) bool {
+	if !strings.HasPrefix(s, "978") && !strings.HasPrefix(s, "979") {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return isbn13(s[:12]) == s
+}
+
+// isbn13 appends the check digit to the first 12 digits of an ISBN-13.
+func isbn13(digits string) string {
+	sum := 0
+	for i, c := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(c-'0')
+	}
+	return fmt.Sprintf("%s%d", digits, (10-sum%10)%10)
+}
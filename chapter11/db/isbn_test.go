@@ -0,0 +1,103 @@
+package db_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"isbn-13":                  {input: "9780441172719", want: "9780441172719"},
+		"isbn-13 with hyphens":     {input: "978-0-441-17271-9", want: "9780441172719"},
+		"isbn-13 with spaces":      {input: "978 0 14 143958 7", want: "9780141439587"},
+		"isbn-13 with 979 prefix":  {input: "979-10-90636-07-1", want: "9791090636071"},
+		"isbn-10":                  {input: "0441172717", want: "9780441172719"},
+		"isbn-10 with hyphens":     {input: "0-14-143958-0", want: "9780141439587"},
+		"isbn-10 with x check":     {input: "080442957X", want: "9780804429573"},
+		"isbn-10 lower case check": {input: "080442957x", want: "9780804429573"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := db.NormalizeISBN(tc.input)
+			require.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNormalizeISBNInvalid(t *testing.T) {
+	tests := map[string]string{
+		"empty":                  "",
+		"isbn-13 wrong checksum": "9780441172710",
+		"isbn-10 wrong checksum": "0441172718",
+		"isbn-13 without prefix": "1234567890128",
+		"isbn-13 with x":         "978044117271X",
+		"isbn-10 x not last":     "04411727X7",
+		"too short":              "044117271",
+		"too long":               "97804411727190",
+		"letters":                "978044117271a",
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := db.NormalizeISBN(input)
+			assert.ErrorIs(t, err, db.ErrInvalidInput)
+		})
+	}
+}
+
+func TestFixtureMetadataProvider(t *testing.T) {
+	// Arrange
+	md, err := db.NewFixtureMetadataProvider(filepath.Join("testdata", "book_metadata.json"))
+	require.Nil(t, err)
+
+	t.Run("known isbn", func(t *testing.T) {
+		// Act
+		m, err := md.Lookup("9780441172719")
+
+		// Assert
+		require.Nil(t, err)
+		assert.Equal(t, db.BookMetadata{
+			ISBN:      "9780441172719",
+			Title:     "Dune",
+			Author:    "Frank Herbert",
+			Publisher: "Ace Books",
+			Year:      1990,
+		}, *m)
+	})
+
+	t.Run("unknown isbn", func(t *testing.T) {
+		// Act
+		m, err := md.Lookup("9780141439518")
+
+		// Assert
+		assert.ErrorIs(t, err, db.ErrMetadataNotFound)
+		assert.Nil(t, m)
+	})
+}
+
+func TestNewFixtureMetadataProviderInvalid(t *testing.T) {
+	tests := map[string]string{
+		"invalid json": `{"isbn":`,
+		"invalid isbn": `[{"isbn":"123","title":"Nothing"}]`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "metadata.json")
+			require.Nil(t, os.WriteFile(path, []byte(data), 0o600))
+			_, err := db.NewFixtureMetadataProvider(path)
+			assert.NotNil(t, err)
+		})
+	}
+	t.Run("missing file", func(t *testing.T) {
+		_, err := db.NewFixtureMetadataProvider(filepath.Join(t.TempDir(), "missing.json"))
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,155 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxTags limits the number of tags of an item.
+	maxTags = 10
+	// maxTagLength limits the length of a tag.
+	maxTagLength = 30
+)
+
+// languagePattern matches ISO 639-1 language codes, once they have been lower cased.
+var languagePattern = regexp.MustCompile(`^[a-z]{2}$`)
+
+// ItemCondition contains the condition grades of items.
+type ItemCondition string
+
+const (
+	ConditionNew     ItemCondition = "NEW"
+	ConditionLikeNew ItemCondition = "LIKE_NEW"
+	ConditionGood    ItemCondition = "GOOD"
+	ConditionFair    ItemCondition = "FAIR"
+	ConditionPoor    ItemCondition = "POOR"
+)
+
+// ItemConditions returns all the condition grades, from best to worst.
+func ItemConditions() []ItemCondition {
+	return []ItemCondition{ConditionNew, ConditionLikeNew, ConditionGood, ConditionFair, ConditionPoor}
+}
+
+// Tags contains the lower case tags of an item, stored as a JSON array.
+type Tags []string
+
+// Value implements driver.Valuer.
+func (t Tags) Value() (driver.Value, error) {
+	return jsonValue([]string(t))
+}
+
+// Scan implements sql.Scanner. Empty arrays are scanned as nil, like the tags of new items.
+func (t *Tags) Scan(src any) error {
+	var tags []string
+	if err := jsonScan(src, &tags); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	*t = tags
+	return nil
+}
+
+// ItemImage is an uploaded image of an item. The URLs are served by the API.
+type ItemImage struct {
+	ID           string `json:"id"`
+	ContentType  string `json:"content_type"`
+	Size         int    `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// ItemImages contains the images of an item, stored as a JSON array.
+type ItemImages []ItemImage
+
+// Value implements driver.Valuer.
+func (im ItemImages) Value() (driver.Value, error) {
+	return jsonValue([]ItemImage(im))
+}
+
+// Scan implements sql.Scanner. Empty arrays are scanned as nil, like the images of new items.
+func (im *ItemImages) Scan(src any) error {
+	var images []ItemImage
+	if err := jsonScan(src, &images); err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		images = nil
+	}
+	*im = images
+	return nil
+}
+
+// normalizeDetails validates the condition, language and tags of an item.
+// Conditions are upper cased and languages lower cased, while tags are lower cased, sorted and deduplicated.
+func normalizeDetails(condition *ItemCondition, language *string, tags *Tags) error {
+	*condition = ItemCondition(strings.ToUpper(strings.TrimSpace(string(*condition))))
+	if *condition != "" && !isItemCondition(*condition) {
+		return fmt.Errorf("%w: unknown condition %q", ErrInvalidInput, *condition)
+	}
+	*language = strings.ToLower(strings.TrimSpace(*language))
+	if *language != "" && !languagePattern.MatchString(*language) {
+		return fmt.Errorf("%w: language %q is not an ISO 639-1 code", ErrInvalidInput, *language)
+	}
+	seen := make(map[string]bool, len(*tags))
+	var normalized Tags
+	for _, tag := range *tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > maxTagLength {
+			return fmt.Errorf("%w: tags need between 1 and %d characters", ErrInvalidInput, maxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxTags {
+		return fmt.Errorf("%w: items have at most %d tags", ErrInvalidInput, maxTags)
+	}
+	sort.Strings(normalized)
+	*tags = normalized
+	return nil
+}
+
+func isItemCondition(c ItemCondition) bool {
+	for _, known := range ItemConditions() {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonValue stores a slice as a JSON array, storing nil slices as empty arrays.
+func jsonValue[T any](s []T) (driver.Value, error) {
+	if s == nil {
+		s = []T{}
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// jsonScan reads a JSON column into v.
+func jsonScan(src any, v any) error {
+	switch data := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(data), v)
+	case []byte:
+		return json.Unmarshal(data, v)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, v)
+	}
+}
@@ -2,36 +2,81 @@ package db
 
 import (
 	"fmt"
+	"time"
 
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // Magazine contains all the fields for representing a magazine.
+// Images are only added by uploading them, so they are kept when a magazine is updated.
+// Flagged magazines are hidden from the catalogue until an admin unflags or removes them.
+// The timestamps are set with the clock of the service whenever a magazine is saved.
 type Magazine struct {
-	ID          string `json:"id" gorm:"primaryKey"`
-	Name        string `json:"name"`
-	IssueNumber int    `json:"issue_number"`
-	OwnerID     string `json:"owner_id"`
-	Status      string `json:"status"`
+	ID          string         `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name"`
+	IssueNumber int            `json:"issue_number"`
+	Condition   ItemCondition  `json:"condition,omitempty"`
+	Language    string         `json:"language,omitempty"`
+	Tags        Tags           `json:"tags,omitempty"`
+	Images      ItemImages     `json:"images,omitempty"`
+	OwnerID     string         `json:"owner_id"`
+	Status      MagazineStatus `json:"status"`
+	Flagged     bool           `json:"flagged,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
 }
 
 // MagazineService contains all the functionality and dependencies for managing magazines.
 type MagazineService struct {
-	DB *gorm.DB
-	ps PostingService
+	DB    *gorm.DB
+	ps    PostingService
+	pub   EventPublisher
+	cache *readThrough
 }
 
 // NewMagazineService initialises a MagazineService given its dependencies.
-func NewMagazineService(db *gorm.DB, ps PostingService) *MagazineService {
+// The publisher is optional.
+func NewMagazineService(db *gorm.DB, ps PostingService, pub EventPublisher,
+	clock Clock, ids IDGenerator) *MagazineService {
 	return &MagazineService{
-		DB: db,
-		ps: ps,
+		DB:  withClock(db, clock, ids),
+		ps:  ps,
+		pub: pub,
 	}
 }
 
-// Get returns a given magazine or error if none exists.
+// WithCache configures the cache which the available magazines and single magazines are read through.
+// Cached magazines are invalidated whenever a change to them is published.
+func (ms *MagazineService) WithCache(c Cache) *MagazineService {
+	ms.cache = newReadThrough(c)
+	return ms
+}
+
+// CacheStats returns how many reads were served by the cache, if there is one.
+func (ms *MagazineService) CacheStats() CacheStats {
+	return ms.cache.stats()
+}
+
+// InCommunity returns a copy of the service which only sees and changes the magazines of a community.
+// The copy reads through the same cache, under keys of its own.
+func (ms *MagazineService) InCommunity(communityID string) *MagazineService {
+	c := *ms
+	c.DB = InCommunity(ms.DB, communityID)
+	return &c
+}
+
+// Get returns a given magazine or error if none exists. It is read through the cache, if there is one.
 func (ms *MagazineService) Get(id string) (*Magazine, error) {
+	return cached(ms.cache, scopedCacheKey(ms.DB, magazineCacheKey(id)), func() (*Magazine, error) {
+		return ms.get(id)
+	})
+}
+
+// get reads a magazine from the database, for changes which must not start from a stale copy.
+func (ms *MagazineService) get(id string) (*Magazine, error) {
+	if !isValidID(id) {
+		return nil, gorm.ErrRecordNotFound
+	}
 	var m Magazine
 	if r := ms.DB.Where("id = ?", id).First(&m); r.Error != nil {
 		return nil, r.Error
@@ -40,21 +85,59 @@ func (ms *MagazineService) Get(id string) (*Magazine, error) {
 	return &m, nil
 }
 
-// Upsert creates or updates a magazine.
-func (ms *MagazineService) Upsert(m Magazine) Magazine {
+// Upsert creates or updates a magazine. Conditions, languages and tags are validated and normalized.
+// Updates cannot change the owner of a magazine, which only changes hands by being swapped.
+func (ms *MagazineService) Upsert(m Magazine) (Magazine, error) {
+	if err := normalizeDetails(&m.Condition, &m.Language, &m.Tags); err != nil {
+		return Magazine{}, err
+	}
+	if err := checkActive(ms.DB, m.OwnerID); err != nil {
+		return Magazine{}, err
+	}
 	var em Magazine
-	if r := ms.DB.Where("id = ?", m.ID).First(&em); r.Error != nil {
-		m.ID = uuid.NewString()
-		m.Status = Available.String()
+	var prev *Magazine
+	eventType := ItemUpdated
+	if !isValidID(m.ID) || ms.DB.Where("id = ?", m.ID).First(&em).Error != nil {
+		if err := checkMember(ms.DB, m.OwnerID); err != nil {
+			return Magazine{}, err
+		}
+		m.ID = idsOf(ms.DB).NewID()
+		m.Status = Available
+		m.Images = nil
+		m.Flagged = false
+		m.CreatedAt, m.UpdatedAt = time.Time{}, time.Time{}
+		eventType = ItemCreated
+	} else {
+		// Items only change hands within the community of their owner, and only by being swapped.
+		if err := checkSameCommunity(ms.DB, em.OwnerID, m.OwnerID); err != nil {
+			return Magazine{}, err
+		}
+		if m.OwnerID != em.OwnerID {
+			return Magazine{}, fmt.Errorf("mag %s:%w %s", m.ID, ErrNotOwner, m.OwnerID)
+		}
+		prev = &em
+		// The status and flag only change through the lifecycle transitions and moderation.
+		m.Status = em.Status
+		m.Images = em.Images
+		m.Flagged = em.Flagged
+		m.CreatedAt = em.CreatedAt
 	}
-	ms.DB.Save(&m)
-	return m
+	if err := ms.save(prev, &m, magazineEvent(m, eventType, m.OwnerID)); err != nil {
+		return Magazine{}, err
+	}
+	return m, nil
 }
 
-// List returns the list of available magazines.
+// List returns the list of available magazines, leaving out flagged magazines and the magazines
+// of suspended users. It is read through the cache, if there is one.
 func (ms *MagazineService) List() ([]Magazine, error) {
+	return cached(ms.cache, scopedCacheKey(ms.DB, availableMagazinesKey), ms.list)
+}
+
+func (ms *MagazineService) list() ([]Magazine, error) {
 	var items []Magazine
-	if result := ms.DB.Where("status = ?", Available.String()).Find(&items); result.Error != nil {
+	q := ms.DB.Where("status = ? AND NOT flagged", Available).Where(activeOwners)
+	if result := q.Find(&items); result.Error != nil {
 		return nil, result.Error
 	}
 
@@ -71,21 +154,272 @@ func (ms *MagazineService) ListByUser(userID string) ([]Magazine, error) {
 	return items, nil
 }
 
-// SwapMagazine checks whether a magazine is available and, if possible, marks it as swapped.
+// ListByUsers returns the magazines of all the given users, in a single query.
+func (ms *MagazineService) ListByUsers(userIDs []string) ([]Magazine, error) {
+	var items []Magazine
+	ids := validIDs(userIDs)
+	if len(ids) == 0 {
+		return items, nil
+	}
+	if result := ms.DB.Where("owner_id IN ?", ids).Find(&items); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return items, nil
+}
+
+// SwapMagazine checks whether a magazine is available and, if possible, sends it to its new owner.
+// The magazine stays in transit until the new owner confirms its delivery. Of two concurrent swaps
+// of the same magazine, only the first one goes through.
 func (ms *MagazineService) SwapMagazine(magID, userID string) (*Magazine, error) {
+	if !isValidID(magID) {
+		return nil, fmt.Errorf("no magazine found for id %s:%w", magID, ErrRecordNotFound)
+	}
 	var m Magazine
 	if r := ms.DB.Where("id = ?", magID).First(&m); r.Error != nil {
-		return nil, fmt.Errorf("no magazine found for id %s:%v", magID, r.Error)
+		return nil, fmt.Errorf("no magazine found for id %s:%w", magID, r.Error)
+	}
+	if m.OwnerID == userID {
+		return nil, fmt.Errorf("%w: user %s already owns magazine %s", ErrInvalidInput, userID, magID)
+	}
+	if err := checkTransition(m.Status, InTransit); err != nil {
+		return nil, fmt.Errorf("mag %s is not available for swapping:%w", magID, err)
+	}
+	if m.Flagged {
+		return nil, fmt.Errorf("mag %s:%w", magID, ErrFlagged)
 	}
-	if m.Status != Available.String() {
-		return nil, fmt.Errorf("mag %s is not available for swapping", magID)
+	if err := checkSameCommunity(ms.DB, m.OwnerID, userID); err != nil {
+		return nil, fmt.Errorf("mag %s:%w", magID, err)
 	}
+	if err := checkActive(ms.DB, m.OwnerID, userID); err != nil {
+		return nil, fmt.Errorf("mag %s:%w", magID, err)
+	}
+	// Reserved magazines are only swapped by their holder, which converts the hold.
+	if m.Status == Reserved {
+		if err := checkHolder(ms.DB, m.ID, userID); err != nil {
+			return nil, fmt.Errorf("mag %s:%w", magID, err)
+		}
+	}
+	read := m
 	m.OwnerID = userID
-	m.Status = Swapped.String()
-	sm := ms.Upsert(m)
-	if err := ms.ps.NewMagazineOrder(sm); err != nil {
+	m.Status = InTransit
+	e := magazineEvent(m, ItemSwapped, userID)
+	e.PreviousOwnerID = read.OwnerID
+	if err := ms.save(&read, &m, e); err != nil {
+		return nil, err
+	}
+	if err := ms.ps.NewMagazineOrder(m); err != nil {
+		// The magazine never left its previous owner, so it goes back on the catalogue.
+		swapped := m
+		m.OwnerID = read.OwnerID
+		m.Status = Available
+		if serr := ms.save(&swapped, &m, magazineEvent(m, ItemPostingFailed, userID)); serr != nil {
+			return nil, serr
+		}
+		return nil, err
+	}
+	posted := magazineEvent(m, ItemPosted, userID)
+	if err := ms.DB.Transaction(func(tx *gorm.DB) error {
+		return ms.record(tx, m, &posted)
+	}); err != nil {
 		return nil, err
 	}
+	ms.publish(posted)
+
+	return &m, nil
+}
+
+// ConfirmDelivery marks a magazine in transit as swapped, once its new owner has received it.
+func (ms *MagazineService) ConfirmDelivery(magID, userID string) (*Magazine, error) {
+	return ms.transition(magID, userID, Swapped, ItemDelivered)
+}
+
+// Relist makes a swapped, withdrawn or held magazine available for swapping again.
+// Relisting a held magazine releases its hold.
+func (ms *MagazineService) Relist(magID, userID string) (*Magazine, error) {
+	return ms.transition(magID, userID, Available, ItemRelisted)
+}
+
+// Withdraw takes a magazine off the catalogue, without swapping it.
+func (ms *MagazineService) Withdraw(magID, userID string) (*Magazine, error) {
+	return ms.transition(magID, userID, Withdrawn, ItemWithdrawn)
+}
 
-	return &sm, nil
+// transition moves a magazine owned by the given user to the next status.
+func (ms *MagazineService) transition(magID, userID string, next BookStatus, t ItemEventType) (*Magazine, error) {
+	m, err := ms.get(magID)
+	if err != nil {
+		return nil, fmt.Errorf("no magazine found for id %s:%w", magID, err)
+	}
+	if m.OwnerID != userID {
+		return nil, fmt.Errorf("mag %s:%w %s", magID, ErrNotOwner, userID)
+	}
+	if err := checkTransition(m.Status, next); err != nil {
+		return nil, fmt.Errorf("mag %s:%w", magID, err)
+	}
+	// Suspended owners cannot put their magazines back on the catalogue.
+	if next == Available {
+		if err := checkActive(ms.DB, userID); err != nil {
+			return nil, fmt.Errorf("mag %s:%w", magID, err)
+		}
+	}
+	read := *m
+	m.Status = next
+	if err := ms.save(&read, m, magazineEvent(*m, t, userID)); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// moderate applies the change of an admin to a magazine, recording it both in the history of the magazine
+// and in the audit log of admin actions, in a single transaction.
+func (ms *MagazineService) moderate(magID string, t ItemEventType, a AdminAction,
+	change func(*Magazine) error) (*Magazine, error) {
+	m, err := ms.get(magID)
+	if err != nil {
+		return nil, fmt.Errorf("no magazine found for id %s:%w", magID, err)
+	}
+	read := *m
+	if err := change(m); err != nil {
+		return nil, fmt.Errorf("mag %s:%w", magID, err)
+	}
+	e := magazineEvent(*m, t, a.AdminID)
+	if err := ms.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockUnchanged(tx, "magazines", read.ID, read.Status, read.OwnerID, read.Flagged); err != nil {
+			return err
+		}
+		if r := tx.Save(m); r.Error != nil {
+			return r.Error
+		}
+		if err := ms.record(tx, *m, &e); err != nil {
+			return err
+		}
+		return recordAdminAction(tx, &a)
+	}); err != nil {
+		return nil, err
+	}
+	ms.publish(e)
+
+	return m, nil
+}
+
+// hold moves a magazine to the next status as a hold on it starts or ends, saving the hold
+// together with the magazine and its history in a single transaction.
+func (ms *MagazineService) hold(h *Hold, next BookStatus, t ItemEventType) error {
+	m, err := ms.get(h.ItemID)
+	if err != nil {
+		return fmt.Errorf("no magazine found for id %s:%w", h.ItemID, err)
+	}
+	if err := checkTransition(m.Status, next); err != nil {
+		return fmt.Errorf("mag %s:%w", h.ItemID, err)
+	}
+	if next == Reserved {
+		if err := checkHoldable(ms.DB, m.OwnerID, h.UserID, m.Flagged); err != nil {
+			return fmt.Errorf("mag %s:%w", h.ItemID, err)
+		}
+	}
+	read := *m
+	m.Status = next
+	e := magazineEvent(*m, t, h.UserID)
+	if err := ms.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockUnchanged(tx, "magazines", read.ID, read.Status, read.OwnerID, read.Flagged); err != nil {
+			return err
+		}
+		if r := tx.Save(m); r.Error != nil {
+			return r.Error
+		}
+		if r := tx.Save(h); r.Error != nil {
+			return r.Error
+		}
+		return ms.record(tx, *m, &e)
+	}); err != nil {
+		return err
+	}
+	ms.publish(e)
+	return nil
+}
+
+// save stores a magazine and appends the given event to its history in a single transaction.
+// prev is the magazine as it was read before the change, or nil for a new magazine, and the change fails
+// if the magazine has changed since. m is stamped with the time it was saved at.
+// The event is only published once the transaction has been committed.
+func (ms *MagazineService) save(prev *Magazine, m *Magazine, e ItemEvent) error {
+	if err := ms.DB.Transaction(func(tx *gorm.DB) error {
+		if prev != nil {
+			if err := lockUnchanged(tx, "magazines", prev.ID, prev.Status, prev.OwnerID, prev.Flagged); err != nil {
+				return err
+			}
+		}
+		if r := tx.Save(m); r.Error != nil {
+			return r.Error
+		}
+		return ms.record(tx, *m, &e)
+	}); err != nil {
+		return err
+	}
+	ms.publish(e)
+	return nil
+}
+
+// record appends an event to the history of a magazine and notifies the users it concerns.
+// Newly created magazines are matched against the wishlists of other users.
+// Swaps make the new owner pay the previous owner in credits, which are refunded if the posting fails.
+func (ms *MagazineService) record(tx *gorm.DB, m Magazine, e *ItemEvent) error {
+	if err := recordEvent(tx, e, m); err != nil {
+		return err
+	}
+	switch e.Type {
+	case ItemCreated:
+		return notifyWishlists(tx, MagazineItem, m.ID, m.OwnerID, m.Name, "")
+	case ItemSwapped:
+		if err := transferCredits(tx, *e); err != nil {
+			return err
+		}
+		return notifySwap(tx, *e, m.Name)
+	case ItemPosted:
+		return notifySwap(tx, *e, m.Name)
+	case ItemPostingFailed:
+		return refundCredits(tx, *e)
+	}
+	return nil
+}
+
+// publish tells the publisher, if any, about a recorded event.
+// The cached copies of the magazine and of the available magazines, as they are and within the community
+// of its owner, are invalidated first, as they may be stale.
+func (ms *MagazineService) publish(e ItemEvent) {
+	if ms.cache != nil {
+		ms.cache.invalidate(communityCacheKeys(ms.DB, e.OwnerID, magazineCacheKey(e.ItemID), availableMagazinesKey)...)
+	}
+	if ms.pub != nil {
+		ms.pub.Publish(e)
+	}
+}
+
+// forgetAvailable invalidates the cached available magazines, as they are and within the community
+// of a user, once the user has been suspended or reactivated.
+func (ms *MagazineService) forgetAvailable(userID string) {
+	if ms.cache != nil {
+		ms.cache.invalidate(communityCacheKeys(ms.DB, userID, availableMagazinesKey)...)
+	}
+}
+
+// availableMagazinesKey is the cache key of the available magazines.
+const availableMagazinesKey = "magazines:available"
+
+// magazineCacheKey returns the cache key of a magazine.
+func magazineCacheKey(id string) string {
+	return "magazines:" + id
+}
+
+// magazineEvent initialises a ledger event of the given type for a magazine.
+func magazineEvent(m Magazine, t ItemEventType, actorID string) ItemEvent {
+	return ItemEvent{
+		ItemID:   m.ID,
+		ItemType: MagazineItem,
+		Type:     t,
+		ActorID:  actorID,
+		OwnerID:  m.OwnerID,
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
@@ -16,12 +17,15 @@ import (
 func TestGetMagazine(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
 	t.Run("initial mag", func(t *testing.T) {
-		ms := db.NewMagazineService(testDB, nil)
-		em := ms.Upsert(db.Magazine{
-			Name:   "New mag",
-			Status: db.Available.String(),
+		ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+		em, err := ms.Upsert(db.Magazine{
+			Name:    "New mag",
+			Status:  db.Available,
+			OwnerID: owner.ID,
 		})
+		require.Nil(t, err)
 		log.Println(em)
 		assert.NotNil(t, em)
 
@@ -49,7 +53,7 @@ func TestGetMagazine(t *testing.T) {
 	})
 
 	t.Run("invalid id", func(t *testing.T) {
-		bs := db.NewMagazineService(testDB, nil)
+		bs := db.NewMagazineService(testDB, nil, nil, nil, nil)
 		b, err := bs.Get("invalid-id")
 		assert.Equal(t, db.ErrRecordNotFound, err)
 		assert.Nil(t, b)
@@ -61,34 +65,66 @@ func TestUpsertMag(t *testing.T) {
 	defer cleaner()
 	newMag := db.Magazine{
 		Name:    "New mag",
-		OwnerID: uuid.New().String(),
+		OwnerID: db.CreateTestUser(t, testDB).ID,
 	}
 	t.Run("new mag", func(t *testing.T) {
-		ms := db.NewMagazineService(testDB, nil)
-		m := ms.Upsert(newMag)
+		ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+		m, err := ms.Upsert(newMag)
+		require.Nil(t, err)
 		assert.Equal(t, newMag.Name, m.Name)
 		assert.Equal(t, newMag.OwnerID, m.OwnerID)
 		assert.NotEmpty(t, m.ID)
-		assert.Equal(t, db.Available.String(), m.Status)
+		assert.Equal(t, db.Available, m.Status)
 	})
 
 	t.Run("duplicate mag", func(t *testing.T) {
-		ms := db.NewMagazineService(testDB, nil)
-		m1 := ms.Upsert(newMag)
-		m2 := ms.Upsert(m1)
+		clock := db.NewFakeClock(time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC))
+		ms := db.NewMagazineService(testDB, nil, nil, clock, nil)
+		m1, err := ms.Upsert(newMag)
+		require.Nil(t, err)
+		clock.Advance(time.Hour)
+		m2, err := ms.Upsert(m1)
+		require.Nil(t, err)
+		assert.True(t, m1.CreatedAt.Equal(m2.CreatedAt))
+		assert.Equal(t, clock.Now(), m2.UpdatedAt)
+		m2.CreatedAt, m2.UpdatedAt = m1.CreatedAt, m1.UpdatedAt
 		assert.Equal(t, m1, m2)
 	})
+
+	t.Run("change of owner", func(t *testing.T) {
+		ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+		m, err := ms.Upsert(newMag)
+		require.Nil(t, err)
+		m.OwnerID = db.CreateTestUser(t, testDB).ID
+		_, err = ms.Upsert(m)
+		assert.ErrorIs(t, err, db.ErrNotOwner)
+		got, err := ms.Get(m.ID)
+		require.Nil(t, err)
+		assert.Equal(t, newMag.OwnerID, got.OwnerID)
+	})
+
+	t.Run("unknown owner", func(t *testing.T) {
+		ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+		_, err := ms.Upsert(db.Magazine{
+			Name:    "Orphan mag",
+			OwnerID: uuid.New().String(),
+		})
+		assert.NotNil(t, err)
+	})
 }
 
 func TestListMags(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
 	t.Run("existing mags", func(t *testing.T) {
-		ms := db.NewMagazineService(testDB, nil)
-		em := ms.Upsert(db.Magazine{
-			Name:   "Existing mag",
-			Status: db.Available.String(),
+		ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+		em, err := ms.Upsert(db.Magazine{
+			Name:    "Existing mag",
+			Status:  db.Available,
+			OwnerID: owner.ID,
 		})
+		require.Nil(t, err)
 		mags, err := ms.List()
 		require.Nil(t, err)
 		assert.NotEmpty(t, mags)
@@ -96,16 +132,19 @@ func TestListMags(t *testing.T) {
 	})
 
 	t.Run("new mag", func(t *testing.T) {
-		ms := db.NewMagazineService(testDB, nil)
-		em := ms.Upsert(db.Magazine{
-			Name:   "Existing mag",
-			Status: db.Available.String(),
+		ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+		em, err := ms.Upsert(db.Magazine{
+			Name:    "Existing mag",
+			Status:  db.Available,
+			OwnerID: owner.ID,
 		})
+		require.Nil(t, err)
 		newMag := db.Magazine{
 			Name:    "New mag",
-			OwnerID: uuid.New().String(),
+			OwnerID: owner.ID,
 		}
-		m := ms.Upsert(newMag)
+		m, err := ms.Upsert(newMag)
+		require.Nil(t, err)
 		mags, err := ms.List()
 		require.Nil(t, err)
 		assert.NotEmpty(t, mags)
@@ -118,12 +157,13 @@ func TestListMagsByUser(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
 	t.Run("existing mag", func(t *testing.T) {
-		ms := db.NewMagazineService(testDB, nil)
-		em := ms.Upsert(db.Magazine{
+		ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+		em, err := ms.Upsert(db.Magazine{
 			Name:    "Existing mag",
-			Status:  db.Available.String(),
-			OwnerID: uuid.New().String(),
+			Status:  db.Available,
+			OwnerID: db.CreateTestUser(t, testDB).ID,
 		})
+		require.Nil(t, err)
 		mags, err := ms.ListByUser(em.OwnerID)
 		require.Nil(t, err)
 		assert.Equal(t, 1, len(mags))
@@ -133,16 +173,18 @@ func TestListMagsByUser(t *testing.T) {
 	t.Run("multiple mags", func(t *testing.T) {
 		testDB, cleaner := db.OpenDB(t)
 		defer cleaner()
-		ms := db.NewMagazineService(testDB, nil)
-		em := ms.Upsert(db.Magazine{
+		ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+		em, err := ms.Upsert(db.Magazine{
 			Name:    "Existing mag",
-			Status:  db.Available.String(),
-			OwnerID: uuid.New().String(),
+			Status:  db.Available,
+			OwnerID: db.CreateTestUser(t, testDB).ID,
 		})
-		m := ms.Upsert(db.Magazine{
+		require.Nil(t, err)
+		m, err := ms.Upsert(db.Magazine{
 			Name:    "New mag",
 			OwnerID: em.OwnerID,
 		})
+		require.Nil(t, err)
 		mags, err := ms.ListByUser(m.OwnerID)
 		require.Nil(t, err)
 		assert.Equal(t, 2, len(mags))
@@ -151,7 +193,7 @@ func TestListMagsByUser(t *testing.T) {
 	})
 
 	t.Run("no mags for user", func(t *testing.T) {
-		ms := db.NewMagazineService(testDB, nil)
+		ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
 		mags, err := ms.ListByUser(uuid.New().String())
 		require.Nil(t, err)
 		assert.Empty(t, mags)
@@ -161,31 +203,36 @@ func TestListMagsByUser(t *testing.T) {
 func TestSwapMagazine(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
-	em := db.Magazine{
-		Name:    "Existing mag",
-		Status:  db.Available.String(),
-		OwnerID: uuid.New().String(),
+	owner := db.CreateTestUser(t, testDB)
+	newExistingMag := func(t *testing.T, ms *db.MagazineService) db.Magazine {
+		em, err := ms.Upsert(db.Magazine{
+			Name:    "Existing mag",
+			OwnerID: owner.ID,
+		})
+		require.Nil(t, err)
+		return em
 	}
 	t.Run("existing mag", func(t *testing.T) {
 		ps := mocks.NewPostingService(t)
-		ms := db.NewMagazineService(testDB, ps)
+		ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+		em := newExistingMag(t, ms)
 		ps.On("NewMagazineOrder", mock.MatchedBy(func(m db.Magazine) bool {
 			return m.ID == em.ID
 		})).Return(nil).Once()
-		em = ms.Upsert(em)
-		newOwner := uuid.New().String()
+		newOwner := db.CreateTestUser(t, testDB).ID
 		mag, err := ms.SwapMagazine(em.ID, newOwner)
 		assert.NotNil(t, mag)
 		assert.Nil(t, err)
 		assert.Equal(t, em.ID, mag.ID)
 		assert.Equal(t, newOwner, mag.OwnerID)
-		assert.Equal(t, db.Swapped.String(), mag.Status)
+		assert.Equal(t, db.InTransit, mag.Status)
+		ps.AssertExpectations(t)
 	})
 
 	t.Run("unknown mag", func(t *testing.T) {
 		ps := mocks.NewPostingService(t)
-		ms := db.NewMagazineService(testDB, ps)
-		em = ms.Upsert(em)
+		ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+		newExistingMag(t, ms)
 		mag, err := ms.SwapMagazine(uuid.New().String(), uuid.New().String())
 		assert.Nil(t, mag)
 		assert.NotNil(t, err)
@@ -195,7 +242,7 @@ func TestSwapMagazine(t *testing.T) {
 
 	t.Run("empty list", func(t *testing.T) {
 		ps := mocks.NewPostingService(t)
-		ms := db.NewMagazineService(testDB, ps)
+		ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
 		mag, err := ms.SwapMagazine(uuid.New().String(), uuid.New().String())
 		assert.Nil(t, mag)
 		assert.NotNil(t, err)
@@ -203,39 +250,103 @@ func TestSwapMagazine(t *testing.T) {
 		ps.AssertNotCalled(t, "NewMagazineOrder", mock.AnythingOfType("db.Magazine"))
 	})
 
+	t.Run("invalid id", func(t *testing.T) {
+		ps := mocks.NewPostingService(t)
+		ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+		mag, err := ms.SwapMagazine("invalid-id", db.CreateTestUser(t, testDB).ID)
+		assert.Nil(t, mag)
+		assert.ErrorIs(t, err, db.ErrRecordNotFound)
+		ps.AssertNotCalled(t, "NewMagazineOrder", mock.AnythingOfType("db.Magazine"))
+	})
+
 	t.Run("unavailable mag", func(t *testing.T) {
 		ps := mocks.NewPostingService(t)
-		ms := db.NewMagazineService(testDB, ps)
-		em = ms.Upsert(em)
+		ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+		em := newExistingMag(t, ms)
 		ps.On("NewMagazineOrder", mock.MatchedBy(func(m db.Magazine) bool {
 			return m.ID == em.ID
 		})).Return(nil).Once()
-		newOwner := uuid.New().String()
+		newOwner := db.CreateTestUser(t, testDB).ID
 		mag, err := ms.SwapMagazine(em.ID, newOwner)
 		assert.NotNil(t, mag)
 		assert.Nil(t, err)
 		assert.Equal(t, em.ID, mag.ID)
 		assert.Equal(t, newOwner, mag.OwnerID)
-		assert.Equal(t, db.Swapped.String(), mag.Status)
-		mag, err = ms.SwapMagazine(em.ID, uuid.New().String())
+		assert.Equal(t, db.InTransit, mag.Status)
+		mag, err = ms.SwapMagazine(em.ID, db.CreateTestUser(t, testDB).ID)
 		assert.Nil(t, mag)
 		assert.NotNil(t, err)
 		assert.Contains(t, err.Error(), "not available")
+		assert.ErrorIs(t, err, db.ErrInvalidTransition)
 		ps.AssertExpectations(t)
 	})
 
 	t.Run("error posting", func(t *testing.T) {
 		postingErr := errors.New("posting error")
 		ps := mocks.NewPostingService(t)
-		ms := db.NewMagazineService(testDB, ps)
-		em = ms.Upsert(em)
+		ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+		em := newExistingMag(t, ms)
 		ps.On("NewMagazineOrder", mock.MatchedBy(func(m db.Magazine) bool {
 			return m.ID == em.ID
 		})).Return(postingErr).Once()
-		newOwner := uuid.New().String()
-		book, err := ms.SwapMagazine(em.ID, newOwner)
-		assert.Nil(t, book)
+		newOwner := db.CreateTestUser(t, testDB).ID
+		mag, err := ms.SwapMagazine(em.ID, newOwner)
+		assert.Nil(t, mag)
 		assert.Equal(t, postingErr, err)
+		m, err := ms.Get(em.ID)
+		require.Nil(t, err)
+		// Putting the magazine back on the catalogue is an update of its own.
+		m.UpdatedAt = em.UpdatedAt
+		assert.Equal(t, em, *m)
 		ps.AssertExpectations(t)
 	})
 }
+
+func TestMagazineLifecycle(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
+	newOwner := db.CreateTestUser(t, testDB)
+	ps := mocks.NewPostingService(t)
+	ps.On("NewMagazineOrder", mock.AnythingOfType("db.Magazine")).Return(nil)
+	ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+	em, err := ms.Upsert(db.Magazine{
+		Name:    "Existing mag",
+		OwnerID: owner.ID,
+	})
+	require.Nil(t, err)
+
+	steps := []struct {
+		name       string
+		op         func(id, userID string) (*db.Magazine, error)
+		userID     string
+		wantStatus db.BookStatus
+		wantErr    error
+	}{
+		{name: "withdraw by non-owner", op: ms.Withdraw, userID: newOwner.ID, wantErr: db.ErrNotOwner},
+		{name: "withdraw", op: ms.Withdraw, userID: owner.ID, wantStatus: db.Withdrawn},
+		{name: "withdraw twice", op: ms.Withdraw, userID: owner.ID, wantErr: db.ErrInvalidTransition},
+		{name: "relist withdrawn", op: ms.Relist, userID: owner.ID, wantStatus: db.Available},
+		{name: "deliver available", op: ms.ConfirmDelivery, userID: owner.ID, wantErr: db.ErrInvalidTransition},
+		{name: "swap", op: ms.SwapMagazine, userID: newOwner.ID, wantStatus: db.InTransit},
+		{name: "relist in transit", op: ms.Relist, userID: newOwner.ID, wantErr: db.ErrInvalidTransition},
+		{name: "deliver by previous owner", op: ms.ConfirmDelivery, userID: owner.ID, wantErr: db.ErrNotOwner},
+		{name: "deliver", op: ms.ConfirmDelivery, userID: newOwner.ID, wantStatus: db.Swapped},
+		{name: "swap swapped", op: ms.SwapMagazine, userID: owner.ID, wantErr: db.ErrInvalidTransition},
+		{name: "relist by previous owner", op: ms.Relist, userID: owner.ID, wantErr: db.ErrNotOwner},
+		{name: "relist swapped", op: ms.Relist, userID: newOwner.ID, wantStatus: db.Available},
+		{name: "swap relisted", op: ms.SwapMagazine, userID: owner.ID, wantStatus: db.InTransit},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			m, err := step.op(em.ID, step.userID)
+			if step.wantErr != nil {
+				assert.ErrorIs(t, err, step.wantErr)
+				assert.Nil(t, m)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, step.wantStatus, m.Status)
+		})
+	}
+}
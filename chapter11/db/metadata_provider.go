@@ -0,0 +1,45 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FixtureMetadataProvider is a MetadataProvider which looks books up in a local fixture file,
+// for tests and for running the service without an external catalogue.
+type FixtureMetadataProvider struct {
+	books map[string]BookMetadata
+}
+
+// NewFixtureMetadataProvider loads a JSON array of book metadata.
+// The ISBNs of the fixture may be given in any valid spelling.
+func NewFixtureMetadataProvider(path string) (*FixtureMetadataProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var items []BookMetadata
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("invalid metadata fixture %s:%v", path, err)
+	}
+	books := make(map[string]BookMetadata, len(items))
+	for _, m := range items {
+		isbn, err := NormalizeISBN(m.ISBN)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metadata fixture %s:%v", path, err)
+		}
+		m.ISBN = isbn
+		books[isbn] = m
+	}
+	return &FixtureMetadataProvider{books: books}, nil
+}
+
+// Lookup returns the metadata of a normalized ISBN from the fixture.
+func (p *FixtureMetadataProvider) Lookup(isbn string) (*BookMetadata, error) {
+	m, ok := p.books[isbn]
+	if !ok {
+		return nil, fmt.Errorf("%w for isbn %s", ErrMetadataNotFound, isbn)
+	}
+	return &m, nil
+}
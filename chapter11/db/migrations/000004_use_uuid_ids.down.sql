@@ -0,0 +1,10 @@
+BEGIN;
+ALTER TABLE magazines
+   ALTER COLUMN id TYPE VARCHAR (50) USING id::text,
+   ALTER COLUMN owner_id TYPE VARCHAR (50) USING owner_id::text;
+ALTER TABLE books
+   ALTER COLUMN id TYPE VARCHAR (50) USING id::text,
+   ALTER COLUMN owner_id TYPE VARCHAR (50) USING owner_id::text;
+ALTER TABLE users
+   ALTER COLUMN id TYPE VARCHAR (50) USING id::text;
+COMMIT;
@@ -0,0 +1,25 @@
+BEGIN;
+-- Items without a valid owner cannot be converted or satisfy the owner foreign keys.
+-- They are not deleted, so the migration stops until they are given an owner or removed by hand.
+DO $$
+DECLARE
+   orphans BIGINT;
+BEGIN
+   SELECT (SELECT count(*) FROM books WHERE owner_id NOT IN (SELECT id FROM users))
+      + (SELECT count(*) FROM magazines WHERE owner_id NOT IN (SELECT id FROM users))
+      INTO orphans;
+   IF orphans > 0 THEN
+      RAISE EXCEPTION '% books and magazines are owned by users who do not exist', orphans
+         USING HINT = 'Give them an existing owner or delete them, then run the migrations again.';
+   END IF;
+END $$;
+
+ALTER TABLE users
+   ALTER COLUMN id TYPE UUID USING id::uuid;
+ALTER TABLE books
+   ALTER COLUMN id TYPE UUID USING id::uuid,
+   ALTER COLUMN owner_id TYPE UUID USING owner_id::uuid;
+ALTER TABLE magazines
+   ALTER COLUMN id TYPE UUID USING id::uuid,
+   ALTER COLUMN owner_id TYPE UUID USING owner_id::uuid;
+COMMIT;
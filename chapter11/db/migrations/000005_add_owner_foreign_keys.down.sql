@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE magazines DROP CONSTRAINT IF EXISTS magazines_owner_id_fkey;
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_owner_id_fkey;
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+ALTER TABLE books
+   ADD CONSTRAINT books_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES users (id);
+ALTER TABLE magazines
+   ADD CONSTRAINT magazines_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES users (id);
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+DROP INDEX IF EXISTS magazines_status_idx;
+DROP INDEX IF EXISTS magazines_owner_id_idx;
+DROP INDEX IF EXISTS books_status_idx;
+DROP INDEX IF EXISTS books_owner_id_idx;
+COMMIT;
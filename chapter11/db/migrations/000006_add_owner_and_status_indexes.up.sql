@@ -0,0 +1,6 @@
+BEGIN;
+CREATE INDEX IF NOT EXISTS books_owner_id_idx ON books (owner_id);
+CREATE INDEX IF NOT EXISTS books_status_idx ON books (status);
+CREATE INDEX IF NOT EXISTS magazines_owner_id_idx ON magazines (owner_id);
+CREATE INDEX IF NOT EXISTS magazines_status_idx ON magazines (status);
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE magazines DROP CONSTRAINT IF EXISTS magazines_status_check;
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_status_check;
+COMMIT;
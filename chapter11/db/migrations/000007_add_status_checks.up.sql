@@ -0,0 +1,6 @@
+BEGIN;
+ALTER TABLE books
+   ADD CONSTRAINT books_status_check CHECK (status IN ('AVAILABLE', 'SWAPPED'));
+ALTER TABLE magazines
+   ADD CONSTRAINT magazines_status_check CHECK (status IN ('AVAILABLE', 'SWAPPED'));
+COMMIT;
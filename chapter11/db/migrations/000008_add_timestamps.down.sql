@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE magazines DROP COLUMN IF EXISTS created_at, DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE books DROP COLUMN IF EXISTS created_at, DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE users DROP COLUMN IF EXISTS created_at, DROP COLUMN IF EXISTS updated_at;
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+ALTER TABLE users
+   ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+   ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE books
+   ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+   ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE magazines
+   ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+   ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+COMMIT;
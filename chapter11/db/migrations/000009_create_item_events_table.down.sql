@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE IF EXISTS item_events;
+DROP FUNCTION IF EXISTS reject_item_event_changes();
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+-- No foreign keys, as the ledger outlives the items and users it refers to.
+CREATE TABLE IF NOT EXISTS item_events
+(
+   id BIGSERIAL PRIMARY KEY,
+   item_id UUID NOT NULL,
+   item_type VARCHAR (50) NOT NULL,
+   type VARCHAR (50) NOT NULL,
+   actor_id UUID NOT NULL,
+   owner_id UUID NOT NULL,
+   previous_owner_id UUID,
+   snapshot JSONB NOT NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS item_events_item_idx ON item_events (item_type, item_id, created_at);
+CREATE INDEX IF NOT EXISTS item_events_actor_id_idx ON item_events (actor_id);
+CREATE INDEX IF NOT EXISTS item_events_owner_id_idx ON item_events (owner_id);
+CREATE INDEX IF NOT EXISTS item_events_previous_owner_id_idx ON item_events (previous_owner_id);
+
+-- The ledger is append-only: events are never changed once recorded.
+CREATE OR REPLACE FUNCTION reject_item_event_changes() RETURNS TRIGGER AS $$
+BEGIN
+   RAISE EXCEPTION 'item_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER item_events_append_only BEFORE UPDATE OR DELETE ON item_events
+   FOR EACH ROW EXECUTE FUNCTION reject_item_event_changes();
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+-- Items in the new states are folded back into the original status with the same visibility.
+UPDATE books SET status = 'AVAILABLE' WHERE status = 'RESERVED';
+UPDATE books SET status = 'SWAPPED' WHERE status IN ('IN_TRANSIT', 'WITHDRAWN');
+UPDATE magazines SET status = 'AVAILABLE' WHERE status = 'RESERVED';
+UPDATE magazines SET status = 'SWAPPED' WHERE status IN ('IN_TRANSIT', 'WITHDRAWN');
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_status_check;
+ALTER TABLE books
+   ADD CONSTRAINT books_status_check CHECK (status IN ('AVAILABLE', 'SWAPPED'));
+ALTER TABLE magazines DROP CONSTRAINT IF EXISTS magazines_status_check;
+ALTER TABLE magazines
+   ADD CONSTRAINT magazines_status_check CHECK (status IN ('AVAILABLE', 'SWAPPED'));
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_status_check;
+ALTER TABLE books
+   ADD CONSTRAINT books_status_check
+   CHECK (status IN ('AVAILABLE', 'RESERVED', 'IN_TRANSIT', 'SWAPPED', 'WITHDRAWN'));
+ALTER TABLE magazines DROP CONSTRAINT IF EXISTS magazines_status_check;
+ALTER TABLE magazines
+   ADD CONSTRAINT magazines_status_check
+   CHECK (status IN ('AVAILABLE', 'RESERVED', 'IN_TRANSIT', 'SWAPPED', 'WITHDRAWN'));
+COMMIT;
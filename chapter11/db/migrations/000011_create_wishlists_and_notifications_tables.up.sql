@@ -0,0 +1,26 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS wishlist_items
+(
+   id UUID PRIMARY KEY,
+   user_id UUID NOT NULL REFERENCES users (id),
+   item_type VARCHAR (50) NOT NULL CHECK (item_type IN ('BOOK', 'MAGAZINE')),
+   name VARCHAR (50) NOT NULL,
+   author VARCHAR (50) NOT NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS wishlist_items_user_id_idx ON wishlist_items (user_id);
+CREATE INDEX IF NOT EXISTS wishlist_items_name_idx ON wishlist_items (item_type, LOWER(name));
+
+CREATE TABLE IF NOT EXISTS notifications
+(
+   id UUID PRIMARY KEY,
+   user_id UUID NOT NULL REFERENCES users (id),
+   type VARCHAR (50) NOT NULL,
+   item_id UUID,
+   item_type VARCHAR (50),
+   wishlist_item_id UUID REFERENCES wishlist_items (id) ON DELETE SET NULL,
+   message TEXT NOT NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS notifications_user_id_idx ON notifications (user_id, created_at);
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+DROP TABLE IF EXISTS notification_deliveries;
+DROP INDEX IF EXISTS notifications_undispatched_idx;
+ALTER TABLE notifications DROP COLUMN IF EXISTS item_name, DROP COLUMN IF EXISTS dispatched_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email;
+COMMIT;
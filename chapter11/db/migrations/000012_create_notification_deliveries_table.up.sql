@@ -0,0 +1,26 @@
+BEGIN;
+ALTER TABLE users ADD COLUMN email VARCHAR (255) NOT NULL DEFAULT '';
+ALTER TABLE notifications
+   ADD COLUMN item_name VARCHAR (50) NOT NULL DEFAULT '',
+   ADD COLUMN dispatched_at TIMESTAMPTZ;
+-- Notifications created before deliveries existed are not sent retrospectively.
+UPDATE notifications SET dispatched_at = now();
+CREATE INDEX IF NOT EXISTS notifications_undispatched_idx ON notifications (created_at)
+   WHERE dispatched_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS notification_deliveries
+(
+   id UUID PRIMARY KEY,
+   notification_id UUID NOT NULL REFERENCES notifications (id) ON DELETE CASCADE,
+   channel VARCHAR (50) NOT NULL,
+   status VARCHAR (50) NOT NULL CHECK (status IN ('PENDING', 'SENT', 'FAILED', 'SKIPPED')),
+   attempts INTEGER NOT NULL DEFAULT 0,
+   next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+   last_error TEXT NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+   updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+   UNIQUE (notification_id, channel)
+);
+CREATE INDEX IF NOT EXISTS notification_deliveries_pending_idx ON notification_deliveries (next_attempt_at)
+   WHERE status = 'PENDING';
+COMMIT;
@@ -0,0 +1,29 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS webhook_subscriptions
+(
+   id UUID PRIMARY KEY,
+   url TEXT NOT NULL,
+   event_types JSONB NOT NULL CHECK (jsonb_typeof(event_types) = 'array' AND jsonb_array_length(event_types) > 0),
+   secret VARCHAR (255) NOT NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+   id UUID PRIMARY KEY,
+   subscription_id UUID NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+   event_id BIGINT NOT NULL REFERENCES item_events (id),
+   event_type VARCHAR (50) NOT NULL,
+   payload JSONB NOT NULL,
+   status VARCHAR (50) NOT NULL CHECK (status IN ('PENDING', 'SENT', 'FAILED')),
+   attempts INTEGER NOT NULL DEFAULT 0,
+   next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+   response_status INTEGER NOT NULL DEFAULT 0,
+   last_error TEXT NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+   updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_id_idx ON webhook_deliveries (subscription_id, created_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at)
+   WHERE status = 'PENDING';
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+DROP INDEX IF EXISTS books_isbn_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS year;
+ALTER TABLE books DROP COLUMN IF EXISTS publisher;
+ALTER TABLE books DROP COLUMN IF EXISTS isbn;
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn VARCHAR (13) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS publisher VARCHAR (255) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS year INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS books_isbn_idx ON books (isbn) WHERE isbn <> '';
+COMMIT;
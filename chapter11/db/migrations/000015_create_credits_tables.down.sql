@@ -0,0 +1,5 @@
+BEGIN;
+DROP TABLE IF EXISTS credit_entries;
+DROP FUNCTION IF EXISTS reject_credit_entry_changes();
+DROP TABLE IF EXISTS credit_balances;
+COMMIT;
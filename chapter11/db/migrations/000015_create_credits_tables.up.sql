@@ -0,0 +1,34 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS credit_balances
+(
+   user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+   balance INTEGER NOT NULL,
+   updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- No foreign keys, as the ledger outlives the items and users it refers to.
+CREATE TABLE IF NOT EXISTS credit_entries
+(
+   id BIGSERIAL PRIMARY KEY,
+   user_id UUID NOT NULL,
+   amount INTEGER NOT NULL,
+   balance INTEGER NOT NULL,
+   reason VARCHAR (50) NOT NULL CHECK (reason IN ('GRANTED', 'EARNED', 'SPENT', 'REFUNDED', 'REVERSED', 'ADJUSTED')),
+   item_type VARCHAR (50),
+   item_id UUID,
+   actor_id UUID NOT NULL,
+   note TEXT NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS credit_entries_user_id_idx ON credit_entries (user_id, id);
+
+-- The ledger is append-only: entries are never changed once recorded.
+CREATE OR REPLACE FUNCTION reject_credit_entry_changes() RETURNS TRIGGER AS $$
+BEGIN
+   RAISE EXCEPTION 'credit_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER credit_entries_append_only BEFORE UPDATE OR DELETE ON credit_entries
+   FOR EACH ROW EXECUTE FUNCTION reject_credit_entry_changes();
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS reviews
+(
+   id UUID PRIMARY KEY,
+   item_type VARCHAR (50) NOT NULL CHECK (item_type IN ('BOOK', 'MAGAZINE')),
+   item_id UUID NOT NULL,
+   swap_id BIGINT NOT NULL,
+   reviewer_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   reviewee_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+   comment TEXT NOT NULL DEFAULT '',
+   flagged BOOLEAN NOT NULL DEFAULT false,
+   flag_reason TEXT NOT NULL DEFAULT '',
+   flagged_by UUID REFERENCES users (id) ON DELETE SET NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+   -- Each side of a swap reviews the other side once.
+   UNIQUE (swap_id, reviewer_id)
+);
+CREATE INDEX IF NOT EXISTS reviews_reviewee_id_idx ON reviews (reviewee_id, created_at);
+COMMIT;
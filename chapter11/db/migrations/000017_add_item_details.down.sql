@@ -0,0 +1,12 @@
+BEGIN;
+ALTER TABLE magazines DROP COLUMN IF EXISTS images;
+ALTER TABLE magazines DROP COLUMN IF EXISTS tags;
+ALTER TABLE magazines DROP COLUMN IF EXISTS language;
+ALTER TABLE magazines DROP COLUMN IF EXISTS condition;
+
+ALTER TABLE books DROP COLUMN IF EXISTS images;
+ALTER TABLE books DROP COLUMN IF EXISTS tags;
+ALTER TABLE books DROP COLUMN IF EXISTS language;
+ALTER TABLE books DROP COLUMN IF EXISTS condition;
+ALTER TABLE books DROP COLUMN IF EXISTS edition;
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS edition VARCHAR (255) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS condition VARCHAR (50) NOT NULL DEFAULT ''
+   CHECK (condition IN ('', 'NEW', 'LIKE_NEW', 'GOOD', 'FAIR', 'POOR'));
+ALTER TABLE books ADD COLUMN IF NOT EXISTS language VARCHAR (2) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE books ADD COLUMN IF NOT EXISTS images JSONB NOT NULL DEFAULT '[]';
+
+ALTER TABLE magazines ADD COLUMN IF NOT EXISTS condition VARCHAR (50) NOT NULL DEFAULT ''
+   CHECK (condition IN ('', 'NEW', 'LIKE_NEW', 'GOOD', 'FAIR', 'POOR'));
+ALTER TABLE magazines ADD COLUMN IF NOT EXISTS language VARCHAR (2) NOT NULL DEFAULT '';
+ALTER TABLE magazines ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE magazines ADD COLUMN IF NOT EXISTS images JSONB NOT NULL DEFAULT '[]';
+COMMIT;
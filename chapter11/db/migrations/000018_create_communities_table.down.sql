@@ -0,0 +1,6 @@
+BEGIN;
+DROP INDEX IF EXISTS users_community_id_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS community_id;
+DROP INDEX IF EXISTS communities_host_idx;
+DROP TABLE IF EXISTS communities;
+COMMIT;
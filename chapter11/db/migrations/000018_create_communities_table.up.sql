@@ -0,0 +1,18 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS communities
+(
+   id UUID PRIMARY KEY,
+   slug VARCHAR (63) UNIQUE NOT NULL CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$'),
+   name VARCHAR (255) NOT NULL,
+   host VARCHAR (255) NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- Communities without a host are only resolved by their slug.
+CREATE UNIQUE INDEX IF NOT EXISTS communities_host_idx ON communities (host) WHERE host <> '';
+-- Existing users all belong to the default community, which serves the requests of unknown hosts.
+INSERT INTO communities (id, slug, name) VALUES ('00000000-0000-0000-0000-000000000001', 'default', 'BookSwap')
+   ON CONFLICT DO NOTHING;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS community_id UUID NOT NULL
+   DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES communities (id);
+CREATE INDEX IF NOT EXISTS users_community_id_idx ON users (community_id);
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+DROP TABLE IF EXISTS admin_actions;
+DROP FUNCTION IF EXISTS reject_admin_action_changes();
+DROP TABLE IF EXISTS reports;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended;
+-- Removed items are folded back into the status with the same visibility.
+UPDATE books SET status = 'WITHDRAWN' WHERE status = 'REMOVED';
+UPDATE magazines SET status = 'WITHDRAWN' WHERE status = 'REMOVED';
+ALTER TABLE books DROP COLUMN IF EXISTS flagged;
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_status_check;
+ALTER TABLE books
+   ADD CONSTRAINT books_status_check
+   CHECK (status IN ('AVAILABLE', 'RESERVED', 'IN_TRANSIT', 'SWAPPED', 'WITHDRAWN'));
+ALTER TABLE magazines DROP COLUMN IF EXISTS flagged;
+ALTER TABLE magazines DROP CONSTRAINT IF EXISTS magazines_status_check;
+ALTER TABLE magazines
+   ADD CONSTRAINT magazines_status_check
+   CHECK (status IN ('AVAILABLE', 'RESERVED', 'IN_TRANSIT', 'SWAPPED', 'WITHDRAWN'));
+COMMIT;
@@ -0,0 +1,52 @@
+BEGIN;
+-- Items taken down by an admin are REMOVED, and flagged items are hidden until they are moderated.
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_status_check;
+ALTER TABLE books
+   ADD CONSTRAINT books_status_check
+   CHECK (status IN ('AVAILABLE', 'RESERVED', 'IN_TRANSIT', 'SWAPPED', 'WITHDRAWN', 'REMOVED'));
+ALTER TABLE books ADD COLUMN IF NOT EXISTS flagged BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE magazines DROP CONSTRAINT IF EXISTS magazines_status_check;
+ALTER TABLE magazines
+   ADD CONSTRAINT magazines_status_check
+   CHECK (status IN ('AVAILABLE', 'RESERVED', 'IN_TRANSIT', 'SWAPPED', 'WITHDRAWN', 'REMOVED'));
+ALTER TABLE magazines ADD COLUMN IF NOT EXISTS flagged BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS reports
+(
+   id UUID PRIMARY KEY,
+   reporter_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   subject_type VARCHAR (50) NOT NULL CHECK (subject_type IN ('BOOK', 'MAGAZINE', 'USER')),
+   subject_id UUID NOT NULL,
+   reason TEXT NOT NULL,
+   status VARCHAR (50) NOT NULL CHECK (status IN ('OPEN', 'RESOLVED')),
+   resolved_by UUID,
+   resolved_at TIMESTAMPTZ,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS reports_status_idx ON reports (status, created_at);
+
+-- No foreign keys, as the audit log outlives the admins and subjects it refers to.
+CREATE TABLE IF NOT EXISTS admin_actions
+(
+   id BIGSERIAL PRIMARY KEY,
+   admin_id UUID NOT NULL,
+   action VARCHAR (50) NOT NULL CHECK (action IN ('FLAGGED', 'UNFLAGGED', 'REMOVED', 'SUSPENDED', 'REACTIVATED',
+      'RESOLVED', 'CREDITS_ADJUSTED', 'COMMUNITY_SAVED')),
+   subject_type VARCHAR (50) NOT NULL,
+   subject_id UUID NOT NULL,
+   reason TEXT NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS admin_actions_subject_id_idx ON admin_actions (subject_id, id);
+
+-- The audit log is append-only: actions are never changed once recorded.
+CREATE OR REPLACE FUNCTION reject_admin_action_changes() RETURNS TRIGGER AS $$
+BEGIN
+   RAISE EXCEPTION 'admin_actions is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER admin_actions_append_only BEFORE UPDATE OR DELETE ON admin_actions
+   FOR EACH ROW EXECUTE FUNCTION reject_admin_action_changes();
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+-- Held items are released, as nothing would ever release them otherwise.
+UPDATE books SET status = 'AVAILABLE'
+   WHERE status = 'RESERVED' AND id IN (SELECT item_id FROM holds WHERE status = 'ACTIVE');
+UPDATE magazines SET status = 'AVAILABLE'
+   WHERE status = 'RESERVED' AND id IN (SELECT item_id FROM holds WHERE status = 'ACTIVE');
+DROP TABLE IF EXISTS holds;
+COMMIT;
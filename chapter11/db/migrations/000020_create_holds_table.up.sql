@@ -0,0 +1,17 @@
+BEGIN;
+-- Holds keep an item RESERVED for one user until they expire, are released or are converted into a swap.
+CREATE TABLE IF NOT EXISTS holds
+(
+   id UUID PRIMARY KEY,
+   item_type VARCHAR (50) NOT NULL CHECK (item_type IN ('BOOK', 'MAGAZINE')),
+   item_id UUID NOT NULL,
+   user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+   status VARCHAR (50) NOT NULL CHECK (status IN ('ACTIVE', 'RELEASED', 'EXPIRED', 'CONVERTED')),
+   expires_at TIMESTAMPTZ NOT NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+-- An item is only held by one user at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS holds_active_item_id_idx ON holds (item_id) WHERE status = 'ACTIVE';
+CREATE INDEX IF NOT EXISTS holds_active_expires_at_idx ON holds (expires_at) WHERE status = 'ACTIVE';
+CREATE INDEX IF NOT EXISTS holds_user_id_idx ON holds (user_id);
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS community_id;
+DROP INDEX IF EXISTS webhook_subscriptions_community_id_idx;
+ALTER TABLE webhook_subscriptions DROP COLUMN IF EXISTS community_id;
+COMMIT;
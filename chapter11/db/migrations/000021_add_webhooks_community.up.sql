@@ -0,0 +1,11 @@
+BEGIN;
+-- Webhooks only receive the events of the community they are subscribed in. Existing webhooks
+-- were subscribed to every community, so they are kept in the default community.
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS community_id UUID NOT NULL
+   DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES communities (id);
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_community_id_idx ON webhook_subscriptions (community_id);
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS community_id UUID NOT NULL
+   DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES communities (id);
+UPDATE webhook_deliveries d SET community_id = s.community_id
+   FROM webhook_subscriptions s WHERE s.id = d.subscription_id;
+COMMIT;
@@ -0,0 +1,123 @@
+package db_test
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMigrations(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestMigrations in short mode.")
+	}
+	schemaURL := openTestSchema(t)
+	files, err := filepath.Glob("migrations/*.up.sql")
+	require.Nil(t, err)
+	require.NotEmpty(t, files)
+	m, err := migrate.New("file://migrations", schemaURL)
+	require.Nil(t, err)
+	defer m.Close()
+
+	t.Run("orphaned items stop the migration", func(t *testing.T) {
+		require.Nil(t, m.Steps(3))
+		gdb, err := gorm.Open(postgres.Open(schemaURL), &gorm.Config{})
+		require.Nil(t, err)
+		bookID := uuid.NewString()
+		r := gdb.Exec("INSERT INTO books (id, name, author, owner_id, status) VALUES (?, 'Book', 'Author', ?, 'AVAILABLE')",
+			bookID, uuid.NewString())
+		require.Nil(t, r.Error)
+
+		err = m.Steps(1)
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "1 books and magazines are owned by users who do not exist")
+		var count int64
+		require.Nil(t, gdb.Table("books").Where("id = ?", bookID).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+
+		require.Nil(t, m.Force(3))
+		require.Nil(t, gdb.Exec("DELETE FROM books WHERE id = ?", bookID).Error)
+		require.Nil(t, m.Down())
+	})
+
+	t.Run("every migration up", func(t *testing.T) {
+		for i := 1; i <= len(files); i++ {
+			require.Nil(t, m.Steps(1), "migration %d up", i)
+			version, dirty, err := m.Version()
+			require.Nil(t, err)
+			assert.False(t, dirty)
+			assert.Equal(t, uint(i), version)
+		}
+	})
+
+	t.Run("every migration down", func(t *testing.T) {
+		for i := len(files); i > 0; i-- {
+			require.Nil(t, m.Steps(-1), "migration %d down", i)
+		}
+		_, _, err := m.Version()
+		assert.Equal(t, migrate.ErrNilVersion, err)
+	})
+
+	t.Run("constraints", func(t *testing.T) {
+		require.Nil(t, m.Up())
+		gdb, err := gorm.Open(postgres.Open(schemaURL), &gorm.Config{})
+		require.Nil(t, err)
+		ownerID := uuid.NewString()
+		r := gdb.Exec("INSERT INTO users (id, name, address, post_code, country) VALUES (?, 'Owner', '', '', '')", ownerID)
+		require.Nil(t, r.Error)
+
+		tests := map[string]struct {
+			ownerID string
+			status  string
+			wantErr bool
+		}{
+			"valid book":     {ownerID: ownerID, status: "AVAILABLE"},
+			"unknown owner":  {ownerID: uuid.NewString(), status: "AVAILABLE", wantErr: true},
+			"invalid owner":  {ownerID: "not-a-uuid", status: "AVAILABLE", wantErr: true},
+			"unknown status": {ownerID: ownerID, status: "LOST", wantErr: true},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				r := gdb.Exec("INSERT INTO books (id, name, author, owner_id, status) VALUES (?, 'Book', 'Author', ?, ?)",
+					uuid.NewString(), tc.ownerID, tc.status)
+				if tc.wantErr {
+					assert.NotNil(t, r.Error)
+					return
+				}
+				assert.Nil(t, r.Error)
+			})
+		}
+	})
+}
+
+// openTestSchema creates an empty schema for the migrations to run in,
+// so that the tables used by other tests are left untouched.
+func openTestSchema(t *testing.T) string {
+	t.Helper()
+	postgresURL, ok := os.LookupEnv("BOOKSWAP_DB_URL")
+	require.True(t, ok)
+	gdb, err := gorm.Open(postgres.Open(postgresURL), &gorm.Config{})
+	require.Nil(t, err)
+	schema := fmt.Sprintf("migrations_test_%d", os.Getpid())
+	require.Nil(t, gdb.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)).Error)
+	t.Cleanup(func() {
+		gdb.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+	})
+
+	u, err := url.Parse(postgresURL)
+	require.Nil(t, err)
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,443 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SubjectType contains the types of things which users report and admins act on.
+type SubjectType string
+
+const (
+	BookSubject      SubjectType = "BOOK"
+	MagazineSubject  SubjectType = "MAGAZINE"
+	UserSubject      SubjectType = "USER"
+	ReportSubject    SubjectType = "REPORT"
+	CommunitySubject SubjectType = "COMMUNITY"
+)
+
+// ReportStatus contains the statuses of a report, which stays open until an admin resolves it.
+type ReportStatus string
+
+const (
+	ReportOpen     ReportStatus = "OPEN"
+	ReportResolved ReportStatus = "RESOLVED"
+)
+
+// AdminActionType contains the types of actions admins take, as recorded in the audit log.
+type AdminActionType string
+
+const (
+	ActionFlagged         AdminActionType = "FLAGGED"
+	ActionUnflagged       AdminActionType = "UNFLAGGED"
+	ActionRemoved         AdminActionType = "REMOVED"
+	ActionSuspended       AdminActionType = "SUSPENDED"
+	ActionReactivated     AdminActionType = "REACTIVATED"
+	ActionResolved        AdminActionType = "RESOLVED"
+	ActionCreditsAdjusted AdminActionType = "CREDITS_ADJUSTED"
+	ActionCommunitySaved  AdminActionType = "COMMUNITY_SAVED"
+)
+
+// activeOwners is the condition which leaves out the items of suspended users.
+const activeOwners = "owner_id NOT IN (SELECT id FROM users WHERE suspended)"
+
+// Report is a request from a user for admins to look at an item or another user, with the reason why.
+type Report struct {
+	ID          string       `json:"id" gorm:"primaryKey"`
+	ReporterID  string       `json:"reporter_id"`
+	SubjectType SubjectType  `json:"subject_type"`
+	SubjectID   string       `json:"subject_id"`
+	Reason      string       `json:"reason"`
+	Status      ReportStatus `json:"status"`
+	ResolvedBy  string       `json:"resolved_by,omitempty" gorm:"default:null"`
+	ResolvedAt  *time.Time   `json:"resolved_at,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// AdminAction is an entry in the append-only audit log of the actions admins take.
+type AdminAction struct {
+	ID          int64           `json:"id" gorm:"primaryKey"`
+	AdminID     string          `json:"admin_id"`
+	Action      AdminActionType `json:"action"`
+	SubjectType SubjectType     `json:"subject_type"`
+	SubjectID   string          `json:"subject_id"`
+	Reason      string          `json:"reason,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// ModerationNote explains why an admin takes an action. Every moderation action needs one.
+type ModerationNote struct {
+	Reason string `json:"reason"`
+}
+
+// ModerationService contains all the functionality and dependencies for moderating items and users.
+type ModerationService struct {
+	DB     *gorm.DB
+	bs     *BookService
+	ms     *MagazineService
+	admins map[string]bool
+}
+
+// NewModerationService initialises a ModerationService given its dependencies.
+// Only the given admins can moderate, but every user can report items and users to them.
+func NewModerationService(db *gorm.DB, bs *BookService, ms *MagazineService, admins []string,
+	clock Clock, ids IDGenerator) *ModerationService {
+	mds := &ModerationService{
+		DB:     withClock(db, clock, ids),
+		bs:     bs,
+		ms:     ms,
+		admins: make(map[string]bool, len(admins)),
+	}
+	for _, id := range admins {
+		mds.admins[id] = true
+	}
+	return mds
+}
+
+// InCommunity returns a copy of the service which only takes reports about the members of a community
+// and their items. Admins moderate every community, so they use the service as it is.
+func (mds *ModerationService) InCommunity(communityID string) *ModerationService {
+	c := *mds
+	c.DB = InCommunity(mds.DB, communityID)
+	c.bs = mds.bs.InCommunity(communityID)
+	c.ms = mds.ms.InCommunity(communityID)
+	return &c
+}
+
+// IsAdmin returns whether the given user is an admin.
+func (mds *ModerationService) IsAdmin(userID string) bool {
+	return mds.admins[userID]
+}
+
+// Report records a report about an item or a user, which stays open until an admin resolves it.
+func (mds *ModerationService) Report(r Report, reporterID string) (*Report, error) {
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" || len(r.Reason) > maxReviewComment {
+		return nil, fmt.Errorf("%w: reports need a reason of at most %d bytes", ErrInvalidInput, maxReviewComment)
+	}
+	if err := userExists(mds.DB, reporterID); err != nil {
+		return nil, err
+	}
+	var model any
+	switch r.SubjectType {
+	case BookSubject:
+		model = &Book{}
+	case MagazineSubject:
+		model = &Magazine{}
+	case UserSubject:
+		model = &User{}
+	default:
+		return nil, fmt.Errorf("%w: unknown subject type %q", ErrInvalidInput, r.SubjectType)
+	}
+	var count int64
+	if isValidID(r.SubjectID) {
+		if res := mds.DB.Model(model).Where("id = ?", r.SubjectID).Count(&count); res.Error != nil {
+			return nil, res.Error
+		}
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("no %s found for id %s:%w", strings.ToLower(string(r.SubjectType)), r.SubjectID,
+			ErrRecordNotFound)
+	}
+	report := Report{
+		ID:          idsOf(mds.DB).NewID(),
+		ReporterID:  reporterID,
+		SubjectType: r.SubjectType,
+		SubjectID:   r.SubjectID,
+		Reason:      r.Reason,
+		Status:      ReportOpen,
+	}
+	if res := mds.DB.Create(&report); res.Error != nil {
+		return nil, res.Error
+	}
+
+	return &report, nil
+}
+
+// ListReports returns the reports with the given status, or all the reports if it is empty, newest first.
+func (mds *ModerationService) ListReports(adminID string, status ReportStatus) ([]Report, error) {
+	if err := mds.checkAdmin(adminID); err != nil {
+		return nil, err
+	}
+	q := mds.DB.Order("created_at DESC")
+	switch status {
+	case "":
+	case ReportOpen, ReportResolved:
+		q = q.Where("status = ?", status)
+	default:
+		return nil, fmt.Errorf("%w: unknown report status %q", ErrInvalidInput, status)
+	}
+	var reports []Report
+	if r := q.Find(&reports); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return reports, nil
+}
+
+// ResolveReport closes an open report once an admin has dealt with it.
+func (mds *ModerationService) ResolveReport(id, adminID string, n ModerationNote) (*Report, error) {
+	a, err := mds.action(adminID, ActionResolved, ReportSubject, id, n)
+	if err != nil {
+		return nil, err
+	}
+	var report Report
+	if !isValidID(id) {
+		return nil, fmt.Errorf("no report found for id %s:%w", id, ErrRecordNotFound)
+	}
+	if r := mds.DB.Where("id = ?", id).First(&report); r.Error != nil {
+		return nil, fmt.Errorf("no report found for id %s:%w", id, r.Error)
+	}
+	if report.Status == ReportResolved {
+		return nil, fmt.Errorf("%w: report %s is already resolved", ErrInvalidInput, id)
+	}
+	now := clockOf(mds.DB).Now()
+	report.Status = ReportResolved
+	report.ResolvedBy = adminID
+	report.ResolvedAt = &now
+	if err := mds.DB.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Model(&report).Select("status", "resolved_by", "resolved_at").Updates(&report); r.Error != nil {
+			return r.Error
+		}
+		return recordAdminAction(tx, &a)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+// ListBooks returns all the books, whatever their status, or only the flagged books, ordered by name.
+func (mds *ModerationService) ListBooks(adminID string, flaggedOnly bool) ([]Book, error) {
+	if err := mds.checkAdmin(adminID); err != nil {
+		return nil, err
+	}
+	var items []Book
+	if r := moderatedItems(mds.DB, flaggedOnly).Find(&items); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return items, nil
+}
+
+// ListMagazines returns all the magazines, whatever their status, or only the flagged magazines, ordered by name.
+func (mds *ModerationService) ListMagazines(adminID string, flaggedOnly bool) ([]Magazine, error) {
+	if err := mds.checkAdmin(adminID); err != nil {
+		return nil, err
+	}
+	var items []Magazine
+	if r := moderatedItems(mds.DB, flaggedOnly).Find(&items); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return items, nil
+}
+
+// FlagBook hides a book from the catalogue, and stops it being swapped, until an admin unflags or removes it.
+func (mds *ModerationService) FlagBook(id, adminID string, n ModerationNote) (*Book, error) {
+	a, err := mds.action(adminID, ActionFlagged, BookSubject, id, n)
+	if err != nil {
+		return nil, err
+	}
+	return mds.bs.moderate(id, ItemFlagged, a, func(b *Book) error {
+		return setFlagged(&b.Flagged, true)
+	})
+}
+
+// UnflagBook puts a flagged book back on the catalogue.
+func (mds *ModerationService) UnflagBook(id, adminID string, n ModerationNote) (*Book, error) {
+	a, err := mds.action(adminID, ActionUnflagged, BookSubject, id, n)
+	if err != nil {
+		return nil, err
+	}
+	return mds.bs.moderate(id, ItemUnflagged, a, func(b *Book) error {
+		return setFlagged(&b.Flagged, false)
+	})
+}
+
+// RemoveBook takes a book down for good. Books in transit are removed once they have been delivered.
+func (mds *ModerationService) RemoveBook(id, adminID string, n ModerationNote) (*Book, error) {
+	a, err := mds.action(adminID, ActionRemoved, BookSubject, id, n)
+	if err != nil {
+		return nil, err
+	}
+	return mds.bs.moderate(id, ItemRemoved, a, func(b *Book) error {
+		if err := checkTransition(b.Status, Removed); err != nil {
+			return err
+		}
+		b.Status = Removed
+		return nil
+	})
+}
+
+// FlagMagazine hides a magazine from the catalogue, and stops it being swapped, until an admin unflags or removes it.
+func (mds *ModerationService) FlagMagazine(id, adminID string, n ModerationNote) (*Magazine, error) {
+	a, err := mds.action(adminID, ActionFlagged, MagazineSubject, id, n)
+	if err != nil {
+		return nil, err
+	}
+	return mds.ms.moderate(id, ItemFlagged, a, func(m *Magazine) error {
+		return setFlagged(&m.Flagged, true)
+	})
+}
+
+// UnflagMagazine puts a flagged magazine back on the catalogue.
+func (mds *ModerationService) UnflagMagazine(id, adminID string, n ModerationNote) (*Magazine, error) {
+	a, err := mds.action(adminID, ActionUnflagged, MagazineSubject, id, n)
+	if err != nil {
+		return nil, err
+	}
+	return mds.ms.moderate(id, ItemUnflagged, a, func(m *Magazine) error {
+		return setFlagged(&m.Flagged, false)
+	})
+}
+
+// RemoveMagazine takes a magazine down for good. Magazines in transit are removed once they have been delivered.
+func (mds *ModerationService) RemoveMagazine(id, adminID string, n ModerationNote) (*Magazine, error) {
+	a, err := mds.action(adminID, ActionRemoved, MagazineSubject, id, n)
+	if err != nil {
+		return nil, err
+	}
+	return mds.ms.moderate(id, ItemRemoved, a, func(m *Magazine) error {
+		if err := checkTransition(m.Status, Removed); err != nil {
+			return err
+		}
+		m.Status = Removed
+		return nil
+	})
+}
+
+// Suspend stops a user listing and swapping items, and hides their items from the catalogue.
+func (mds *ModerationService) Suspend(userID, adminID string, n ModerationNote) (*User, error) {
+	return mds.suspend(userID, adminID, n, true)
+}
+
+// Reactivate lets a suspended user list and swap items again.
+func (mds *ModerationService) Reactivate(userID, adminID string, n ModerationNote) (*User, error) {
+	return mds.suspend(userID, adminID, n, false)
+}
+
+// suspend suspends or reactivates a user, and forgets the cached catalogue their items are left out of.
+func (mds *ModerationService) suspend(userID, adminID string, n ModerationNote, suspended bool) (*User, error) {
+	action := ActionSuspended
+	if !suspended {
+		action = ActionReactivated
+	}
+	a, err := mds.action(adminID, action, UserSubject, userID, n)
+	if err != nil {
+		return nil, err
+	}
+	if !isValidID(userID) {
+		return nil, fmt.Errorf("no user found for id %s:%w", userID, ErrRecordNotFound)
+	}
+	var u User
+	if r := mds.DB.Where("id = ?", userID).First(&u); r.Error != nil {
+		return nil, fmt.Errorf("no user found for id %s:%w", userID, r.Error)
+	}
+	if u.Suspended == suspended {
+		return nil, fmt.Errorf("%w: user %s is already %s", ErrInvalidInput, userID,
+			strings.ToLower(string(action)))
+	}
+	u.Suspended = suspended
+	if err := mds.DB.Transaction(func(tx *gorm.DB) error {
+		if r := tx.Model(&u).Update("suspended", suspended); r.Error != nil {
+			return r.Error
+		}
+		return recordAdminAction(tx, &a)
+	}); err != nil {
+		return nil, err
+	}
+	mds.bs.forgetAvailable(userID)
+	mds.ms.forgetAvailable(userID)
+
+	return &u, nil
+}
+
+// ListActions returns the audit log of admin actions, newest first,
+// or only the actions taken on the given subject if it is not empty.
+func (mds *ModerationService) ListActions(adminID, subjectID string) ([]AdminAction, error) {
+	if err := mds.checkAdmin(adminID); err != nil {
+		return nil, err
+	}
+	q := mds.DB.Order("id DESC")
+	if subjectID != "" {
+		if !isValidID(subjectID) {
+			return []AdminAction{}, nil
+		}
+		q = q.Where("subject_id = ?", subjectID)
+	}
+	var actions []AdminAction
+	if r := q.Find(&actions); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return actions, nil
+}
+
+// checkAdmin returns ErrNotAdmin unless the given user is an admin.
+func (mds *ModerationService) checkAdmin(adminID string) error {
+	if !mds.admins[adminID] {
+		return fmt.Errorf("%w: %s", ErrNotAdmin, adminID)
+	}
+	return nil
+}
+
+// action checks that an admin can take an action, and why, and initialises its entry in the audit log.
+func (mds *ModerationService) action(adminID string, t AdminActionType, st SubjectType, subjectID string,
+	n ModerationNote) (AdminAction, error) {
+	if err := mds.checkAdmin(adminID); err != nil {
+		return AdminAction{}, err
+	}
+	n.Reason = strings.TrimSpace(n.Reason)
+	if n.Reason == "" || len(n.Reason) > maxReviewComment {
+		return AdminAction{}, fmt.Errorf("%w: admin actions need a reason of at most %d bytes", ErrInvalidInput,
+			maxReviewComment)
+	}
+	return AdminAction{AdminID: adminID, Action: t, SubjectType: st, SubjectID: subjectID, Reason: n.Reason}, nil
+}
+
+// moderatedItems returns the query of the items admins list, which are all the items or only the flagged ones.
+func moderatedItems(db *gorm.DB, flaggedOnly bool) *gorm.DB {
+	q := db.Order("name")
+	if flaggedOnly {
+		q = q.Where("flagged")
+	}
+	return q
+}
+
+// setFlagged changes the flag of an item, which must not already have been flagged or unflagged.
+func setFlagged(flagged *bool, next bool) error {
+	if *flagged == next {
+		if next {
+			return fmt.Errorf("%w: already flagged", ErrInvalidInput)
+		}
+		return fmt.Errorf("%w: not flagged", ErrInvalidInput)
+	}
+	*flagged = next
+	return nil
+}
+
+// recordAdminAction appends an action to the audit log of admin actions.
+func recordAdminAction(tx *gorm.DB, a *AdminAction) error {
+	return tx.Create(a).Error
+}
+
+// checkActive returns ErrSuspended if any of the given users is suspended.
+// Users which do not exist are left to the other checks.
+func checkActive(gdb *gorm.DB, userIDs ...string) error {
+	ids := validIDs(userIDs)
+	if len(ids) == 0 {
+		return nil
+	}
+	var suspended []string
+	if r := gdb.Model(&User{}).Where("id IN ? AND suspended", ids).Pluck("id", &suspended); r.Error != nil {
+		return r.Error
+	}
+	if len(suspended) > 0 {
+		return fmt.Errorf("user %s:%w", suspended[0], ErrSuspended)
+	}
+	return nil
+}
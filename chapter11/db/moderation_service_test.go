@@ -0,0 +1,234 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModerateItems(t *testing.T) {
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	admin := db.CreateTestUser(t, testDB)
+	owner := db.CreateTestUser(t, testDB)
+	swapper := db.CreateTestUser(t, testDB)
+	bs := db.NewBookService(testDB, mocks.NewPostingService(t), nil, nil, nil, nil).
+		WithCache(db.NewLRUCache(10, time.Minute, nil))
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	mds := db.NewModerationService(testDB, bs, ms, []string{admin.ID}, nil, nil)
+	note := db.ModerationNote{Reason: "Counterfeit"}
+	book, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+	require.Nil(t, err)
+	_, err = bs.List()
+	require.Nil(t, err)
+
+	t.Run("only admins moderate", func(t *testing.T) {
+		_, err := mds.FlagBook(book.ID, owner.ID, note)
+		assert.True(t, errors.Is(err, db.ErrNotAdmin))
+		_, err = mds.ListBooks(owner.ID, false)
+		assert.True(t, errors.Is(err, db.ErrNotAdmin))
+		_, err = mds.FlagBook(book.ID, admin.ID, db.ModerationNote{Reason: " "})
+		assert.True(t, errors.Is(err, db.ErrInvalidInput))
+	})
+
+	t.Run("flagged books are hidden and not swapped", func(t *testing.T) {
+		// Act
+		flagged, err := mds.FlagBook(book.ID, admin.ID, note)
+
+		// Assert
+		require.Nil(t, err)
+		assert.True(t, flagged.Flagged)
+		books, err := bs.List()
+		require.Nil(t, err)
+		assert.NotContains(t, ids(books), book.ID)
+		books, err = mds.ListBooks(admin.ID, true)
+		require.Nil(t, err)
+		assert.Contains(t, ids(books), book.ID)
+		_, err = bs.SwapBook(book.ID, swapper.ID)
+		assert.True(t, errors.Is(err, db.ErrFlagged))
+		// Owners cannot clear the flag by updating their book.
+		updated, err := bs.Upsert(db.Book{ID: book.ID, Name: "Dune", OwnerID: owner.ID})
+		require.Nil(t, err)
+		assert.True(t, updated.Flagged)
+		_, err = mds.FlagBook(book.ID, admin.ID, note)
+		assert.True(t, errors.Is(err, db.ErrInvalidInput))
+	})
+
+	t.Run("unflagged books are listed again", func(t *testing.T) {
+		unflagged, err := mds.UnflagBook(book.ID, admin.ID, db.ModerationNote{Reason: "Genuine"})
+
+		require.Nil(t, err)
+		assert.False(t, unflagged.Flagged)
+		books, err := bs.List()
+		require.Nil(t, err)
+		assert.Contains(t, ids(books), book.ID)
+	})
+
+	t.Run("removed items never come back", func(t *testing.T) {
+		mag, err := ms.Upsert(db.Magazine{Name: "Wired", OwnerID: owner.ID})
+		require.Nil(t, err)
+
+		removed, err := mds.RemoveMagazine(mag.ID, admin.ID, note)
+
+		require.Nil(t, err)
+		assert.Equal(t, db.Removed, removed.Status)
+		_, err = ms.Relist(mag.ID, owner.ID)
+		assert.True(t, errors.Is(err, db.ErrInvalidTransition))
+		_, err = mds.RemoveMagazine(mag.ID, admin.ID, note)
+		assert.True(t, errors.Is(err, db.ErrInvalidTransition))
+		events, err := db.NewHistoryService(testDB, nil, nil).ListByItem(db.MagazineItem, mag.ID)
+		require.Nil(t, err)
+		last := events[len(events)-1]
+		assert.Equal(t, db.ItemRemoved, last.Type)
+		assert.Equal(t, admin.ID, last.ActorID)
+	})
+
+	t.Run("audit log", func(t *testing.T) {
+		actions, err := mds.ListActions(admin.ID, book.ID)
+
+		require.Nil(t, err)
+		require.Equal(t, 2, len(actions))
+		assert.Equal(t, db.ActionUnflagged, actions[0].Action)
+		assert.Equal(t, "Genuine", actions[0].Reason)
+		assert.Equal(t, db.ActionFlagged, actions[1].Action)
+		assert.Equal(t, admin.ID, actions[1].AdminID)
+		assert.Equal(t, db.BookSubject, actions[1].SubjectType)
+	})
+}
+
+func TestSuspendUser(t *testing.T) {
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	admin := db.CreateTestUser(t, testDB)
+	owner := db.CreateTestUser(t, testDB)
+	swapper := db.CreateTestUser(t, testDB)
+	bs := db.NewBookService(testDB, mocks.NewPostingService(t), nil, nil, nil, nil).
+		WithCache(db.NewLRUCache(10, time.Minute, nil))
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	mds := db.NewModerationService(testDB, bs, ms, []string{admin.ID}, nil, nil)
+	book, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+	require.Nil(t, err)
+	_, err = bs.List()
+	require.Nil(t, err)
+
+	// Act
+	suspended, err := mds.Suspend(owner.ID, admin.ID, db.ModerationNote{Reason: "Spam"})
+
+	// Assert
+	require.Nil(t, err)
+	assert.True(t, suspended.Suspended)
+	t.Run("items are hidden", func(t *testing.T) {
+		books, err := bs.List()
+		require.Nil(t, err)
+		assert.NotContains(t, ids(books), book.ID)
+	})
+	t.Run("suspended users cannot list", func(t *testing.T) {
+		_, err := bs.Upsert(db.Book{Name: "Emma", OwnerID: owner.ID})
+		assert.True(t, errors.Is(err, db.ErrSuspended))
+		_, err = ms.Upsert(db.Magazine{Name: "Wired", OwnerID: owner.ID})
+		assert.True(t, errors.Is(err, db.ErrSuspended))
+	})
+	t.Run("suspended users cannot swap", func(t *testing.T) {
+		_, err := bs.SwapBook(book.ID, swapper.ID)
+		assert.True(t, errors.Is(err, db.ErrSuspended))
+	})
+	t.Run("users cannot reactivate themselves", func(t *testing.T) {
+		u, err := us.Upsert(db.User{ID: owner.ID, Name: "Reformed"})
+		require.Nil(t, err)
+		assert.True(t, u.Suspended)
+	})
+	t.Run("reactivate", func(t *testing.T) {
+		_, err := mds.Suspend(owner.ID, admin.ID, db.ModerationNote{Reason: "Spam"})
+		assert.True(t, errors.Is(err, db.ErrInvalidInput))
+
+		u, err := mds.Reactivate(owner.ID, admin.ID, db.ModerationNote{Reason: "Appealed"})
+
+		require.Nil(t, err)
+		assert.False(t, u.Suspended)
+		books, err := bs.List()
+		require.Nil(t, err)
+		assert.Contains(t, ids(books), book.ID)
+		actions, err := mds.ListActions(admin.ID, owner.ID)
+		require.Nil(t, err)
+		require.Equal(t, 2, len(actions))
+		assert.Equal(t, db.ActionReactivated, actions[0].Action)
+		assert.Equal(t, db.ActionSuspended, actions[1].Action)
+	})
+}
+
+func TestReports(t *testing.T) {
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	admin := db.CreateTestUser(t, testDB)
+	reporter := db.CreateTestUser(t, testDB)
+	owner := db.CreateTestUser(t, testDB)
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	mds := db.NewModerationService(testDB, bs, ms, []string{admin.ID}, nil, nil)
+	book, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+	require.Nil(t, err)
+	tests := map[string]struct {
+		report     db.Report
+		reporterID string
+		wantErr    error
+	}{
+		"book": {report: db.Report{SubjectType: db.BookSubject, SubjectID: book.ID, Reason: "Counterfeit"},
+			reporterID: reporter.ID},
+		"user": {report: db.Report{SubjectType: db.UserSubject, SubjectID: owner.ID, Reason: " Rude "},
+			reporterID: reporter.ID},
+		"no reason": {report: db.Report{SubjectType: db.BookSubject, SubjectID: book.ID},
+			reporterID: reporter.ID, wantErr: db.ErrInvalidInput},
+		"unknown subject type": {report: db.Report{SubjectType: db.ReportSubject, SubjectID: book.ID,
+			Reason: "Spam"}, reporterID: reporter.ID, wantErr: db.ErrInvalidInput},
+		"unknown subject": {report: db.Report{SubjectType: db.MagazineSubject, SubjectID: book.ID,
+			Reason: "Spam"}, reporterID: reporter.ID, wantErr: db.ErrRecordNotFound},
+		"unknown reporter": {report: db.Report{SubjectType: db.BookSubject, SubjectID: book.ID,
+			Reason: "Spam"}, reporterID: uuid.NewString(), wantErr: db.ErrRecordNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := mds.Report(tc.report, tc.reporterID)
+			if tc.wantErr != nil {
+				assert.True(t, errors.Is(err, tc.wantErr))
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, db.ReportOpen, got.Status)
+			assert.Equal(t, tc.reporterID, got.ReporterID)
+			assert.NotEmpty(t, got.Reason)
+		})
+	}
+
+	t.Run("resolve", func(t *testing.T) {
+		open, err := mds.ListReports(admin.ID, db.ReportOpen)
+		require.Nil(t, err)
+		require.NotEmpty(t, open)
+		_, err = mds.ListReports(reporter.ID, db.ReportOpen)
+		assert.True(t, errors.Is(err, db.ErrNotAdmin))
+
+		resolved, err := mds.ResolveReport(open[0].ID, admin.ID, db.ModerationNote{Reason: "Warned the owner"})
+
+		require.Nil(t, err)
+		assert.Equal(t, db.ReportResolved, resolved.Status)
+		assert.Equal(t, admin.ID, resolved.ResolvedBy)
+		_, err = mds.ResolveReport(open[0].ID, admin.ID, db.ModerationNote{Reason: "Again"})
+		assert.True(t, errors.Is(err, db.ErrInvalidInput))
+		reports, err := mds.ListReports(admin.ID, db.ReportResolved)
+		require.Nil(t, err)
+		var found bool
+		for _, r := range reports {
+			found = found || r.ID == open[0].ID
+		}
+		assert.True(t, found)
+	})
+}
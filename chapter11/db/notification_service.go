@@ -0,0 +1,98 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotificationType contains the different types of user notifications.
+type NotificationType string
+
+const (
+	WishlistMatch NotificationType = "WISHLIST_MATCH"
+	SwapRequested NotificationType = "SWAP_REQUESTED"
+	SwapAccepted  NotificationType = "SWAP_ACCEPTED"
+	SwapPosted    NotificationType = "SWAP_POSTED"
+)
+
+// Notification contains all the fields for representing a message to a user.
+type Notification struct {
+	ID             string           `json:"id" gorm:"primaryKey"`
+	UserID         string           `json:"user_id"`
+	Type           NotificationType `json:"type"`
+	ItemID         string           `json:"item_id,omitempty" gorm:"default:null"`
+	ItemType       ItemType         `json:"item_type,omitempty" gorm:"default:null"`
+	ItemName       string           `json:"item_name,omitempty"`
+	WishlistItemID string           `json:"wishlist_item_id,omitempty" gorm:"default:null"`
+	Message        string           `json:"message"`
+	CreatedAt      time.Time        `json:"created_at"`
+	DispatchedAt   *time.Time       `json:"-"`
+}
+
+// NotificationService contains all the functionality and dependencies for managing notifications.
+type NotificationService struct {
+	DB *gorm.DB
+}
+
+// NewNotificationService initialises a NotificationService given its dependencies.
+func NewNotificationService(db *gorm.DB, clock Clock, ids IDGenerator) *NotificationService {
+	return &NotificationService{
+		DB: withClock(db, clock, ids),
+	}
+}
+
+// InCommunity returns a copy of the service which only reads the notifications of a community.
+func (ns *NotificationService) InCommunity(communityID string) *NotificationService {
+	c := *ns
+	c.DB = InCommunity(ns.DB, communityID)
+	return &c
+}
+
+// ListByUser returns the notifications of a given user, newest first.
+func (ns *NotificationService) ListByUser(userID string) ([]Notification, error) {
+	var items []Notification
+	if !isValidID(userID) {
+		return items, nil
+	}
+	if r := ns.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&items); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return items, nil
+}
+
+// createNotification stores a new notification as part of the given transaction.
+func createNotification(tx *gorm.DB, n Notification) error {
+	n.ID = idsOf(tx).NewID()
+	return tx.Create(&n).Error
+}
+
+// notifySwap notifies both parties of a swap about its progress.
+func notifySwap(tx *gorm.DB, e ItemEvent, name string) error {
+	kind := strings.ToLower(string(e.ItemType))
+	var ns []Notification
+	switch e.Type {
+	case ItemSwapped:
+		ns = []Notification{
+			{UserID: e.PreviousOwnerID, Type: SwapRequested, Message: fmt.Sprintf("Your %s %q has been requested in a swap", kind, name)},
+			{UserID: e.OwnerID, Type: SwapAccepted, Message: fmt.Sprintf("Your swap for the %s %q has been accepted", kind, name)},
+		}
+	case ItemPosted:
+		ns = []Notification{
+			{UserID: e.OwnerID, Type: SwapPosted, Message: fmt.Sprintf("The %s %q has been posted to you", kind, name)},
+		}
+	}
+	for _, n := range ns {
+		n.ItemID = e.ItemID
+		n.ItemType = e.ItemType
+		n.ItemName = name
+		if err := createNotification(tx, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
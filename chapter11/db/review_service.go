@@ -0,0 +1,217 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MinRating and MaxRating bound the rating of a review.
+	MinRating = 1
+	MaxRating = 5
+	// maxReviewComment limits the length of review comments and of the reasons of flags, reports and admin actions.
+	maxReviewComment = 1000
+)
+
+// Review contains all the fields for representing a review one side of a swap leaves the other.
+// SwapID is the ID of the SWAPPED event of the reviewed swap.
+// Flagged reviews are hidden from listings and left out of reputations until they are moderated.
+type Review struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	ItemType   ItemType  `json:"item_type"`
+	ItemID     string    `json:"item_id"`
+	SwapID     int64     `json:"swap_id"`
+	ReviewerID string    `json:"reviewer_id"`
+	RevieweeID string    `json:"reviewee_id"`
+	Rating     int       `json:"rating"`
+	Comment    string    `json:"comment,omitempty"`
+	Flagged    bool      `json:"flagged"`
+	FlagReason string    `json:"flag_reason,omitempty"`
+	FlaggedBy  string    `json:"flagged_by,omitempty" gorm:"default:null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ReviewFlag is a request to moderate a review, with the reason it should be looked at.
+type ReviewFlag struct {
+	Reason string `json:"reason"`
+}
+
+// Reputation aggregates the ratings a user has received. Users without reviews have a zero reputation.
+type Reputation struct {
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
+// ReviewService contains all the functionality and dependencies for managing reviews.
+type ReviewService struct {
+	DB *gorm.DB
+}
+
+// NewReviewService initialises a ReviewService given its dependencies.
+func NewReviewService(db *gorm.DB, clock Clock, ids IDGenerator) *ReviewService {
+	return &ReviewService{
+		DB: withClock(db, clock, ids),
+	}
+}
+
+// InCommunity returns a copy of the service which only sees and changes the reviews of a community.
+func (rs *ReviewService) InCommunity(communityID string) *ReviewService {
+	c := *rs
+	c.DB = InCommunity(rs.DB, communityID)
+	return &c
+}
+
+// Add reviews the other side of the latest delivered swap of an item the reviewer took part in.
+// Each side of a swap can only review it once.
+func (rs *ReviewService) Add(itemType ItemType, itemID, reviewerID string, r Review) (*Review, error) {
+	r.Comment = strings.TrimSpace(r.Comment)
+	switch {
+	case r.Rating < MinRating || r.Rating > MaxRating:
+		return nil, fmt.Errorf("%w: ratings are between %d and %d", ErrInvalidInput, MinRating, MaxRating)
+	case len(r.Comment) > maxReviewComment:
+		return nil, fmt.Errorf("%w: comments are at most %d bytes", ErrInvalidInput, maxReviewComment)
+	}
+	if err := userExists(rs.DB, reviewerID); err != nil {
+		return nil, err
+	}
+	swap, err := deliveredSwap(rs.DB, itemType, itemID, reviewerID)
+	if err != nil {
+		return nil, err
+	}
+	review := Review{
+		ID:         idsOf(rs.DB).NewID(),
+		ItemType:   itemType,
+		ItemID:     itemID,
+		SwapID:     swap.ID,
+		ReviewerID: reviewerID,
+		RevieweeID: swap.OwnerID,
+		Rating:     r.Rating,
+		Comment:    r.Comment,
+	}
+	if reviewerID == swap.OwnerID {
+		review.RevieweeID = swap.PreviousOwnerID
+	}
+	if err := rs.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if r := tx.Model(&Review{}).Where("swap_id = ? AND reviewer_id = ?", swap.ID, reviewerID).
+			Count(&count); r.Error != nil {
+			return r.Error
+		}
+		if count > 0 {
+			return fmt.Errorf("%s %s:%w by %s", itemType, itemID, ErrAlreadyReviewed, reviewerID)
+		}
+		return tx.Create(&review).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	return &review, nil
+}
+
+// ListByUser returns the reviews a given user has received, newest first, leaving out flagged reviews.
+func (rs *ReviewService) ListByUser(userID string) ([]Review, error) {
+	if err := userExists(rs.DB, userID); err != nil {
+		return nil, err
+	}
+	var reviews []Review
+	if r := rs.DB.Where("reviewee_id = ? AND NOT flagged", userID).
+		Order("created_at DESC").Find(&reviews); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return reviews, nil
+}
+
+// Flag hides a review until it is moderated. Flagging a review again keeps its first reason.
+func (rs *ReviewService) Flag(id, userID string, f ReviewFlag) (*Review, error) {
+	f.Reason = strings.TrimSpace(f.Reason)
+	if f.Reason == "" || len(f.Reason) > maxReviewComment {
+		return nil, fmt.Errorf("%w: flags need a reason of at most %d bytes", ErrInvalidInput, maxReviewComment)
+	}
+	if err := userExists(rs.DB, userID); err != nil {
+		return nil, err
+	}
+	if !isValidID(id) {
+		return nil, fmt.Errorf("no review found for id %s:%w", id, ErrRecordNotFound)
+	}
+	var review Review
+	if r := rs.DB.Where("id = ?", id).First(&review); r.Error != nil {
+		return nil, fmt.Errorf("no review found for id %s:%w", id, r.Error)
+	}
+	if review.Flagged {
+		return &review, nil
+	}
+	review.Flagged = true
+	review.FlagReason = f.Reason
+	review.FlaggedBy = userID
+	if r := rs.DB.Model(&review).Select("flagged", "flag_reason", "flagged_by").Updates(&review); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return &review, nil
+}
+
+// Reputations returns the reputations of the given users, leaving out users without reviews.
+func (rs *ReviewService) Reputations(userIDs []string) (map[string]Reputation, error) {
+	return reputations(rs.DB, userIDs)
+}
+
+// reputations aggregates the ratings of the reviews the given users have received, in a single query.
+// Flagged reviews are left out.
+func reputations(db *gorm.DB, userIDs []string) (map[string]Reputation, error) {
+	reps := make(map[string]Reputation)
+	ids := validIDs(userIDs)
+	if len(ids) == 0 {
+		return reps, nil
+	}
+	var rows []struct {
+		RevieweeID string
+		Average    float64
+		Count      int
+	}
+	if r := db.Model(&Review{}).Select("reviewee_id, AVG(rating)::float8 AS average, COUNT(*) AS count").
+		Where("reviewee_id IN ? AND NOT flagged", ids).Group("reviewee_id").Scan(&rows); r.Error != nil {
+		return nil, r.Error
+	}
+	for _, row := range rows {
+		reps[row.RevieweeID] = Reputation{Average: row.Average, Count: row.Count}
+	}
+	return reps, nil
+}
+
+// deliveredSwap returns the SWAPPED event of the latest delivered swap of an item the user took part in.
+// Swaps whose posting failed never completed, so they cannot be reviewed.
+func deliveredSwap(db *gorm.DB, itemType ItemType, itemID, userID string) (*ItemEvent, error) {
+	var events []ItemEvent
+	if isValidID(itemID) {
+		if r := db.Where("item_type = ? AND item_id = ?", itemType, itemID).
+			Order("id").Find(&events); r.Error != nil {
+			return nil, r.Error
+		}
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no %s found for id %s:%w", strings.ToLower(string(itemType)), itemID,
+			ErrRecordNotFound)
+	}
+	var swap, delivered *ItemEvent
+	for i, e := range events {
+		switch e.Type {
+		case ItemSwapped:
+			swap = &events[i]
+		case ItemPostingFailed:
+			swap = nil
+		case ItemDelivered:
+			if swap != nil && (swap.OwnerID == userID || swap.PreviousOwnerID == userID) {
+				delivered = swap
+			}
+			swap = nil
+		}
+	}
+	if delivered == nil {
+		return nil, fmt.Errorf("%s %s:%w for user %s", itemType, itemID, ErrNotReviewable, userID)
+	}
+	return delivered, nil
+}
@@ -0,0 +1,171 @@
+package db_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddReview(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	ps := mocks.NewPostingService(t)
+	ps.On("NewBookOrder", mock.AnythingOfType("db.Book")).Return(nil).Once()
+	ps.On("NewBookOrder", mock.AnythingOfType("db.Book")).Return(errors.New("posting error")).Once()
+	bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+	rs := db.NewReviewService(testDB, nil, nil)
+	owner := db.CreateTestUser(t, testDB)
+	swapper := db.CreateTestUser(t, testDB)
+	stranger := db.CreateTestUser(t, testDB)
+	delivered, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+	require.Nil(t, err)
+	_, err = bs.SwapBook(delivered.ID, swapper.ID)
+	require.Nil(t, err)
+	_, err = bs.ConfirmDelivery(delivered.ID, swapper.ID)
+	require.Nil(t, err)
+	failed, err := bs.Upsert(db.Book{Name: "Emma", OwnerID: owner.ID})
+	require.Nil(t, err)
+	_, err = bs.SwapBook(failed.ID, stranger.ID)
+	require.NotNil(t, err)
+
+	// The reviews build on each other, so they run in order.
+	steps := []struct {
+		name           string
+		itemID, userID string
+		review         db.Review
+		wantRevieweeID string
+		wantErr        error
+	}{
+		{name: "new owner", itemID: delivered.ID, userID: swapper.ID,
+			review: db.Review{Rating: 5, Comment: " Well packed "}, wantRevieweeID: owner.ID},
+		{name: "previous owner", itemID: delivered.ID, userID: owner.ID,
+			review: db.Review{Rating: 3}, wantRevieweeID: swapper.ID},
+		{name: "same swap twice", itemID: delivered.ID, userID: swapper.ID,
+			review: db.Review{Rating: 1}, wantErr: db.ErrAlreadyReviewed},
+		{name: "not a side of the swap", itemID: delivered.ID, userID: stranger.ID,
+			review: db.Review{Rating: 1}, wantErr: db.ErrNotReviewable},
+		{name: "posting failed", itemID: failed.ID, userID: stranger.ID,
+			review: db.Review{Rating: 1}, wantErr: db.ErrNotReviewable},
+		{name: "rating too low", itemID: delivered.ID, userID: swapper.ID,
+			review: db.Review{Rating: 0}, wantErr: db.ErrInvalidInput},
+		{name: "rating too high", itemID: delivered.ID, userID: swapper.ID,
+			review: db.Review{Rating: 6}, wantErr: db.ErrInvalidInput},
+		{name: "comment too long", itemID: delivered.ID, userID: swapper.ID,
+			review: db.Review{Rating: 4, Comment: strings.Repeat("a", 1001)}, wantErr: db.ErrInvalidInput},
+		{name: "unknown item", itemID: uuid.NewString(), userID: swapper.ID,
+			review: db.Review{Rating: 4}, wantErr: db.ErrRecordNotFound},
+		{name: "unknown user", itemID: delivered.ID, userID: uuid.NewString(),
+			review: db.Review{Rating: 4}, wantErr: db.ErrRecordNotFound},
+	}
+	for _, tc := range steps {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := rs.Add(db.BookItem, tc.itemID, tc.userID, tc.review)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Nil(t, got)
+				return
+			}
+			require.Nil(t, err)
+			assert.NotEmpty(t, got.ID)
+			assert.NotZero(t, got.SwapID)
+			assert.Equal(t, db.BookItem, got.ItemType)
+			assert.Equal(t, tc.itemID, got.ItemID)
+			assert.Equal(t, tc.userID, got.ReviewerID)
+			assert.Equal(t, tc.wantRevieweeID, got.RevieweeID)
+			assert.Equal(t, tc.review.Rating, got.Rating)
+			assert.Equal(t, strings.TrimSpace(tc.review.Comment), got.Comment)
+		})
+	}
+}
+
+func TestReputation(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	ps := mocks.NewPostingService(t)
+	ps.On("NewBookOrder", mock.AnythingOfType("db.Book")).Return(nil).Twice()
+	bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, db.NewMagazineService(testDB, nil, nil, nil, nil), nil, nil)
+	rs := db.NewReviewService(testDB, nil, nil)
+	owner := db.CreateTestUser(t, testDB)
+	first := db.CreateTestUser(t, testDB)
+	second := db.CreateTestUser(t, testDB)
+	var reviews []*db.Review
+	for i, reviewer := range []db.User{first, second} {
+		b, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+		require.Nil(t, err)
+		_, err = bs.SwapBook(b.ID, reviewer.ID)
+		require.Nil(t, err)
+		_, err = bs.ConfirmDelivery(b.ID, reviewer.ID)
+		require.Nil(t, err)
+		r, err := rs.Add(db.BookItem, b.ID, reviewer.ID, db.Review{Rating: 4 + i})
+		require.Nil(t, err)
+		reviews = append(reviews, r)
+	}
+
+	t.Run("profile", func(t *testing.T) {
+		profile, err := us.Get(owner.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.Reputation{Average: 4.5, Count: 2}, profile.Reputation)
+	})
+
+	t.Run("no reviews", func(t *testing.T) {
+		reps, err := rs.Reputations([]string{owner.ID, first.ID, "invalid-id"})
+		require.Nil(t, err)
+		assert.Equal(t, map[string]db.Reputation{owner.ID: {Average: 4.5, Count: 2}}, reps)
+	})
+
+	t.Run("flagged", func(t *testing.T) {
+		// Act
+		flagged, err := rs.Flag(reviews[0].ID, owner.ID, db.ReviewFlag{Reason: "Never received it"})
+
+		// Assert
+		require.Nil(t, err)
+		assert.True(t, flagged.Flagged)
+		assert.Equal(t, "Never received it", flagged.FlagReason)
+		assert.Equal(t, owner.ID, flagged.FlaggedBy)
+		profile, err := us.Get(owner.ID)
+		require.Nil(t, err)
+		assert.Equal(t, db.Reputation{Average: 5, Count: 1}, profile.Reputation)
+		listed, err := rs.ListByUser(owner.ID)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(listed))
+		assert.Equal(t, reviews[1].ID, listed[0].ID)
+	})
+
+	t.Run("flagged again", func(t *testing.T) {
+		flagged, err := rs.Flag(reviews[0].ID, first.ID, db.ReviewFlag{Reason: "Spam"})
+		require.Nil(t, err)
+		assert.Equal(t, "Never received it", flagged.FlagReason)
+		assert.Equal(t, owner.ID, flagged.FlaggedBy)
+	})
+}
+
+func TestFlagReviewInvalid(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	rs := db.NewReviewService(testDB, nil, nil)
+	user := db.CreateTestUser(t, testDB)
+	tests := map[string]struct {
+		id, userID string
+		flag       db.ReviewFlag
+		wantErr    error
+	}{
+		"no reason":      {id: uuid.NewString(), userID: user.ID, wantErr: db.ErrInvalidInput},
+		"unknown user":   {id: uuid.NewString(), userID: uuid.NewString(), flag: db.ReviewFlag{Reason: "Spam"}, wantErr: db.ErrRecordNotFound},
+		"invalid review": {id: "invalid-id", userID: user.ID, flag: db.ReviewFlag{Reason: "Spam"}, wantErr: db.ErrRecordNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := rs.Flag(tc.id, tc.userID, tc.flag)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Nil(t, got)
+		})
+	}
+}
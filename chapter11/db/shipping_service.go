@@ -0,0 +1,277 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// ShippingRate is the cost and delivery time of posting an item from one zone to another.
+type ShippingRate struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Cost is in the minor unit of the currency of the rate table, such as pence.
+	Cost    int `json:"cost"`
+	MinDays int `json:"min_days"`
+	MaxDays int `json:"max_days"`
+}
+
+// ShippingRates is the zone/rate table shipping quotes are computed from.
+// Zones group ISO 3166-1 alpha-2 country codes, and a rate from one zone to another
+// also applies the other way around, unless the table has a rate for it too.
+// The optional local rate applies between users of the same country and post code area.
+type ShippingRates struct {
+	Currency string              `json:"currency"`
+	Zones    map[string][]string `json:"zones"`
+	Local    *ShippingRate       `json:"local,omitempty"`
+	Rates    []ShippingRate      `json:"rates"`
+
+	zoneOf map[string]string
+	rates  map[[2]string]ShippingRate
+}
+
+// ShippingQuote is the cost and delivery time of posting an item to a user.
+type ShippingQuote struct {
+	ItemType ItemType `json:"item_type"`
+	ItemID   string   `json:"item_id"`
+	UserID   string   `json:"user_id"`
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Local    bool     `json:"local"`
+	Cost     int      `json:"cost"`
+	Currency string   `json:"currency"`
+	MinDays  int      `json:"min_days"`
+	MaxDays  int      `json:"max_days"`
+}
+
+// LoadShippingRates reads and validates a JSON rate table.
+func LoadShippingRates(path string) (*ShippingRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sr ShippingRates
+	if err := json.Unmarshal(data, &sr); err != nil {
+		return nil, fmt.Errorf("invalid shipping rates %s:%v", path, err)
+	}
+	if err := sr.index(); err != nil {
+		return nil, fmt.Errorf("invalid shipping rates %s:%v", path, err)
+	}
+	return &sr, nil
+}
+
+// index validates the table and builds its lookups.
+func (sr *ShippingRates) index() error {
+	if sr.Currency == "" {
+		return fmt.Errorf("%w: no currency", ErrInvalidInput)
+	}
+	sr.zoneOf = map[string]string{}
+	for zone, countries := range sr.Zones {
+		for _, c := range countries {
+			country := normalizeCountry(c)
+			if len(country) != 2 {
+				return fmt.Errorf("%w: invalid country %q in zone %s", ErrInvalidInput, c, zone)
+			}
+			if other, ok := sr.zoneOf[country]; ok {
+				return fmt.Errorf("%w: country %s is in zones %s and %s", ErrInvalidInput, country, other, zone)
+			}
+			sr.zoneOf[country] = zone
+		}
+	}
+	if sr.Local != nil {
+		if err := validateRate(*sr.Local); err != nil {
+			return fmt.Errorf("local rate:%w", err)
+		}
+	}
+	explicit := map[[2]string]bool{}
+	sr.rates = map[[2]string]ShippingRate{}
+	for _, r := range sr.Rates {
+		for _, zone := range []string{r.From, r.To} {
+			if _, ok := sr.Zones[zone]; !ok {
+				return fmt.Errorf("%w: unknown zone %q", ErrInvalidInput, zone)
+			}
+		}
+		if err := validateRate(r); err != nil {
+			return fmt.Errorf("rate from %s to %s:%w", r.From, r.To, err)
+		}
+		key := [2]string{r.From, r.To}
+		if explicit[key] {
+			return fmt.Errorf("%w: duplicate rate from %s to %s", ErrInvalidInput, r.From, r.To)
+		}
+		explicit[key] = true
+		sr.rates[key] = r
+		if reverse := [2]string{r.To, r.From}; !explicit[reverse] {
+			sr.rates[reverse] = ShippingRate{From: r.To, To: r.From, Cost: r.Cost, MinDays: r.MinDays, MaxDays: r.MaxDays}
+		}
+	}
+	return nil
+}
+
+// Rate returns the rate between two users, or ErrNotShippable if items cannot be posted between them.
+// It also returns whether the local rate applies.
+func (sr *ShippingRates) Rate(from, to User) (ShippingRate, bool, error) {
+	fromCountry, toCountry := normalizeCountry(from.Country), normalizeCountry(to.Country)
+	if sr.Local != nil && fromCountry != "" && fromCountry == toCountry {
+		if area := postCodeArea(from.PostCode); area != "" && area == postCodeArea(to.PostCode) {
+			return *sr.Local, true, nil
+		}
+	}
+	fromZone, ok := sr.zoneOf[fromCountry]
+	if !ok {
+		return ShippingRate{}, false, fmt.Errorf("%w: no shipping from country %q", ErrNotShippable, from.Country)
+	}
+	toZone, ok := sr.zoneOf[toCountry]
+	if !ok {
+		return ShippingRate{}, false, fmt.Errorf("%w: no shipping to country %q", ErrNotShippable, to.Country)
+	}
+	r, ok := sr.rates[[2]string{fromZone, toZone}]
+	if !ok {
+		return ShippingRate{}, false, fmt.Errorf("%w: no shipping from %s to %s", ErrNotShippable, fromZone, toZone)
+	}
+	return r, false, nil
+}
+
+// Origins returns the countries items can be posted from to the given country.
+func (sr *ShippingRates) Origins(country string) []string {
+	toZone, ok := sr.zoneOf[normalizeCountry(country)]
+	if !ok {
+		return nil
+	}
+	var countries []string
+	for c, zone := range sr.zoneOf {
+		if _, ok := sr.rates[[2]string{zone, toZone}]; ok {
+			countries = append(countries, c)
+		}
+	}
+	return countries
+}
+
+// ShippingService quotes the cost of posting items between users.
+type ShippingService struct {
+	DB    *gorm.DB
+	rates *ShippingRates
+}
+
+// NewShippingService initialises a ShippingService given its dependencies.
+func NewShippingService(db *gorm.DB, rates *ShippingRates, clock Clock, ids IDGenerator) *ShippingService {
+	return &ShippingService{
+		DB:    withClock(db, clock, ids),
+		rates: rates,
+	}
+}
+
+// InCommunity returns a copy of the service which only quotes the items and users of a community.
+func (ss *ShippingService) InCommunity(communityID string) *ShippingService {
+	c := *ss
+	c.DB = InCommunity(ss.DB, communityID)
+	return &c
+}
+
+// QuoteBook returns the cost of posting a book from its owner to a user.
+func (ss *ShippingService) QuoteBook(bookID, userID string) (*ShippingQuote, error) {
+	var b Book
+	if !isValidID(bookID) {
+		return nil, fmt.Errorf("no book found for id %s:%w", bookID, ErrRecordNotFound)
+	}
+	if r := ss.DB.Where("id = ?", bookID).First(&b); r.Error != nil {
+		return nil, fmt.Errorf("no book found for id %s:%w", bookID, ErrRecordNotFound)
+	}
+	if b.OwnerID == userID {
+		return nil, fmt.Errorf("%w: user %s already owns book %s", ErrInvalidInput, userID, bookID)
+	}
+	return ss.quote(BookItem, b.ID, b.OwnerID, userID)
+}
+
+// ShippableTo returns which of the given owners can post items to a user.
+func (ss *ShippingService) ShippableTo(userID string, ownerIDs []string) (map[string]bool, error) {
+	to, err := ss.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	shippable := make(map[string]bool, len(ownerIDs))
+	origins := ss.rates.Origins(to.Country)
+	if len(ownerIDs) == 0 || len(origins) == 0 {
+		return shippable, nil
+	}
+	var owners []User
+	if r := ss.DB.Where("id IN ?", ownerIDs).Find(&owners); r.Error != nil {
+		return nil, r.Error
+	}
+	for _, o := range owners {
+		if _, _, err := ss.rates.Rate(o, *to); err == nil {
+			shippable[o.ID] = true
+		}
+	}
+	return shippable, nil
+}
+
+// quote computes the quote of posting an item between two users.
+func (ss *ShippingService) quote(itemType ItemType, itemID, ownerID, userID string) (*ShippingQuote, error) {
+	from, err := ss.user(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := ss.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	r, local, err := ss.rates.Rate(*from, *to)
+	if err != nil {
+		return nil, err
+	}
+	return &ShippingQuote{
+		ItemType: itemType,
+		ItemID:   itemID,
+		UserID:   userID,
+		From:     normalizeCountry(from.Country),
+		To:       normalizeCountry(to.Country),
+		Local:    local,
+		Cost:     r.Cost,
+		Currency: ss.rates.Currency,
+		MinDays:  r.MinDays,
+		MaxDays:  r.MaxDays,
+	}, nil
+}
+
+// user returns the user of the given ID, or ErrRecordNotFound if none exists.
+func (ss *ShippingService) user(id string) (*User, error) {
+	var u User
+	if !isValidID(id) {
+		return nil, fmt.Errorf("no user found for id %s:%w", id, ErrRecordNotFound)
+	}
+	if r := ss.DB.Where("id = ?", id).First(&u); r.Error != nil {
+		return nil, fmt.Errorf("no user found for id %s:%w", id, ErrRecordNotFound)
+	}
+	return &u, nil
+}
+
+func validateRate(r ShippingRate) error {
+	if r.Cost < 0 || r.MinDays < 0 || r.MaxDays < r.MinDays {
+		return fmt.Errorf("%w: negative cost or invalid delivery days", ErrInvalidInput)
+	}
+	return nil
+}
+
+func normalizeCountry(country string) string {
+	return strings.ToUpper(strings.TrimSpace(country))
+}
+
+// postCodeArea returns the area of a post code: its leading letters, such as SW for SW1A 1AA,
+// or its first two digits for numeric post codes, such as 75 for 75001.
+func postCodeArea(postCode string) string {
+	pc := strings.ToUpper(strings.ReplaceAll(postCode, " ", ""))
+	letters := strings.IndexFunc(pc, func(r rune) bool { return !unicode.IsLetter(r) })
+	switch {
+	case letters > 0:
+		return pc[:letters]
+	case letters < 0:
+		return pc
+	case len(pc) >= 2:
+		return pc[:2]
+	}
+	return pc
+}
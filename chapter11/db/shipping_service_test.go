@@ -0,0 +1,185 @@
+package db_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShippingRate(t *testing.T) {
+	rates, err := db.LoadShippingRates("shipping_rates.json")
+	require.Nil(t, err)
+	tests := map[string]struct {
+		from, to  db.User
+		want      db.ShippingRate
+		wantLocal bool
+		wantErr   error
+	}{
+		"local": {
+			from: db.User{PostCode: "N1 9GU", Country: "GB"}, to: db.User{PostCode: "n7 6ab", Country: "gb"},
+			want: db.ShippingRate{Cost: 150, MinDays: 1, MaxDays: 2}, wantLocal: true,
+		},
+		"numeric post codes": {
+			from: db.User{PostCode: "75001", Country: "FR"}, to: db.User{PostCode: "75019", Country: "FR"},
+			want: db.ShippingRate{Cost: 150, MinDays: 1, MaxDays: 2}, wantLocal: true,
+		},
+		"same zone": {
+			from: db.User{PostCode: "N1 9GU", Country: "GB"}, to: db.User{PostCode: "SW1A 1AA", Country: "GB"},
+			want: db.ShippingRate{From: "UK", To: "UK", Cost: 295, MinDays: 1, MaxDays: 3},
+		},
+		"across zones": {
+			from: db.User{Country: "GB"}, to: db.User{Country: "DE"},
+			want: db.ShippingRate{From: "UK", To: "EU", Cost: 950, MinDays: 3, MaxDays: 7},
+		},
+		"reverse rate": {
+			from: db.User{Country: "US"}, to: db.User{Country: "GB"},
+			want: db.ShippingRate{From: "NA", To: "UK", Cost: 1450, MinDays: 5, MaxDays: 10},
+		},
+		"same post code area in other countries": {
+			from: db.User{PostCode: "75001", Country: "FR"}, to: db.User{PostCode: "75001", Country: "DE"},
+			want: db.ShippingRate{From: "EU", To: "EU", Cost: 750, MinDays: 2, MaxDays: 5},
+		},
+		"unknown origin":      {from: db.User{Country: "JP"}, to: db.User{Country: "GB"}, wantErr: db.ErrNotShippable},
+		"unknown destination": {from: db.User{Country: "GB"}, to: db.User{Country: "JP"}, wantErr: db.ErrNotShippable},
+		"no country":          {from: db.User{PostCode: "N1"}, to: db.User{PostCode: "N1"}, wantErr: db.ErrNotShippable},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, local, err := rates.Rate(tc.from, tc.to)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantLocal, local)
+		})
+	}
+}
+
+func TestShippingOrigins(t *testing.T) {
+	data := `{"currency":"GBP","zones":{"UK":["GB"],"EU":["FR","DE"],"ASIA":["JP"]},
+		"rates":[{"from":"UK","to":"EU","cost":950,"min_days":3,"max_days":7}]}`
+	rates, err := db.LoadShippingRates(writeRates(t, data))
+	require.Nil(t, err)
+
+	assert.ElementsMatch(t, []string{"GB"}, rates.Origins("FR"))
+	assert.ElementsMatch(t, []string{"FR", "DE"}, rates.Origins("gb"))
+	assert.Empty(t, rates.Origins("JP"))
+	assert.Empty(t, rates.Origins("US"))
+}
+
+func TestLoadShippingRatesInvalid(t *testing.T) {
+	tests := map[string]string{
+		"invalid json":    `{"currency":`,
+		"no currency":     `{"zones":{"UK":["GB"]}}`,
+		"invalid country": `{"currency":"GBP","zones":{"UK":["GBR"]}}`,
+		"country twice":   `{"currency":"GBP","zones":{"UK":["GB"],"EU":["gb"]}}`,
+		"unknown zone":    `{"currency":"GBP","zones":{"UK":["GB"]},"rates":[{"from":"UK","to":"EU"}]}`,
+		"negative cost":   `{"currency":"GBP","zones":{"UK":["GB"]},"rates":[{"from":"UK","to":"UK","cost":-1}]}`,
+		"invalid days":    `{"currency":"GBP","zones":{"UK":["GB"]},"rates":[{"from":"UK","to":"UK","min_days":3,"max_days":1}]}`,
+		"invalid local":   `{"currency":"GBP","zones":{"UK":["GB"]},"local":{"cost":-1}}`,
+		"duplicate rate":  `{"currency":"GBP","zones":{"UK":["GB"]},"rates":[{"from":"UK","to":"UK"},{"from":"UK","to":"UK"}]}`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := db.LoadShippingRates(writeRates(t, data))
+			assert.NotNil(t, err)
+		})
+	}
+	t.Run("missing file", func(t *testing.T) {
+		_, err := db.LoadShippingRates(filepath.Join(t.TempDir(), "missing.json"))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestQuoteBook(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	rates, err := db.LoadShippingRates("shipping_rates.json")
+	require.Nil(t, err)
+	us := db.NewUserService(testDB, nil, nil, nil, nil)
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ss := db.NewShippingService(testDB, rates, nil, nil)
+	london, err := us.Upsert(db.User{Name: "London", PostCode: "N1 9GU", Country: "GB"})
+	require.Nil(t, err)
+	paris, err := us.Upsert(db.User{Name: "Paris", PostCode: "75001", Country: "FR"})
+	require.Nil(t, err)
+	tokyo, err := us.Upsert(db.User{Name: "Tokyo", PostCode: "100-0001", Country: "JP"})
+	require.Nil(t, err)
+	book, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: london.ID})
+	require.Nil(t, err)
+
+	tests := map[string]struct {
+		bookID, userID string
+		want           *db.ShippingQuote
+		wantErr        error
+	}{
+		"shippable": {bookID: book.ID, userID: paris.ID, want: &db.ShippingQuote{
+			ItemType: db.BookItem, ItemID: book.ID, UserID: paris.ID, From: "GB", To: "FR",
+			Cost: 950, Currency: "GBP", MinDays: 3, MaxDays: 7,
+		}},
+		"not shippable": {bookID: book.ID, userID: tokyo.ID, wantErr: db.ErrNotShippable},
+		"owner":         {bookID: book.ID, userID: london.ID, wantErr: db.ErrInvalidInput},
+		"unknown book":  {bookID: uuid.NewString(), userID: paris.ID, wantErr: db.ErrRecordNotFound},
+		"invalid book":  {bookID: "invalid-id", userID: paris.ID, wantErr: db.ErrRecordNotFound},
+		"unknown user":  {bookID: book.ID, userID: uuid.NewString(), wantErr: db.ErrRecordNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ss.QuoteBook(tc.bookID, tc.userID)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Nil(t, got)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestShippableTo(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	rates, err := db.LoadShippingRates("shipping_rates.json")
+	require.Nil(t, err)
+	us := db.NewUserService(testDB, nil, nil, nil, nil)
+	ss := db.NewShippingService(testDB, rates, nil, nil)
+	london, err := us.Upsert(db.User{Name: "London", Country: "GB"})
+	require.Nil(t, err)
+	paris, err := us.Upsert(db.User{Name: "Paris", Country: "FR"})
+	require.Nil(t, err)
+	tokyo, err := us.Upsert(db.User{Name: "Tokyo", Country: "JP"})
+	require.Nil(t, err)
+
+	t.Run("shippable owners", func(t *testing.T) {
+		got, err := ss.ShippableTo(paris.ID, []string{london.ID, tokyo.ID, paris.ID})
+		require.Nil(t, err)
+		assert.Equal(t, map[string]bool{london.ID: true, paris.ID: true}, got)
+	})
+
+	t.Run("unreachable user", func(t *testing.T) {
+		got, err := ss.ShippableTo(tokyo.ID, []string{london.ID, paris.ID})
+		require.Nil(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, err := ss.ShippableTo(uuid.NewString(), []string{london.ID})
+		assert.ErrorIs(t, err, db.ErrRecordNotFound)
+	})
+}
+
+// writeRates writes a rate table to a temporary file and returns its path.
+func writeRates(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.Nil(t, os.WriteFile(path, []byte(data), 0o600))
+	return path
+}
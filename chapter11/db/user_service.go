@@ -2,8 +2,8 @@ package db
 
 import (
 	"fmt"
+	"time"
 
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -11,16 +11,26 @@ import (
 type User struct {
 	ID       string `json:"id" gorm:"primaryKey"`
 	Name     string `json:"name"`
+	Email    string `json:"email,omitempty"`
 	Address  string `json:"address"`
 	PostCode string `json:"post_code"`
 	Country  string `json:"country"`
+	// CommunityID is the community the user is a member of. It is set when the user is created.
+	CommunityID string `json:"community_id,omitempty"`
+	// Suspended users cannot list or swap items. Only admins suspend and reactivate users.
+	Suspended bool `json:"suspended,omitempty"`
+	// The timestamps are set with the clock of the service whenever a user is saved.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// Wrapper struct for all the books and magazines of a given user
+// Wrapper struct for all the books and magazines of a given user,
+// together with the reputation they have earned in reviews
 type UserProfile struct {
-	User User
-	Books []Book
-	Magazines []Magazine
+	User       User
+	Books      []Book
+	Magazines  []Magazine
+	Reputation Reputation
 }
 
 // UserService has all the dependencies required for managing users.
@@ -39,9 +49,10 @@ type MagazineOperationsService interface {
 }
 
 // NewUserService initialises the UserService.
-func NewUserService(db *gorm.DB, bs BookOperationsService, ms MagazineOperationsService) *UserService {
+func NewUserService(db *gorm.DB, bs BookOperationsService, ms MagazineOperationsService,
+	clock Clock, ids IDGenerator) *UserService {
 	return &UserService{
-		DB: db,
+		DB: withClock(db, clock, ids),
 		bs: bs,
 		ms: ms,
 	}
@@ -49,9 +60,12 @@ func NewUserService(db *gorm.DB, bs BookOperationsService, ms MagazineOperations
 
 // Get returns a given user or error if none exists.
 func (us *UserService) Get(id string) (*UserProfile, error) {
+	if !isValidID(id) {
+		return nil, fmt.Errorf("no user found for id %s:%w", id, gorm.ErrRecordNotFound)
+	}
 	var u User
 	if r := us.DB.Where("id = ?", id).First(&u); r.Error != nil {
-		return nil, fmt.Errorf("no user found for id %s:%v", id, r.Error)
+		return nil, fmt.Errorf("no user found for id %s:%w", id, r.Error)
 	}
 	books, err := us.bs.ListByUser(id)
 	if err != nil {
@@ -61,31 +75,106 @@ func (us *UserService) Get(id string) (*UserProfile, error) {
 	if err != nil {
 		return nil, err
 	}
+	reps, err := reputations(us.DB, []string{id})
+	if err != nil {
+		return nil, err
+	}
 
 	return &UserProfile{
-		User: u,
-		Books: books,
-		Magazines: mags,
+		User:       u,
+		Books:      books,
+		Magazines:  mags,
+		Reputation: reps[id],
 	}, nil
 }
 
+// List returns all the users, ordered by name.
+func (us *UserService) List() ([]User, error) {
+	var items []User
+	if r := us.DB.Order("name").Find(&items); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return items, nil
+}
+
+// ListByIDs returns the users with the given IDs, in a single query.
+// Unknown users are left out.
+func (us *UserService) ListByIDs(ids []string) ([]User, error) {
+	var items []User
+	ids = validIDs(ids)
+	if len(ids) == 0 {
+		return items, nil
+	}
+	if r := us.DB.Where("id IN ?", ids).Find(&items); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return items, nil
+}
+
 // Exists returns whether a given user exists and returns an error if none found.
 func (us *UserService) Exists(id string) error {
+	if !isValidID(id) {
+		return fmt.Errorf("no user found for id %s:%w", id, gorm.ErrRecordNotFound)
+	}
 	var u User
 	if r := us.DB.Where("id = ?", id).First(&u); r.Error != nil {
-		return fmt.Errorf("no user found for id %s:%v", id, r.Error)
+		return fmt.Errorf("no user found for id %s:%w", id, r.Error)
 	}
 
 	return nil
 }
 
+// InCommunity returns a copy of the service which only sees and changes the users of a community.
+func (us *UserService) InCommunity(communityID string) *UserService {
+	c := *us
+	c.DB = InCommunity(us.DB, communityID)
+	return &c
+}
+
 // Upsert creates or updates a new order.
+// New users join the community the service is scoped to, or else the given or default community,
+// and users never change community once they have joined one. Users cannot suspend or reactivate themselves.
 func (us *UserService) Upsert(u User) (User, error) {
 	var eu User
-	if r := us.DB.Where("id = ?", u.ID).First(&eu); r.Error != nil {
-		u.ID = uuid.NewString()
+	if !isValidID(u.ID) || us.DB.Where("id = ?", u.ID).First(&eu).Error != nil {
+		u.ID = idsOf(us.DB).NewID()
+		communityID, err := us.community(u.CommunityID)
+		if err != nil {
+			return User{}, err
+		}
+		u.CommunityID = communityID
+		u.Suspended = false
+		u.CreatedAt, u.UpdatedAt = time.Time{}, time.Time{}
+	} else {
+		u.CommunityID = eu.CommunityID
+		u.Suspended = eu.Suspended
+		u.CreatedAt = eu.CreatedAt
+	}
+	if r := us.DB.Save(&u); r.Error != nil {
+		return User{}, r.Error
 	}
-	us.DB.Save(&u)
 
 	return u, nil
 }
+
+// community returns the community a new user joins.
+func (us *UserService) community(id string) (string, error) {
+	if scoped, ok := communityOf(us.DB); ok {
+		return scoped, nil
+	}
+	if id == "" {
+		return DefaultCommunityID, nil
+	}
+	var count int64
+	if isValidID(id) {
+		if r := us.DB.Model(&Community{}).Where("id = ?", id).Count(&count); r.Error != nil {
+			return "", r.Error
+		}
+	}
+	if count == 0 {
+		return "", fmt.Errorf("%w: no community found for id %q", ErrInvalidInput, id)
+	}
+	return id, nil
+}
@@ -24,7 +24,7 @@ func TestGetUser(t *testing.T) {
 		}
 		bs := mocks.NewBookOperationsService(t)
 		ms := mocks.NewMagazineOperationsService(t)
-		us := db.NewUserService(testDB, bs, ms)
+		us := db.NewUserService(testDB, bs, ms, nil, nil)
 		eu, err := us.Upsert(db.User{
 			Name: "Existing user",
 		})
@@ -36,11 +36,12 @@ func TestGetUser(t *testing.T) {
 		assert.Equal(t, eu, userProfile.User)
 		assert.Equal(t, 1, len(userProfile.Books))
 		assert.Contains(t, userProfile.Books, eb)
+		assert.Equal(t, db.Reputation{}, userProfile.Reputation)
 		bs.AssertExpectations(t)
 		ms.AssertExpectations(t)
 	})
 	t.Run("invalid users", func(t *testing.T) {
-		us := db.NewUserService(testDB, nil, nil)
+		us := db.NewUserService(testDB, nil, nil, nil, nil)
 		tests := map[string]struct {
 			id      string
 			wantErr string
@@ -63,7 +64,7 @@ func TestUpsertUser(t *testing.T) {
 	defer cleaner()
 	bs := mocks.NewBookOperationsService(t)
 	ms := mocks.NewMagazineOperationsService(t)
-	us := db.NewUserService(testDB, bs, ms)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
 	newUser := db.User{
 		Name: "New user",
 	}
@@ -81,7 +82,7 @@ func TestExistsUser(t *testing.T) {
 	bs := mocks.NewBookOperationsService(t)
 	ms := mocks.NewMagazineOperationsService(t)
 	t.Run("existing user", func(t *testing.T) {
-		us := db.NewUserService(testDB, bs, ms)
+		us := db.NewUserService(testDB, bs, ms, nil, nil)
 		eu, err := us.Upsert(db.User{
 			Name: "Existing user",
 		})
@@ -90,9 +91,24 @@ func TestExistsUser(t *testing.T) {
 		require.Nil(t, err)
 	})
 	t.Run("invalid ID user", func(t *testing.T) {
-		us := db.NewUserService(testDB, bs, ms)
+		us := db.NewUserService(testDB, bs, ms, nil, nil)
 		err := us.Exists(uuid.New().String())
 		require.NotNil(t, err)
 		assert.Contains(t, err.Error(), "no user found")
 	})
 }
+
+func TestListUsers(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	us := db.NewUserService(testDB, nil, nil, nil, nil)
+	zoe, err := us.Upsert(db.User{Name: "Zoe"})
+	require.Nil(t, err)
+	adam, err := us.Upsert(db.User{Name: "Adam"})
+	require.Nil(t, err)
+
+	users, err := us.List()
+
+	require.Nil(t, err)
+	assert.Equal(t, []db.User{adam, zoe}, users)
+}
@@ -0,0 +1,321 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventTypes is a list of item event types, stored as a JSON array.
+type EventTypes []ItemEventType
+
+// Value implements the driver.Valuer interface.
+func (et EventTypes) Value() (driver.Value, error) {
+	b, err := json.Marshal(et)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (et *EventTypes) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, et)
+	case string:
+		return json.Unmarshal([]byte(v), et)
+	default:
+		return fmt.Errorf("cannot scan %T into EventTypes", src)
+	}
+}
+
+// WebhookSubscription contains all the fields for representing a partner's webhook.
+// The secret is used for signing payloads and is only returned when the subscription is created.
+// Webhooks only receive the events of the community they are subscribed in.
+type WebhookSubscription struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	URL         string     `json:"url"`
+	EventTypes  EventTypes `json:"event_types"`
+	Secret      string     `json:"secret,omitempty"`
+	CommunityID string     `json:"community_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// WebhookDelivery contains all the fields for representing an item event sent to a webhook.
+type WebhookDelivery struct {
+	ID             string          `json:"id" gorm:"primaryKey"`
+	SubscriptionID string          `json:"subscription_id"`
+	CommunityID    string          `json:"-"`
+	EventID        int64           `json:"event_id"`
+	EventType      ItemEventType   `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// QueuedWebhookDelivery is a due webhook delivery together with the subscription it is for.
+type QueuedWebhookDelivery struct {
+	Delivery     WebhookDelivery
+	Subscription WebhookSubscription
+}
+
+// WebhookService contains all the functionality and dependencies for managing webhooks.
+type WebhookService struct {
+	DB *gorm.DB
+	// privateURLs allows webhooks to be sent to private and loopback addresses.
+	privateURLs bool
+}
+
+// NewWebhookService initialises a WebhookService given its dependencies.
+func NewWebhookService(db *gorm.DB, clock Clock, ids IDGenerator) *WebhookService {
+	return &WebhookService{
+		DB: withClock(db, clock, ids),
+	}
+}
+
+// WithPrivateURLs allows webhooks to be subscribed on private and loopback addresses,
+// such as the local receivers of tests. They are refused otherwise, so that partners
+// cannot make the dispatcher send requests to the internal network of the deployment.
+func (whs *WebhookService) WithPrivateURLs() *WebhookService {
+	whs.privateURLs = true
+	return whs
+}
+
+// InCommunity returns a copy of the service which only sees and changes the webhooks of a community.
+func (whs *WebhookService) InCommunity(communityID string) *WebhookService {
+	c := *whs
+	c.DB = InCommunity(whs.DB, communityID)
+	return &c
+}
+
+// Create subscribes a new webhook to the given event types, in the community the service is scoped to
+// or else the default community. A secret is generated for it, if one is not provided.
+func (whs *WebhookService) Create(s WebhookSubscription) (WebhookSubscription, error) {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return WebhookSubscription{}, fmt.Errorf("%w: invalid webhook url %q", ErrInvalidInput, s.URL)
+	}
+	if !whs.privateURLs {
+		if err := checkPublicHost(u.Hostname()); err != nil {
+			return WebhookSubscription{}, err
+		}
+	}
+	if len(s.EventTypes) == 0 {
+		return WebhookSubscription{}, fmt.Errorf("%w: webhooks need at least one event type", ErrInvalidInput)
+	}
+	for _, t := range s.EventTypes {
+		if !IsItemEventType(t) {
+			return WebhookSubscription{}, fmt.Errorf("%w: unknown event type %q", ErrInvalidInput, t)
+		}
+	}
+	if s.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return WebhookSubscription{}, err
+		}
+		s.Secret = hex.EncodeToString(secret)
+	}
+	s.ID = idsOf(whs.DB).NewID()
+	s.CommunityID = DefaultCommunityID
+	if communityID, ok := communityOf(whs.DB); ok {
+		s.CommunityID = communityID
+	}
+	if r := whs.DB.Create(&s); r.Error != nil {
+		return WebhookSubscription{}, r.Error
+	}
+
+	return s, nil
+}
+
+// List returns all the webhook subscriptions, without their secrets.
+func (whs *WebhookService) List() ([]WebhookSubscription, error) {
+	var items []WebhookSubscription
+	if r := whs.DB.Omit("secret").Order("created_at").Find(&items); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return items, nil
+}
+
+// Get returns a given webhook subscription, without its secret, or error if none exists.
+func (whs *WebhookService) Get(id string) (*WebhookSubscription, error) {
+	if !isValidID(id) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var s WebhookSubscription
+	if r := whs.DB.Omit("secret").Where("id = ?", id).First(&s); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return &s, nil
+}
+
+// Delete unsubscribes a webhook, removing its delivery log.
+func (whs *WebhookService) Delete(id string) error {
+	if _, err := whs.Get(id); err != nil {
+		return fmt.Errorf("no webhook found for id %s:%w", id, err)
+	}
+
+	return whs.DB.Where("id = ?", id).Delete(&WebhookSubscription{}).Error
+}
+
+// ListDeliveries returns the delivery log of a given webhook, newest first.
+func (whs *WebhookService) ListDeliveries(subscriptionID string) ([]WebhookDelivery, error) {
+	var items []WebhookDelivery
+	if !isValidID(subscriptionID) {
+		return items, nil
+	}
+	if r := whs.DB.Where("subscription_id = ?", subscriptionID).
+		Order("created_at DESC").Find(&items); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return items, nil
+}
+
+// Replay queues a previous delivery of a webhook to be sent again with the same payload.
+func (whs *WebhookService) Replay(subscriptionID, deliveryID string) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	if !isValidID(subscriptionID) || !isValidID(deliveryID) {
+		return d, fmt.Errorf("no webhook delivery found for id %s:%w", deliveryID, gorm.ErrRecordNotFound)
+	}
+	if r := whs.DB.Where("id = ? AND subscription_id = ?", deliveryID, subscriptionID).First(&d); r.Error != nil {
+		return WebhookDelivery{}, fmt.Errorf("no webhook delivery found for id %s:%w", deliveryID, r.Error)
+	}
+	replay := newWebhookDelivery(whs.DB, WebhookSubscription{ID: subscriptionID, CommunityID: d.CommunityID},
+		d.EventID, d.EventType, d.Payload)
+	if r := whs.DB.Create(&replay); r.Error != nil {
+		return WebhookDelivery{}, r.Error
+	}
+
+	return replay, nil
+}
+
+// Pending returns up to limit webhook deliveries which are due to be attempted.
+func (whs *WebhookService) Pending(limit int) ([]QueuedWebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	if r := whs.DB.Where("status = ? AND next_attempt_at <= ?", DeliveryPending, clockOf(whs.DB).Now()).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries); r.Error != nil {
+		return nil, r.Error
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		ids = append(ids, d.SubscriptionID)
+	}
+	var ss []WebhookSubscription
+	if r := whs.DB.Where("id IN ?", ids).Find(&ss); r.Error != nil {
+		return nil, r.Error
+	}
+	subscriptions := make(map[string]WebhookSubscription, len(ss))
+	for _, s := range ss {
+		subscriptions[s.ID] = s
+	}
+
+	queued := make([]QueuedWebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		queued = append(queued, QueuedWebhookDelivery{
+			Delivery:     d,
+			Subscription: subscriptions[d.SubscriptionID],
+		})
+	}
+	return queued, nil
+}
+
+// checkPublicHost returns ErrInvalidInput unless a host only resolves to public addresses.
+func checkPublicHost(host string) error {
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		if ips, err = net.LookupIP(host); err != nil {
+			return fmt.Errorf("%w: cannot resolve webhook host %q", ErrInvalidInput, host)
+		}
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return fmt.Errorf("%w: webhook host %q is not a public address", ErrInvalidInput, host)
+		}
+	}
+	return nil
+}
+
+// IsPublicIP reports whether an address can be reached on the internet, as opposed to
+// loopback, private, link-local and unspecified addresses.
+func IsPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsUnspecified()
+}
+
+// RecordAttempt stores the outcome of an attempt to send a webhook delivery.
+// Deliveries which are still pending are attempted again at retryAt.
+func (whs *WebhookService) RecordAttempt(id string, status DeliveryStatus, responseStatus int,
+	sendErr error, retryAt time.Time) error {
+	updates := map[string]any{
+		"status":          status,
+		"attempts":        gorm.Expr("attempts + 1"),
+		"next_attempt_at": retryAt,
+		"response_status": responseStatus,
+		"last_error":      "",
+	}
+	if sendErr != nil {
+		updates["last_error"] = sendErr.Error()
+	}
+	return whs.DB.Model(&WebhookDelivery{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// enqueueWebhooks queues a delivery of a recorded event to every webhook subscribed to its type
+// in the community of the owner of its item.
+func enqueueWebhooks(tx *gorm.DB, e ItemEvent) error {
+	var ss []WebhookSubscription
+	if r := tx.Where("event_types @> jsonb_build_array(?::text)", e.Type).
+		Where("community_id = (SELECT community_id FROM users WHERE id = ?)", e.OwnerID).
+		Find(&ss); r.Error != nil {
+		return r.Error
+	}
+	if len(ss) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	for _, s := range ss {
+		d := newWebhookDelivery(tx, s, e.ID, e.Type, payload)
+		if r := tx.Create(&d); r.Error != nil {
+			return r.Error
+		}
+	}
+
+	return nil
+}
+
+// newWebhookDelivery initialises a pending delivery of an event's payload to a webhook,
+// due straight away by the clock of the given connection.
+func newWebhookDelivery(gdb *gorm.DB, s WebhookSubscription, eventID int64, t ItemEventType,
+	payload json.RawMessage) WebhookDelivery {
+	return WebhookDelivery{
+		ID:             idsOf(gdb).NewID(),
+		SubscriptionID: s.ID,
+		CommunityID:    s.CommunityID,
+		EventID:        eventID,
+		EventType:      t,
+		Payload:        payload,
+		Status:         DeliveryPending,
+		NextAttemptAt:  clockOf(gdb).Now(),
+	}
+}
@@ -0,0 +1,33 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWebhookInvalidURL(t *testing.T) {
+	tests := map[string]string{
+		"not http":        "ftp://partner.example/hook",
+		"no host":         "https:///hook",
+		"loopback":        "http://127.0.0.1:8080/hook",
+		"localhost":       "http://localhost/hook",
+		"ipv6 loopback":   "http://[::1]/hook",
+		"private":         "https://10.0.0.5/hook",
+		"link-local":      "http://169.254.169.254/latest/meta-data",
+		"unspecified":     "http://0.0.0.0/hook",
+		"private ipv6":    "http://[fd00::1]/hook",
+		"unresolved host": "https://partner.invalid/hook",
+	}
+	for name, url := range tests {
+		t.Run(name, func(t *testing.T) {
+			whs := db.NewWebhookService(nil, nil, nil)
+
+			_, err := whs.Create(db.WebhookSubscription{URL: url, EventTypes: db.EventTypes{db.ItemCreated}})
+
+			assert.True(t, errors.Is(err, db.ErrInvalidInput))
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WishlistItem contains all the fields for representing a wanted book or magazine.
+// Books can be wanted by name, author or both, while magazines are wanted by name.
+type WishlistItem struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id"`
+	ItemType  ItemType  `json:"item_type"`
+	Name      string    `json:"name"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WishlistService contains all the functionality and dependencies for managing wishlists.
+type WishlistService struct {
+	DB *gorm.DB
+}
+
+// NewWishlistService initialises a WishlistService given its dependencies.
+func NewWishlistService(db *gorm.DB, clock Clock, ids IDGenerator) *WishlistService {
+	return &WishlistService{
+		DB: withClock(db, clock, ids),
+	}
+}
+
+// InCommunity returns a copy of the service which only sees and changes the wishlists of a community.
+func (ws *WishlistService) InCommunity(communityID string) *WishlistService {
+	c := *ws
+	c.DB = InCommunity(ws.DB, communityID)
+	return &c
+}
+
+// Add adds a new item to a user's wishlist.
+func (ws *WishlistService) Add(wi WishlistItem) (WishlistItem, error) {
+	wi.Name = strings.TrimSpace(wi.Name)
+	wi.Author = strings.TrimSpace(wi.Author)
+	switch {
+	case wi.ItemType == BookItem && wi.Name == "" && wi.Author == "":
+		return WishlistItem{}, fmt.Errorf("%w: wanted books need a name or author", ErrInvalidInput)
+	case wi.ItemType == MagazineItem && wi.Name == "":
+		return WishlistItem{}, fmt.Errorf("%w: wanted magazines need a name", ErrInvalidInput)
+	case wi.ItemType == MagazineItem && wi.Author != "":
+		return WishlistItem{}, fmt.Errorf("%w: wanted magazines cannot have an author", ErrInvalidInput)
+	case wi.ItemType != BookItem && wi.ItemType != MagazineItem:
+		return WishlistItem{}, fmt.Errorf("%w: unknown item type %q", ErrInvalidInput, wi.ItemType)
+	}
+	if err := checkMember(ws.DB, wi.UserID); err != nil {
+		return WishlistItem{}, err
+	}
+	wi.ID = idsOf(ws.DB).NewID()
+	if r := ws.DB.Create(&wi); r.Error != nil {
+		return WishlistItem{}, r.Error
+	}
+
+	return wi, nil
+}
+
+// ListByUser returns the wishlist of a given user.
+func (ws *WishlistService) ListByUser(userID string) ([]WishlistItem, error) {
+	var items []WishlistItem
+	if !isValidID(userID) {
+		return items, nil
+	}
+	if r := ws.DB.Where("user_id = ?", userID).Order("created_at").Find(&items); r.Error != nil {
+		return nil, r.Error
+	}
+
+	return items, nil
+}
+
+// Remove removes an item from a user's wishlist.
+func (ws *WishlistService) Remove(id, userID string) error {
+	if !isValidID(id) {
+		return fmt.Errorf("no wishlist item found for id %s:%w", id, gorm.ErrRecordNotFound)
+	}
+	var wi WishlistItem
+	if r := ws.DB.Where("id = ?", id).First(&wi); r.Error != nil {
+		return fmt.Errorf("no wishlist item found for id %s:%w", id, r.Error)
+	}
+	if wi.UserID != userID {
+		return fmt.Errorf("wishlist item %s:%w %s", id, ErrNotOwner, userID)
+	}
+
+	return ws.DB.Delete(&wi).Error
+}
+
+// notifyWishlists notifies every user whose wishlist matches a newly available item.
+// Owners are not notified about their own items, nor are the users of other communities.
+func notifyWishlists(tx *gorm.DB, itemType ItemType, itemID, ownerID, name, author string) error {
+	var matches []WishlistItem
+	q := tx.Where("item_type = ? AND user_id <> ?", itemType, ownerID).
+		Where("user_id IN (SELECT id FROM users WHERE community_id = (SELECT community_id FROM users WHERE id = ?))",
+			ownerID).
+		Where("name = '' OR LOWER(name) = LOWER(?)", strings.TrimSpace(name))
+	if itemType == BookItem {
+		q = q.Where("author = '' OR LOWER(author) = LOWER(?)", strings.TrimSpace(author))
+	}
+	if r := q.Find(&matches); r.Error != nil {
+		return r.Error
+	}
+	for _, wi := range matches {
+		n := Notification{
+			UserID:         wi.UserID,
+			Type:           WishlistMatch,
+			ItemID:         itemID,
+			ItemType:       itemType,
+			ItemName:       name,
+			WishlistItemID: wi.ID,
+			Message:        fmt.Sprintf("%q from your wishlist is now available", name),
+		}
+		if err := createNotification(tx, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
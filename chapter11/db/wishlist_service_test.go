@@ -0,0 +1,133 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddWishlistItemValidation(t *testing.T) {
+	ws := db.NewWishlistService(nil, nil, nil)
+	tests := map[string]db.WishlistItem{
+		"book without name or author": {ItemType: db.BookItem, Name: " ", Author: ""},
+		"magazine without name":       {ItemType: db.MagazineItem, Name: ""},
+		"magazine with author":        {ItemType: db.MagazineItem, Name: "Wired", Author: "Someone"},
+		"unknown item type":           {ItemType: "VINYL", Name: "Abbey Road"},
+		"missing item type":           {Name: "Dune"},
+	}
+	for name, wi := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ws.Add(wi)
+			assert.ErrorIs(t, err, db.ErrInvalidInput)
+		})
+	}
+}
+
+func TestWishlist(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	user := db.CreateTestUser(t, testDB)
+	ws := db.NewWishlistService(testDB, nil, nil)
+
+	wi, err := ws.Add(db.WishlistItem{
+		UserID:   user.ID,
+		ItemType: db.BookItem,
+		Name:     "  Dune ",
+	})
+	require.Nil(t, err)
+	assert.NotEmpty(t, wi.ID)
+	assert.Equal(t, "Dune", wi.Name)
+
+	t.Run("list", func(t *testing.T) {
+		items, err := ws.ListByUser(user.ID)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(items))
+		assert.Equal(t, wi.ID, items[0].ID)
+	})
+
+	t.Run("remove by another user", func(t *testing.T) {
+		err := ws.Remove(wi.ID, uuid.New().String())
+		assert.ErrorIs(t, err, db.ErrNotOwner)
+	})
+
+	t.Run("remove unknown item", func(t *testing.T) {
+		err := ws.Remove(uuid.New().String(), user.ID)
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "no wishlist item found")
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		require.Nil(t, ws.Remove(wi.ID, user.ID))
+		items, err := ws.ListByUser(user.ID)
+		require.Nil(t, err)
+		assert.Empty(t, items)
+	})
+}
+
+func TestWishlistMatching(t *testing.T) {
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	owner := db.CreateTestUser(t, testDB)
+	byName := db.CreateTestUser(t, testDB)
+	byAuthor := db.CreateTestUser(t, testDB)
+	otherAuthor := db.CreateTestUser(t, testDB)
+	magReader := db.CreateTestUser(t, testDB)
+	ws := db.NewWishlistService(testDB, nil, nil)
+	ns := db.NewNotificationService(testDB, nil, nil)
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	wishes := []db.WishlistItem{
+		{UserID: byName.ID, ItemType: db.BookItem, Name: "The Hobbit"},
+		{UserID: byAuthor.ID, ItemType: db.BookItem, Author: "J. R. R. Tolkien"},
+		{UserID: otherAuthor.ID, ItemType: db.BookItem, Name: "The Hobbit", Author: "Someone Else"},
+		{UserID: owner.ID, ItemType: db.BookItem, Name: "The Hobbit"},
+		{UserID: magReader.ID, ItemType: db.MagazineItem, Name: "National Geographic"},
+	}
+	for _, wi := range wishes {
+		_, err := ws.Add(wi)
+		require.Nil(t, err)
+	}
+
+	eb, err := bs.Upsert(db.Book{
+		Name:    "the hobbit",
+		Author:  "J. R. R. Tolkien",
+		OwnerID: owner.ID,
+	})
+	require.Nil(t, err)
+	// Updating an existing book does not notify anyone again.
+	_, err = bs.Upsert(eb)
+	require.Nil(t, err)
+	em, err := ms.Upsert(db.Magazine{
+		Name:    "National Geographic",
+		OwnerID: owner.ID,
+	})
+	require.Nil(t, err)
+
+	tests := map[string]struct {
+		userID     string
+		wantItemID string
+	}{
+		"matched by name":       {userID: byName.ID, wantItemID: eb.ID},
+		"matched by author":     {userID: byAuthor.ID, wantItemID: eb.ID},
+		"different author":      {userID: otherAuthor.ID},
+		"owner":                 {userID: owner.ID},
+		"matched magazine name": {userID: magReader.ID, wantItemID: em.ID},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			notifications, err := ns.ListByUser(tc.userID)
+			require.Nil(t, err)
+			if tc.wantItemID == "" {
+				assert.Empty(t, notifications)
+				return
+			}
+			require.Equal(t, 1, len(notifications))
+			assert.Equal(t, db.WishlistMatch, notifications[0].Type)
+			assert.Equal(t, tc.wantItemID, notifications[0].ItemID)
+			assert.NotEmpty(t, notifications[0].WishlistItemID)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Package events fans item events out to subscribers in the same process, such as Server-Sent Events streams.
+package events
+
+import (
+	"sync"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// DefaultBuffer is the number of events a subscriber can fall behind by before it is dropped.
+const DefaultBuffer = 64
+
+// Filter selects the events a subscriber is interested in. Empty fields match every event.
+type Filter struct {
+	Types    []db.ItemEventType
+	ItemType db.ItemType
+	OwnerID  string
+}
+
+// Match returns whether an event passes the filter.
+// Events match an owner both when they give an item to them and when they take one from them.
+func (f Filter) Match(e db.ItemEvent) bool {
+	if f.ItemType != "" && f.ItemType != e.ItemType {
+		return false
+	}
+	if f.OwnerID != "" && f.OwnerID != e.OwnerID && f.OwnerID != e.PreviousOwnerID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Broker is an in-process publish/subscribe hub for item events.
+// Publishing never blocks: subscribers which fall too far behind are dropped
+// and are expected to resume from the ledger.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	buffer int
+}
+
+// NewBroker initialises a Broker, giving each subscriber a buffer of the given size.
+func NewBroker(buffer int) *Broker {
+	return &Broker{
+		subs:   make(map[*Subscription]struct{}),
+		buffer: buffer,
+	}
+}
+
+// Subscription receives the published events which match its filter.
+type Subscription struct {
+	b      *Broker
+	filter Filter
+	ch     chan db.ItemEvent
+	lagged bool
+}
+
+// Subscribe registers a new subscriber for the events matching the filter.
+// The subscription must be closed once it is no longer needed.
+func (b *Broker) Subscribe(f Filter) *Subscription {
+	s := &Subscription{
+		b:      b,
+		filter: f,
+		ch:     make(chan db.ItemEvent, b.buffer),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Publish sends an event to every subscriber interested in it.
+func (b *Broker) Publish(e db.ItemEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			// The subscriber is too slow to keep up, so it is dropped rather than holding up everyone else.
+			s.lagged = true
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// remove unregisters a subscription and closes its channel. The caller must hold the lock.
+func (b *Broker) remove(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.ch)
+}
+
+// Events returns the channel events are received on.
+// It is closed when the subscription is closed or dropped.
+func (s *Subscription) Events() <-chan db.ItemEvent {
+	return s.ch
+}
+
+// Lagged returns whether the subscription was dropped for falling behind.
+func (s *Subscription) Lagged() bool {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	return s.lagged
+}
+
+// Close unsubscribes from the broker. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.remove(s)
+}
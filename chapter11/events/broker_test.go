@@ -0,0 +1,113 @@
+package events_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterMatch(t *testing.T) {
+	e := db.ItemEvent{
+		ID:              1,
+		ItemType:        db.BookItem,
+		Type:            db.ItemSwapped,
+		OwnerID:         "new-owner",
+		PreviousOwnerID: "previous-owner",
+	}
+	tests := map[string]struct {
+		filter events.Filter
+		want   bool
+	}{
+		"empty filter":        {filter: events.Filter{}, want: true},
+		"matching type":       {filter: events.Filter{Types: []db.ItemEventType{db.ItemCreated, db.ItemSwapped}}, want: true},
+		"other type":          {filter: events.Filter{Types: []db.ItemEventType{db.ItemCreated}}},
+		"matching item type":  {filter: events.Filter{ItemType: db.BookItem}, want: true},
+		"other item type":     {filter: events.Filter{ItemType: db.MagazineItem}},
+		"owner":               {filter: events.Filter{OwnerID: "new-owner"}, want: true},
+		"previous owner":      {filter: events.Filter{OwnerID: "previous-owner"}, want: true},
+		"other owner":         {filter: events.Filter{OwnerID: "someone-else"}},
+		"all fields matching": {filter: events.Filter{Types: []db.ItemEventType{db.ItemSwapped}, ItemType: db.BookItem, OwnerID: "new-owner"}, want: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.filter.Match(e))
+		})
+	}
+}
+
+func TestBroker(t *testing.T) {
+	created := db.ItemEvent{ID: 1, ItemType: db.BookItem, Type: db.ItemCreated, OwnerID: "owner"}
+	swapped := db.ItemEvent{ID: 2, ItemType: db.BookItem, Type: db.ItemSwapped, OwnerID: "new-owner", PreviousOwnerID: "owner"}
+
+	t.Run("filtered fan out", func(t *testing.T) {
+		b := events.NewBroker(events.DefaultBuffer)
+		all := b.Subscribe(events.Filter{})
+		defer all.Close()
+		swaps := b.Subscribe(events.Filter{Types: []db.ItemEventType{db.ItemSwapped}})
+		defer swaps.Close()
+
+		b.Publish(created)
+		b.Publish(swapped)
+
+		assert.Equal(t, created, <-all.Events())
+		assert.Equal(t, swapped, <-all.Events())
+		assert.Equal(t, swapped, <-swaps.Events())
+		assert.Empty(t, swaps.Events())
+	})
+
+	t.Run("slow subscriber", func(t *testing.T) {
+		b := events.NewBroker(1)
+		slow := b.Subscribe(events.Filter{})
+		defer slow.Close()
+		fast := b.Subscribe(events.Filter{})
+		defer fast.Close()
+
+		b.Publish(created)
+		assert.Equal(t, created, <-fast.Events())
+		// The slow subscriber has not read its first event, so it is dropped
+		// instead of blocking the publisher and the other subscribers.
+		b.Publish(swapped)
+		assert.Equal(t, swapped, <-fast.Events())
+		assert.True(t, slow.Lagged())
+		assert.False(t, fast.Lagged())
+		assert.Equal(t, 1, b.Subscribers())
+
+		// Events buffered before it was dropped can still be read.
+		e, ok := <-slow.Events()
+		require.True(t, ok)
+		assert.Equal(t, created, e)
+		_, ok = <-slow.Events()
+		assert.False(t, ok)
+	})
+
+	t.Run("closed subscriber", func(t *testing.T) {
+		b := events.NewBroker(events.DefaultBuffer)
+		s := b.Subscribe(events.Filter{})
+		s.Close()
+		s.Close()
+
+		b.Publish(created)
+		_, ok := <-s.Events()
+		assert.False(t, ok)
+		assert.False(t, s.Lagged())
+		assert.Equal(t, 0, b.Subscribers())
+	})
+}
+
+func TestWriteSSE(t *testing.T) {
+	var buf bytes.Buffer
+	e := db.ItemEvent{ID: 42, ItemID: "item-id", ItemType: db.BookItem, Type: db.ItemPostingFailed}
+
+	require.Nil(t, events.WriteSSE(&buf, e))
+	lines := bytes.Split(buf.Bytes(), []byte("\n"))
+	require.Equal(t, 5, len(lines))
+	assert.Equal(t, "id: 42", string(lines[0]))
+	assert.Equal(t, "event: item-posting-failed", string(lines[1]))
+	assert.Contains(t, string(lines[2]), `"item_id":"item-id"`)
+	assert.Empty(t, lines[3])
+	assert.Empty(t, lines[4])
+}
@@ -0,0 +1,26 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// Name returns the name an event is streamed under, such as item-created.
+func Name(t db.ItemEventType) string {
+	return "item-" + strings.ReplaceAll(strings.ToLower(string(t)), "_", "-")
+}
+
+// WriteSSE writes an event in the Server-Sent Events format.
+// Its ledger ID is used as the event ID, so that clients can resume from it.
+func WriteSSE(w io.Writer, e db.ItemEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, Name(e.Type), data)
+	return err
+}
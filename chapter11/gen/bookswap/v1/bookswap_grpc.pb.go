@@ -0,0 +1,511 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: bookswap/v1/bookswap.proto
+
+package bookswapv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	BookSwapService_GetBook_FullMethodName        = "/bookswap.v1.BookSwapService/GetBook"
+	BookSwapService_ListBooks_FullMethodName      = "/bookswap.v1.BookSwapService/ListBooks"
+	BookSwapService_UpsertBook_FullMethodName     = "/bookswap.v1.BookSwapService/UpsertBook"
+	BookSwapService_SwapBook_FullMethodName       = "/bookswap.v1.BookSwapService/SwapBook"
+	BookSwapService_GetMagazine_FullMethodName    = "/bookswap.v1.BookSwapService/GetMagazine"
+	BookSwapService_ListMagazines_FullMethodName  = "/bookswap.v1.BookSwapService/ListMagazines"
+	BookSwapService_UpsertMagazine_FullMethodName = "/bookswap.v1.BookSwapService/UpsertMagazine"
+	BookSwapService_SwapMagazine_FullMethodName   = "/bookswap.v1.BookSwapService/SwapMagazine"
+	BookSwapService_GetUser_FullMethodName        = "/bookswap.v1.BookSwapService/GetUser"
+	BookSwapService_UpsertUser_FullMethodName     = "/bookswap.v1.BookSwapService/UpsertUser"
+	BookSwapService_WatchEvents_FullMethodName    = "/bookswap.v1.BookSwapService/WatchEvents"
+)
+
+// BookSwapServiceClient is the client API for BookSwapService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BookSwapServiceClient interface {
+	GetBook(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (*GetBookResponse, error)
+	ListBooks(ctx context.Context, in *ListBooksRequest, opts ...grpc.CallOption) (*ListBooksResponse, error)
+	UpsertBook(ctx context.Context, in *UpsertBookRequest, opts ...grpc.CallOption) (*UpsertBookResponse, error)
+	SwapBook(ctx context.Context, in *SwapBookRequest, opts ...grpc.CallOption) (*SwapBookResponse, error)
+	GetMagazine(ctx context.Context, in *GetMagazineRequest, opts ...grpc.CallOption) (*GetMagazineResponse, error)
+	ListMagazines(ctx context.Context, in *ListMagazinesRequest, opts ...grpc.CallOption) (*ListMagazinesResponse, error)
+	UpsertMagazine(ctx context.Context, in *UpsertMagazineRequest, opts ...grpc.CallOption) (*UpsertMagazineResponse, error)
+	SwapMagazine(ctx context.Context, in *SwapMagazineRequest, opts ...grpc.CallOption) (*SwapMagazineResponse, error)
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*GetUserResponse, error)
+	UpsertUser(ctx context.Context, in *UpsertUserRequest, opts ...grpc.CallOption) (*UpsertUserResponse, error)
+	// WatchEvents streams item events as they are recorded, until the client cancels.
+	// The stream ends with ABORTED if the client falls too far behind.
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (BookSwapService_WatchEventsClient, error)
+}
+
+type bookSwapServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBookSwapServiceClient(cc grpc.ClientConnInterface) BookSwapServiceClient {
+	return &bookSwapServiceClient{cc}
+}
+
+func (c *bookSwapServiceClient) GetBook(ctx context.Context, in *GetBookRequest, opts ...grpc.CallOption) (*GetBookResponse, error) {
+	out := new(GetBookResponse)
+	err := c.cc.Invoke(ctx, BookSwapService_GetBook_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookSwapServiceClient) ListBooks(ctx context.Context, in *ListBooksRequest, opts ...grpc.CallOption) (*ListBooksResponse, error) {
+	out := new(ListBooksResponse)
+	err := c.cc.Invoke(ctx, BookSwapService_ListBooks_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookSwapServiceClient) UpsertBook(ctx context.Context, in *UpsertBookRequest, opts ...grpc.CallOption) (*UpsertBookResponse, error) {
+	out := new(UpsertBookResponse)
+	err := c.cc.Invoke(ctx, BookSwapService_UpsertBook_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookSwapServiceClient) SwapBook(ctx context.Context, in *SwapBookRequest, opts ...grpc.CallOption) (*SwapBookResponse, error) {
+	out := new(SwapBookResponse)
+	err := c.cc.Invoke(ctx, BookSwapService_SwapBook_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookSwapServiceClient) GetMagazine(ctx context.Context, in *GetMagazineRequest, opts ...grpc.CallOption) (*GetMagazineResponse, error) {
+	out := new(GetMagazineResponse)
+	err := c.cc.Invoke(ctx, BookSwapService_GetMagazine_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookSwapServiceClient) ListMagazines(ctx context.Context, in *ListMagazinesRequest, opts ...grpc.CallOption) (*ListMagazinesResponse, error) {
+	out := new(ListMagazinesResponse)
+	err := c.cc.Invoke(ctx, BookSwapService_ListMagazines_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookSwapServiceClient) UpsertMagazine(ctx context.Context, in *UpsertMagazineRequest, opts ...grpc.CallOption) (*UpsertMagazineResponse, error) {
+	out := new(UpsertMagazineResponse)
+	err := c.cc.Invoke(ctx, BookSwapService_UpsertMagazine_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookSwapServiceClient) SwapMagazine(ctx context.Context, in *SwapMagazineRequest, opts ...grpc.CallOption) (*SwapMagazineResponse, error) {
+	out := new(SwapMagazineResponse)
+	err := c.cc.Invoke(ctx, BookSwapService_SwapMagazine_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookSwapServiceClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*GetUserResponse, error) {
+	out := new(GetUserResponse)
+	err := c.cc.Invoke(ctx, BookSwapService_GetUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookSwapServiceClient) UpsertUser(ctx context.Context, in *UpsertUserRequest, opts ...grpc.CallOption) (*UpsertUserResponse, error) {
+	out := new(UpsertUserResponse)
+	err := c.cc.Invoke(ctx, BookSwapService_UpsertUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bookSwapServiceClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (BookSwapService_WatchEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &BookSwapService_ServiceDesc.Streams[0], BookSwapService_WatchEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &bookSwapServiceWatchEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type BookSwapService_WatchEventsClient interface {
+	Recv() (*WatchEventsResponse, error)
+	grpc.ClientStream
+}
+
+type bookSwapServiceWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *bookSwapServiceWatchEventsClient) Recv() (*WatchEventsResponse, error) {
+	m := new(WatchEventsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// BookSwapServiceServer is the server API for BookSwapService service.
+// All implementations must embed UnimplementedBookSwapServiceServer
+// for forward compatibility
+type BookSwapServiceServer interface {
+	GetBook(context.Context, *GetBookRequest) (*GetBookResponse, error)
+	ListBooks(context.Context, *ListBooksRequest) (*ListBooksResponse, error)
+	UpsertBook(context.Context, *UpsertBookRequest) (*UpsertBookResponse, error)
+	SwapBook(context.Context, *SwapBookRequest) (*SwapBookResponse, error)
+	GetMagazine(context.Context, *GetMagazineRequest) (*GetMagazineResponse, error)
+	ListMagazines(context.Context, *ListMagazinesRequest) (*ListMagazinesResponse, error)
+	UpsertMagazine(context.Context, *UpsertMagazineRequest) (*UpsertMagazineResponse, error)
+	SwapMagazine(context.Context, *SwapMagazineRequest) (*SwapMagazineResponse, error)
+	GetUser(context.Context, *GetUserRequest) (*GetUserResponse, error)
+	UpsertUser(context.Context, *UpsertUserRequest) (*UpsertUserResponse, error)
+	// WatchEvents streams item events as they are recorded, until the client cancels.
+	// The stream ends with ABORTED if the client falls too far behind.
+	WatchEvents(*WatchEventsRequest, BookSwapService_WatchEventsServer) error
+	mustEmbedUnimplementedBookSwapServiceServer()
+}
+
+// UnimplementedBookSwapServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedBookSwapServiceServer struct {
+}
+
+func (UnimplementedBookSwapServiceServer) GetBook(context.Context, *GetBookRequest) (*GetBookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBook not implemented")
+}
+func (UnimplementedBookSwapServiceServer) ListBooks(context.Context, *ListBooksRequest) (*ListBooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBooks not implemented")
+}
+func (UnimplementedBookSwapServiceServer) UpsertBook(context.Context, *UpsertBookRequest) (*UpsertBookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpsertBook not implemented")
+}
+func (UnimplementedBookSwapServiceServer) SwapBook(context.Context, *SwapBookRequest) (*SwapBookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SwapBook not implemented")
+}
+func (UnimplementedBookSwapServiceServer) GetMagazine(context.Context, *GetMagazineRequest) (*GetMagazineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMagazine not implemented")
+}
+func (UnimplementedBookSwapServiceServer) ListMagazines(context.Context, *ListMagazinesRequest) (*ListMagazinesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMagazines not implemented")
+}
+func (UnimplementedBookSwapServiceServer) UpsertMagazine(context.Context, *UpsertMagazineRequest) (*UpsertMagazineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpsertMagazine not implemented")
+}
+func (UnimplementedBookSwapServiceServer) SwapMagazine(context.Context, *SwapMagazineRequest) (*SwapMagazineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SwapMagazine not implemented")
+}
+func (UnimplementedBookSwapServiceServer) GetUser(context.Context, *GetUserRequest) (*GetUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
+}
+func (UnimplementedBookSwapServiceServer) UpsertUser(context.Context, *UpsertUserRequest) (*UpsertUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpsertUser not implemented")
+}
+func (UnimplementedBookSwapServiceServer) WatchEvents(*WatchEventsRequest, BookSwapService_WatchEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedBookSwapServiceServer) mustEmbedUnimplementedBookSwapServiceServer() {}
+
+// UnsafeBookSwapServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BookSwapServiceServer will
+// result in compilation errors.
+type UnsafeBookSwapServiceServer interface {
+	mustEmbedUnimplementedBookSwapServiceServer()
+}
+
+func RegisterBookSwapServiceServer(s grpc.ServiceRegistrar, srv BookSwapServiceServer) {
+	s.RegisterService(&BookSwapService_ServiceDesc, srv)
+}
+
+func _BookSwapService_GetBook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookSwapServiceServer).GetBook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookSwapService_GetBook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookSwapServiceServer).GetBook(ctx, req.(*GetBookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookSwapService_ListBooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookSwapServiceServer).ListBooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookSwapService_ListBooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookSwapServiceServer).ListBooks(ctx, req.(*ListBooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookSwapService_UpsertBook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpsertBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookSwapServiceServer).UpsertBook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookSwapService_UpsertBook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookSwapServiceServer).UpsertBook(ctx, req.(*UpsertBookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookSwapService_SwapBook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SwapBookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookSwapServiceServer).SwapBook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookSwapService_SwapBook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookSwapServiceServer).SwapBook(ctx, req.(*SwapBookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookSwapService_GetMagazine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMagazineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookSwapServiceServer).GetMagazine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookSwapService_GetMagazine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookSwapServiceServer).GetMagazine(ctx, req.(*GetMagazineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookSwapService_ListMagazines_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMagazinesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookSwapServiceServer).ListMagazines(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookSwapService_ListMagazines_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookSwapServiceServer).ListMagazines(ctx, req.(*ListMagazinesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookSwapService_UpsertMagazine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpsertMagazineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookSwapServiceServer).UpsertMagazine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookSwapService_UpsertMagazine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookSwapServiceServer).UpsertMagazine(ctx, req.(*UpsertMagazineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookSwapService_SwapMagazine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SwapMagazineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookSwapServiceServer).SwapMagazine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookSwapService_SwapMagazine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookSwapServiceServer).SwapMagazine(ctx, req.(*SwapMagazineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookSwapService_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookSwapServiceServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookSwapService_GetUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookSwapServiceServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookSwapService_UpsertUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpsertUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BookSwapServiceServer).UpsertUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BookSwapService_UpsertUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BookSwapServiceServer).UpsertUser(ctx, req.(*UpsertUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BookSwapService_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BookSwapServiceServer).WatchEvents(m, &bookSwapServiceWatchEventsServer{stream})
+}
+
+type BookSwapService_WatchEventsServer interface {
+	Send(*WatchEventsResponse) error
+	grpc.ServerStream
+}
+
+type bookSwapServiceWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *bookSwapServiceWatchEventsServer) Send(m *WatchEventsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// BookSwapService_ServiceDesc is the grpc.ServiceDesc for BookSwapService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BookSwapService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bookswap.v1.BookSwapService",
+	HandlerType: (*BookSwapServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBook",
+			Handler:    _BookSwapService_GetBook_Handler,
+		},
+		{
+			MethodName: "ListBooks",
+			Handler:    _BookSwapService_ListBooks_Handler,
+		},
+		{
+			MethodName: "UpsertBook",
+			Handler:    _BookSwapService_UpsertBook_Handler,
+		},
+		{
+			MethodName: "SwapBook",
+			Handler:    _BookSwapService_SwapBook_Handler,
+		},
+		{
+			MethodName: "GetMagazine",
+			Handler:    _BookSwapService_GetMagazine_Handler,
+		},
+		{
+			MethodName: "ListMagazines",
+			Handler:    _BookSwapService_ListMagazines_Handler,
+		},
+		{
+			MethodName: "UpsertMagazine",
+			Handler:    _BookSwapService_UpsertMagazine_Handler,
+		},
+		{
+			MethodName: "SwapMagazine",
+			Handler:    _BookSwapService_SwapMagazine_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _BookSwapService_GetUser_Handler,
+		},
+		{
+			MethodName: "UpsertUser",
+			Handler:    _BookSwapService_UpsertUser_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _BookSwapService_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "bookswap/v1/bookswap.proto",
+}
@@ -0,0 +1,23 @@
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// NewHandler initialises the handler of the GraphQL endpoint, given the services it resolves with.
+func NewHandler(bs BookService, us UserService, ms MagazineService) http.Handler {
+	s := graphqlgo.MustParseSchema(schema, &resolver{bs: bs, us: us, ms: ms})
+	h := &relay.Handler{Schema: s}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), loadersKey{}, newLoaders(bs, us, ms))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
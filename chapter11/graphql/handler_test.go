@@ -0,0 +1,178 @@
+package graphql_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/graphql"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// execute posts a query to the GraphQL handler and decodes its response.
+func execute(t *testing.T, h http.Handler, query string, variables map[string]any) gqlResponse {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	require.Nil(t, err)
+	req, err := http.NewRequest("POST", "/graphql", bytes.NewReader(body))
+	require.Nil(t, err)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp gqlResponse
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestUsersQuery(t *testing.T) {
+	users := []db.User{{ID: "u1", Name: "Ann"}, {ID: "u2", Name: "Bob"}, {ID: "u3", Name: "Cat"}}
+	bs := mocks.NewBookService(t)
+	us := mocks.NewUserService(t)
+	ms := mocks.NewMagazineService(t)
+	// Every user's lists are loaded in one batch, rather than one query per user.
+	us.On("ListByIDs", mock.MatchedBy(func(ids []string) bool {
+		return assert.ElementsMatch(t, []string{"u1", "u2", "u3", "unknown"}, ids)
+	})).Return(users, nil).Once()
+	bs.On("ListByUsers", mock.MatchedBy(func(ids []string) bool {
+		return assert.ElementsMatch(t, []string{"u1", "u2", "u3"}, ids)
+	})).Return([]db.Book{
+		{ID: "b1", Name: "Dune", OwnerID: "u1"},
+		{ID: "b2", Name: "Emma", OwnerID: "u1"},
+		{ID: "b3", Name: "Ulysses", OwnerID: "u3", Status: db.InTransit},
+	}, nil).Once()
+	ms.On("ListByUsers", mock.AnythingOfType("[]string")).Return([]db.Magazine{
+		{ID: "m1", Name: "Wired", OwnerID: "u2"},
+	}, nil).Once()
+	h := graphql.NewHandler(bs, us, ms)
+
+	resp := execute(t, h, `query($ids: [ID!]!) {
+		users(ids: $ids) { id name books { id status } magazines { name } }
+	}`, map[string]any{"ids": []string{"u1", "u2", "u3", "unknown"}})
+
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"users": [
+		{"id": "u1", "name": "Ann", "books": [{"id": "b1", "status": "AVAILABLE"}, {"id": "b2", "status": "AVAILABLE"}], "magazines": []},
+		{"id": "u2", "name": "Bob", "books": [], "magazines": [{"name": "Wired"}]},
+		{"id": "u3", "name": "Cat", "books": [{"id": "b3", "status": "IN_TRANSIT"}], "magazines": []}
+	]}`, string(resp.Data))
+}
+
+func TestBooksQuery(t *testing.T) {
+	bs := mocks.NewBookService(t)
+	us := mocks.NewUserService(t)
+	bs.On("List").Return([]db.Book{
+		{ID: "b1", Name: "Dune", OwnerID: "u1"},
+		{ID: "b2", Name: "Emma", OwnerID: "u2"},
+		{ID: "b3", Name: "Ulysses", OwnerID: "u1"},
+	}, nil).Once()
+	us.On("ListByIDs", mock.MatchedBy(func(ids []string) bool {
+		return assert.ElementsMatch(t, []string{"u1", "u2"}, ids)
+	})).Return([]db.User{{ID: "u1", Name: "Ann"}, {ID: "u2", Name: "Bob"}}, nil).Once()
+	h := graphql.NewHandler(bs, us, nil)
+
+	resp := execute(t, h, `{ books { id owner { name } } }`, nil)
+
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"books": [
+		{"id": "b1", "owner": {"name": "Ann"}},
+		{"id": "b2", "owner": {"name": "Bob"}},
+		{"id": "b3", "owner": {"name": "Ann"}}
+	]}`, string(resp.Data))
+}
+
+func TestBookDetailsQuery(t *testing.T) {
+	bs := mocks.NewBookService(t)
+	bs.On("List").Return([]db.Book{
+		{ID: "b1", Name: "Dune", Edition: "First", Condition: db.ConditionLikeNew, Language: "en",
+			Tags: db.Tags{"classic", "sci-fi"}, Images: db.ItemImages{{ID: "i1", ContentType: "image/png",
+				Width: 40, Height: 20, URL: "/images/i1.png", ThumbnailURL: "/images/i1_thumb.png"}}},
+		{ID: "b2", Name: "Emma"},
+	}, nil).Once()
+	h := graphql.NewHandler(bs, nil, nil)
+
+	resp := execute(t, h, `{ books { id edition condition language tags images { url thumbnailUrl width } } }`, nil)
+
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"books": [
+		{"id": "b1", "edition": "First", "condition": "LIKE_NEW", "language": "en", "tags": ["classic", "sci-fi"],
+			"images": [{"url": "/images/i1.png", "thumbnailUrl": "/images/i1_thumb.png", "width": 40}]},
+		{"id": "b2", "edition": null, "condition": null, "language": null, "tags": [], "images": []}
+	]}`, string(resp.Data))
+}
+
+func TestUserQuery(t *testing.T) {
+	tests := map[string]struct {
+		users    []db.User
+		err      error
+		wantData string
+		wantErr  bool
+	}{
+		"found":     {users: []db.User{{ID: "u1", Name: "Ann"}}, wantData: `{"user": {"name": "Ann"}}`},
+		"not found": {users: []db.User{}, wantData: `{"user": null}`},
+		"error":     {err: errors.New("db error"), wantData: `{"user": null}`, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			us := mocks.NewUserService(t)
+			us.On("ListByIDs", []string{"u1"}).Return(tc.users, tc.err).Once()
+			h := graphql.NewHandler(nil, us, nil)
+
+			resp := execute(t, h, `{ user(id: "u1") { name } }`, nil)
+
+			assert.Equal(t, tc.wantErr, len(resp.Errors) > 0)
+			assert.JSONEq(t, tc.wantData, string(resp.Data))
+		})
+	}
+}
+
+func TestSwapMutations(t *testing.T) {
+	swapErr := errors.New("book b1 is not available for swapping")
+	tests := map[string]struct {
+		book     *db.Book
+		err      error
+		wantData string
+	}{
+		"swapped":       {book: &db.Book{ID: "b1", OwnerID: "u2", Status: db.InTransit}, wantData: `{"swapBook": {"id": "b1", "status": "IN_TRANSIT"}}`},
+		"not available": {err: swapErr, wantData: `null`},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			bs := mocks.NewBookService(t)
+			bs.On("SwapBook", "b1", "u2").Return(tc.book, tc.err).Once()
+			h := graphql.NewHandler(bs, nil, nil)
+
+			resp := execute(t, h, `mutation { swapBook(id: "b1", userId: "u2") { id status } }`, nil)
+
+			assert.JSONEq(t, tc.wantData, string(resp.Data))
+			if tc.err != nil {
+				require.Equal(t, 1, len(resp.Errors))
+				assert.Equal(t, swapErr.Error(), resp.Errors[0].Message)
+			}
+		})
+	}
+
+	t.Run("magazine", func(t *testing.T) {
+		ms := mocks.NewMagazineService(t)
+		ms.On("SwapMagazine", "m1", "u2").Return(&db.Magazine{ID: "m1", Status: db.InTransit}, nil).Once()
+		h := graphql.NewHandler(nil, nil, ms)
+
+		resp := execute(t, h, `mutation { swapMagazine(id: "m1", userId: "u2") { id status } }`, nil)
+
+		require.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"swapMagazine": {"id": "m1", "status": "IN_TRANSIT"}}`, string(resp.Data))
+	})
+}
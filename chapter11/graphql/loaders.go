@@ -0,0 +1,70 @@
+package graphql
+
+import (
+	"context"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/graph-gophers/dataloader/v7"
+)
+
+// loaderWait is how long loaders wait for more keys before running a batch.
+const loaderWait = 2 * time.Millisecond
+
+type loadersKey struct{}
+
+// loaders batch the lookups made while resolving a single request, so that
+// resolving a field for every element of a list takes one query rather than one per element.
+type loaders struct {
+	users            *dataloader.Loader[string, *db.User]
+	booksByOwner     *dataloader.Loader[string, []db.Book]
+	magazinesByOwner *dataloader.Loader[string, []db.Magazine]
+}
+
+func newLoaders(bs BookService, us UserService, ms MagazineService) *loaders {
+	return &loaders{
+		users: dataloader.NewBatchedLoader(func(ctx context.Context, ids []string) []*dataloader.Result[*db.User] {
+			users, err := us.ListByIDs(ids)
+			byID := make(map[string]*db.User, len(users))
+			for i := range users {
+				byID[users[i].ID] = &users[i]
+			}
+			return results(ids, err, func(id string) *db.User { return byID[id] })
+		}, dataloader.WithWait[string, *db.User](loaderWait)),
+		booksByOwner: dataloader.NewBatchedLoader(func(ctx context.Context, ids []string) []*dataloader.Result[[]db.Book] {
+			books, err := bs.ListByUsers(ids)
+			byOwner := make(map[string][]db.Book, len(ids))
+			for _, b := range books {
+				byOwner[b.OwnerID] = append(byOwner[b.OwnerID], b)
+			}
+			return results(ids, err, func(id string) []db.Book { return byOwner[id] })
+		}, dataloader.WithWait[string, []db.Book](loaderWait)),
+		magazinesByOwner: dataloader.NewBatchedLoader(func(ctx context.Context, ids []string) []*dataloader.Result[[]db.Magazine] {
+			mags, err := ms.ListByUsers(ids)
+			byOwner := make(map[string][]db.Magazine, len(ids))
+			for _, m := range mags {
+				byOwner[m.OwnerID] = append(byOwner[m.OwnerID], m)
+			}
+			return results(ids, err, func(id string) []db.Magazine { return byOwner[id] })
+		}, dataloader.WithWait[string, []db.Magazine](loaderWait)),
+	}
+}
+
+// results builds the result of a batch, in the same order as its keys.
+// An error fails every key in the batch.
+func results[V any](keys []string, err error, get func(key string) V) []*dataloader.Result[V] {
+	rs := make([]*dataloader.Result[V], len(keys))
+	for i, k := range keys {
+		if err != nil {
+			rs[i] = &dataloader.Result[V]{Error: err}
+			continue
+		}
+		rs[i] = &dataloader.Result[V]{Data: get(k)}
+	}
+	return rs
+}
+
+// loadersFrom returns the loaders of the request a context belongs to.
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,234 @@
+// Package graphql exposes users, books and magazines through a GraphQL API, on top of the db services.
+package graphql
+
+import (
+	"context"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	graphqlgo "github.com/graph-gophers/graphql-go"
+)
+
+// BookService is the book functionality the API depends on.
+type BookService interface {
+	Get(id string) (*db.Book, error)
+	List() ([]db.Book, error)
+	ListByUsers(userIDs []string) ([]db.Book, error)
+	SwapBook(bookID, userID string) (*db.Book, error)
+}
+
+// MagazineService is the magazine functionality the API depends on.
+type MagazineService interface {
+	Get(id string) (*db.Magazine, error)
+	List() ([]db.Magazine, error)
+	ListByUsers(userIDs []string) ([]db.Magazine, error)
+	SwapMagazine(magID, userID string) (*db.Magazine, error)
+}
+
+// UserService is the user functionality the API depends on.
+type UserService interface {
+	ListByIDs(ids []string) ([]db.User, error)
+}
+
+// resolver is the root of the schema, resolving its queries and mutations.
+type resolver struct {
+	bs BookService
+	us UserService
+	ms MagazineService
+}
+
+func (r *resolver) User(ctx context.Context, args struct{ ID graphqlgo.ID }) (*userResolver, error) {
+	return loadUser(ctx, string(args.ID))
+}
+
+func (r *resolver) Users(ctx context.Context, args struct{ IDs []graphqlgo.ID }) ([]*userResolver, error) {
+	ids := make([]string, 0, len(args.IDs))
+	for _, id := range args.IDs {
+		ids = append(ids, string(id))
+	}
+	users, errs := loadersFrom(ctx).users.LoadMany(ctx, ids)()
+	var resolvers []*userResolver
+	for i, u := range users {
+		if len(errs) > i && errs[i] != nil {
+			return nil, errs[i]
+		}
+		if u != nil {
+			resolvers = append(resolvers, &userResolver{u: *u})
+		}
+	}
+	return resolvers, nil
+}
+
+func (r *resolver) Book(args struct{ ID graphqlgo.ID }) *bookResolver {
+	b, err := r.bs.Get(string(args.ID))
+	if err != nil {
+		return nil
+	}
+	return &bookResolver{b: *b}
+}
+
+func (r *resolver) Books() ([]*bookResolver, error) {
+	books, err := r.bs.List()
+	if err != nil {
+		return nil, err
+	}
+	return bookResolvers(books), nil
+}
+
+func (r *resolver) Magazine(args struct{ ID graphqlgo.ID }) *magazineResolver {
+	m, err := r.ms.Get(string(args.ID))
+	if err != nil {
+		return nil
+	}
+	return &magazineResolver{m: *m}
+}
+
+func (r *resolver) Magazines() ([]*magazineResolver, error) {
+	mags, err := r.ms.List()
+	if err != nil {
+		return nil, err
+	}
+	return magazineResolvers(mags), nil
+}
+
+type swapArgs struct {
+	ID     graphqlgo.ID
+	UserID graphqlgo.ID
+}
+
+func (r *resolver) SwapBook(args swapArgs) (*bookResolver, error) {
+	b, err := r.bs.SwapBook(string(args.ID), string(args.UserID))
+	if err != nil {
+		return nil, err
+	}
+	return &bookResolver{b: *b}, nil
+}
+
+func (r *resolver) SwapMagazine(args swapArgs) (*magazineResolver, error) {
+	m, err := r.ms.SwapMagazine(string(args.ID), string(args.UserID))
+	if err != nil {
+		return nil, err
+	}
+	return &magazineResolver{m: *m}, nil
+}
+
+type userResolver struct {
+	u db.User
+}
+
+func (r *userResolver) ID() graphqlgo.ID { return graphqlgo.ID(r.u.ID) }
+func (r *userResolver) Name() string     { return r.u.Name }
+func (r *userResolver) Address() string  { return r.u.Address }
+func (r *userResolver) PostCode() string { return r.u.PostCode }
+func (r *userResolver) Country() string  { return r.u.Country }
+
+func (r *userResolver) Books(ctx context.Context) ([]*bookResolver, error) {
+	books, err := loadersFrom(ctx).booksByOwner.Load(ctx, r.u.ID)()
+	if err != nil {
+		return nil, err
+	}
+	return bookResolvers(books), nil
+}
+
+func (r *userResolver) Magazines(ctx context.Context) ([]*magazineResolver, error) {
+	mags, err := loadersFrom(ctx).magazinesByOwner.Load(ctx, r.u.ID)()
+	if err != nil {
+		return nil, err
+	}
+	return magazineResolvers(mags), nil
+}
+
+type bookResolver struct {
+	b db.Book
+}
+
+func (r *bookResolver) ID() graphqlgo.ID   { return graphqlgo.ID(r.b.ID) }
+func (r *bookResolver) Name() string       { return r.b.Name }
+func (r *bookResolver) Author() string     { return r.b.Author }
+func (r *bookResolver) Status() string     { return r.b.Status.String() }
+func (r *bookResolver) Isbn() *string      { return optional(r.b.ISBN) }
+func (r *bookResolver) Publisher() *string { return optional(r.b.Publisher) }
+
+func (r *bookResolver) Year() *int32 {
+	if r.b.Year == 0 {
+		return nil
+	}
+	y := int32(r.b.Year)
+	return &y
+}
+
+func (r *bookResolver) Edition() *string         { return optional(r.b.Edition) }
+func (r *bookResolver) Condition() *string       { return optional(string(r.b.Condition)) }
+func (r *bookResolver) Language() *string        { return optional(r.b.Language) }
+func (r *bookResolver) Tags() []string           { return r.b.Tags }
+func (r *bookResolver) Images() []*imageResolver { return imageResolvers(r.b.Images) }
+
+func (r *bookResolver) Owner(ctx context.Context) (*userResolver, error) {
+	return loadUser(ctx, r.b.OwnerID)
+}
+
+type magazineResolver struct {
+	m db.Magazine
+}
+
+func (r *magazineResolver) ID() graphqlgo.ID         { return graphqlgo.ID(r.m.ID) }
+func (r *magazineResolver) Name() string             { return r.m.Name }
+func (r *magazineResolver) Status() string           { return r.m.Status.String() }
+func (r *magazineResolver) Condition() *string       { return optional(string(r.m.Condition)) }
+func (r *magazineResolver) Language() *string        { return optional(r.m.Language) }
+func (r *magazineResolver) Tags() []string           { return r.m.Tags }
+func (r *magazineResolver) Images() []*imageResolver { return imageResolvers(r.m.Images) }
+
+func (r *magazineResolver) Owner(ctx context.Context) (*userResolver, error) {
+	return loadUser(ctx, r.m.OwnerID)
+}
+
+type imageResolver struct {
+	img db.ItemImage
+}
+
+func (r *imageResolver) URL() string          { return r.img.URL }
+func (r *imageResolver) ThumbnailURL() string { return r.img.ThumbnailURL }
+func (r *imageResolver) ContentType() string  { return r.img.ContentType }
+func (r *imageResolver) Width() int32         { return int32(r.img.Width) }
+func (r *imageResolver) Height() int32        { return int32(r.img.Height) }
+
+func imageResolvers(images db.ItemImages) []*imageResolver {
+	resolvers := make([]*imageResolver, 0, len(images))
+	for _, img := range images {
+		resolvers = append(resolvers, &imageResolver{img: img})
+	}
+	return resolvers
+}
+
+// loadUser resolves a user through the request's loader, or nil if none exists.
+func loadUser(ctx context.Context, id string) (*userResolver, error) {
+	u, err := loadersFrom(ctx).users.Load(ctx, id)()
+	if err != nil || u == nil {
+		return nil, err
+	}
+	return &userResolver{u: *u}, nil
+}
+
+func bookResolvers(books []db.Book) []*bookResolver {
+	resolvers := make([]*bookResolver, 0, len(books))
+	for _, b := range books {
+		resolvers = append(resolvers, &bookResolver{b: b})
+	}
+	return resolvers
+}
+
+func magazineResolvers(mags []db.Magazine) []*magazineResolver {
+	resolvers := make([]*magazineResolver, 0, len(mags))
+	for _, m := range mags {
+		resolvers = append(resolvers, &magazineResolver{m: m})
+	}
+	return resolvers
+}
+
+// optional returns nil for empty strings, which are null in the schema.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,163 @@
+package grpcserver
+
+import (
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	bookswapv1 "github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/gen/bookswap/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var toItemStatus = map[db.BookStatus]bookswapv1.ItemStatus{
+	db.Available: bookswapv1.ItemStatus_ITEM_STATUS_AVAILABLE,
+	db.Reserved:  bookswapv1.ItemStatus_ITEM_STATUS_RESERVED,
+	db.InTransit: bookswapv1.ItemStatus_ITEM_STATUS_IN_TRANSIT,
+	db.Swapped:   bookswapv1.ItemStatus_ITEM_STATUS_SWAPPED,
+	db.Withdrawn: bookswapv1.ItemStatus_ITEM_STATUS_WITHDRAWN,
+	db.Removed:   bookswapv1.ItemStatus_ITEM_STATUS_REMOVED,
+}
+
+var fromItemStatus = map[bookswapv1.ItemStatus]db.BookStatus{
+	bookswapv1.ItemStatus_ITEM_STATUS_AVAILABLE:  db.Available,
+	bookswapv1.ItemStatus_ITEM_STATUS_RESERVED:   db.Reserved,
+	bookswapv1.ItemStatus_ITEM_STATUS_IN_TRANSIT: db.InTransit,
+	bookswapv1.ItemStatus_ITEM_STATUS_SWAPPED:    db.Swapped,
+	bookswapv1.ItemStatus_ITEM_STATUS_WITHDRAWN:  db.Withdrawn,
+	bookswapv1.ItemStatus_ITEM_STATUS_REMOVED:    db.Removed,
+}
+
+func toBook(b db.Book) *bookswapv1.Book {
+	return &bookswapv1.Book{
+		Id:        b.ID,
+		Name:      b.Name,
+		Author:    b.Author,
+		OwnerId:   b.OwnerID,
+		Status:    toItemStatus[b.Status],
+		Isbn:      b.ISBN,
+		Publisher: b.Publisher,
+		Year:      int32(b.Year),
+		Edition:   b.Edition,
+		Condition: string(b.Condition),
+		Language:  b.Language,
+		Tags:      b.Tags,
+		Images:    toItemImages(b.Images),
+	}
+}
+
+func toBooks(books []db.Book) []*bookswapv1.Book {
+	items := make([]*bookswapv1.Book, 0, len(books))
+	for _, b := range books {
+		items = append(items, toBook(b))
+	}
+	return items
+}
+
+// fromBook converts a book from a request. Unspecified statuses become available.
+func fromBook(b *bookswapv1.Book) db.Book {
+	return db.Book{
+		ID:        b.GetId(),
+		Name:      b.GetName(),
+		Author:    b.GetAuthor(),
+		ISBN:      b.GetIsbn(),
+		Publisher: b.GetPublisher(),
+		Year:      int(b.GetYear()),
+		Edition:   b.GetEdition(),
+		Condition: db.ItemCondition(b.GetCondition()),
+		Language:  b.GetLanguage(),
+		Tags:      b.GetTags(),
+		OwnerID:   b.GetOwnerId(),
+		Status:    fromItemStatus[b.GetStatus()],
+	}
+}
+
+func toMagazine(m db.Magazine) *bookswapv1.Magazine {
+	return &bookswapv1.Magazine{
+		Id:          m.ID,
+		Name:        m.Name,
+		IssueNumber: int32(m.IssueNumber),
+		OwnerId:     m.OwnerID,
+		Status:      toItemStatus[m.Status],
+		Condition:   string(m.Condition),
+		Language:    m.Language,
+		Tags:        m.Tags,
+		Images:      toItemImages(m.Images),
+	}
+}
+
+func toMagazines(mags []db.Magazine) []*bookswapv1.Magazine {
+	items := make([]*bookswapv1.Magazine, 0, len(mags))
+	for _, m := range mags {
+		items = append(items, toMagazine(m))
+	}
+	return items
+}
+
+// fromMagazine converts a magazine from a request. Unspecified statuses become available.
+func fromMagazine(m *bookswapv1.Magazine) db.Magazine {
+	return db.Magazine{
+		ID:          m.GetId(),
+		Name:        m.GetName(),
+		IssueNumber: int(m.GetIssueNumber()),
+		Condition:   db.ItemCondition(m.GetCondition()),
+		Language:    m.GetLanguage(),
+		Tags:        m.GetTags(),
+		OwnerID:     m.GetOwnerId(),
+		Status:      fromItemStatus[m.GetStatus()],
+	}
+}
+
+func toItemImages(images db.ItemImages) []*bookswapv1.ItemImage {
+	items := make([]*bookswapv1.ItemImage, 0, len(images))
+	for _, img := range images {
+		items = append(items, &bookswapv1.ItemImage{
+			Id:           img.ID,
+			ContentType:  img.ContentType,
+			Size:         int64(img.Size),
+			Width:        int32(img.Width),
+			Height:       int32(img.Height),
+			Url:          img.URL,
+			ThumbnailUrl: img.ThumbnailURL,
+		})
+	}
+	return items
+}
+
+func toUser(u db.User) *bookswapv1.User {
+	return &bookswapv1.User{
+		Id:       u.ID,
+		Name:     u.Name,
+		Email:    u.Email,
+		Address:  u.Address,
+		PostCode: u.PostCode,
+		Country:  u.Country,
+	}
+}
+
+func toReputation(r db.Reputation) *bookswapv1.Reputation {
+	return &bookswapv1.Reputation{
+		Average: r.Average,
+		Count:   int32(r.Count),
+	}
+}
+
+func fromUser(u *bookswapv1.User) db.User {
+	return db.User{
+		ID:       u.GetId(),
+		Name:     u.GetName(),
+		Email:    u.GetEmail(),
+		Address:  u.GetAddress(),
+		PostCode: u.GetPostCode(),
+		Country:  u.GetCountry(),
+	}
+}
+
+func toItemEvent(e db.ItemEvent) *bookswapv1.ItemEvent {
+	return &bookswapv1.ItemEvent{
+		Id:              e.ID,
+		ItemId:          e.ItemID,
+		ItemType:        string(e.ItemType),
+		Type:            string(e.Type),
+		ActorId:         e.ActorID,
+		OwnerId:         e.OwnerID,
+		PreviousOwnerId: e.PreviousOwnerID,
+		CreatedAt:       timestamppb.New(e.CreatedAt),
+	}
+}
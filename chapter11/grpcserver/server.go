@@ -0,0 +1,364 @@
+// Package grpcserver serves the BookSwap gRPC API, on top of the same services as the REST handlers.
+package grpcserver
+
+//go:generate buf generate ../proto --template ../proto/buf.gen.yaml --output ..
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/events"
+	bookswapv1 "github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/gen/bookswap/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// CommunityHeader is the metadata key which selects the community of a call by its slug,
+// like the X-Community header of the REST API.
+const CommunityHeader = "x-community"
+
+// BookOperations is the book functionality the server depends on.
+type BookOperations interface {
+	Get(id string) (*db.Book, error)
+	List() ([]db.Book, error)
+	Upsert(b db.Book) (db.Book, error)
+	SwapBook(bookID, userID string) (*db.Book, error)
+}
+
+// MagazineOperations is the magazine functionality the server depends on.
+type MagazineOperations interface {
+	Get(id string) (*db.Magazine, error)
+	List() ([]db.Magazine, error)
+	Upsert(m db.Magazine) (db.Magazine, error)
+	SwapMagazine(magID, userID string) (*db.Magazine, error)
+}
+
+// UserOperations is the user functionality the server depends on.
+type UserOperations interface {
+	Get(id string) (*db.UserProfile, error)
+	Exists(id string) error
+	Upsert(u db.User) (db.User, error)
+}
+
+// CommunityResolver is the community functionality the server depends on.
+type CommunityResolver interface {
+	Resolve(slug, host string) (*db.Community, error)
+}
+
+// Scope returns the services which only see the items and users of the given community.
+type Scope func(communityID string) (BookOperations, UserOperations, MagazineOperations)
+
+// Server implements the BookSwapService.
+type Server struct {
+	bookswapv1.UnimplementedBookSwapServiceServer
+	bs BookOperations
+	us UserOperations
+	ms MagazineOperations
+	eb *events.Broker
+	// cms and scope split the calls by community, if the deployment has communities.
+	cms   CommunityResolver
+	scope Scope
+	// community is the community the services are scoped to, if they are scoped to one.
+	community string
+}
+
+// NewServer initialises a Server given its dependencies.
+func NewServer(bs BookOperations, us UserOperations, ms MagazineOperations, eb *events.Broker) *Server {
+	return &Server{
+		bs: bs,
+		us: us,
+		ms: ms,
+		eb: eb,
+	}
+}
+
+// NewGRPCServer creates a gRPC server for the given Server, which logs every call
+// and only accepts callers presenting one of the given tokens.
+func NewGRPCServer(s *Server, tokens []string, opts ...grpc.ServerOption) *grpc.Server {
+	auth := NewAuthenticator(tokens...)
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(LoggingUnaryInterceptor, auth.UnaryInterceptor),
+		grpc.ChainStreamInterceptor(LoggingStreamInterceptor, auth.StreamInterceptor),
+	)
+	g := grpc.NewServer(opts...)
+	bookswapv1.RegisterBookSwapServiceServer(g, s)
+	return g
+}
+
+// WithCommunities returns a copy of the server which serves every call with the services
+// of the community the call is made to, as returned by scope.
+func (s *Server) WithCommunities(cms CommunityResolver, scope Scope) *Server {
+	c := *s
+	c.cms = cms
+	c.scope = scope
+	return &c
+}
+
+// scoped returns a copy of the server whose services only see the community the call is made to.
+// The community is selected by the x-community metadata or else by the authority of the call.
+// Calls are served by the server itself if there are no communities.
+func (s *Server) scoped(ctx context.Context) (*Server, error) {
+	if s.cms == nil {
+		return s, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	c, err := s.cms.Resolve(firstValue(md, CommunityHeader), firstValue(md, ":authority"))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	sc := *s
+	sc.community = c.ID
+	sc.bs, sc.us, sc.ms = s.scope(c.ID)
+	return &sc, nil
+}
+
+// firstValue returns the first value of a metadata key, or an empty string if it has none.
+func firstValue(md metadata.MD, key string) string {
+	if vs := md.Get(key); len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// inScope returns a function which reports whether an event is about an item of the community
+// of the server, which is the case of every event if the server is not scoped.
+// Owners are only looked up once per stream.
+func (s *Server) inScope() func(db.ItemEvent) bool {
+	if s.community == "" {
+		return func(db.ItemEvent) bool { return true }
+	}
+	owners := map[string]bool{}
+	return func(e db.ItemEvent) bool {
+		visible, ok := owners[e.OwnerID]
+		if !ok {
+			visible = s.us.Exists(e.OwnerID) == nil
+			owners[e.OwnerID] = visible
+		}
+		return visible
+	}
+}
+
+// GetBook returns a given book.
+func (s *Server) GetBook(ctx context.Context, req *bookswapv1.GetBookRequest) (*bookswapv1.GetBookResponse, error) {
+	s, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.bs.Get(req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bookswapv1.GetBookResponse{Book: toBook(*b)}, nil
+}
+
+// ListBooks returns the books available for swapping.
+func (s *Server) ListBooks(ctx context.Context, req *bookswapv1.ListBooksRequest) (*bookswapv1.ListBooksResponse, error) {
+	s, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	books, err := s.bs.List()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &bookswapv1.ListBooksResponse{Books: toBooks(books)}, nil
+}
+
+// UpsertBook creates or updates a book.
+func (s *Server) UpsertBook(ctx context.Context, req *bookswapv1.UpsertBookRequest) (*bookswapv1.UpsertBookResponse, error) {
+	s, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetBook() == nil {
+		return nil, status.Error(codes.InvalidArgument, "book is required")
+	}
+	if err := s.ownerExists(req.GetBook().GetOwnerId()); err != nil {
+		return nil, err
+	}
+	b, err := s.bs.Upsert(fromBook(req.GetBook()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bookswapv1.UpsertBookResponse{Book: toBook(b)}, nil
+}
+
+// SwapBook swaps a book to a new owner and posts it to them.
+func (s *Server) SwapBook(ctx context.Context, req *bookswapv1.SwapBookRequest) (*bookswapv1.SwapBookResponse, error) {
+	s, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ownerExists(req.GetUserId()); err != nil {
+		return nil, err
+	}
+	b, err := s.bs.SwapBook(req.GetId(), req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bookswapv1.SwapBookResponse{Book: toBook(*b)}, nil
+}
+
+// GetMagazine returns a given magazine.
+func (s *Server) GetMagazine(ctx context.Context, req *bookswapv1.GetMagazineRequest) (*bookswapv1.GetMagazineResponse, error) {
+	s, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.ms.Get(req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bookswapv1.GetMagazineResponse{Magazine: toMagazine(*m)}, nil
+}
+
+// ListMagazines returns the magazines available for swapping.
+func (s *Server) ListMagazines(ctx context.Context, req *bookswapv1.ListMagazinesRequest) (*bookswapv1.ListMagazinesResponse, error) {
+	s, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mags, err := s.ms.List()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &bookswapv1.ListMagazinesResponse{Magazines: toMagazines(mags)}, nil
+}
+
+// UpsertMagazine creates or updates a magazine.
+func (s *Server) UpsertMagazine(ctx context.Context, req *bookswapv1.UpsertMagazineRequest) (*bookswapv1.UpsertMagazineResponse, error) {
+	s, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetMagazine() == nil {
+		return nil, status.Error(codes.InvalidArgument, "magazine is required")
+	}
+	if err := s.ownerExists(req.GetMagazine().GetOwnerId()); err != nil {
+		return nil, err
+	}
+	m, err := s.ms.Upsert(fromMagazine(req.GetMagazine()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bookswapv1.UpsertMagazineResponse{Magazine: toMagazine(m)}, nil
+}
+
+// SwapMagazine swaps a magazine to a new owner and posts it to them.
+func (s *Server) SwapMagazine(ctx context.Context, req *bookswapv1.SwapMagazineRequest) (*bookswapv1.SwapMagazineResponse, error) {
+	s, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ownerExists(req.GetUserId()); err != nil {
+		return nil, err
+	}
+	m, err := s.ms.SwapMagazine(req.GetId(), req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bookswapv1.SwapMagazineResponse{Magazine: toMagazine(*m)}, nil
+}
+
+// GetUser returns a given user, together with their books, magazines and reputation.
+func (s *Server) GetUser(ctx context.Context, req *bookswapv1.GetUserRequest) (*bookswapv1.GetUserResponse, error) {
+	s, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.us.Get(req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bookswapv1.GetUserResponse{
+		User:       toUser(p.User),
+		Books:      toBooks(p.Books),
+		Magazines:  toMagazines(p.Magazines),
+		Reputation: toReputation(p.Reputation),
+	}, nil
+}
+
+// UpsertUser creates or updates a user.
+func (s *Server) UpsertUser(ctx context.Context, req *bookswapv1.UpsertUserRequest) (*bookswapv1.UpsertUserResponse, error) {
+	s, err := s.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetUser() == nil {
+		return nil, status.Error(codes.InvalidArgument, "user is required")
+	}
+	u, err := s.us.Upsert(fromUser(req.GetUser()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &bookswapv1.UpsertUserResponse{User: toUser(u)}, nil
+}
+
+// WatchEvents streams the item events matching the request, until the client cancels.
+// Only the events about the items of the community of the call are streamed.
+func (s *Server) WatchEvents(req *bookswapv1.WatchEventsRequest, stream bookswapv1.BookSwapService_WatchEventsServer) error {
+	s, err := s.scoped(stream.Context())
+	if err != nil {
+		return err
+	}
+	filter := events.Filter{
+		ItemType: db.ItemType(req.GetItemType()),
+		OwnerID:  req.GetOwnerId(),
+	}
+	for _, t := range req.GetTypes() {
+		et := db.ItemEventType(t)
+		if !db.IsItemEventType(et) {
+			return status.Errorf(codes.InvalidArgument, "unknown event type %q", t)
+		}
+		filter.Types = append(filter.Types, et)
+	}
+	sub := s.eb.Subscribe(filter)
+	defer sub.Close()
+	visible := s.inScope()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.Aborted, "fell behind the event stream")
+			}
+			if !visible(e) {
+				continue
+			}
+			if err := stream.Send(&bookswapv1.WatchEventsResponse{Event: toItemEvent(e)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ownerExists checks that a user exists, before items are given to them.
+func (s *Server) ownerExists(userID string) error {
+	if _, err := s.us.Get(userID); err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return nil
+}
+
+// toStatus converts a service error to a gRPC status, in the same way as the REST handlers.
+// Errors which are not known failures of the operation, such as database failures, are internal errors.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, db.ErrRecordNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, db.ErrNotOwner), errors.Is(err, db.ErrNotAdmin), errors.Is(err, db.ErrNotMember),
+		errors.Is(err, db.ErrSuspended):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, db.ErrInvalidTransition), errors.Is(err, db.ErrInsufficientCredits),
+		errors.Is(err, db.ErrFlagged), errors.Is(err, db.ErrReserved), errors.Is(err, db.ErrHoldExpired):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, db.ErrInvalidInput):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,302 @@
+package grpcserver_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/events"
+	bookswapv1 "github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/gen/bookswap/v1"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/grpcserver"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const token = "test-token"
+
+// newClient serves the given server over an in-memory listener and returns a client connected to it.
+func newClient(t *testing.T, s *grpcserver.Server) bookswapv1.BookSwapServiceClient {
+	t.Helper()
+	l := bufconn.Listen(1024 * 1024)
+	g := grpcserver.NewGRPCServer(s, []string{token})
+	go g.Serve(l)
+	t.Cleanup(g.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return bookswapv1.NewBookSwapServiceClient(conn)
+}
+
+func authorized(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestAuth(t *testing.T) {
+	bs := mocks.NewBookOperations(t)
+	bs.On("List").Return([]db.Book{}, nil).Once()
+	c := newClient(t, grpcserver.NewServer(bs, nil, nil, nil))
+
+	t.Run("missing token", func(t *testing.T) {
+		_, err := c.ListBooks(context.Background(), &bookswapv1.ListBooksRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+	t.Run("invalid token", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer nope")
+		_, err := c.ListBooks(ctx, &bookswapv1.ListBooksRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+	t.Run("invalid stream token", func(t *testing.T) {
+		stream, err := c.WatchEvents(context.Background(), &bookswapv1.WatchEventsRequest{})
+		require.Nil(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+	t.Run("valid token", func(t *testing.T) {
+		_, err := c.ListBooks(authorized(context.Background()), &bookswapv1.ListBooksRequest{})
+		assert.Nil(t, err)
+	})
+}
+
+func TestGetBook(t *testing.T) {
+	bs := mocks.NewBookOperations(t)
+	book := &db.Book{ID: "b1", Name: "Dune", Author: "Frank Herbert", OwnerID: "u1", Status: db.InTransit}
+	bs.On("Get", "b1").Return(book, nil).Once()
+	bs.On("Get", "unknown").Return(nil, fmt.Errorf("no book found for id unknown:%w", db.ErrRecordNotFound)).Once()
+	c := newClient(t, grpcserver.NewServer(bs, nil, nil, nil))
+	ctx := authorized(context.Background())
+
+	resp, err := c.GetBook(ctx, &bookswapv1.GetBookRequest{Id: "b1"})
+	require.Nil(t, err)
+	assert.Equal(t, "Dune", resp.GetBook().GetName())
+	assert.Equal(t, "u1", resp.GetBook().GetOwnerId())
+	assert.Equal(t, bookswapv1.ItemStatus_ITEM_STATUS_IN_TRANSIT, resp.GetBook().GetStatus())
+
+	_, err = c.GetBook(ctx, &bookswapv1.GetBookRequest{Id: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// communities resolves the communities of calls by slug, to the default community if there is no slug.
+type communities map[string]string
+
+func (cs communities) Resolve(slug, host string) (*db.Community, error) {
+	if slug == "" {
+		return &db.Community{ID: db.DefaultCommunityID}, nil
+	}
+	id, ok := cs[slug]
+	if !ok {
+		return nil, fmt.Errorf("no community found for slug %s:%w", slug, db.ErrRecordNotFound)
+	}
+	return &db.Community{ID: id, Slug: slug}, nil
+}
+
+func TestCommunities(t *testing.T) {
+	north := mocks.NewBookOperations(t)
+	north.On("Get", "b1").Return(&db.Book{ID: "b1", Name: "Dune", OwnerID: "u1"}, nil).Once()
+	south := mocks.NewBookOperations(t)
+	south.On("Get", "b1").Return(nil, fmt.Errorf("no book found for id b1:%w", db.ErrRecordNotFound)).Once()
+	books := map[string]grpcserver.BookOperations{"north-id": north, "south-id": south}
+	s := grpcserver.NewServer(nil, nil, nil, nil).WithCommunities(communities{"north": "north-id", "south": "south-id"},
+		func(communityID string) (grpcserver.BookOperations, grpcserver.UserOperations, grpcserver.MagazineOperations) {
+			return books[communityID], nil, nil
+		})
+	c := newClient(t, s)
+	inCommunity := func(slug string) context.Context {
+		return metadata.AppendToOutgoingContext(authorized(context.Background()), grpcserver.CommunityHeader, slug)
+	}
+
+	t.Run("own community", func(t *testing.T) {
+		resp, err := c.GetBook(inCommunity("north"), &bookswapv1.GetBookRequest{Id: "b1"})
+		require.Nil(t, err)
+		assert.Equal(t, "Dune", resp.GetBook().GetName())
+	})
+	t.Run("other community", func(t *testing.T) {
+		_, err := c.GetBook(inCommunity("south"), &bookswapv1.GetBookRequest{Id: "b1"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+	t.Run("unknown community", func(t *testing.T) {
+		_, err := c.GetBook(inCommunity("east"), &bookswapv1.GetBookRequest{Id: "b1"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestSwapBook(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		code codes.Code
+	}{
+		"swapped":            {code: codes.OK},
+		"invalid transition": {err: fmt.Errorf("%w: from SWAPPED", db.ErrInvalidTransition), code: codes.FailedPrecondition},
+		"not owner":          {err: db.ErrNotOwner, code: codes.PermissionDenied},
+		"invalid input":      {err: db.ErrInvalidInput, code: codes.InvalidArgument},
+		"reserved":           {err: fmt.Errorf("%w: u2", db.ErrReserved), code: codes.FailedPrecondition},
+		"suspended":          {err: fmt.Errorf("user u2:%w", db.ErrSuspended), code: codes.PermissionDenied},
+		"database failure":   {err: errors.New("connection refused"), code: codes.Internal},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			bs := mocks.NewBookOperations(t)
+			us := mocks.NewUserOperations(t)
+			us.On("Get", "u2").Return(&db.UserProfile{User: db.User{ID: "u2"}}, nil).Once()
+			if tc.err != nil {
+				bs.On("SwapBook", "b1", "u2").Return(nil, tc.err).Once()
+			} else {
+				bs.On("SwapBook", "b1", "u2").Return(&db.Book{ID: "b1", OwnerID: "u2", Status: db.InTransit}, nil).Once()
+			}
+			c := newClient(t, grpcserver.NewServer(bs, us, nil, nil))
+
+			resp, err := c.SwapBook(authorized(context.Background()), &bookswapv1.SwapBookRequest{Id: "b1", UserId: "u2"})
+			assert.Equal(t, tc.code, status.Code(err))
+			if tc.err == nil {
+				assert.Equal(t, "u2", resp.GetBook().GetOwnerId())
+				assert.Equal(t, bookswapv1.ItemStatus_ITEM_STATUS_IN_TRANSIT, resp.GetBook().GetStatus())
+			}
+		})
+	}
+
+	t.Run("unknown user", func(t *testing.T) {
+		bs := mocks.NewBookOperations(t)
+		us := mocks.NewUserOperations(t)
+		us.On("Get", "unknown").Return(nil, fmt.Errorf("no user found for id unknown")).Once()
+		c := newClient(t, grpcserver.NewServer(bs, us, nil, nil))
+
+		_, err := c.SwapBook(authorized(context.Background()), &bookswapv1.SwapBookRequest{Id: "b1", UserId: "unknown"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestUpsertBook(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		code codes.Code
+	}{
+		"upserted":         {code: codes.OK},
+		"invalid isbn":     {err: fmt.Errorf("%w: invalid isbn", db.ErrInvalidInput), code: codes.InvalidArgument},
+		"not a member":     {err: fmt.Errorf("user u1:%w", db.ErrNotMember), code: codes.PermissionDenied},
+		"suspended":        {err: fmt.Errorf("user u1:%w", db.ErrSuspended), code: codes.PermissionDenied},
+		"flagged":          {err: db.ErrFlagged, code: codes.FailedPrecondition},
+		"database failure": {err: errors.New("connection refused"), code: codes.Internal},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			bs := mocks.NewBookOperations(t)
+			us := mocks.NewUserOperations(t)
+			us.On("Get", "u1").Return(&db.UserProfile{User: db.User{ID: "u1"}}, nil).Once()
+			if tc.err != nil {
+				bs.On("Upsert", mock.Anything).Return(db.Book{}, tc.err).Once()
+			} else {
+				bs.On("Upsert", mock.Anything).Return(db.Book{ID: "b1", Name: "Dune", OwnerID: "u1"}, nil).Once()
+			}
+			c := newClient(t, grpcserver.NewServer(bs, us, nil, nil))
+
+			resp, err := c.UpsertBook(authorized(context.Background()), &bookswapv1.UpsertBookRequest{
+				Book: &bookswapv1.Book{Name: "Dune", OwnerId: "u1"},
+			})
+			assert.Equal(t, tc.code, status.Code(err))
+			if tc.err == nil {
+				assert.Equal(t, "b1", resp.GetBook().GetId())
+			}
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	us := mocks.NewUserOperations(t)
+	us.On("Get", "u1").Return(&db.UserProfile{
+		User:       db.User{ID: "u1", Name: "Ann", Email: "ann@example.com"},
+		Books:      []db.Book{{ID: "b1", OwnerID: "u1"}},
+		Magazines:  []db.Magazine{{ID: "m1", IssueNumber: 42, OwnerID: "u1", Status: db.Withdrawn}},
+		Reputation: db.Reputation{Average: 4.5, Count: 2},
+	}, nil).Once()
+	c := newClient(t, grpcserver.NewServer(nil, us, nil, nil))
+
+	resp, err := c.GetUser(authorized(context.Background()), &bookswapv1.GetUserRequest{Id: "u1"})
+	require.Nil(t, err)
+	assert.Equal(t, "ann@example.com", resp.GetUser().GetEmail())
+	require.Len(t, resp.GetBooks(), 1)
+	assert.Equal(t, bookswapv1.ItemStatus_ITEM_STATUS_AVAILABLE, resp.GetBooks()[0].GetStatus())
+	require.Len(t, resp.GetMagazines(), 1)
+	assert.Equal(t, int32(42), resp.GetMagazines()[0].GetIssueNumber())
+	assert.Equal(t, bookswapv1.ItemStatus_ITEM_STATUS_WITHDRAWN, resp.GetMagazines()[0].GetStatus())
+	assert.Equal(t, 4.5, resp.GetReputation().GetAverage())
+	assert.Equal(t, int32(2), resp.GetReputation().GetCount())
+}
+
+func TestWatchEvents(t *testing.T) {
+	eb := events.NewBroker(events.DefaultBuffer)
+	c := newClient(t, grpcserver.NewServer(nil, nil, nil, eb))
+	ctx, cancel := context.WithCancel(authorized(context.Background()))
+	defer cancel()
+
+	stream, err := c.WatchEvents(ctx, &bookswapv1.WatchEventsRequest{
+		Types: []string{string(db.ItemSwapped)},
+	})
+	require.Nil(t, err)
+	require.Eventually(t, func() bool { return eb.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+	eb.Publish(db.ItemEvent{ID: 1, ItemID: "b1", ItemType: db.BookItem, Type: db.ItemCreated})
+	eb.Publish(db.ItemEvent{ID: 2, ItemID: "b1", ItemType: db.BookItem, Type: db.ItemSwapped,
+		OwnerID: "u2", PreviousOwnerID: "u1"})
+
+	resp, err := stream.Recv()
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), resp.GetEvent().GetId())
+	assert.Equal(t, string(db.ItemSwapped), resp.GetEvent().GetType())
+	assert.Equal(t, "u1", resp.GetEvent().GetPreviousOwnerId())
+
+	cancel()
+	require.Eventually(t, func() bool { return eb.Subscribers() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWatchEventsInCommunity(t *testing.T) {
+	eb := events.NewBroker(events.DefaultBuffer)
+	us := mocks.NewUserOperations(t)
+	us.On("Exists", "u1").Return(nil).Once()
+	us.On("Exists", "u2").Return(fmt.Errorf("no user found for id u2:%w", db.ErrRecordNotFound)).Once()
+	s := grpcserver.NewServer(nil, nil, nil, eb).WithCommunities(communities{"north": "north-id"},
+		func(communityID string) (grpcserver.BookOperations, grpcserver.UserOperations, grpcserver.MagazineOperations) {
+			return nil, us, nil
+		})
+	c := newClient(t, s)
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(authorized(context.Background()),
+		grpcserver.CommunityHeader, "north"))
+	defer cancel()
+
+	stream, err := c.WatchEvents(ctx, &bookswapv1.WatchEventsRequest{})
+	require.Nil(t, err)
+	require.Eventually(t, func() bool { return eb.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+	eb.Publish(db.ItemEvent{ID: 1, ItemID: "b2", ItemType: db.BookItem, Type: db.ItemCreated, OwnerID: "u2"})
+	eb.Publish(db.ItemEvent{ID: 2, ItemID: "b1", ItemType: db.BookItem, Type: db.ItemCreated, OwnerID: "u1"})
+
+	resp, err := stream.Recv()
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), resp.GetEvent().GetId())
+}
+
+func TestWatchEventsUnknownType(t *testing.T) {
+	c := newClient(t, grpcserver.NewServer(nil, nil, nil, events.NewBroker(events.DefaultBuffer)))
+
+	stream, err := c.WatchEvents(authorized(context.Background()), &bookswapv1.WatchEventsRequest{
+		Types: []string{"UNKNOWN"},
+	})
+	require.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
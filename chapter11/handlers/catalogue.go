@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+const (
+	csvContentType   = "text/csv"
+	jsonlContentType = "application/x-ndjson"
+	// maxImportLine limits the length of a JSON Lines row.
+	maxImportLine = 64 << 10
+)
+
+// catalogueColumns are the columns of the catalogue in CSV. Only item_type, name and owner_id are required.
+var catalogueColumns = []string{"item_type", "id", "name", "author", "isbn", "publisher", "year", "edition",
+	"issue_number", "condition", "language", "tags", "owner_id", "status"}
+
+// tagSeparator separates the tags of an item in a CSV cell.
+const tagSeparator = ";"
+
+// catalogueFormats maps the format names of the export query to their content types.
+var catalogueFormats = map[string]string{
+	"csv":   csvContentType,
+	"jsonl": jsonlContentType,
+}
+
+// rowReader reads the rows of an imported catalogue one at a time.
+// Rows which cannot be read are returned with their error, so that the rest of the file is still imported.
+// It returns io.EOF once all the rows have been read.
+type rowReader interface {
+	next() (db.ImportRow, error)
+}
+
+// newRowReader initialises a reader of the format given by a Content-Type header.
+func newRowReader(contentType string, r io.Reader) (rowReader, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type %q", contentType)
+	}
+	switch mediaType {
+	case csvContentType:
+		return newCSVRowReader(r)
+	case jsonlContentType, "application/jsonl":
+		s := bufio.NewScanner(r)
+		s.Buffer(nil, maxImportLine)
+		return &jsonlRowReader{s: s}, nil
+	}
+	return nil, fmt.Errorf("unsupported content type %q, use %s or %s", mediaType, csvContentType, jsonlContentType)
+}
+
+// csvRowReader reads CSV with a header row naming its columns, in any order.
+type csvRowReader struct {
+	r    *csv.Reader
+	cols map[string]int
+}
+
+func newCSVRowReader(r io.Reader) (*csvRowReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("no csv header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv header:%v", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if !isCatalogueColumn(name) {
+			return nil, fmt.Errorf("unknown csv column %q, expected some of %s", name,
+				strings.Join(catalogueColumns, ","))
+		}
+		cols[name] = i
+	}
+	for _, name := range []string{"item_type", "name", "owner_id"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("missing csv column %q", name)
+		}
+	}
+	return &csvRowReader{r: cr, cols: cols}, nil
+}
+
+func (c *csvRowReader) next() (db.ImportRow, error) {
+	record, err := c.r.Read()
+	var perr *csv.ParseError
+	if errors.As(err, &perr) {
+		return db.ImportRow{Row: perr.StartLine, Err: fmt.Errorf("invalid row:%v", perr.Err)}, nil
+	}
+	if err != nil {
+		return db.ImportRow{}, err
+	}
+	line, _ := c.r.FieldPos(0)
+	row := db.ImportRow{
+		Row: line,
+		Item: db.CatalogueItem{
+			ItemType:  db.ItemType(strings.ToUpper(c.cell(record, "item_type"))),
+			ID:        c.cell(record, "id"),
+			Name:      c.cell(record, "name"),
+			Author:    c.cell(record, "author"),
+			ISBN:      c.cell(record, "isbn"),
+			Publisher: c.cell(record, "publisher"),
+			Edition:   c.cell(record, "edition"),
+			Condition: db.ItemCondition(c.cell(record, "condition")),
+			Language:  c.cell(record, "language"),
+			OwnerID:   c.cell(record, "owner_id"),
+		},
+	}
+	if s := c.cell(record, "tags"); s != "" {
+		row.Item.Tags = strings.Split(s, tagSeparator)
+	}
+	if s := c.cell(record, "year"); s != "" {
+		if row.Item.Year, err = strconv.Atoi(s); err != nil {
+			row.Err = fmt.Errorf("invalid year %q", s)
+		}
+	}
+	if s := c.cell(record, "issue_number"); s != "" && row.Err == nil {
+		if row.Item.IssueNumber, err = strconv.Atoi(s); err != nil {
+			row.Err = fmt.Errorf("invalid issue number %q", s)
+		}
+	}
+	if s := c.cell(record, "status"); s != "" && row.Err == nil {
+		row.Item.Status, row.Err = db.ParseBookStatus(strings.ToUpper(s))
+	}
+	return row, nil
+}
+
+// cell returns the trimmed value of a column, or an empty string if the file does not have it.
+func (c *csvRowReader) cell(record []string, name string) string {
+	i, ok := c.cols[name]
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// jsonlRowReader reads JSON Lines, skipping blank lines.
+type jsonlRowReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (j *jsonlRowReader) next() (db.ImportRow, error) {
+	for j.s.Scan() {
+		j.line++
+		data := bytes.TrimSpace(j.s.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		row := db.ImportRow{Row: j.line}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row.Item); err != nil {
+			row.Err = fmt.Errorf("invalid row:%v", err)
+		}
+		row.Item.ItemType = db.ItemType(strings.ToUpper(string(row.Item.ItemType)))
+		return row, nil
+	}
+	if err := j.s.Err(); err != nil {
+		return db.ImportRow{}, fmt.Errorf("line %d:%v", j.line+1, err)
+	}
+	return db.ImportRow{}, io.EOF
+}
+
+// rowWriter writes the items of an exported catalogue one at a time.
+type rowWriter interface {
+	write(item db.CatalogueItem) error
+	flush() error
+}
+
+// newRowWriter initialises a writer of the given content type.
+func newRowWriter(contentType string, w io.Writer) (rowWriter, error) {
+	if contentType == jsonlContentType {
+		return jsonlRowWriter{enc: json.NewEncoder(w)}, nil
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(catalogueColumns); err != nil {
+		return nil, err
+	}
+	return csvRowWriter{w: cw}, nil
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (c csvRowWriter) write(item db.CatalogueItem) error {
+	year, issue := "", ""
+	if item.Year != 0 {
+		year = strconv.Itoa(item.Year)
+	}
+	if item.ItemType == db.MagazineItem {
+		issue = strconv.Itoa(item.IssueNumber)
+	}
+	return c.w.Write([]string{string(item.ItemType), item.ID, item.Name, item.Author, item.ISBN, item.Publisher,
+		year, item.Edition, issue, string(item.Condition), item.Language, strings.Join(item.Tags, tagSeparator),
+		item.OwnerID, item.Status.String()})
+}
+
+func (c csvRowWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlRowWriter struct {
+	enc *json.Encoder
+}
+
+func (j jsonlRowWriter) write(item db.CatalogueItem) error {
+	return j.enc.Encode(item)
+}
+
+func (j jsonlRowWriter) flush() error {
+	return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func isCatalogueColumn(name string) bool {
+	for _, c := range catalogueColumns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/graphql"
+)
+
+// CommunityHeader is the header which selects the community of a request by its slug,
+// for clients which cannot use the host of a community.
+const CommunityHeader = "X-Community"
+
+// errNoCommunities is the error of community requests to a deployment which is not split into communities.
+const errNoCommunities = "communities are not configured"
+
+// scoped serves a request with a copy of the handler whose services only see the community
+// the request is made to. The community is selected by the X-Community header or else by the host
+// of the request. Requests are served by the handler itself if there are no communities.
+func (h *Handler) scoped(serve func(*Handler, http.ResponseWriter, *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.cms == nil {
+			serve(h, w, r)
+			return
+		}
+		w.Header().Add("Vary", CommunityHeader)
+		c, err := h.cms.Resolve(r.Header.Get(CommunityHeader), r.Host)
+		if err != nil {
+			writeResponse(w, errorStatus(err), &Response[db.Community]{
+				Error: err.Error(),
+			})
+			return
+		}
+		serve(h.inCommunity(c.ID), w, r)
+	})
+}
+
+// inCommunity returns a copy of the handler whose services only see the given community.
+// The event broker is shared by all the communities of a deployment.
+func (h *Handler) inCommunity(communityID string) *Handler {
+	c := *h
+	c.community = communityID
+	if h.bs != nil {
+		c.bs = h.bs.InCommunity(communityID)
+	}
+	if h.us != nil {
+		c.us = h.us.InCommunity(communityID)
+	}
+	if h.ms != nil {
+		c.ms = h.ms.InCommunity(communityID)
+	}
+	if h.hs != nil {
+		c.hs = h.hs.InCommunity(communityID)
+	}
+	if h.ws != nil {
+		c.ws = h.ws.InCommunity(communityID)
+	}
+	if h.ns != nil {
+		c.ns = h.ns.InCommunity(communityID)
+	}
+	if h.whs != nil {
+		c.whs = h.whs.InCommunity(communityID)
+	}
+	if h.cs != nil {
+		c.cs = h.cs.InCommunity(communityID)
+	}
+	if h.ss != nil {
+		c.ss = h.ss.InCommunity(communityID)
+	}
+	if h.crs != nil {
+		c.crs = h.crs.InCommunity(communityID)
+	}
+	if h.rs != nil {
+		c.rs = h.rs.InCommunity(communityID)
+	}
+	if h.is != nil {
+		c.is = h.is.InCommunity(communityID)
+	}
+	if h.mds != nil {
+		c.mds = h.mds.InCommunity(communityID)
+	}
+	if h.hds != nil {
+		c.hds = h.hds.InCommunity(communityID)
+	}
+	return &c
+}
+
+// inScope returns a function which reports whether an event is about an item of the community
+// of the handler, which is the case of every event if the handler is not scoped.
+// Owners are only looked up once per stream.
+func (h *Handler) inScope() func(db.ItemEvent) bool {
+	if h.community == "" {
+		return func(db.ItemEvent) bool { return true }
+	}
+	owners := map[string]bool{}
+	return func(e db.ItemEvent) bool {
+		visible, ok := owners[e.OwnerID]
+		if !ok {
+			visible = h.us.Exists(e.OwnerID) == nil
+			owners[e.OwnerID] = visible
+		}
+		return visible
+	}
+}
+
+// GraphQL is invoked by HTTP POST /graphql.
+// The schema is only parsed once per community, as it is costly.
+func (h *Handler) GraphQL(w http.ResponseWriter, r *http.Request) {
+	gh, ok := h.graphqls.Load(h.community)
+	if !ok {
+		gh, _ = h.graphqls.LoadOrStore(h.community, graphql.NewHandler(h.bs, h.us, h.ms))
+	}
+	gh.(http.Handler).ServeHTTP(w, r)
+}
+
+// ListCommunities is invoked by HTTP GET /communities.
+func (h *Handler) ListCommunities(w http.ResponseWriter, r *http.Request) {
+	if h.cms == nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.Community]{
+			Error: errNoCommunities,
+		})
+		return
+	}
+	communities, err := h.cms.List()
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Community]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Community]{
+		Items: communities,
+	})
+}
+
+// CommunityUpsert is invoked by HTTP POST /communities.
+// The community is created or changed on behalf of the admin given by ?user=.
+func (h *Handler) CommunityUpsert(w http.ResponseWriter, r *http.Request) {
+	if h.cms == nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.Community]{
+			Error: errNoCommunities,
+		})
+		return
+	}
+	body, err := readRequestBody(r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Community]{
+			Error: fmt.Errorf("invalid community body:%v", err).Error(),
+		})
+		return
+	}
+	var c db.Community
+	if err := json.Unmarshal(body, &c); err != nil {
+		writeResponse(w, http.StatusUnprocessableEntity, &Response[db.Community]{
+			Error: fmt.Errorf("invalid community body:%v", err).Error(),
+		})
+		return
+	}
+
+	c, err = h.cms.Upsert(c, r.URL.Query().Get("user"))
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Community]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Community]{
+		Items: []db.Community{c},
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// encodings are the content codings which responses are compressed with, in order of preference.
+var encodings = []string{"gzip", "deflate"}
+
+// compress is a middleware which compresses responses with the content coding
+// preferred by the Accept-Encoding header of their request.
+// Images are already compressed and event streams are flushed event by event, so they are sent as they are.
+func compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Values("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, head: r.Method == http.MethodHead}
+		next.ServeHTTP(cw, r)
+		// The stream is not closed if the handler panics, so that aborted responses
+		// are not mistaken for complete ones.
+		cw.close()
+	})
+}
+
+// negotiateEncoding returns the content coding preferred by Accept-Encoding headers,
+// or an empty string if the response should not be compressed.
+func negotiateEncoding(headers []string) string {
+	ranges := parseAccept(strings.Join(headers, ","))
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		// A coding which is not listed takes the quality of the wildcard, if there is one.
+		q := 0.0
+		for _, r := range ranges {
+			if r.value == encoding {
+				q = r.q
+				break
+			}
+			if r.value == "*" {
+				q = r.q
+			}
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressWriter compresses a response once its headers show that it has a body worth compressing.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	head     bool
+	// w is the compressing writer, or nil if the response is not compressed.
+	w           io.WriteCloser
+	wroteHeader bool
+}
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	if c.compressible(status) {
+		h := c.Header()
+		h.Set("Content-Encoding", c.encoding)
+		h.Del("Content-Length")
+		if c.encoding == "gzip" {
+			c.w = gzip.NewWriter(c.ResponseWriter)
+		} else {
+			c.w, _ = flate.NewWriter(c.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		// The content type is sniffed before the body is compressed, as the server would do otherwise.
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.w == nil {
+		return c.ResponseWriter.Write(p)
+	}
+	return c.w.Write(p)
+}
+
+// Flush sends the data compressed so far to the client.
+func (c *compressWriter) Flush() {
+	if f, ok := c.w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// compressible returns whether a response of the given status is compressed.
+func (c *compressWriter) compressible(status int) bool {
+	if c.head || status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	h := c.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	return !strings.HasPrefix(contentType, "image/") && !strings.HasPrefix(contentType, "text/event-stream")
+}
+
+// close writes the end of the compressed stream.
+func (c *compressWriter) close() error {
+	if c.w == nil {
+		return nil
+	}
+	return c.w.Close()
+}
@@ -4,29 +4,93 @@ import (
 	"net/http"
 	"os"
 
+	_ "expvar"
 	_ "net/http/pprof"
 
 	"github.com/gorilla/mux"
 )
 
-// ConfigureServer configures the routes of this server and binds handler functions to them
+// ConfigureServer configures the routes of this server and binds handler functions to them.
+// Routes are scoped to the community of their request, apart from the deployment-wide communities
+// and admin API. The admin API and webhooks are only served to admins.
 func ConfigureServer(handler *Handler) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
 
-	router.Methods("GET").Path("/").Handler(http.HandlerFunc(handler.Index))
-	router.Methods("GET").Path("/books").Handler(http.HandlerFunc(handler.ListBooks))
-	router.Methods("POST").Path("/users").Handler(http.HandlerFunc(handler.UserUpsert))
-	router.Methods("GET").Path("/users/{id}/books").Handler(http.HandlerFunc(handler.ListUserByID_Books))
-	router.Methods("POST").Path("/books/{id}").Handler(http.HandlerFunc(handler.SwapBook))
-	router.Methods("POST").Path("/books").Handler(http.HandlerFunc(handler.BookUpsert))
-	router.Methods("GET").Path("/users/{id}/magazines").Handler(http.HandlerFunc(handler.ListUserByID_Magazines))
-	router.Methods("GET").Path("/magazines").Handler(http.HandlerFunc(handler.ListMagazines))
-	router.Methods("POST").Path("/magazines").Handler(http.HandlerFunc(handler.MagazineUpsert))
-	router.Methods("POST").Path("/magazines/{id}").Handler(http.HandlerFunc(handler.SwapMagazine))
+	router.Methods("GET").Path("/").Handler(handler.scoped((*Handler).Index))
+	router.Methods("GET").Path("/books").Handler(handler.scoped((*Handler).ListBooks))
+	router.Methods("GET").Path("/users").Handler(handler.scoped((*Handler).ListUsers))
+	router.Methods("POST").Path("/users").Handler(handler.scoped((*Handler).UserUpsert))
+	router.Methods("GET").Path("/users/{id}/books").Handler(handler.scoped((*Handler).ListUserByID_Books))
+	router.Methods("POST").Path("/books/{id}").Handler(handler.scoped((*Handler).SwapBook))
+	router.Methods("POST").Path("/books").Handler(handler.scoped((*Handler).BookUpsert))
+	router.Methods("GET").Path("/users/{id}/magazines").Handler(handler.scoped((*Handler).ListUserByID_Magazines))
+	router.Methods("GET").Path("/magazines").Handler(handler.scoped((*Handler).ListMagazines))
+	router.Methods("POST").Path("/magazines").Handler(handler.scoped((*Handler).MagazineUpsert))
+	router.Methods("POST").Path("/magazines/{id}").Handler(handler.scoped((*Handler).SwapMagazine))
+	router.Methods("POST").Path("/books/{id}/delivery").Handler(handler.scoped((*Handler).BookDelivery))
+	router.Methods("POST").Path("/books/{id}/relist").Handler(handler.scoped((*Handler).BookRelist))
+	router.Methods("POST").Path("/books/{id}/withdraw").Handler(handler.scoped((*Handler).BookWithdraw))
+	router.Methods("POST").Path("/magazines/{id}/delivery").Handler(handler.scoped((*Handler).MagazineDelivery))
+	router.Methods("POST").Path("/magazines/{id}/relist").Handler(handler.scoped((*Handler).MagazineRelist))
+	router.Methods("POST").Path("/magazines/{id}/withdraw").Handler(handler.scoped((*Handler).MagazineWithdraw))
+	router.Methods("GET").Path("/books/{id}").Handler(handler.scoped((*Handler).GetBook))
+	router.Methods("GET").Path("/books/{id}/history").Handler(handler.scoped((*Handler).BookHistory))
+	router.Methods("GET").Path("/books/{id}/quote").Handler(handler.scoped((*Handler).BookQuote))
+	router.Methods("GET").Path("/magazines/{id}").Handler(handler.scoped((*Handler).GetMagazine))
+	router.Methods("GET").Path("/magazines/{id}/history").Handler(handler.scoped((*Handler).MagazineHistory))
+	router.Methods("GET").Path("/users/{id}/history").Handler(handler.scoped((*Handler).UserHistory))
+	router.Methods("GET").Path("/users/{id}/wishlist").Handler(handler.scoped((*Handler).ListWishlist))
+	router.Methods("POST").Path("/users/{id}/wishlist").Handler(handler.scoped((*Handler).WishlistAdd))
+	router.Methods("DELETE").Path("/users/{id}/wishlist/{itemID}").Handler(handler.scoped((*Handler).WishlistRemove))
+	router.Methods("GET").Path("/users/{id}/notifications").Handler(handler.scoped((*Handler).ListNotifications))
+	router.Methods("GET").Path("/users/{id}/credits").Handler(handler.scoped((*Handler).GetCredits))
+	router.Methods("POST").Path("/users/{id}/credits").Handler(handler.scoped((*Handler).CreditAdjust))
+	router.Methods("POST").Path("/books/{id}/reviews").Handler(handler.scoped((*Handler).BookReview))
+	router.Methods("POST").Path("/magazines/{id}/reviews").Handler(handler.scoped((*Handler).MagazineReview))
+	router.Methods("GET").Path("/users/{id}/reviews").Handler(handler.scoped((*Handler).ListUserReviews))
+	router.Methods("POST").Path("/reviews/{id}/flag").Handler(handler.scoped((*Handler).ReviewFlag))
+	router.Methods("POST").Path("/reports").Handler(handler.scoped((*Handler).ReportCreate))
+	router.Methods("POST").Path("/books/{id}/hold").Handler(handler.scoped((*Handler).BookHold))
+	router.Methods("POST").Path("/magazines/{id}/hold").Handler(handler.scoped((*Handler).MagazineHold))
+	router.Methods("GET").Path("/users/{id}/holds").Handler(handler.scoped((*Handler).ListUserHolds))
+	router.Methods("POST").Path("/holds/{id}/release").Handler(handler.scoped((*Handler).HoldRelease))
+	router.Methods("POST").Path("/holds/{id}/swap").Handler(handler.scoped((*Handler).HoldSwap))
+	router.Methods("POST").Path("/books/{id}/images").Handler(handler.scoped((*Handler).BookImageUpload))
+	router.Methods("POST").Path("/magazines/{id}/images").Handler(handler.scoped((*Handler).MagazineImageUpload))
+	router.Methods("GET").Path("/images/{key}").Handler(handler.scoped((*Handler).GetImage))
+	router.Methods("POST").Path("/graphql").Handler(handler.scoped((*Handler).GraphQL))
+	router.Methods("GET").Path("/events").Handler(handler.scoped((*Handler).Events))
+	router.Methods("GET").Path("/webhooks").Handler(handler.admin(handler.scoped((*Handler).ListWebhooks).ServeHTTP))
+	router.Methods("POST").Path("/webhooks").Handler(handler.admin(handler.scoped((*Handler).WebhookCreate).ServeHTTP))
+	router.Methods("GET").Path("/webhooks/{id}").Handler(handler.admin(handler.scoped((*Handler).GetWebhook).ServeHTTP))
+	router.Methods("DELETE").Path("/webhooks/{id}").Handler(handler.admin(handler.scoped((*Handler).WebhookDelete).ServeHTTP))
+	router.Methods("GET").Path("/webhooks/{id}/deliveries").Handler(handler.admin(handler.scoped((*Handler).ListWebhookDeliveries).ServeHTTP))
+	router.Methods("POST").Path("/webhooks/{id}/deliveries/{deliveryID}/replay").Handler(handler.admin(handler.scoped((*Handler).WebhookReplay).ServeHTTP))
+	router.Methods("POST").Path("/import").Handler(handler.scoped((*Handler).Import))
+	router.Methods("GET").Path("/export").Handler(handler.scoped((*Handler).Export))
+	router.Methods("GET").Path("/communities").Handler(http.HandlerFunc(handler.ListCommunities))
+	router.Methods("POST").Path("/communities").Handler(http.HandlerFunc(handler.CommunityUpsert))
+	router.Methods("GET").Path("/admin/reports").Handler(handler.admin(handler.ListReports))
+	router.Methods("POST").Path("/admin/reports/{id}/resolve").Handler(handler.admin(handler.ReportResolve))
+	router.Methods("GET").Path("/admin/books").Handler(handler.admin(handler.AdminListBooks))
+	router.Methods("POST").Path("/admin/books/{id}/flag").Handler(handler.admin(handler.BookFlag))
+	router.Methods("POST").Path("/admin/books/{id}/unflag").Handler(handler.admin(handler.BookUnflag))
+	router.Methods("POST").Path("/admin/books/{id}/remove").Handler(handler.admin(handler.BookRemove))
+	router.Methods("GET").Path("/admin/magazines").Handler(handler.admin(handler.AdminListMagazines))
+	router.Methods("POST").Path("/admin/magazines/{id}/flag").Handler(handler.admin(handler.MagazineFlag))
+	router.Methods("POST").Path("/admin/magazines/{id}/unflag").Handler(handler.admin(handler.MagazineUnflag))
+	router.Methods("POST").Path("/admin/magazines/{id}/remove").Handler(handler.admin(handler.MagazineRemove))
+	router.Methods("POST").Path("/admin/users/{id}/suspend").Handler(handler.admin(handler.UserSuspend))
+	router.Methods("POST").Path("/admin/users/{id}/reactivate").Handler(handler.admin(handler.UserReactivate))
+	router.Methods("GET").Path("/admin/actions").Handler(handler.admin(handler.ListAdminActions))
+	router.Methods("GET").Path("/openapi.json").Handler(http.HandlerFunc(handler.OpenAPI))
+	router.Use(compress)
 
 	if os.Getenv("DEBUG") != "" {
 		router.PathPrefix("/debug/pprof/").
 			Handler(http.DefaultServeMux)
+		router.Path("/debug/vars").
+			Handler(http.DefaultServeMux)
 	}
 	return router
 }
@@ -2,32 +2,91 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/events"
 	"github.com/gorilla/mux"
 )
 
+const (
+	// eventsPageSize is how many missed events are read from the ledger at a time when a stream resumes.
+	eventsPageSize = 100
+	// eventsHeartbeat is how often idle streams are sent a comment, to keep them open through proxies.
+	eventsHeartbeat = 15 * time.Second
+	// defaultImportBatch is how many imported rows are created per transaction, unless a batch size is given.
+	defaultImportBatch = 100
+	// maxImportBatch limits the batch size of imports.
+	maxImportBatch = 1000
+	// maxImportSize limits the size of an imported file.
+	maxImportSize = 32 << 20
+)
+
 // Handler contains the handler and all its dependencies.
 type Handler struct {
-	bs *db.BookService
-	us *db.UserService
-	ms *db.MagazineService
+	bs  *db.BookService
+	us  *db.UserService
+	ms  *db.MagazineService
+	hs  *db.HistoryService
+	ws  *db.WishlistService
+	ns  *db.NotificationService
+	whs *db.WebhookService
+	cs  *db.CatalogueService
+	ss  *db.ShippingService
+	crs *db.CreditService
+	rs  *db.ReviewService
+	is  *db.ImageService
+	cms *db.CommunityService
+	mds *db.ModerationService
+	hds *db.HoldService
+	eb  *events.Broker
+	// community is the community the services are scoped to, if they are scoped to one.
+	community string
+	// graphqls holds the GraphQL handler of each community, shared by the copies of the handler.
+	graphqls *sync.Map
 }
 
 // NewHandler initialises a new handler, given dependencies.
-func NewHandler(bs *db.BookService, us *db.UserService, ms *db.MagazineService) *Handler {
+func NewHandler(bs *db.BookService, us *db.UserService, ms *db.MagazineService,
+	hs *db.HistoryService, ws *db.WishlistService, ns *db.NotificationService,
+	whs *db.WebhookService, cs *db.CatalogueService, ss *db.ShippingService, crs *db.CreditService,
+	rs *db.ReviewService, is *db.ImageService, cms *db.CommunityService, mds *db.ModerationService,
+	hds *db.HoldService, eb *events.Broker) *Handler {
 	return &Handler{
-		bs: bs,
-		us: us,
-		ms: ms,
+		bs:  bs,
+		us:  us,
+		ms:  ms,
+		hs:  hs,
+		ws:  ws,
+		ns:  ns,
+		whs: whs,
+		cs:  cs,
+		ss:  ss,
+		crs: crs,
+		rs:  rs,
+		is:  is,
+		cms: cms,
+		mds: mds,
+		hds: hds,
+		eb:  eb,
+
+		graphqls: &sync.Map{},
 	}
 }
 
 // Index is invoked by HTTP GET /.
 func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.Book](w, r)
+	if !ok {
+		return
+	}
 	books, err := h.bs.List()
 	if err != nil {
 		writeResponse(w, http.StatusInternalServerError, &Response[db.Book]{
@@ -41,12 +100,32 @@ func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
 		Message: "Welcome to the BookSwap service!",
 		Items:   books,
 	}
-	writeResponse(w, http.StatusOK, resp)
+	writeList(w, contentType, resp)
 }
 
 // ListBooks is invoked by HTTP GET /books.
+// The books are optionally filtered by the ?isbn= of their edition,
+// to those which can be shipped to the user given by ?ship_to=
+// and to those whose owners have an average rating of at least ?min_rating=.
 func (h *Handler) ListBooks(w http.ResponseWriter, r *http.Request) {
-	books, err := h.bs.List()
+	contentType, ok := negotiateList[db.Book](w, r)
+	if !ok {
+		return
+	}
+	owner := func(b db.Book) string { return b.OwnerID }
+	books, err := h.listBooks(r.URL.Query().Get("isbn"))
+	if err == nil {
+		books, err = shippable(h.ss, r.URL.Query().Get("ship_to"), books, owner)
+	}
+	if err == nil {
+		books, err = rated(h.rs, r.URL.Query().Get("min_rating"), books, owner)
+	}
+	if errors.Is(err, db.ErrInvalidInput) || errors.Is(err, db.ErrRecordNotFound) {
+		writeResponse(w, http.StatusBadRequest, &Response[db.Book]{
+			Error: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		writeResponse(w, http.StatusInternalServerError, &Response[db.Book]{
 			Error: err.Error(),
@@ -55,14 +134,122 @@ func (h *Handler) ListBooks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send an HTTP status & the list of books
-	writeResponse(w, http.StatusOK, &Response[db.Book]{
+	writeList(w, contentType, &Response[db.Book]{
 		Items: books,
 	})
 }
 
+// listBooks is a helper method that returns the available books, of the given ISBN if it is not empty.
+func (h *Handler) listBooks(isbn string) ([]db.Book, error) {
+	if isbn == "" {
+		return h.bs.List()
+	}
+	books, err := h.bs.ListByISBN(isbn)
+	if err != nil {
+		return nil, err
+	}
+	available := make([]db.Book, 0, len(books))
+	for _, b := range books {
+		if b.Status == db.Available {
+			available = append(available, b)
+		}
+	}
+	return available, nil
+}
+
+// shippable is a helper function that filters items to those whose owners can post them to a user.
+// Items are not filtered if the user ID is empty.
+func shippable[T any](ss *db.ShippingService, userID string, items []T, owner func(T) string) ([]T, error) {
+	if userID == "" {
+		return items, nil
+	}
+	ownerIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		ownerIDs = append(ownerIDs, owner(item))
+	}
+	owners, err := ss.ShippableTo(userID, ownerIDs)
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if owners[owner(item)] {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
+
+// rated is a helper function that filters items to those whose owners have at least a minimum average rating.
+// Items are not filtered if the minimum is empty, while owners without reviews are always filtered out.
+func rated[T any](rs *db.ReviewService, minRating string, items []T, owner func(T) string) ([]T, error) {
+	if minRating == "" {
+		return items, nil
+	}
+	min, err := strconv.ParseFloat(minRating, 64)
+	if err != nil || min < db.MinRating || min > db.MaxRating {
+		return nil, fmt.Errorf("%w: min_rating must be a number between %d and %d", db.ErrInvalidInput,
+			db.MinRating, db.MaxRating)
+	}
+	ownerIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		ownerIDs = append(ownerIDs, owner(item))
+	}
+	reps, err := rs.Reputations(ownerIDs)
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if rep, ok := reps[owner(item)]; ok && rep.Average >= min {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
+
+// ListUsers is invoked by HTTP GET /users.
+func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.User](w, r)
+	if !ok {
+		return
+	}
+	users, err := h.us.List()
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.User]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	// Send an HTTP status & the list of users
+	writeList(w, contentType, &Response[db.User]{
+		Items: users,
+	})
+}
+
 // ListMagazines is invoked by HTTP GET /magazines.
+// The magazines are optionally filtered to those which can be shipped to the user given by ?ship_to=
+// and to those whose owners have an average rating of at least ?min_rating=.
 func (h *Handler) ListMagazines(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.Magazine](w, r)
+	if !ok {
+		return
+	}
+	owner := func(m db.Magazine) string { return m.OwnerID }
 	mags, err := h.ms.List()
+	if err == nil {
+		mags, err = shippable(h.ss, r.URL.Query().Get("ship_to"), mags, owner)
+	}
+	if err == nil {
+		mags, err = rated(h.rs, r.URL.Query().Get("min_rating"), mags, owner)
+	}
+	if errors.Is(err, db.ErrInvalidInput) || errors.Is(err, db.ErrRecordNotFound) {
+		writeResponse(w, http.StatusBadRequest, &Response[db.Magazine]{
+			Error: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		writeResponse(w, http.StatusInternalServerError, &Response[db.Magazine]{
 			Error: err.Error(),
@@ -71,7 +258,7 @@ func (h *Handler) ListMagazines(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send an HTTP status & the list of mags
-	writeResponse(w, http.StatusOK, &Response[db.Magazine]{
+	writeList(w, contentType, &Response[db.Magazine]{
 		Items: mags,
 	})
 }
@@ -125,8 +312,9 @@ func (h *Handler) ListUserByID_Books(w http.ResponseWriter, r *http.Request) {
 
 	// Send an HTTP success status & the return value from the repo
 	writeResponse(w, http.StatusOK, &Response[db.Book]{
-		User:  &userProfile.User,
-		Items: userProfile.Books,
+		User:       &userProfile.User,
+		Reputation: &userProfile.Reputation,
+		Items:      userProfile.Books,
 	})
 }
 
@@ -143,8 +331,9 @@ func (h *Handler) ListUserByID_Magazines(w http.ResponseWriter, r *http.Request)
 
 	// Send an HTTP success status & the return value from the repo
 	writeResponse(w, http.StatusOK, &Response[db.Magazine]{
-		User:  &userProfile.User,
-		Items: userProfile.Magazines,
+		User:       &userProfile.User,
+		Reputation: &userProfile.Reputation,
+		Items:      userProfile.Magazines,
 	})
 }
 
@@ -160,7 +349,7 @@ func (h *Handler) SwapBook(w http.ResponseWriter, r *http.Request) {
 	}
 	_, err := h.bs.SwapBook(bookID, userID)
 	if err != nil {
-		writeResponse(w, http.StatusNotFound, &Response[db.Book]{
+		writeResponse(w, errorStatus(err), &Response[db.Book]{
 			Error: err.Error(),
 		})
 		return
@@ -175,8 +364,9 @@ func (h *Handler) SwapBook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeResponse(w, http.StatusOK, &Response[db.Book]{
-		User:  &userProfile.User,
-		Items: userProfile.Books,
+		User:       &userProfile.User,
+		Reputation: &userProfile.Reputation,
+		Items:      userProfile.Books,
 	})
 }
 
@@ -192,7 +382,105 @@ func (h *Handler) SwapMagazine(w http.ResponseWriter, r *http.Request) {
 	}
 	_, err := h.ms.SwapMagazine(magID, userID)
 	if err != nil {
-		writeResponse(w, http.StatusNotFound, &Response[db.Magazine]{
+		writeResponse(w, errorStatus(err), &Response[db.Magazine]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	userProfile, err := h.us.Get(userID)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Magazine]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Magazine]{
+		User:       &userProfile.User,
+		Reputation: &userProfile.Reputation,
+		Items:      userProfile.Magazines,
+	})
+}
+
+// BookDelivery is invoked by POST /books/{id}/delivery.
+func (h *Handler) BookDelivery(w http.ResponseWriter, r *http.Request) {
+	h.bookTransition(w, r, h.bs.ConfirmDelivery)
+}
+
+// BookRelist is invoked by POST /books/{id}/relist.
+func (h *Handler) BookRelist(w http.ResponseWriter, r *http.Request) {
+	h.bookTransition(w, r, h.bs.Relist)
+}
+
+// BookWithdraw is invoked by POST /books/{id}/withdraw.
+func (h *Handler) BookWithdraw(w http.ResponseWriter, r *http.Request) {
+	h.bookTransition(w, r, h.bs.Withdraw)
+}
+
+// bookTransition moves a book through its lifecycle on behalf of its owner
+// and writes the owner's books.
+func (h *Handler) bookTransition(w http.ResponseWriter, r *http.Request,
+	op func(bookID, userID string) (*db.Book, error)) {
+	bookID := mux.Vars(r)["id"]
+	userID := r.URL.Query().Get("user")
+	if err := h.us.Exists(userID); err != nil {
+		writeResponse(w, http.StatusBadRequest, &Response[db.Book]{
+			Error: err.Error(),
+		})
+		return
+	}
+	if _, err := op(bookID, userID); err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Book]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	userProfile, err := h.us.Get(userID)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Book]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Book]{
+		User:       &userProfile.User,
+		Reputation: &userProfile.Reputation,
+		Items:      userProfile.Books,
+	})
+}
+
+// MagazineDelivery is invoked by POST /magazines/{id}/delivery.
+func (h *Handler) MagazineDelivery(w http.ResponseWriter, r *http.Request) {
+	h.magazineTransition(w, r, h.ms.ConfirmDelivery)
+}
+
+// MagazineRelist is invoked by POST /magazines/{id}/relist.
+func (h *Handler) MagazineRelist(w http.ResponseWriter, r *http.Request) {
+	h.magazineTransition(w, r, h.ms.Relist)
+}
+
+// MagazineWithdraw is invoked by POST /magazines/{id}/withdraw.
+func (h *Handler) MagazineWithdraw(w http.ResponseWriter, r *http.Request) {
+	h.magazineTransition(w, r, h.ms.Withdraw)
+}
+
+// magazineTransition moves a magazine through its lifecycle on behalf of its owner
+// and writes the owner's magazines.
+func (h *Handler) magazineTransition(w http.ResponseWriter, r *http.Request,
+	op func(magID, userID string) (*db.Magazine, error)) {
+	magID := mux.Vars(r)["id"]
+	userID := r.URL.Query().Get("user")
+	if err := h.us.Exists(userID); err != nil {
+		writeResponse(w, http.StatusBadRequest, &Response[db.Magazine]{
+			Error: err.Error(),
+		})
+		return
+	}
+	if _, err := op(magID, userID); err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Magazine]{
 			Error: err.Error(),
 		})
 		return
@@ -207,8 +495,9 @@ func (h *Handler) SwapMagazine(w http.ResponseWriter, r *http.Request) {
 	}
 
 	writeResponse(w, http.StatusOK, &Response[db.Magazine]{
-		User:  &userProfile.User,
-		Items: userProfile.Magazines,
+		User:       &userProfile.User,
+		Reputation: &userProfile.Reputation,
+		Items:      userProfile.Magazines,
 	})
 }
 
@@ -240,13 +529,49 @@ func (h *Handler) BookUpsert(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the repository method corresponding to the operation
-	updatedBook := h.bs.Upsert(book)
+	updatedBook, err := h.bs.Upsert(book)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Book]{
+			Error: err.Error(),
+		})
+		return
+	}
+	msg, err := h.duplicateHint(updatedBook)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Book]{
+			Error: err.Error(),
+		})
+		return
+	}
 	// Send an HTTP success status & the return value from the repo
 	writeResponse(w, http.StatusOK, &Response[db.Book]{
-		Items: []db.Book{updatedBook},
+		Message: msg,
+		Items:   []db.Book{updatedBook},
 	})
 }
 
+// duplicateHint is a helper method that tells about the other books of the same edition as b,
+// which may be duplicates of it.
+func (h *Handler) duplicateHint(b db.Book) (string, error) {
+	if b.ISBN == "" {
+		return "", nil
+	}
+	books, err := h.bs.ListByISBN(b.ISBN)
+	if err != nil {
+		return "", err
+	}
+	var ids []string
+	for _, other := range books {
+		if other.ID != b.ID {
+			ids = append(ids, other.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("other books have ISBN %s: %s", b.ISBN, strings.Join(ids, ", ")), nil
+}
+
 // MagazineUpsert is invoked by HTTP POST /magazines.
 func (h *Handler) MagazineUpsert(w http.ResponseWriter, r *http.Request) {
 	// Read the request body
@@ -275,13 +600,940 @@ func (h *Handler) MagazineUpsert(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the repository method corresponding to the operation
-	updatedMag := h.ms.Upsert(mag)
+	updatedMag, err := h.ms.Upsert(mag)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Magazine]{
+			Error: err.Error(),
+		})
+		return
+	}
 	// Send an HTTP success status & the return value from the repo
 	writeResponse(w, http.StatusOK, &Response[db.Magazine]{
 		Items: []db.Magazine{updatedMag},
 	})
 }
 
+// GetBook is invoked by HTTP GET /books/{id}.
+// The optional at query parameter returns the state of the book at that point in time.
+func (h *Handler) GetBook(w http.ResponseWriter, r *http.Request) {
+	bookID := mux.Vars(r)["id"]
+	at, err := parseAt(r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, &Response[db.Book]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var book *db.Book
+	if at == nil {
+		book, err = h.bs.Get(bookID)
+	} else {
+		book, err = h.hs.BookAt(bookID, *at)
+	}
+	if err != nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.Book]{
+			Error: fmt.Errorf("no book found for id %s:%v", bookID, err).Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Book]{
+		Items: []db.Book{*book},
+	})
+}
+
+// BookQuote is invoked by HTTP GET /books/{id}/quote.
+// It quotes the cost and delivery time of posting the book to the user given by ?user=.
+func (h *Handler) BookQuote(w http.ResponseWriter, r *http.Request) {
+	bookID := mux.Vars(r)["id"]
+	userID := r.URL.Query().Get("user")
+	if userID == "" {
+		writeResponse(w, http.StatusBadRequest, &Response[db.ShippingQuote]{
+			Error: "missing user query parameter",
+		})
+		return
+	}
+
+	quote, err := h.ss.QuoteBook(bookID, userID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, db.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, db.ErrInvalidInput):
+			status = http.StatusBadRequest
+		case errors.Is(err, db.ErrNotShippable):
+			status = http.StatusUnprocessableEntity
+		}
+		writeResponse(w, status, &Response[db.ShippingQuote]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.ShippingQuote]{
+		Items: []db.ShippingQuote{*quote},
+	})
+}
+
+// GetMagazine is invoked by HTTP GET /magazines/{id}.
+// The optional at query parameter returns the state of the magazine at that point in time.
+func (h *Handler) GetMagazine(w http.ResponseWriter, r *http.Request) {
+	magID := mux.Vars(r)["id"]
+	at, err := parseAt(r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, &Response[db.Magazine]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var mag *db.Magazine
+	if at == nil {
+		mag, err = h.ms.Get(magID)
+	} else {
+		mag, err = h.hs.MagazineAt(magID, *at)
+	}
+	if err != nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.Magazine]{
+			Error: fmt.Errorf("no magazine found for id %s:%v", magID, err).Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Magazine]{
+		Items: []db.Magazine{*mag},
+	})
+}
+
+// BookHistory is invoked by HTTP GET /books/{id}/history.
+func (h *Handler) BookHistory(w http.ResponseWriter, r *http.Request) {
+	h.itemHistory(w, r, db.BookItem, mux.Vars(r)["id"])
+}
+
+// MagazineHistory is invoked by HTTP GET /magazines/{id}/history.
+func (h *Handler) MagazineHistory(w http.ResponseWriter, r *http.Request) {
+	h.itemHistory(w, r, db.MagazineItem, mux.Vars(r)["id"])
+}
+
+// itemHistory writes the history of a given item, oldest event first.
+func (h *Handler) itemHistory(w http.ResponseWriter, r *http.Request, itemType db.ItemType, itemID string) {
+	contentType, ok := negotiateList[db.ItemEvent](w, r)
+	if !ok {
+		return
+	}
+	events, err := h.hs.ListByItem(itemType, itemID)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.ItemEvent]{
+			Error: err.Error(),
+		})
+		return
+	}
+	if len(events) == 0 {
+		writeResponse(w, http.StatusNotFound, &Response[db.ItemEvent]{
+			Error: fmt.Sprintf("no history found for id %s", itemID),
+		})
+		return
+	}
+
+	writeList(w, contentType, &Response[db.ItemEvent]{
+		Items: events,
+	})
+}
+
+// UserHistory is invoked by HTTP GET /users/{id}/history.
+func (h *Handler) UserHistory(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.ItemEvent](w, r)
+	if !ok {
+		return
+	}
+	userID := mux.Vars(r)["id"]
+	if err := h.us.Exists(userID); err != nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.ItemEvent]{
+			Error: err.Error(),
+		})
+		return
+	}
+	events, err := h.hs.ListByUser(userID)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.ItemEvent]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeList(w, contentType, &Response[db.ItemEvent]{
+		Items: events,
+	})
+}
+
+// ListWishlist is invoked by HTTP GET /users/{id}/wishlist.
+func (h *Handler) ListWishlist(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.WishlistItem](w, r)
+	if !ok {
+		return
+	}
+	userID := mux.Vars(r)["id"]
+	if err := h.us.Exists(userID); err != nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.WishlistItem]{
+			Error: err.Error(),
+		})
+		return
+	}
+	items, err := h.ws.ListByUser(userID)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.WishlistItem]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeList(w, contentType, &Response[db.WishlistItem]{
+		Items: items,
+	})
+}
+
+// WishlistAdd is invoked by HTTP POST /users/{id}/wishlist.
+func (h *Handler) WishlistAdd(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["id"]
+	if err := h.us.Exists(userID); err != nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.WishlistItem]{
+			Error: err.Error(),
+		})
+		return
+	}
+	// Read the request body
+	body, err := readRequestBody(r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.WishlistItem]{
+			Error: fmt.Errorf("invalid wishlist body:%v", err).Error(),
+		})
+		return
+	}
+
+	// Initialize a wishlist item to unmarshal request body into
+	var item db.WishlistItem
+	if err := json.Unmarshal(body, &item); err != nil {
+		writeResponse(w, http.StatusUnprocessableEntity, &Response[db.WishlistItem]{
+			Error: fmt.Errorf("invalid wishlist body:%v", err).Error(),
+		})
+		return
+	}
+	item.UserID = userID
+	item, err = h.ws.Add(item)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.WishlistItem]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.WishlistItem]{
+		Items: []db.WishlistItem{item},
+	})
+}
+
+// WishlistRemove is invoked by HTTP DELETE /users/{id}/wishlist/{itemID}.
+func (h *Handler) WishlistRemove(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := h.ws.Remove(vars["itemID"], vars["id"]); err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.WishlistItem]{
+			Error: err.Error(),
+		})
+		return
+	}
+	items, err := h.ws.ListByUser(vars["id"])
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.WishlistItem]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.WishlistItem]{
+		Items: items,
+	})
+}
+
+// ListNotifications is invoked by HTTP GET /users/{id}/notifications.
+func (h *Handler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.Notification](w, r)
+	if !ok {
+		return
+	}
+	userID := mux.Vars(r)["id"]
+	if err := h.us.Exists(userID); err != nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.Notification]{
+			Error: err.Error(),
+		})
+		return
+	}
+	items, err := h.ns.ListByUser(userID)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Notification]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeList(w, contentType, &Response[db.Notification]{
+		Items: items,
+	})
+}
+
+// GetCredits is invoked by HTTP GET /users/{id}/credits.
+func (h *Handler) GetCredits(w http.ResponseWriter, r *http.Request) {
+	account, err := h.crs.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.CreditAccount]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.CreditAccount]{
+		Items: []db.CreditAccount{*account},
+	})
+}
+
+// CreditAdjust is invoked by HTTP POST /users/{id}/credits.
+// The admin making the adjustment is given by ?user=.
+func (h *Handler) CreditAdjust(w http.ResponseWriter, r *http.Request) {
+	body, err := readRequestBody(r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.CreditAccount]{
+			Error: fmt.Errorf("invalid adjustment body:%v", err).Error(),
+		})
+		return
+	}
+	var adj db.CreditAdjustment
+	if err := json.Unmarshal(body, &adj); err != nil {
+		writeResponse(w, http.StatusUnprocessableEntity, &Response[db.CreditAccount]{
+			Error: fmt.Errorf("invalid adjustment body:%v", err).Error(),
+		})
+		return
+	}
+
+	account, err := h.crs.Adjust(mux.Vars(r)["id"], r.URL.Query().Get("user"), adj)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.CreditAccount]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.CreditAccount]{
+		Items: []db.CreditAccount{*account},
+	})
+}
+
+// BookReview is invoked by HTTP POST /books/{id}/reviews.
+// The reviewer is given by ?user= and must have taken part in a delivered swap of the book.
+func (h *Handler) BookReview(w http.ResponseWriter, r *http.Request) {
+	h.itemReview(w, r, db.BookItem)
+}
+
+// MagazineReview is invoked by HTTP POST /magazines/{id}/reviews.
+// The reviewer is given by ?user= and must have taken part in a delivered swap of the magazine.
+func (h *Handler) MagazineReview(w http.ResponseWriter, r *http.Request) {
+	h.itemReview(w, r, db.MagazineItem)
+}
+
+// itemReview is a helper method that reviews the other side of the latest swap of an item.
+func (h *Handler) itemReview(w http.ResponseWriter, r *http.Request, itemType db.ItemType) {
+	body, err := readRequestBody(r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Review]{
+			Error: fmt.Errorf("invalid review body:%v", err).Error(),
+		})
+		return
+	}
+	var review db.Review
+	if err := json.Unmarshal(body, &review); err != nil {
+		writeResponse(w, http.StatusUnprocessableEntity, &Response[db.Review]{
+			Error: fmt.Errorf("invalid review body:%v", err).Error(),
+		})
+		return
+	}
+
+	added, err := h.rs.Add(itemType, mux.Vars(r)["id"], r.URL.Query().Get("user"), review)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Review]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Review]{
+		Items: []db.Review{*added},
+	})
+}
+
+// ListUserReviews is invoked by HTTP GET /users/{id}/reviews.
+func (h *Handler) ListUserReviews(w http.ResponseWriter, r *http.Request) {
+	userProfile, err := h.us.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.Review]{
+			Error: err.Error(),
+		})
+		return
+	}
+	reviews, err := h.rs.ListByUser(userProfile.User.ID)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Review]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Review]{
+		User:       &userProfile.User,
+		Reputation: &userProfile.Reputation,
+		Items:      reviews,
+	})
+}
+
+// ReviewFlag is invoked by HTTP POST /reviews/{id}/flag.
+// The user flagging the review for moderation is given by ?user=.
+func (h *Handler) ReviewFlag(w http.ResponseWriter, r *http.Request) {
+	body, err := readRequestBody(r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Review]{
+			Error: fmt.Errorf("invalid flag body:%v", err).Error(),
+		})
+		return
+	}
+	var flag db.ReviewFlag
+	if err := json.Unmarshal(body, &flag); err != nil {
+		writeResponse(w, http.StatusUnprocessableEntity, &Response[db.Review]{
+			Error: fmt.Errorf("invalid flag body:%v", err).Error(),
+		})
+		return
+	}
+
+	review, err := h.rs.Flag(mux.Vars(r)["id"], r.URL.Query().Get("user"), flag)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Review]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Review]{
+		Items: []db.Review{*review},
+	})
+}
+
+// BookImageUpload is invoked by HTTP POST /books/{id}/images.
+// The body is the image itself, uploaded by the owner of the book given by ?user=.
+func (h *Handler) BookImageUpload(w http.ResponseWriter, r *http.Request) {
+	data, err := readImageBody(r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Book]{
+			Error: fmt.Errorf("invalid image body:%v", err).Error(),
+		})
+		return
+	}
+
+	book, err := h.is.AddBookImage(mux.Vars(r)["id"], r.URL.Query().Get("user"), data)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Book]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Book]{
+		Items: []db.Book{*book},
+	})
+}
+
+// MagazineImageUpload is invoked by HTTP POST /magazines/{id}/images.
+// The body is the image itself, uploaded by the owner of the magazine given by ?user=.
+func (h *Handler) MagazineImageUpload(w http.ResponseWriter, r *http.Request) {
+	data, err := readImageBody(r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Magazine]{
+			Error: fmt.Errorf("invalid image body:%v", err).Error(),
+		})
+		return
+	}
+
+	mag, err := h.is.AddMagazineImage(mux.Vars(r)["id"], r.URL.Query().Get("user"), data)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Magazine]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Magazine]{
+		Items: []db.Magazine{*mag},
+	})
+}
+
+// GetImage is invoked by HTTP GET /images/{key}.
+// Images and thumbnails never change once uploaded, so they can be cached for good.
+func (h *Handler) GetImage(w http.ResponseWriter, r *http.Request) {
+	rc, contentType, err := h.is.Open(mux.Vars(r)["key"])
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Book]{
+			Error: err.Error(),
+		})
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, rc)
+}
+
+// ListWebhooks is invoked by HTTP GET /webhooks.
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.WebhookSubscription](w, r)
+	if !ok {
+		return
+	}
+	items, err := h.whs.List()
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.WebhookSubscription]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeList(w, contentType, &Response[db.WebhookSubscription]{
+		Items: items,
+	})
+}
+
+// WebhookCreate is invoked by HTTP POST /webhooks.
+func (h *Handler) WebhookCreate(w http.ResponseWriter, r *http.Request) {
+	// Read the request body
+	body, err := readRequestBody(r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.WebhookSubscription]{
+			Error: fmt.Errorf("invalid webhook body:%v", err).Error(),
+		})
+		return
+	}
+
+	// Initialize a subscription to unmarshal request body into
+	var s db.WebhookSubscription
+	if err := json.Unmarshal(body, &s); err != nil {
+		writeResponse(w, http.StatusUnprocessableEntity, &Response[db.WebhookSubscription]{
+			Error: fmt.Errorf("invalid webhook body:%v", err).Error(),
+		})
+		return
+	}
+	s, err = h.whs.Create(s)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.WebhookSubscription]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.WebhookSubscription]{
+		Message: "Keep the secret safe, it will not be shown again.",
+		Items:   []db.WebhookSubscription{s},
+	})
+}
+
+// GetWebhook is invoked by HTTP GET /webhooks/{id}.
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	s, err := h.whs.Get(mux.Vars(r)["id"])
+	if err != nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.WebhookSubscription]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.WebhookSubscription]{
+		Items: []db.WebhookSubscription{*s},
+	})
+}
+
+// WebhookDelete is invoked by HTTP DELETE /webhooks/{id}.
+func (h *Handler) WebhookDelete(w http.ResponseWriter, r *http.Request) {
+	if err := h.whs.Delete(mux.Vars(r)["id"]); err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.WebhookSubscription]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.WebhookSubscription]{
+		Message: "Webhook deleted.",
+	})
+}
+
+// ListWebhookDeliveries is invoked by HTTP GET /webhooks/{id}/deliveries.
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.WebhookDelivery](w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if _, err := h.whs.Get(id); err != nil {
+		writeResponse(w, http.StatusNotFound, &Response[db.WebhookDelivery]{
+			Error: err.Error(),
+		})
+		return
+	}
+	items, err := h.whs.ListDeliveries(id)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.WebhookDelivery]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeList(w, contentType, &Response[db.WebhookDelivery]{
+		Items: items,
+	})
+}
+
+// WebhookReplay is invoked by HTTP POST /webhooks/{id}/deliveries/{deliveryID}/replay.
+func (h *Handler) WebhookReplay(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	d, err := h.whs.Replay(vars["id"], vars["deliveryID"])
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.WebhookDelivery]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.WebhookDelivery]{
+		Items: []db.WebhookDelivery{d},
+	})
+}
+
+// Import is invoked by HTTP POST /import. It reads books and magazines as CSV or JSON Lines,
+// depending on the Content-Type, and imports them in batches of the ?batch= size, each in a transaction.
+// Every row is reported with the ID of its new item or with the reason it was not imported.
+// With ?dry_run=true the rows are only validated.
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	dryRun, batchSize, err := parseImportOptions(r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, &Response[db.ImportResult]{
+			Error: err.Error(),
+		})
+		return
+	}
+	rows, err := newRowReader(r.Header.Get("Content-Type"), http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, &Response[db.ImportResult]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	// Rows are imported as they are read, so that the file is never held in memory.
+	var results []db.ImportResult
+	batch := make([]db.ImportRow, 0, batchSize)
+	for {
+		row, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The batches before the error have been imported and are reported, the rest is not imported.
+			writeResponse(w, http.StatusBadRequest, &Response[db.ImportResult]{
+				Error: fmt.Errorf("invalid import body:%v", err).Error(),
+				Items: results,
+			})
+			return
+		}
+		batch = append(batch, row)
+		if len(batch) == batchSize {
+			results = append(results, h.cs.Import(batch, dryRun)...)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		results = append(results, h.cs.Import(batch, dryRun)...)
+	}
+
+	var imported int
+	for _, res := range results {
+		if res.Error == "" {
+			imported++
+		}
+	}
+	msg := fmt.Sprintf("%d of %d rows imported", imported, len(results))
+	if dryRun {
+		msg = fmt.Sprintf("%d of %d rows valid, nothing imported", imported, len(results))
+	}
+	writeResponse(w, http.StatusOK, &Response[db.ImportResult]{
+		Message: msg,
+		Items:   results,
+	})
+}
+
+// Export is invoked by HTTP GET /export. It streams every book and magazine, or only those of
+// the ?type= item type, as CSV or as JSON Lines depending on the ?format= query parameter.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	contentType, ok := catalogueFormats[format]
+	if !ok {
+		writeResponse(w, http.StatusBadRequest, &Response[db.Book]{
+			Error: fmt.Sprintf("unknown export format %q", format),
+		})
+		return
+	}
+	itemType := db.ItemType(strings.ToUpper(q.Get("type")))
+	if itemType != "" && itemType != db.BookItem && itemType != db.MagazineItem {
+		writeResponse(w, http.StatusBadRequest, &Response[db.Book]{
+			Error: fmt.Sprintf("unknown item type %q", q.Get("type")),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="catalogue.%s"`, format))
+	cw := &countingWriter{w: w}
+	rw, err := newRowWriter(contentType, cw)
+	if err == nil {
+		if err = h.cs.Export(itemType, rw.write); err == nil {
+			err = rw.flush()
+		}
+	}
+	if err != nil && cw.n == 0 {
+		w.Header().Del("Content-Disposition")
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Book]{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		// The status has already been sent, so the connection is aborted
+		// for the client to tell a failed export from a complete one.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// Events is invoked by HTTP GET /events. It streams item events as Server-Sent Events,
+// optionally filtered by type, item type and owner. Clients resume from the Last-Event-ID they were sent.
+// Event IDs are handed out when events are recorded, but events may be committed out of order,
+// so an event committed while a client is disconnected is not resumed if its ID is lower than the last one sent.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.ItemEvent]{
+			Error: "streaming is not supported",
+		})
+		return
+	}
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, &Response[db.ItemEvent]{
+			Error: err.Error(),
+		})
+		return
+	}
+	lastID, err := parseLastEventID(r)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, &Response[db.ItemEvent]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	// Subscribe before catching up, so that no events are missed in between.
+	sub := h.eb.Subscribe(filter)
+	defer sub.Close()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Events replayed from the ledger may also be received live, so they are only sent once.
+	// They are told apart by ID rather than by the last ID replayed, as events committed late,
+	// after the ledger was read, can have lower IDs than the events replayed.
+	replayed := map[int64]bool{}
+	if lastID != nil {
+		for {
+			missed, err := h.hs.ListSince(*lastID, eventsPageSize)
+			if err != nil {
+				return
+			}
+			for _, e := range missed {
+				*lastID = e.ID
+				replayed[e.ID] = true
+				if !filter.Match(e) {
+					continue
+				}
+				if err := events.WriteSSE(w, e); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+			if len(missed) < eventsPageSize {
+				break
+			}
+		}
+	}
+
+	// Live events come from every community, unlike the ones read from the scoped ledger.
+	visible := h.inScope()
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-sub.Events():
+			if !ok {
+				// The subscriber fell behind, so the client has to reconnect and resume from its last event.
+				return
+			}
+			if replayed[e.ID] {
+				delete(replayed, e.ID)
+				continue
+			}
+			if !visible(e) {
+				continue
+			}
+			if err := events.WriteSSE(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// parseAt is a helper method that reads the optional
+// RFC 3339 at query parameter of a request.
+func parseAt(r *http.Request) (*time.Time, error) {
+	v := r.URL.Query().Get("at")
+	if v == "" {
+		return nil, nil
+	}
+	at, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid at parameter:%v", err)
+	}
+	return &at, nil
+}
+
+// parseEventFilter is a helper method that reads the optional
+// type, item_type and owner query parameters of an event stream.
+func parseEventFilter(r *http.Request) (events.Filter, error) {
+	q := r.URL.Query()
+	filter := events.Filter{
+		ItemType: db.ItemType(q.Get("item_type")),
+		OwnerID:  q.Get("owner"),
+	}
+	if filter.ItemType != "" && filter.ItemType != db.BookItem && filter.ItemType != db.MagazineItem {
+		return events.Filter{}, fmt.Errorf("unknown item type %q", filter.ItemType)
+	}
+	for _, types := range q["type"] {
+		for _, t := range strings.Split(types, ",") {
+			et := db.ItemEventType(strings.ToUpper(strings.TrimSpace(t)))
+			if !db.IsItemEventType(et) {
+				return events.Filter{}, fmt.Errorf("unknown event type %q", t)
+			}
+			filter.Types = append(filter.Types, et)
+		}
+	}
+	return filter, nil
+}
+
+// parseImportOptions is a helper method that reads the optional
+// dry_run and batch query parameters of an import.
+func parseImportOptions(r *http.Request) (bool, int, error) {
+	q := r.URL.Query()
+	dryRun := false
+	if raw := q.Get("dry_run"); raw != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(raw); err != nil {
+			return false, 0, fmt.Errorf("invalid dry_run %q", raw)
+		}
+	}
+	batchSize := defaultImportBatch
+	if raw := q.Get("batch"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxImportBatch {
+			return false, 0, fmt.Errorf("invalid batch size %q, expected 1 to %d", raw, maxImportBatch)
+		}
+		batchSize = n
+	}
+	return dryRun, batchSize, nil
+}
+
+// parseLastEventID is a helper method that reads the ID of the last event a client received,
+// from the Last-Event-ID header or the last_event_id query parameter.
+func parseLastEventID(r *http.Request) (*int64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid last event id %q", raw)
+	}
+	return &id, nil
+}
+
+// errorStatus is a helper method that
+// maps the errors of item operations to HTTP statuses.
+// Errors which are not known failures of the operation, such as database failures, are internal errors.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrNotOwner), errors.Is(err, db.ErrNotAdmin), errors.Is(err, db.ErrNotMember),
+		errors.Is(err, db.ErrSuspended):
+		return http.StatusForbidden
+	case errors.Is(err, db.ErrInsufficientCredits):
+		return http.StatusPaymentRequired
+	case errors.Is(err, db.ErrInvalidTransition), errors.Is(err, db.ErrNotReviewable),
+		errors.Is(err, db.ErrAlreadyReviewed), errors.Is(err, db.ErrFlagged), errors.Is(err, db.ErrReserved),
+		errors.Is(err, db.ErrHoldExpired):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, db.ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, db.ErrUnsupportedImage):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// readImageBody is a helper method that reads an uploaded image.
+// One byte more than the largest image is read, so that larger images are rejected rather than truncated.
+func readImageBody(r *http.Request) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, db.MaxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Body.Close(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 // readRequestBody is a helper method that
 // allows to read a request body and return any errors.
 func readRequestBody(r *http.Request) ([]byte, error) {
@@ -1,23 +1,42 @@
 package handlers_test
 
 import (
+	"bufio"
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/events"
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/handlers"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/webhooks"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
 )
 
+var update = flag.Bool("update", false, "update the golden files")
+
 func TestIndexIntegration(t *testing.T) {
 	if os.Getenv("LONG") == "" {
 		t.Skip("Skipping TestIndexIntegration in short mode.")
@@ -25,12 +44,14 @@ func TestIndexIntegration(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
 	// Arrange
-	bs := db.NewBookService(testDB, nil)
-	book := bs.Upsert(db.Book{
-		Name:   "My first integration test",
-		Status: db.Available.String(),
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	book, err := bs.Upsert(db.Book{
+		Name:    "My first integration test",
+		Status:  db.Available,
+		OwnerID: db.CreateTestUser(t, testDB).ID,
 	})
-	ha := handlers.NewHandler(bs, nil, nil)
+	require.Nil(t, err)
+	ha := handlers.NewHandler(bs, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	svr := httptest.NewServer(http.HandlerFunc(ha.Index))
 	defer svr.Close()
 
@@ -58,12 +79,14 @@ func TestListBooksIntegration(t *testing.T) {
 	// Arrange
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
-	bs := db.NewBookService(testDB, nil)
-	eb := bs.Upsert(db.Book{
-		Name:   "My first integration test",
-		Status: db.Available.String(),
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	eb, err := bs.Upsert(db.Book{
+		Name:    "My first integration test",
+		Status:  db.Available,
+		OwnerID: db.CreateTestUser(t, testDB).ID,
 	})
-	ha := handlers.NewHandler(bs, nil, nil)
+	require.Nil(t, err)
+	ha := handlers.NewHandler(bs, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	svr := httptest.NewServer(http.HandlerFunc(ha.ListBooks))
 	defer svr.Close()
 
@@ -91,12 +114,14 @@ func TestListMagazinesIntegration(t *testing.T) {
 	// Arrange
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
-	ms := db.NewMagazineService(testDB, nil)
-	em := ms.Upsert(db.Magazine{
-		Name:   "My integration test",
-		Status: db.Available.String(),
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	em, err := ms.Upsert(db.Magazine{
+		Name:    "My integration test",
+		Status:  db.Available,
+		OwnerID: db.CreateTestUser(t, testDB).ID,
 	})
-	ha := handlers.NewHandler(nil, nil, ms)
+	require.Nil(t, err)
+	ha := handlers.NewHandler(nil, nil, ms, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	svr := httptest.NewServer(http.HandlerFunc(ha.ListMagazines))
 	defer svr.Close()
 
@@ -117,6 +142,85 @@ func TestListMagazinesIntegration(t *testing.T) {
 	assert.Contains(t, resp.Items, em)
 }
 
+func TestListContentTypesIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestListContentTypesIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	eb, err := bs.Upsert(db.Book{
+		Name:      "Dune, Messiah",
+		Author:    "Frank Herbert",
+		Condition: db.ConditionGood,
+		Tags:      db.Tags{"classic", "sci-fi"},
+		Status:    db.Available,
+		OwnerID:   db.CreateTestUser(t, testDB).ID,
+	})
+	require.Nil(t, err)
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+	get := func(t *testing.T, accept string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/books", nil)
+		require.Nil(t, err)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Header().Values("Vary"), "Accept")
+		return rr
+	}
+
+	t.Run("json", func(t *testing.T) {
+		// Act
+		rr := get(t, "text/html, application/*;q=0.9")
+
+		// Assert
+		assert.Equal(t, "application/json; charset=UTF-8", rr.Header().Get("Content-Type"))
+		var resp handlers.Response[db.Book]
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.Contains(t, resp.Items, eb)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		// Act
+		rr := get(t, "application/json;q=0.5, text/csv")
+
+		// Assert
+		assert.Equal(t, "text/csv; charset=UTF-8", rr.Header().Get("Content-Type"))
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		require.Nil(t, err)
+		require.NotEmpty(t, records)
+		assert.Equal(t, []string{"id", "name", "author", "isbn", "publisher", "year", "edition", "condition",
+			"language", "tags", "images", "owner_id", "status"}, records[0])
+		assert.Contains(t, records[1:], []string{eb.ID, "Dune, Messiah", "Frank Herbert", "", "", "", "",
+			string(db.ConditionGood), "", "classic;sci-fi", "", eb.OwnerID, db.Available.String()})
+	})
+
+	t.Run("msgpack", func(t *testing.T) {
+		// Act
+		rr := get(t, "application/x-msgpack")
+
+		// Assert
+		assert.Equal(t, "application/msgpack", rr.Header().Get("Content-Type"))
+		h := &codec.MsgpackHandle{}
+		h.MapType = reflect.TypeOf(map[string]any(nil))
+		h.RawToString = true
+		var resp map[string]any
+		require.Nil(t, codec.NewDecoderBytes(rr.Body.Bytes(), h).Decode(&resp))
+		var found map[string]any
+		for _, item := range resp["items"].([]any) {
+			if book := item.(map[string]any); book["id"] == eb.ID {
+				found = book
+			}
+		}
+		require.NotNil(t, found)
+		assert.Equal(t, "Dune, Messiah", found["name"])
+		assert.Equal(t, db.Available.String(), found["status"])
+		assert.Equal(t, []any{"classic", "sci-fi"}, found["tags"])
+	})
+}
+
 func TestUserUpsertIntegration(t *testing.T) {
 	if os.Getenv("LONG") == "" {
 		t.Skip("Skipping TestIndexIntegration in short mode.")
@@ -129,8 +233,8 @@ func TestUserUpsertIntegration(t *testing.T) {
 	require.Nil(t, err)
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
-	us := db.NewUserService(testDB, nil, nil)
-	ha := handlers.NewHandler(nil, us, nil)
+	us := db.NewUserService(testDB, nil, nil, nil, nil)
+	ha := handlers.NewHandler(nil, us, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	svr := httptest.NewServer(http.HandlerFunc(ha.UserUpsert))
 	defer svr.Close()
 
@@ -157,22 +261,22 @@ func TestBookUpsertIntegration(t *testing.T) {
 	// Arrange
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
-	bs := db.NewBookService(testDB, nil)
-	ms := db.NewMagazineService(testDB, nil)
-	us := db.NewUserService(testDB, bs, ms)
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
 	eu, err := us.Upsert(db.User{
 		Name: "Existing user",
 	})
 	require.Nil(t, err)
 	newBook := db.Book{
 		Name:    "Existing book",
-		Status:  db.Available.String(),
+		Status:  db.Available,
 		OwnerID: eu.ID,
 	}
 	bookPayload, err := json.Marshal(newBook)
 	require.Nil(t, err)
 
-	ha := handlers.NewHandler(bs, us, nil)
+	ha := handlers.NewHandler(bs, us, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	svr := httptest.NewServer(http.HandlerFunc(ha.BookUpsert))
 	defer svr.Close()
 
@@ -190,7 +294,52 @@ func TestBookUpsertIntegration(t *testing.T) {
 	require.Nil(t, err)
 	assert.Equal(t, 1, len(resp.Items))
 	assert.Equal(t, newBook.Name, resp.Items[0].Name)
-	assert.Equal(t, db.Available.String(), resp.Items[0].Status)
+	assert.Equal(t, db.Available, resp.Items[0].Status)
+}
+
+func TestBookUpsertISBNIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestBookUpsertISBNIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	md, err := db.NewFixtureMetadataProvider(filepath.Join("..", "db", "testdata", "book_metadata.json"))
+	require.Nil(t, err)
+	bs := db.NewBookService(testDB, nil, nil, md, nil, nil)
+	us := db.NewUserService(testDB, bs, nil, nil, nil)
+	eu, err := us.Upsert(db.User{
+		Name: "Existing user",
+	})
+	require.Nil(t, err)
+	ha := handlers.NewHandler(bs, us, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	svr := httptest.NewServer(http.HandlerFunc(ha.BookUpsert))
+	defer svr.Close()
+	post := func(isbn string) (int, handlers.Response[db.Book]) {
+		payload := fmt.Sprintf(`{"isbn":%q,"owner_id":%q}`, isbn, eu.ID)
+		r, err := http.Post(svr.URL, "application/json", bytes.NewBufferString(payload))
+		require.Nil(t, err)
+		defer r.Body.Close()
+		var resp handlers.Response[db.Book]
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+		return r.StatusCode, resp
+	}
+
+	// Act
+	status, first := post("0-441-17271-7")
+	_, second := post("978-0-441-17271-9")
+	invalidStatus, invalid := post("978-0-441-17271-0")
+
+	// Assert
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, 1, len(first.Items))
+	assert.Equal(t, "Dune", first.Items[0].Name)
+	assert.Equal(t, "Frank Herbert", first.Items[0].Author)
+	assert.Equal(t, "9780441172719", first.Items[0].ISBN)
+	assert.Empty(t, first.Message)
+	assert.Contains(t, second.Message, first.Items[0].ID)
+	assert.Equal(t, http.StatusBadRequest, invalidStatus)
+	assert.Contains(t, invalid.Error, "invalid isbn")
 }
 
 func TestMagazineUpsertIntegration(t *testing.T) {
@@ -200,21 +349,21 @@ func TestMagazineUpsertIntegration(t *testing.T) {
 	// Arrange
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
-	ms := db.NewMagazineService(testDB, nil)
-	us := db.NewUserService(testDB, nil, ms)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, nil, ms, nil, nil)
 	eu, err := us.Upsert(db.User{
 		Name: "Existing user",
 	})
 	require.Nil(t, err)
 	newMag := db.Magazine{
 		Name:    "Existing mag",
-		Status:  db.Available.String(),
+		Status:  db.Available,
 		OwnerID: eu.ID,
 	}
 	magPayload, err := json.Marshal(newMag)
 	require.Nil(t, err)
 
-	ha := handlers.NewHandler(nil, us, ms)
+	ha := handlers.NewHandler(nil, us, ms, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	svr := httptest.NewServer(http.HandlerFunc(ha.MagazineUpsert))
 	defer svr.Close()
 
@@ -232,7 +381,56 @@ func TestMagazineUpsertIntegration(t *testing.T) {
 	require.Nil(t, err)
 	assert.Equal(t, 1, len(resp.Items))
 	assert.Equal(t, newMag.Name, resp.Items[0].Name)
-	assert.Equal(t, db.Available.String(), resp.Items[0].Status)
+	assert.Equal(t, db.Available, resp.Items[0].Status)
+}
+
+func TestUpsertOwnerChangeIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestUpsertOwnerChangeIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	owner := db.CreateTestUser(t, testDB)
+	other := db.CreateTestUser(t, testDB)
+	eb, err := bs.Upsert(db.Book{Name: "Existing book", OwnerID: owner.ID})
+	require.Nil(t, err)
+	em, err := ms.Upsert(db.Magazine{Name: "Existing mag", OwnerID: owner.ID})
+	require.Nil(t, err)
+	eb.OwnerID, em.OwnerID = other.ID, other.ID
+	ha := handlers.NewHandler(bs, us, ms, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	tests := map[string]struct {
+		handler http.HandlerFunc
+		item    any
+	}{
+		"book":     {handler: ha.BookUpsert, item: eb},
+		"magazine": {handler: ha.MagazineUpsert, item: em},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			payload, err := json.Marshal(tc.item)
+			require.Nil(t, err)
+			svr := httptest.NewServer(tc.handler)
+			defer svr.Close()
+
+			// Act
+			r, err := http.Post(svr.URL, "application/json", bytes.NewBuffer(payload))
+
+			// Assert
+			require.Nil(t, err)
+			defer r.Body.Close()
+			assert.Equal(t, http.StatusForbidden, r.StatusCode)
+		})
+	}
+	b, err := bs.Get(eb.ID)
+	require.Nil(t, err)
+	assert.Equal(t, owner.ID, b.OwnerID)
+	m, err := ms.Get(em.ID)
+	require.Nil(t, err)
+	assert.Equal(t, owner.ID, m.OwnerID)
 }
 
 func TestListUserByID_Books_Integration(t *testing.T) {
@@ -242,20 +440,21 @@ func TestListUserByID_Books_Integration(t *testing.T) {
 	// Arrange
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
-	bs := db.NewBookService(testDB, nil)
-	ms := db.NewMagazineService(testDB, nil)
-	us := db.NewUserService(testDB, bs, ms)
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
 	eu, err := us.Upsert(db.User{
 		Name: "Existing user",
 	})
 	require.Nil(t, err)
-	eb := bs.Upsert(db.Book{
+	eb, err := bs.Upsert(db.Book{
 		ID:      uuid.New().String(),
 		Name:    "Existing book",
-		Status:  db.Available.String(),
+		Status:  db.Available,
 		OwnerID: eu.ID,
 	})
-	ha := handlers.NewHandler(bs, us, nil)
+	require.Nil(t, err)
+	ha := handlers.NewHandler(bs, us, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 
 	// Act
 	path := fmt.Sprintf("/users/%s/books", eu.ID)
@@ -284,19 +483,20 @@ func TestListUserByID_Magazines_Integration(t *testing.T) {
 	// Arrange
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
-	bs := db.NewBookService(testDB, nil)
-	ms := db.NewMagazineService(testDB, nil)
-	us := db.NewUserService(testDB, bs, ms)
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
 	eu, err := us.Upsert(db.User{
 		Name: "Existing user",
 	})
 	require.Nil(t, err)
-	em := ms.Upsert(db.Magazine{
+	em, err := ms.Upsert(db.Magazine{
 		Name:    "Existing mag",
-		Status:  db.Available.String(),
+		Status:  db.Available,
 		OwnerID: eu.ID,
 	})
-	ha := handlers.NewHandler(bs, us, nil)
+	require.Nil(t, err)
+	ha := handlers.NewHandler(bs, us, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 
 	// Act
 	path := fmt.Sprintf("/users/%s/magazines", eu.ID)
@@ -327,9 +527,9 @@ func TestSwapBookIntegration(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
 	ps := db.NewPostingService()
-	bs := db.NewBookService(testDB, ps)
-	ms := db.NewMagazineService(testDB, ps)
-	us := db.NewUserService(testDB, bs, ms)
+	bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
 	eu, err := us.Upsert(db.User{
 		Name: "Existing user",
 	})
@@ -338,12 +538,13 @@ func TestSwapBookIntegration(t *testing.T) {
 		Name: "Swap user",
 	})
 	require.Nil(t, err)
-	eb := bs.Upsert(db.Book{
+	eb, err := bs.Upsert(db.Book{
 		Name:    "Existing book",
-		Status:  db.Available.String(),
+		Status:  db.Available,
 		OwnerID: eu.ID,
 	})
-	ha := handlers.NewHandler(bs, us, ms)
+	require.Nil(t, err)
+	ha := handlers.NewHandler(bs, us, ms, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 
 	// Act
 	path := fmt.Sprintf("/books/%s?user=%s", eb.ID, swapUser.ID)
@@ -364,7 +565,7 @@ func TestSwapBookIntegration(t *testing.T) {
 	assert.Equal(t, 1, len(resp.Items))
 	assert.Equal(t, eb.Name, resp.Items[0].Name)
 	assert.Equal(t, eb.ID, resp.Items[0].ID)
-	assert.Equal(t, db.Swapped.String(), resp.Items[0].Status)
+	assert.Equal(t, db.InTransit, resp.Items[0].Status)
 }
 
 func TestSwapMagazineIntegration(t *testing.T) {
@@ -375,9 +576,9 @@ func TestSwapMagazineIntegration(t *testing.T) {
 	testDB, cleaner := db.OpenDB(t)
 	defer cleaner()
 	ps := db.NewPostingService()
-	bs := db.NewBookService(testDB, ps)
-	ms := db.NewMagazineService(testDB, ps)
-	us := db.NewUserService(testDB, bs, ms)
+	bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
 	eu, err := us.Upsert(db.User{
 		Name: "Existing user",
 	})
@@ -386,12 +587,13 @@ func TestSwapMagazineIntegration(t *testing.T) {
 		Name: "Swap user",
 	})
 	require.Nil(t, err)
-	em := ms.Upsert(db.Magazine{
+	em, err := ms.Upsert(db.Magazine{
 		Name:    "Existing mag",
-		Status:  db.Available.String(),
+		Status:  db.Available,
 		OwnerID: eu.ID,
 	})
-	ha := handlers.NewHandler(bs, us, ms)
+	require.Nil(t, err)
+	ha := handlers.NewHandler(bs, us, ms, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 
 	// Act
 	path := fmt.Sprintf("/magazines/%s?user=%s", em.ID, swapUser.ID)
@@ -412,5 +614,1265 @@ func TestSwapMagazineIntegration(t *testing.T) {
 	assert.Equal(t, 1, len(resp.Items))
 	assert.Equal(t, em.Name, resp.Items[0].Name)
 	assert.Equal(t, em.ID, resp.Items[0].ID)
-	assert.Equal(t, db.Swapped.String(), resp.Items[0].Status)
+	assert.Equal(t, db.InTransit, resp.Items[0].Status)
+}
+
+func TestBookHistoryIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestBookHistoryIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	ps := db.NewPostingService()
+	bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	hs := db.NewHistoryService(testDB, nil, nil)
+	eu, err := us.Upsert(db.User{
+		Name: "Existing user",
+	})
+	require.Nil(t, err)
+	swapUser, err := us.Upsert(db.User{
+		Name: "Swap user",
+	})
+	require.Nil(t, err)
+	eb, err := bs.Upsert(db.Book{
+		Name:    "Existing book",
+		Status:  db.Available,
+		OwnerID: eu.ID,
+	})
+	require.Nil(t, err)
+	_, err = bs.SwapBook(eb.ID, swapUser.ID)
+	require.Nil(t, err)
+	ha := handlers.NewHandler(bs, us, ms, hs, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	// Act
+	path := fmt.Sprintf("/books/%s/history", eb.ID)
+	req, err := http.NewRequest("GET", path, nil)
+	require.Nil(t, err)
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.Methods("GET").Path("/books/{id}/history").Handler(http.HandlerFunc(ha.BookHistory))
+	router.ServeHTTP(rr, req)
+
+	// Assert
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp handlers.Response[db.ItemEvent]
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(resp.Items))
+	assert.Equal(t, db.ItemCreated, resp.Items[0].Type)
+	assert.Equal(t, db.ItemSwapped, resp.Items[1].Type)
+	assert.Equal(t, eu.ID, resp.Items[1].PreviousOwnerID)
+	assert.Equal(t, swapUser.ID, resp.Items[1].OwnerID)
+	assert.Equal(t, db.ItemPosted, resp.Items[2].Type)
+}
+
+func TestBookLifecycleIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestBookLifecycleIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	ps := db.NewPostingService()
+	bs := db.NewBookService(testDB, ps, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, ps, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	eu, err := us.Upsert(db.User{
+		Name: "Existing user",
+	})
+	require.Nil(t, err)
+	swapUser, err := us.Upsert(db.User{
+		Name: "Swap user",
+	})
+	require.Nil(t, err)
+	eb, err := bs.Upsert(db.Book{
+		Name:    "Existing book",
+		OwnerID: eu.ID,
+	})
+	require.Nil(t, err)
+	ha := handlers.NewHandler(bs, us, ms, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	router := handlers.ConfigureServer(ha)
+
+	tests := []struct {
+		name       string
+		action     string
+		userID     string
+		wantCode   int
+		wantStatus db.BookStatus
+	}{
+		{name: "relist available", action: "/relist", userID: eu.ID, wantCode: http.StatusConflict},
+		{name: "swap", action: "", userID: swapUser.ID, wantCode: http.StatusOK, wantStatus: db.InTransit},
+		{name: "deliver by previous owner", action: "/delivery", userID: eu.ID, wantCode: http.StatusForbidden},
+		{name: "deliver", action: "/delivery", userID: swapUser.ID, wantCode: http.StatusOK, wantStatus: db.Swapped},
+		{name: "relist", action: "/relist", userID: swapUser.ID, wantCode: http.StatusOK, wantStatus: db.Available},
+		{name: "withdraw", action: "/withdraw", userID: swapUser.ID, wantCode: http.StatusOK, wantStatus: db.Withdrawn},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			path := fmt.Sprintf("/books/%s%s?user=%s", eb.ID, tc.action, tc.userID)
+			req, err := http.NewRequest("POST", path, nil)
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			// Assert
+			require.Equal(t, tc.wantCode, rr.Code)
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			var resp handlers.Response[db.Book]
+			err = json.Unmarshal(rr.Body.Bytes(), &resp)
+			require.Nil(t, err)
+			assert.Equal(t, tc.userID, resp.User.ID)
+			require.Equal(t, 1, len(resp.Items))
+			assert.Equal(t, tc.wantStatus, resp.Items[0].Status)
+		})
+	}
+}
+
+func TestWebhooksIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestWebhooksIntegration in short mode.")
+	}
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	// Arrange
+	var received [][]byte
+	var signatures []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+		received = append(received, body)
+		signatures = append(signatures, r.Header.Get(webhooks.SignatureHeader))
+	}))
+	defer receiver.Close()
+	owner := db.CreateTestUser(t, testDB)
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	whs := db.NewWebhookService(testDB, nil, nil).WithPrivateURLs()
+	mds := db.NewModerationService(nil, nil, nil, []string{"admin"}, nil, nil)
+	ha := handlers.NewHandler(bs, nil, nil, nil, nil, nil, whs, nil, nil, nil, nil, nil, nil, mds, nil, nil)
+	router := handlers.ConfigureServer(ha)
+	dispatcher := webhooks.NewDispatcher(whs, receiver.Client(), nil)
+
+	// Act
+	payload := fmt.Sprintf(`{"url":%q,"event_types":["CREATED"]}`, receiver.URL)
+	req, err := http.NewRequest("POST", "/webhooks?user=admin", bytes.NewBufferString(payload))
+	require.Nil(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var created handlers.Response[db.WebhookSubscription]
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	require.Equal(t, 1, len(created.Items))
+	s := created.Items[0]
+	require.NotEmpty(t, s.Secret)
+
+	// Webhooks of other communities are not sent the events of the owner's community.
+	other := db.CreateTestCommunity(t, testDB)
+	_, err = whs.InCommunity(other.ID).Create(db.WebhookSubscription{URL: receiver.URL,
+		EventTypes: db.EventTypes{db.ItemCreated}})
+	require.Nil(t, err)
+	eb, err := bs.Upsert(db.Book{Name: "Webhook book", OwnerID: owner.ID})
+	require.Nil(t, err)
+	// Updates are not subscribed to, so they are not delivered.
+	_, err = bs.Upsert(eb)
+	require.Nil(t, err)
+	require.Nil(t, dispatcher.DispatchOnce(context.Background()))
+
+	// Assert
+	require.Equal(t, 1, len(received))
+	assert.True(t, webhooks.Verify(s.Secret, received[0], signatures[0]))
+	var e db.ItemEvent
+	require.Nil(t, json.Unmarshal(received[0], &e))
+	assert.Equal(t, db.ItemCreated, e.Type)
+	assert.Equal(t, eb.ID, e.ItemID)
+
+	t.Run("delivery log", func(t *testing.T) {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/webhooks/%s/deliveries?user=admin", s.ID), nil)
+		require.Nil(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp handlers.Response[db.WebhookDelivery]
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		require.Equal(t, 1, len(resp.Items))
+		assert.Equal(t, db.DeliverySent, resp.Items[0].Status)
+		assert.Equal(t, http.StatusOK, resp.Items[0].ResponseStatus)
+
+		// Act
+		path := fmt.Sprintf("/webhooks/%s/deliveries/%s/replay?user=admin", s.ID, resp.Items[0].ID)
+		req, err = http.NewRequest("POST", path, nil)
+		require.Nil(t, err)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Nil(t, dispatcher.DispatchOnce(context.Background()))
+
+		// Assert
+		require.Equal(t, 2, len(received))
+		assert.Equal(t, received[0], received[1])
+	})
+
+	t.Run("webhook of another community", func(t *testing.T) {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/webhooks/%s?user=admin", s.ID), nil)
+		require.Nil(t, err)
+		req.Header.Set(handlers.CommunityHeader, other.Slug)
+		rr := httptest.NewRecorder()
+		handlers.ConfigureServer(handlers.NewHandler(bs, nil, nil, nil, nil, nil, whs, nil, nil, nil, nil, nil,
+			db.NewCommunityService(testDB, nil, nil, nil), mds, nil, nil)).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("secret is not listed", func(t *testing.T) {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/webhooks/%s?user=admin", s.ID), nil)
+		require.Nil(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp handlers.Response[db.WebhookSubscription]
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		require.Equal(t, 1, len(resp.Items))
+		assert.Empty(t, resp.Items[0].Secret)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		req, err := http.NewRequest("DELETE", fmt.Sprintf("/webhooks/%s?user=admin", s.ID), nil)
+		require.Nil(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestEvents(t *testing.T) {
+	created := db.ItemEvent{ID: 1, ItemType: db.BookItem, Type: db.ItemCreated, OwnerID: "owner"}
+	updated := db.ItemEvent{ID: 2, ItemType: db.BookItem, Type: db.ItemUpdated, OwnerID: "owner"}
+	swapped := db.ItemEvent{ID: 3, ItemType: db.BookItem, Type: db.ItemSwapped, OwnerID: "new-owner", PreviousOwnerID: "owner"}
+	// connect opens an event stream and waits for it to be subscribed to the broker.
+	connect := func(t *testing.T, srv *httptest.Server, eb *events.Broker, query string) (*bufio.Reader, context.CancelFunc) {
+		t.Helper()
+		subscribers := eb.Subscribers()
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events"+query, nil)
+		require.Nil(t, err)
+		resp, err := srv.Client().Do(req)
+		require.Nil(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		require.Eventually(t, func() bool { return eb.Subscribers() > subscribers }, time.Second, time.Millisecond)
+		return bufio.NewReader(resp.Body), cancel
+	}
+	// readEvent reads the next event from a stream, returning its id and name.
+	readEvent := func(t *testing.T, r *bufio.Reader) (string, string) {
+		t.Helper()
+		var id, name string
+		for {
+			line, err := r.ReadString('\n')
+			require.Nil(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "":
+				return id, name
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			}
+		}
+	}
+
+	t.Run("filtered stream", func(t *testing.T) {
+		eb := events.NewBroker(events.DefaultBuffer)
+		srv := httptest.NewServer(handlers.ConfigureServer(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, eb)))
+		defer srv.Close()
+		r, cancel := connect(t, srv, eb, "?type=created,swapped&owner=owner")
+		defer cancel()
+
+		eb.Publish(created)
+		eb.Publish(updated)
+		eb.Publish(swapped)
+
+		id, name := readEvent(t, r)
+		assert.Equal(t, "1", id)
+		assert.Equal(t, "item-created", name)
+		id, name = readEvent(t, r)
+		assert.Equal(t, "3", id)
+		assert.Equal(t, "item-swapped", name)
+	})
+
+	t.Run("disconnected subscriber", func(t *testing.T) {
+		eb := events.NewBroker(events.DefaultBuffer)
+		srv := httptest.NewServer(handlers.ConfigureServer(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, eb)))
+		defer srv.Close()
+		_, cancel := connect(t, srv, eb, "")
+
+		cancel()
+		assert.Eventually(t, func() bool { return eb.Subscribers() == 0 }, time.Second, time.Millisecond)
+		// Publishing carries on without anyone listening.
+		eb.Publish(created)
+	})
+
+	t.Run("slow subscriber", func(t *testing.T) {
+		eb := events.NewBroker(1)
+		srv := httptest.NewServer(handlers.ConfigureServer(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, eb)))
+		defer srv.Close()
+		r, cancel := connect(t, srv, eb, "")
+		defer cancel()
+
+		// Publishing faster than the stream drains its buffer drops the subscriber,
+		// which ends the stream so that the client reconnects with its Last-Event-ID.
+		for i := int64(1); eb.Subscribers() > 0; i++ {
+			eb.Publish(db.ItemEvent{ID: i, ItemType: db.BookItem, Type: db.ItemUpdated})
+		}
+		_, err := io.ReadAll(r)
+		assert.Nil(t, err)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		eb := events.NewBroker(events.DefaultBuffer)
+		router := handlers.ConfigureServer(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, eb))
+		tests := map[string]struct {
+			query       string
+			lastEventID string
+		}{
+			"unknown type":       {query: "?type=LOST"},
+			"unknown item type":  {query: "?item_type=VINYL"},
+			"invalid last event": {lastEventID: "abc"},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				req, err := http.NewRequest("GET", "/events"+tc.query, nil)
+				require.Nil(t, err)
+				if tc.lastEventID != "" {
+					req.Header.Set("Last-Event-ID", tc.lastEventID)
+				}
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, req)
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+				assert.Equal(t, 0, eb.Subscribers())
+			})
+		}
+	})
+}
+
+func TestEventsResumeIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestEventsResumeIntegration in short mode.")
+	}
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	// Arrange
+	owner := db.CreateTestUser(t, testDB)
+	eb := events.NewBroker(events.DefaultBuffer)
+	bs := db.NewBookService(testDB, nil, eb, nil, nil, nil)
+	hs := db.NewHistoryService(testDB, nil, nil)
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, nil, nil, hs, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, eb))
+	seenBook, err := bs.Upsert(db.Book{Name: "Seen book", OwnerID: owner.ID})
+	require.Nil(t, err)
+	seen, err := hs.ListByItem(db.BookItem, seenBook.ID)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(seen))
+	missed, err := bs.Upsert(db.Book{Name: "Missed book", OwnerID: owner.ID})
+	require.Nil(t, err)
+	missedEvents, err := hs.ListByItem(db.BookItem, missed.ID)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(missedEvents))
+	// An event committed late has a lower ID than the events replayed, but has not been sent yet.
+	late := db.ItemEvent{ID: missedEvents[0].ID - 1, ItemID: uuid.NewString(), ItemType: db.BookItem,
+		Type: db.ItemCreated, OwnerID: owner.ID}
+
+	// Act
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("/events?owner=%s", owner.ID), nil)
+	require.Nil(t, err)
+	req.Header.Set("Last-Event-ID", fmt.Sprint(seen[0].ID))
+	rr := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		router.ServeHTTP(rr, req)
+	}()
+	require.Eventually(t, func() bool { return eb.Subscribers() > 0 }, time.Second, time.Millisecond)
+	eb.Publish(missedEvents[0])
+	eb.Publish(late)
+	<-done
+
+	// Assert
+	require.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.NotContains(t, body, seenBook.ID)
+	assert.Equal(t, 1, strings.Count(body, fmt.Sprintf("id: %d\n", missedEvents[0].ID)))
+	assert.Contains(t, body, late.ItemID)
+	assert.Contains(t, body, "event: item-created")
+}
+
+func TestGraphQLIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestGraphQLIntegration in short mode.")
+	}
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	// Arrange
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	owner := db.CreateTestUser(t, testDB)
+	eb, err := bs.Upsert(db.Book{Name: "GraphQL book", OwnerID: owner.ID})
+	require.Nil(t, err)
+	em, err := ms.Upsert(db.Magazine{Name: "GraphQL mag", OwnerID: owner.ID})
+	require.Nil(t, err)
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, us, ms, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+
+	// Act
+	query := fmt.Sprintf(`{"query": "{ user(id: \"%s\") { name books { id } magazines { id } } }"}`, owner.ID)
+	req, err := http.NewRequest("POST", "/graphql", bytes.NewBufferString(query))
+	require.Nil(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	// Assert
+	require.Equal(t, http.StatusOK, rr.Code)
+	want := fmt.Sprintf(`{"data": {"user": {"name": %q, "books": [{"id": %q}], "magazines": [{"id": %q}]}}}`,
+		owner.Name, eb.ID, em.ID)
+	assert.JSONEq(t, want, rr.Body.String())
+}
+
+func TestImportInvalid(t *testing.T) {
+	router := handlers.ConfigureServer(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+	tests := map[string]struct {
+		query       string
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		"unsupported content type": {contentType: "application/json", body: "[]", wantErr: "unsupported content type"},
+		"missing column":           {contentType: "text/csv", body: "item_type,name\nBOOK,Dune\n", wantErr: "missing csv column"},
+		"unknown column":           {contentType: "text/csv", body: "item_type,name,owner_id,price\n", wantErr: "unknown csv column"},
+		"empty csv":                {contentType: "text/csv", wantErr: "no csv header row"},
+		"invalid batch size":       {query: "?batch=0", contentType: "text/csv", wantErr: "invalid batch size"},
+		"invalid dry run":          {query: "?dry_run=maybe", contentType: "text/csv", wantErr: "invalid dry_run"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/import"+tc.query, strings.NewReader(tc.body))
+			require.Nil(t, err)
+			req.Header.Set("Content-Type", tc.contentType)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			var resp handlers.Response[db.ImportResult]
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Contains(t, resp.Error, tc.wantErr)
+		})
+	}
+
+	t.Run("header only", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/import", strings.NewReader("item_type,name,owner_id\n"))
+		require.Nil(t, err)
+		req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var resp handlers.Response[db.ImportResult]
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.Equal(t, "0 of 0 rows imported", resp.Message)
+		assert.Empty(t, resp.Items)
+	})
+
+	t.Run("invalid export", func(t *testing.T) {
+		for _, query := range []string{"?format=xml", "?type=shelf"} {
+			req, err := http.NewRequest("GET", "/export"+query, nil)
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		}
+	})
+}
+
+func TestListNotAcceptable(t *testing.T) {
+	router := handlers.ConfigureServer(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+	for _, accept := range []string{"application/xml", "text/*;q=0, application/json;q=0", "image/*"} {
+		t.Run(accept, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/books", nil)
+			require.Nil(t, err)
+			req.Header.Set("Accept", accept)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusNotAcceptable, rr.Code)
+			var resp handlers.Response[db.Book]
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Contains(t, resp.Error, "unsupported accept")
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	router := handlers.ConfigureServer(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+	tests := map[string]struct {
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		"no accept encoding": {},
+		"gzip":               {acceptEncoding: "gzip", wantEncoding: "gzip"},
+		"deflate":            {acceptEncoding: "deflate", wantEncoding: "deflate"},
+		"preferred":          {acceptEncoding: "gzip;q=0.5, deflate", wantEncoding: "deflate"},
+		"wildcard":           {acceptEncoding: "br, *;q=0.1", wantEncoding: "gzip"},
+		"refused":            {acceptEncoding: "gzip;q=0, *;q=0"},
+		"unsupported":        {acceptEncoding: "br, identity"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			req, err := http.NewRequest("GET", "/openapi.json", nil)
+			require.Nil(t, err)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			rr := httptest.NewRecorder()
+
+			// Act
+			router.ServeHTTP(rr, req)
+
+			// Assert
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.wantEncoding, rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+			var body io.Reader = rr.Body
+			switch tc.wantEncoding {
+			case "gzip":
+				body, err = gzip.NewReader(rr.Body)
+				require.Nil(t, err)
+			case "deflate":
+				body = flate.NewReader(rr.Body)
+			}
+			var doc map[string]any
+			require.Nil(t, json.NewDecoder(body).Decode(&doc))
+			assert.Equal(t, "3.0.3", doc["openapi"])
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/books", nil)
+		require.Nil(t, err)
+		req.Header.Set("Accept", "application/xml")
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotAcceptable, rr.Code)
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, "application/json; charset=UTF-8", rr.Header().Get("Content-Type"))
+		gz, err := gzip.NewReader(rr.Body)
+		require.Nil(t, err)
+		var resp handlers.Response[db.Book]
+		require.Nil(t, json.NewDecoder(gz).Decode(&resp))
+		assert.NotEmpty(t, resp.Error)
+	})
+}
+
+func TestImportExportIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestImportExportIntegration in short mode.")
+	}
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	// Arrange
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	cs := db.NewCatalogueService(testDB, bs, ms, nil, nil)
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, nil, ms, nil, nil, nil, nil, cs, nil, nil, nil, nil, nil, nil, nil, nil))
+	owner := db.CreateTestUser(t, testDB)
+	csv := fmt.Sprintf("item_type,name,author,issue_number,condition,tags,owner_id\n"+
+		"book,Dune,Frank Herbert,,good,classic;Sci-Fi,%[1]s\n"+
+		"MAGAZINE,Wired,,seven,,,%[1]s\n"+
+		"MAGAZINE,Wired,,7,,,%[1]s\n"+
+		"BOOK,Emma,Jane Austen,,,,%[1]s\n", owner.ID)
+	importCSV := func(query string) handlers.Response[db.ImportResult] {
+		req, err := http.NewRequest("POST", "/import"+query, strings.NewReader(csv))
+		require.Nil(t, err)
+		req.Header.Set("Content-Type", "text/csv")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp handlers.Response[db.ImportResult]
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		return resp
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		// Act
+		resp := importCSV("?dry_run=true")
+
+		// Assert
+		assert.Equal(t, "3 of 4 rows valid, nothing imported", resp.Message)
+		books, err := bs.ListByUser(owner.ID)
+		require.Nil(t, err)
+		assert.Empty(t, books)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		// Act
+		resp := importCSV("?batch=2")
+
+		// Assert
+		assert.Equal(t, "3 of 4 rows imported", resp.Message)
+		require.Equal(t, 4, len(resp.Items))
+		for i, res := range resp.Items {
+			assert.Equal(t, i+2, res.Row)
+		}
+		assert.Contains(t, resp.Items[1].Error, "invalid issue number")
+		assert.Empty(t, resp.Items[1].ItemID)
+		books, err := bs.ListByUser(owner.ID)
+		require.Nil(t, err)
+		assert.Equal(t, 2, len(books))
+	})
+
+	t.Run("export", func(t *testing.T) {
+		// Act
+		req, err := http.NewRequest("GET", "/export?format=jsonl&type=book", nil)
+		require.Nil(t, err)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		// Assert
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+		var names []string
+		dec := json.NewDecoder(rr.Body)
+		for dec.More() {
+			var item db.CatalogueItem
+			require.Nil(t, dec.Decode(&item))
+			assert.Equal(t, db.BookItem, item.ItemType)
+			assert.Equal(t, db.Available, item.Status)
+			names = append(names, item.Name)
+			if item.Name == "Dune" {
+				assert.Equal(t, db.ConditionGood, item.Condition)
+				assert.Equal(t, db.Tags{"classic", "sci-fi"}, item.Tags)
+			}
+		}
+		assert.ElementsMatch(t, []string{"Dune", "Emma"}, names)
+	})
+}
+
+func TestShippingIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestShippingIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	ss := db.NewShippingService(testDB, loadShippingRates(t), nil, nil)
+	london, err := us.Upsert(db.User{Name: "London", PostCode: "N1 9GU", Country: "GB"})
+	require.Nil(t, err)
+	paris, err := us.Upsert(db.User{Name: "Paris", PostCode: "75001", Country: "FR"})
+	require.Nil(t, err)
+	tokyo, err := us.Upsert(db.User{Name: "Tokyo", PostCode: "100-0001", Country: "JP"})
+	require.Nil(t, err)
+	fromLondon, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: london.ID})
+	require.Nil(t, err)
+	fromTokyo, err := bs.Upsert(db.Book{Name: "Emma", OwnerID: tokyo.ID})
+	require.Nil(t, err)
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, us, ms, nil, nil, nil, nil, nil, ss, nil, nil, nil, nil, nil, nil, nil))
+	svr := httptest.NewServer(router)
+	defer svr.Close()
+
+	t.Run("quote", func(t *testing.T) {
+		tests := map[string]struct {
+			path       string
+			wantStatus int
+			wantCost   int
+		}{
+			"shippable":     {path: "/books/" + fromLondon.ID + "/quote?user=" + paris.ID, wantStatus: http.StatusOK, wantCost: 950},
+			"not shippable": {path: "/books/" + fromTokyo.ID + "/quote?user=" + paris.ID, wantStatus: http.StatusUnprocessableEntity},
+			"own book":      {path: "/books/" + fromLondon.ID + "/quote?user=" + london.ID, wantStatus: http.StatusBadRequest},
+			"missing user":  {path: "/books/" + fromLondon.ID + "/quote", wantStatus: http.StatusBadRequest},
+			"unknown user":  {path: "/books/" + fromLondon.ID + "/quote?user=" + uuid.NewString(), wantStatus: http.StatusNotFound},
+			"unknown book":  {path: "/books/" + uuid.NewString() + "/quote?user=" + paris.ID, wantStatus: http.StatusNotFound},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				// Act
+				r, err := http.Get(svr.URL + tc.path)
+
+				// Assert
+				require.Nil(t, err)
+				defer r.Body.Close()
+				require.Equal(t, tc.wantStatus, r.StatusCode)
+				var resp handlers.Response[db.ShippingQuote]
+				require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+				if tc.wantStatus != http.StatusOK {
+					assert.NotEmpty(t, resp.Error)
+					return
+				}
+				require.Equal(t, 1, len(resp.Items))
+				assert.Equal(t, tc.wantCost, resp.Items[0].Cost)
+				assert.Equal(t, "GBP", resp.Items[0].Currency)
+			})
+		}
+	})
+
+	t.Run("ship to", func(t *testing.T) {
+		// Act
+		r, err := http.Get(svr.URL + "/books?ship_to=" + paris.ID)
+
+		// Assert
+		require.Nil(t, err)
+		defer r.Body.Close()
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		var resp handlers.Response[db.Book]
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+		assert.Contains(t, resp.Items, fromLondon)
+		assert.NotContains(t, resp.Items, fromTokyo)
+	})
+}
+
+func TestReviewsIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestReviewsIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	bs := db.NewBookService(testDB, db.NewPostingService(), nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	owner := db.CreateTestUser(t, testDB)
+	swapper := db.CreateTestUser(t, testDB)
+	stranger := db.CreateTestUser(t, testDB)
+	swapped, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+	require.Nil(t, err)
+	listed, err := bs.Upsert(db.Book{Name: "Emma", OwnerID: owner.ID})
+	require.Nil(t, err)
+	_, err = bs.SwapBook(swapped.ID, swapper.ID)
+	require.Nil(t, err)
+	_, err = bs.ConfirmDelivery(swapped.ID, swapper.ID)
+	require.Nil(t, err)
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, us, ms, nil, nil, nil, nil, nil, nil, nil,
+		db.NewReviewService(testDB, nil, nil), nil, nil, nil, nil, nil))
+	svr := httptest.NewServer(router)
+	defer svr.Close()
+	reviewPath := func(bookID, userID string) string {
+		return svr.URL + "/books/" + bookID + "/reviews?user=" + userID
+	}
+
+	// The reviews build on each other, so they run in order.
+	steps := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "invalid body", path: reviewPath(swapped.ID, swapper.ID), body: `{"rating":`,
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid rating", path: reviewPath(swapped.ID, swapper.ID), body: `{"rating":6}`,
+			wantStatus: http.StatusBadRequest},
+		{name: "not swapped", path: reviewPath(listed.ID, swapper.ID), body: `{"rating":5}`,
+			wantStatus: http.StatusConflict},
+		{name: "not a side of the swap", path: reviewPath(swapped.ID, stranger.ID), body: `{"rating":1}`,
+			wantStatus: http.StatusConflict},
+		{name: "unknown book", path: reviewPath(uuid.NewString(), swapper.ID), body: `{"rating":5}`,
+			wantStatus: http.StatusNotFound},
+		{name: "review", path: reviewPath(swapped.ID, swapper.ID), body: `{"rating":5,"comment":"Great"}`,
+			wantStatus: http.StatusOK},
+		{name: "review again", path: reviewPath(swapped.ID, swapper.ID), body: `{"rating":1}`,
+			wantStatus: http.StatusConflict},
+		{name: "other side", path: reviewPath(swapped.ID, owner.ID), body: `{"rating":4}`,
+			wantStatus: http.StatusOK},
+	}
+	for _, tc := range steps {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			r, err := http.Post(tc.path, "application/json", strings.NewReader(tc.body))
+
+			// Assert
+			require.Nil(t, err)
+			defer r.Body.Close()
+			require.Equal(t, tc.wantStatus, r.StatusCode)
+			var resp handlers.Response[db.Review]
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+			if tc.wantStatus != http.StatusOK {
+				assert.NotEmpty(t, resp.Error)
+				return
+			}
+			require.Equal(t, 1, len(resp.Items))
+			assert.Equal(t, swapped.ID, resp.Items[0].ItemID)
+		})
+	}
+
+	t.Run("reputation", func(t *testing.T) {
+		// Act
+		r, err := http.Get(svr.URL + "/users/" + owner.ID + "/books")
+
+		// Assert
+		require.Nil(t, err)
+		defer r.Body.Close()
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		var resp handlers.Response[db.Book]
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+		assert.Equal(t, &db.Reputation{Average: 5, Count: 1}, resp.Reputation)
+	})
+
+	t.Run("min rating", func(t *testing.T) {
+		// Act
+		r, err := http.Get(svr.URL + "/books?min_rating=4.5")
+
+		// Assert
+		require.Nil(t, err)
+		defer r.Body.Close()
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		var resp handlers.Response[db.Book]
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+		assert.Contains(t, resp.Items, listed)
+		for _, b := range resp.Items {
+			assert.NotEqual(t, swapper.ID, b.OwnerID)
+		}
+	})
+}
+
+func TestGetImageErrors(t *testing.T) {
+	tests := map[string]struct {
+		key        string
+		err        error
+		wantStatus int
+	}{
+		"unknown extension": {key: "photo.txt", wantStatus: http.StatusNotFound},
+		"missing blob": {key: "photo.png", err: fmt.Errorf("no blob:%w", db.ErrRecordNotFound),
+			wantStatus: http.StatusNotFound},
+		"store failure": {key: "photo.png", err: os.ErrPermission, wantStatus: http.StatusInternalServerError},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			store := mocks.NewBlobStore(t)
+			if tc.err != nil {
+				store.On("Open", tc.key).Return(nil, tc.err).Once()
+			}
+			is := db.NewImageService(nil, nil, nil, store, nil, nil)
+			ha := handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, is, nil, nil, nil, nil)
+			router := handlers.ConfigureServer(ha)
+			req, err := http.NewRequest("GET", "/images/"+tc.key, nil)
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+
+			// Act
+			router.ServeHTTP(rr, req)
+
+			// Assert
+			assert.Equal(t, tc.wantStatus, rr.Code)
+		})
+	}
+}
+
+func TestImagesIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestImagesIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	store, err := db.NewFileBlobStore(t.TempDir())
+	require.Nil(t, err)
+	owner := db.CreateTestUser(t, testDB)
+	stranger := db.CreateTestUser(t, testDB)
+	book, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID, Condition: db.ConditionGood})
+	require.Nil(t, err)
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, nil, ms, nil, nil, nil, nil, nil, nil, nil, nil,
+		db.NewImageService(testDB, bs, ms, store, nil, nil), nil, nil, nil, nil))
+	svr := httptest.NewServer(router)
+	defer svr.Close()
+	imagePath := svr.URL + "/books/" + book.ID + "/images?user="
+	tests := map[string]struct {
+		path       string
+		body       []byte
+		wantStatus int
+	}{
+		"not an image": {path: imagePath + owner.ID, body: []byte("Dune by Frank Herbert"),
+			wantStatus: http.StatusUnsupportedMediaType},
+		"too large": {path: imagePath + owner.ID, body: make([]byte, db.MaxImageSize+1),
+			wantStatus: http.StatusRequestEntityTooLarge},
+		"not the owner": {path: imagePath + stranger.ID, body: testPNG(t, 10, 10),
+			wantStatus: http.StatusForbidden},
+		"unknown book": {path: svr.URL + "/books/" + uuid.NewString() + "/images?user=" + owner.ID,
+			body: testPNG(t, 10, 10), wantStatus: http.StatusNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Act
+			r, err := http.Post(tc.path, "image/png", bytes.NewReader(tc.body))
+
+			// Assert
+			require.Nil(t, err)
+			defer r.Body.Close()
+			require.Equal(t, tc.wantStatus, r.StatusCode)
+			var resp handlers.Response[db.Book]
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+			assert.NotEmpty(t, resp.Error)
+		})
+	}
+
+	t.Run("upload", func(t *testing.T) {
+		// Act
+		r, err := http.Post(imagePath+owner.ID, "image/png", bytes.NewReader(testPNG(t, 600, 300)))
+
+		// Assert
+		require.Nil(t, err)
+		defer r.Body.Close()
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		var resp handlers.Response[db.Book]
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+		require.Equal(t, 1, len(resp.Items))
+		require.Equal(t, 1, len(resp.Items[0].Images))
+		img := resp.Items[0].Images[0]
+		assert.Equal(t, "image/png", img.ContentType)
+		assert.Equal(t, 600, img.Width)
+		assert.Equal(t, 300, img.Height)
+		assert.Equal(t, db.ConditionGood, resp.Items[0].Condition)
+
+		thumb, err := http.Get(svr.URL + img.ThumbnailURL)
+		require.Nil(t, err)
+		defer thumb.Body.Close()
+		require.Equal(t, http.StatusOK, thumb.StatusCode)
+		assert.Equal(t, "image/png", thumb.Header.Get("Content-Type"))
+		cfg, err := png.DecodeConfig(thumb.Body)
+		require.Nil(t, err)
+		assert.Equal(t, db.ThumbnailSize, cfg.Width)
+		assert.Equal(t, db.ThumbnailSize/2, cfg.Height)
+
+		got, err := bs.Get(book.ID)
+		require.Nil(t, err)
+		assert.Equal(t, resp.Items[0].Images, got.Images)
+	})
+
+	t.Run("unknown image", func(t *testing.T) {
+		r, err := http.Get(svr.URL + "/images/" + uuid.NewString() + ".png")
+		require.Nil(t, err)
+		defer r.Body.Close()
+		assert.Equal(t, http.StatusNotFound, r.StatusCode)
+	})
+}
+
+func TestCommunitiesIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestCommunitiesIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	office := db.CreateTestCommunity(t, testDB)
+	school := db.CreateTestCommunity(t, testDB)
+	schoolHost := school.Slug + ".bookswap.test"
+	require.Nil(t, testDB.Model(&school).Update("host", schoolHost).Error)
+	bs := db.NewBookService(testDB, nil, nil, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	colleague := db.CreateTestUser(t, db.InCommunity(testDB, office.ID))
+	pupil := db.CreateTestUser(t, db.InCommunity(testDB, school.ID))
+	officeBook, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: colleague.ID})
+	require.Nil(t, err)
+	schoolBook, err := bs.Upsert(db.Book{Name: "Matilda", OwnerID: pupil.ID})
+	require.Nil(t, err)
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, us, ms, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		db.NewCommunityService(testDB, nil, nil, nil), nil, nil, nil))
+	tests := map[string]struct {
+		method     string
+		path       string
+		community  string
+		host       string
+		wantStatus int
+		wantBooks  []string
+	}{
+		"header": {method: "GET", path: "/books", community: office.Slug, host: schoolHost,
+			wantStatus: http.StatusOK, wantBooks: []string{officeBook.ID}},
+		"host": {method: "GET", path: "/books", host: schoolHost + ":3000",
+			wantStatus: http.StatusOK, wantBooks: []string{schoolBook.ID}},
+		"unknown community": {method: "GET", path: "/books", community: "unknown-" + office.Slug,
+			wantStatus: http.StatusNotFound},
+		"book of another community": {method: "GET", path: "/books/" + schoolBook.ID, community: office.Slug,
+			wantStatus: http.StatusNotFound},
+		"swap with another community": {method: "POST", path: "/books/" + schoolBook.ID + "?user=" + colleague.ID,
+			community: office.Slug, wantStatus: http.StatusNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			require.Nil(t, err)
+			req.Host = tc.host
+			if tc.community != "" {
+				req.Header.Set(handlers.CommunityHeader, tc.community)
+			}
+			rr := httptest.NewRecorder()
+
+			// Act
+			router.ServeHTTP(rr, req)
+
+			// Assert
+			require.Equal(t, tc.wantStatus, rr.Code)
+			assert.Contains(t, rr.Header().Values("Vary"), handlers.CommunityHeader)
+			var resp handlers.Response[db.Book]
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			if tc.wantStatus != http.StatusOK {
+				assert.NotEmpty(t, resp.Error)
+				return
+			}
+			var got []string
+			for _, b := range resp.Items {
+				got = append(got, b.ID)
+			}
+			assert.Equal(t, tc.wantBooks, got)
+		})
+	}
+}
+
+func TestCommunitiesNotConfigured(t *testing.T) {
+	router := handlers.ConfigureServer(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+	for _, method := range []string{"GET", "POST"} {
+		t.Run(method, func(t *testing.T) {
+			req, err := http.NewRequest(method, "/communities", strings.NewReader("{}"))
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+			var resp handlers.Response[db.Community]
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(t, "communities are not configured", resp.Error)
+		})
+	}
+}
+
+func TestAdminRoutesForbidden(t *testing.T) {
+	// Arrange
+	mds := db.NewModerationService(nil, nil, nil, []string{"admin"}, nil, nil)
+	tests := map[string]struct {
+		mds    *db.ModerationService
+		method string
+		path   string
+	}{
+		"not configured": {method: "GET", path: "/admin/reports?user=admin"},
+		"no user":        {mds: mds, method: "GET", path: "/admin/actions"},
+		"not an admin":   {mds: mds, method: "POST", path: "/admin/users/u1/suspend?user=u1"},
+		"webhooks":       {mds: mds, method: "POST", path: "/webhooks?user=u1"},
+		"webhook replay": {mds: mds, method: "POST", path: "/webhooks/w1/deliveries/d1/replay"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			router := handlers.ConfigureServer(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+				nil, nil, nil, tc.mds, nil, nil))
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(`{"reason":"Spam"}`))
+			require.Nil(t, err)
+			rr := httptest.NewRecorder()
+
+			// Act
+			router.ServeHTTP(rr, req)
+
+			// Assert
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+			var resp handlers.Response[db.AdminAction]
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Contains(t, resp.Error, db.ErrNotAdmin.Error())
+		})
+	}
+}
+
+func TestHoldsIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestHoldsIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	clock := db.NewFakeClock(time.Now())
+	bs := db.NewBookService(testDB, db.NewPostingService(), nil, nil, clock, nil)
+	ms := db.NewMagazineService(testDB, nil, nil, clock, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	owner := db.CreateTestUser(t, testDB)
+	holder := db.CreateTestUser(t, testDB)
+	other := db.CreateTestUser(t, testDB)
+	expiring, err := bs.Upsert(db.Book{Name: "Dune", OwnerID: owner.ID})
+	require.Nil(t, err)
+	swapped, err := bs.Upsert(db.Book{Name: "Emma", OwnerID: owner.ID})
+	require.Nil(t, err)
+	hs := db.NewHoldService(testDB, bs, ms, time.Hour, clock, nil)
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, us, ms, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, hs, nil))
+	svr := httptest.NewServer(router)
+	defer svr.Close()
+	hold := func(t *testing.T, path string) db.Hold {
+		r, err := http.Post(svr.URL+path, "application/json", nil)
+		require.Nil(t, err)
+		defer r.Body.Close()
+		require.Equal(t, http.StatusOK, r.StatusCode)
+		var resp handlers.Response[db.Hold]
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+		require.Equal(t, 1, len(resp.Items))
+		return resp.Items[0]
+	}
+	expired := hold(t, "/books/"+expiring.ID+"/hold?user="+holder.ID)
+	converted := hold(t, "/books/"+swapped.ID+"/hold?user="+holder.ID)
+
+	// The holds build on each other, so they run in order.
+	steps := []struct {
+		name       string
+		path       string
+		advance    time.Duration
+		wantStatus int
+	}{
+		{name: "held by another user", path: "/books/" + swapped.ID + "/hold?user=" + other.ID,
+			wantStatus: http.StatusConflict},
+		{name: "swapped by another user", path: "/books/" + swapped.ID + "?user=" + other.ID,
+			wantStatus: http.StatusConflict},
+		{name: "not the holder", path: "/holds/" + converted.ID + "/swap?user=" + other.ID,
+			wantStatus: http.StatusForbidden},
+		{name: "unknown hold", path: "/holds/" + uuid.NewString() + "/release?user=" + holder.ID,
+			wantStatus: http.StatusNotFound},
+		{name: "swap", path: "/holds/" + converted.ID + "/swap?user=" + holder.ID, advance: 30 * time.Minute,
+			wantStatus: http.StatusOK},
+		{name: "swap again", path: "/holds/" + converted.ID + "/swap?user=" + holder.ID,
+			wantStatus: http.StatusConflict},
+		{name: "expired", path: "/holds/" + expired.ID + "/swap?user=" + holder.ID, advance: time.Hour,
+			wantStatus: http.StatusConflict},
+	}
+	for _, tc := range steps {
+		t.Run(tc.name, func(t *testing.T) {
+			clock.Advance(tc.advance)
+
+			// Act
+			r, err := http.Post(svr.URL+tc.path, "application/json", nil)
+
+			// Assert
+			require.Nil(t, err)
+			defer r.Body.Close()
+			require.Equal(t, tc.wantStatus, r.StatusCode)
+			var resp handlers.Response[db.Hold]
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+			if tc.wantStatus != http.StatusOK {
+				assert.NotEmpty(t, resp.Error)
+			}
+		})
+	}
+
+	t.Run("expired holds are released", func(t *testing.T) {
+		// Act
+		released, err := hs.ReleaseExpired()
+
+		// Assert
+		require.Nil(t, err)
+		var ids []string
+		for _, h := range released {
+			ids = append(ids, h.ID)
+		}
+		assert.Contains(t, ids, expired.ID)
+		r, err := http.Get(svr.URL + "/users/" + holder.ID + "/holds")
+		require.Nil(t, err)
+		defer r.Body.Close()
+		var resp handlers.Response[db.Hold]
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&resp))
+		assert.Empty(t, resp.Items)
+	})
+}
+
+func TestGoldenResponsesIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestGoldenResponsesIntegration in short mode.")
+	}
+	// Arrange
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	// Every service numbers its own IDs, so the IDs stay the same whichever other rows,
+	// such as webhook deliveries, the requests happen to create.
+	clock := db.NewFakeClock(time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC))
+	bs := db.NewBookService(testDB, db.NewPostingService(), nil, nil, clock, db.NewSequentialIDs(0x601d0b))
+	ms := db.NewMagazineService(testDB, nil, nil, clock, db.NewSequentialIDs(0x601d0a))
+	us := db.NewUserService(testDB, bs, ms, clock, db.NewSequentialIDs(0x601d01))
+	hs := db.NewHoldService(testDB, bs, ms, time.Hour, clock, db.NewSequentialIDs(0x601d04))
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, us, ms, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, hs, nil))
+	svr := httptest.NewServer(router)
+	defer svr.Close()
+
+	// The requests build on each other, so they run in order.
+	steps := []struct {
+		name string
+		path string
+		body string
+	}{
+		{name: "create_owner", path: "/users",
+			body: `{"name":"Owner","address":"1 London Road","post_code":"N1","country":"GB"}`},
+		{name: "create_holder", path: "/users", body: `{"name":"Holder","address":"2 Rue de Rivoli","country":"FR"}`},
+		{name: "create_book", path: "/books",
+			body: `{"name":"The Golden Notebook","author":"Doris Lessing","owner_id":"00601d01-0000-4000-8000-000000000001"}`},
+		{name: "hold_book",
+			path: "/books/00601d0b-0000-4000-8000-000000000001/hold?user=00601d01-0000-4000-8000-000000000002"},
+	}
+	for _, tc := range steps {
+		t.Run(tc.name, func(t *testing.T) {
+			// Act
+			r, err := http.Post(svr.URL+tc.path, "application/json", strings.NewReader(tc.body))
+
+			// Assert
+			require.Nil(t, err)
+			defer r.Body.Close()
+			require.Equal(t, http.StatusOK, r.StatusCode)
+			body, err := io.ReadAll(r.Body)
+			require.Nil(t, err)
+			golden(t, tc.name+".golden", body)
+		})
+	}
+}
+
+// golden compares got with the contents of a golden file, which is rewritten when the tests
+// are run with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.Nil(t, os.WriteFile(path, got, 0644))
+	}
+	want, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+// testPNG encodes a PNG image of the given size.
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, x*height/width, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	require.Nil(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// loadShippingRates loads the rate table the application ships with.
+func loadShippingRates(t *testing.T) *db.ShippingRates {
+	t.Helper()
+	rates, err := db.LoadShippingRates(filepath.Join("..", "db", "shipping_rates.json"))
+	require.Nil(t, err)
+	return rates
 }
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/gorilla/mux"
+)
+
+// BookHold is invoked by HTTP POST /books/{id}/hold.
+// The book is held for the user given by ?user= until the hold period is over.
+func (h *Handler) BookHold(w http.ResponseWriter, r *http.Request) {
+	h.itemHold(w, r, db.BookItem)
+}
+
+// MagazineHold is invoked by HTTP POST /magazines/{id}/hold.
+// The magazine is held for the user given by ?user= until the hold period is over.
+func (h *Handler) MagazineHold(w http.ResponseWriter, r *http.Request) {
+	h.itemHold(w, r, db.MagazineItem)
+}
+
+// itemHold is a helper method that holds an item for a user.
+func (h *Handler) itemHold(w http.ResponseWriter, r *http.Request, itemType db.ItemType) {
+	held, err := h.hds.Hold(itemType, mux.Vars(r)["id"], r.URL.Query().Get("user"))
+	writeHold(w, held, err)
+}
+
+// ListUserHolds is invoked by HTTP GET /users/{id}/holds.
+// Only the active holds are listed, soonest to expire first.
+func (h *Handler) ListUserHolds(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.Hold](w, r)
+	if !ok {
+		return
+	}
+	holds, err := h.hds.ListByUser(mux.Vars(r)["id"])
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Hold]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeList(w, contentType, &Response[db.Hold]{
+		Items: holds,
+	})
+}
+
+// HoldRelease is invoked by HTTP POST /holds/{id}/release.
+// The hold must belong to the user given by ?user=.
+func (h *Handler) HoldRelease(w http.ResponseWriter, r *http.Request) {
+	released, err := h.hds.Release(mux.Vars(r)["id"], r.URL.Query().Get("user"))
+	writeHold(w, released, err)
+}
+
+// HoldSwap is invoked by HTTP POST /holds/{id}/swap.
+// The held item is swapped to the user given by ?user=, who must hold it.
+func (h *Handler) HoldSwap(w http.ResponseWriter, r *http.Request) {
+	converted, err := h.hds.Convert(mux.Vars(r)["id"], r.URL.Query().Get("user"))
+	writeHold(w, converted, err)
+}
+
+// writeHold is a helper function that writes a changed hold, or the error that stopped its change.
+func writeHold(w http.ResponseWriter, hold *db.Hold, err error) {
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Hold]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Hold]{
+		Items: []db.Hold{*hold},
+	})
+}
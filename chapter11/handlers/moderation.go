@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/gorilla/mux"
+)
+
+// admin guards the routes of the admin API and webhooks, which are only served to the admins given by ?user=.
+// The services of the admin API check the role again, so the guard only saves them the work of a request
+// they would refuse. Webhooks are only guarded here.
+func (h *Handler) admin(serve http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminID := r.URL.Query().Get("user")
+		if h.mds == nil || !h.mds.IsAdmin(adminID) {
+			writeResponse(w, http.StatusForbidden, &Response[db.AdminAction]{
+				Error: fmt.Errorf("%w: %s", db.ErrNotAdmin, adminID).Error(),
+			})
+			return
+		}
+		serve(w, r)
+	})
+}
+
+// ReportCreate is invoked by HTTP POST /reports.
+// The user reporting the item or user to the admins is given by ?user=.
+func (h *Handler) ReportCreate(w http.ResponseWriter, r *http.Request) {
+	body, err := readRequestBody(r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Report]{
+			Error: fmt.Errorf("invalid report body:%v", err).Error(),
+		})
+		return
+	}
+	var report db.Report
+	if err := json.Unmarshal(body, &report); err != nil {
+		writeResponse(w, http.StatusUnprocessableEntity, &Response[db.Report]{
+			Error: fmt.Errorf("invalid report body:%v", err).Error(),
+		})
+		return
+	}
+
+	created, err := h.mds.Report(report, r.URL.Query().Get("user"))
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Report]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[db.Report]{
+		Items: []db.Report{*created},
+	})
+}
+
+// ListReports is invoked by HTTP GET /admin/reports.
+// Only the reports with the status given by ?status= are listed, if it is given.
+func (h *Handler) ListReports(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.Report](w, r)
+	if !ok {
+		return
+	}
+	reports, err := h.mds.ListReports(r.URL.Query().Get("user"), db.ReportStatus(r.URL.Query().Get("status")))
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.Report]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeList(w, contentType, &Response[db.Report]{
+		Items: reports,
+	})
+}
+
+// ReportResolve is invoked by HTTP POST /admin/reports/{id}/resolve.
+func (h *Handler) ReportResolve(w http.ResponseWriter, r *http.Request) {
+	moderate(w, r, h.mds.ResolveReport)
+}
+
+// AdminListBooks is invoked by HTTP GET /admin/books.
+// Books are listed whatever their status, or only the flagged ones if ?flagged=true.
+func (h *Handler) AdminListBooks(w http.ResponseWriter, r *http.Request) {
+	moderatedList(w, r, h.mds.ListBooks)
+}
+
+// AdminListMagazines is invoked by HTTP GET /admin/magazines.
+// Magazines are listed whatever their status, or only the flagged ones if ?flagged=true.
+func (h *Handler) AdminListMagazines(w http.ResponseWriter, r *http.Request) {
+	moderatedList(w, r, h.mds.ListMagazines)
+}
+
+// BookFlag is invoked by HTTP POST /admin/books/{id}/flag.
+func (h *Handler) BookFlag(w http.ResponseWriter, r *http.Request) {
+	moderate(w, r, h.mds.FlagBook)
+}
+
+// BookUnflag is invoked by HTTP POST /admin/books/{id}/unflag.
+func (h *Handler) BookUnflag(w http.ResponseWriter, r *http.Request) {
+	moderate(w, r, h.mds.UnflagBook)
+}
+
+// BookRemove is invoked by HTTP POST /admin/books/{id}/remove.
+func (h *Handler) BookRemove(w http.ResponseWriter, r *http.Request) {
+	moderate(w, r, h.mds.RemoveBook)
+}
+
+// MagazineFlag is invoked by HTTP POST /admin/magazines/{id}/flag.
+func (h *Handler) MagazineFlag(w http.ResponseWriter, r *http.Request) {
+	moderate(w, r, h.mds.FlagMagazine)
+}
+
+// MagazineUnflag is invoked by HTTP POST /admin/magazines/{id}/unflag.
+func (h *Handler) MagazineUnflag(w http.ResponseWriter, r *http.Request) {
+	moderate(w, r, h.mds.UnflagMagazine)
+}
+
+// MagazineRemove is invoked by HTTP POST /admin/magazines/{id}/remove.
+func (h *Handler) MagazineRemove(w http.ResponseWriter, r *http.Request) {
+	moderate(w, r, h.mds.RemoveMagazine)
+}
+
+// UserSuspend is invoked by HTTP POST /admin/users/{id}/suspend.
+func (h *Handler) UserSuspend(w http.ResponseWriter, r *http.Request) {
+	moderate(w, r, h.mds.Suspend)
+}
+
+// UserReactivate is invoked by HTTP POST /admin/users/{id}/reactivate.
+func (h *Handler) UserReactivate(w http.ResponseWriter, r *http.Request) {
+	moderate(w, r, h.mds.Reactivate)
+}
+
+// ListAdminActions is invoked by HTTP GET /admin/actions.
+// Only the actions taken on the subject given by ?subject= are listed, if it is given.
+func (h *Handler) ListAdminActions(w http.ResponseWriter, r *http.Request) {
+	contentType, ok := negotiateList[db.AdminAction](w, r)
+	if !ok {
+		return
+	}
+	actions, err := h.mds.ListActions(r.URL.Query().Get("user"), r.URL.Query().Get("subject"))
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[db.AdminAction]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeList(w, contentType, &Response[db.AdminAction]{
+		Items: actions,
+	})
+}
+
+// moderate is a helper function that applies the action of the admin given by ?user= to the subject
+// of the request, for the reason given in the body.
+func moderate[T ResponseItemType](w http.ResponseWriter, r *http.Request,
+	apply func(id, adminID string, n db.ModerationNote) (*T, error)) {
+	body, err := readRequestBody(r)
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[T]{
+			Error: fmt.Errorf("invalid moderation body:%v", err).Error(),
+		})
+		return
+	}
+	var note db.ModerationNote
+	if err := json.Unmarshal(body, &note); err != nil {
+		writeResponse(w, http.StatusUnprocessableEntity, &Response[T]{
+			Error: fmt.Errorf("invalid moderation body:%v", err).Error(),
+		})
+		return
+	}
+
+	item, err := apply(mux.Vars(r)["id"], r.URL.Query().Get("user"), note)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[T]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response[T]{
+		Items: []T{*item},
+	})
+}
+
+// moderatedList is a helper function that lists the items of the catalogue to the admin given by ?user=.
+func moderatedList[T ResponseItemType](w http.ResponseWriter, r *http.Request,
+	list func(adminID string, flaggedOnly bool) ([]T, error)) {
+	contentType, ok := negotiateList[T](w, r)
+	if !ok {
+		return
+	}
+	var flaggedOnly bool
+	if v := r.URL.Query().Get("flagged"); v != "" {
+		var err error
+		if flaggedOnly, err = strconv.ParseBool(v); err != nil {
+			writeResponse(w, http.StatusBadRequest, &Response[T]{
+				Error: fmt.Sprintf("invalid flagged %q", v),
+			})
+			return
+		}
+	}
+	items, err := list(r.URL.Query().Get("user"), flaggedOnly)
+	if err != nil {
+		writeResponse(w, errorStatus(err), &Response[T]{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	writeList(w, contentType, &Response[T]{
+		Items: items,
+	})
+}
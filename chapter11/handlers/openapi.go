@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+)
+
+// operation documents a route registered in ConfigureServer.
+type operation struct {
+	method  string
+	path    string
+	id      string
+	summary string
+	// item is the schema of the items in the response, which also describes errors.
+	item string
+	// body is the schema of the request body, if there is one.
+	body string
+	// requestBody overrides the JSON request body, for routes which read other formats.
+	requestBody *openapi3.RequestBodyRef
+	query       openapi3.Parameters
+	// responses overrides the JSON response, for routes which do not write a Response.
+	responses openapi3.Responses
+	// list marks the routes which write their items in the content type negotiated by negotiateList.
+	list bool
+	// global marks the routes which are not scoped to the community of their request.
+	global bool
+}
+
+var (
+	userParam = &openapi3.ParameterRef{Value: openapi3.NewQueryParameter("user").
+			WithDescription("The user performing the operation.").
+			WithRequired(true).WithSchema(openapi3.NewStringSchema())}
+	catalogueContent = openapi3.NewContentWithSchema(openapi3.NewStringSchema(),
+		[]string{csvContentType, jsonlContentType})
+	adminParam = &openapi3.ParameterRef{Value: openapi3.NewQueryParameter("user").
+			WithDescription("The admin performing the operation.").
+			WithRequired(true).WithSchema(openapi3.NewStringSchema())}
+	shipToParam = &openapi3.ParameterRef{Value: openapi3.NewQueryParameter("ship_to").
+			WithDescription("Only lists the items which can be shipped to this user.").
+			WithSchema(openapi3.NewStringSchema())}
+	minRatingParam = &openapi3.ParameterRef{Value: openapi3.NewQueryParameter("min_rating").
+			WithDescription("Only lists the items whose owners have at least this average rating.").
+			WithSchema(openapi3.NewFloat64Schema().WithMin(db.MinRating).WithMax(db.MaxRating))}
+	atParam = &openapi3.ParameterRef{Value: openapi3.NewQueryParameter("at").
+		WithDescription("Returns the item as it was at this point in time.").
+		WithSchema(openapi3.NewDateTimeSchema())}
+	flaggedParam = &openapi3.ParameterRef{Value: openapi3.NewQueryParameter("flagged").
+			WithDescription("Only lists the flagged items.").
+			WithSchema(openapi3.NewBoolSchema())}
+	imageContent = openapi3.NewContentWithSchema(openapi3.NewStringSchema().WithFormat("binary"),
+		[]string{"image/jpeg", "image/png", "image/gif"})
+	communityParam = openapi3.NewHeaderParameter(CommunityHeader).
+			WithDescription("The slug of the community of the request. The community is looked up by host otherwise.").
+			WithSchema(openapi3.NewStringSchema())
+	imageBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().
+			WithDescription(fmt.Sprintf("A JPEG, PNG or GIF image of at most %d bytes.", db.MaxImageSize)).
+			WithRequired(true).WithContent(imageContent)}
+)
+
+// operations contains every route of the API. It must be kept in line with ConfigureServer.
+var operations = []operation{
+	{method: "GET", path: "/", id: "Index", summary: "Welcome message and the available books", item: "Book",
+		list: true},
+	{method: "GET", path: "/books", id: "ListBooks", summary: "List the available books", item: "Book",
+		query: openapi3.Parameters{
+			{Value: openapi3.NewQueryParameter("isbn").
+				WithDescription("Only lists the books of this ISBN-10 or ISBN-13.").
+				WithSchema(openapi3.NewStringSchema())},
+			shipToParam,
+			minRatingParam,
+		}, list: true},
+	{method: "POST", path: "/books", id: "BookUpsert", summary: "Create or update a book", item: "Book", body: "Book"},
+	{method: "GET", path: "/books/{id}", id: "GetBook", summary: "Get a book", item: "Book",
+		query: openapi3.Parameters{atParam}},
+	{method: "POST", path: "/books/{id}", id: "SwapBook", summary: "Swap a book to a user", item: "Book",
+		query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/books/{id}/delivery", id: "BookDelivery", summary: "Confirm the delivery of a book",
+		item: "Book", query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/books/{id}/relist", id: "BookRelist", summary: "Put a book back on the catalogue",
+		item: "Book", query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/books/{id}/withdraw", id: "BookWithdraw", summary: "Take a book off the catalogue",
+		item: "Book", query: openapi3.Parameters{userParam}},
+	{method: "GET", path: "/books/{id}/history", id: "BookHistory", summary: "List the history of a book",
+		item: "ItemEvent", list: true},
+	{method: "GET", path: "/books/{id}/quote", id: "BookQuote", summary: "Quote the cost of posting a book to a user",
+		item: "ShippingQuote", query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/books/{id}/reviews", id: "BookReview",
+		summary: "Review the other side of a delivered swap of a book", item: "Review", body: "Review",
+		query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/books/{id}/images", id: "BookImageUpload", summary: "Upload an image of a book",
+		item: "Book", requestBody: imageBody, query: openapi3.Parameters{userParam}},
+	{method: "GET", path: "/magazines", id: "ListMagazines", summary: "List the available magazines", item: "Magazine",
+		query: openapi3.Parameters{shipToParam, minRatingParam}, list: true},
+	{method: "POST", path: "/magazines", id: "MagazineUpsert", summary: "Create or update a magazine",
+		item: "Magazine", body: "Magazine"},
+	{method: "GET", path: "/magazines/{id}", id: "GetMagazine", summary: "Get a magazine", item: "Magazine",
+		query: openapi3.Parameters{atParam}},
+	{method: "POST", path: "/magazines/{id}", id: "SwapMagazine", summary: "Swap a magazine to a user",
+		item: "Magazine", query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/magazines/{id}/delivery", id: "MagazineDelivery",
+		summary: "Confirm the delivery of a magazine", item: "Magazine", query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/magazines/{id}/relist", id: "MagazineRelist",
+		summary: "Put a magazine back on the catalogue", item: "Magazine", query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/magazines/{id}/withdraw", id: "MagazineWithdraw",
+		summary: "Take a magazine off the catalogue", item: "Magazine", query: openapi3.Parameters{userParam}},
+	{method: "GET", path: "/magazines/{id}/history", id: "MagazineHistory", summary: "List the history of a magazine",
+		item: "ItemEvent", list: true},
+	{method: "POST", path: "/magazines/{id}/reviews", id: "MagazineReview",
+		summary: "Review the other side of a delivered swap of a magazine", item: "Review", body: "Review",
+		query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/magazines/{id}/images", id: "MagazineImageUpload",
+		summary: "Upload an image of a magazine", item: "Magazine", requestBody: imageBody,
+		query: openapi3.Parameters{userParam}},
+	{method: "GET", path: "/users", id: "ListUsers", summary: "List the users", item: "User", list: true},
+	{method: "POST", path: "/users", id: "UserUpsert", summary: "Create or update a user", item: "Book", body: "User"},
+	{method: "GET", path: "/users/{id}/books", id: "ListUserByID_Books", summary: "Get a user and their books",
+		item: "Book"},
+	{method: "GET", path: "/users/{id}/magazines", id: "ListUserByID_Magazines",
+		summary: "Get a user and their magazines", item: "Magazine"},
+	{method: "GET", path: "/users/{id}/history", id: "UserHistory", summary: "List the history of a user's items",
+		item: "ItemEvent", list: true},
+	{method: "GET", path: "/users/{id}/wishlist", id: "ListWishlist", summary: "List a user's wishlist",
+		item: "WishlistItem", list: true},
+	{method: "POST", path: "/users/{id}/wishlist", id: "WishlistAdd", summary: "Add an item to a user's wishlist",
+		item: "WishlistItem", body: "WishlistItem"},
+	{method: "DELETE", path: "/users/{id}/wishlist/{itemID}", id: "WishlistRemove",
+		summary: "Remove an item from a user's wishlist", item: "WishlistItem"},
+	{method: "GET", path: "/users/{id}/notifications", id: "ListNotifications", summary: "List a user's notifications",
+		item: "Notification", list: true},
+	{method: "GET", path: "/users/{id}/credits", id: "GetCredits", summary: "Get a user's credit balance and ledger",
+		item: "CreditAccount"},
+	{method: "POST", path: "/users/{id}/credits", id: "CreditAdjust", summary: "Adjust a user's credit balance",
+		item: "CreditAccount", body: "CreditAdjustment", query: openapi3.Parameters{adminParam}},
+	{method: "GET", path: "/users/{id}/reviews", id: "ListUserReviews",
+		summary: "List the reviews a user has received and their reputation", item: "Review"},
+	{method: "POST", path: "/reviews/{id}/flag", id: "ReviewFlag", summary: "Flag a review for moderation",
+		item: "Review", body: "ReviewFlag", query: openapi3.Parameters{userParam}},
+	{method: "GET", path: "/images/{key}", id: "GetImage", summary: "Get an uploaded image or its thumbnail",
+		item: "Book", responses: openapi3.Responses{
+			"200": {Value: openapi3.NewResponse().WithDescription("The image.").WithContent(imageContent)},
+		}},
+	{method: "GET", path: "/webhooks", id: "ListWebhooks", summary: "List the webhook subscriptions",
+		item: "WebhookSubscription", query: openapi3.Parameters{adminParam}, list: true},
+	{method: "POST", path: "/webhooks", id: "WebhookCreate", summary: "Subscribe a webhook to item events",
+		item: "WebhookSubscription", body: "WebhookSubscription", query: openapi3.Parameters{adminParam}},
+	{method: "GET", path: "/webhooks/{id}", id: "GetWebhook", summary: "Get a webhook subscription",
+		item: "WebhookSubscription", query: openapi3.Parameters{adminParam}},
+	{method: "DELETE", path: "/webhooks/{id}", id: "WebhookDelete", summary: "Unsubscribe a webhook",
+		item: "WebhookSubscription", query: openapi3.Parameters{adminParam}},
+	{method: "GET", path: "/webhooks/{id}/deliveries", id: "ListWebhookDeliveries",
+		summary: "List the delivery log of a webhook", item: "WebhookDelivery", query: openapi3.Parameters{adminParam},
+		list: true},
+	{method: "POST", path: "/webhooks/{id}/deliveries/{deliveryID}/replay", id: "WebhookReplay",
+		summary: "Send a webhook delivery again", item: "WebhookDelivery", query: openapi3.Parameters{adminParam}},
+	{method: "GET", path: "/events", id: "Events", summary: "Stream item events as Server-Sent Events",
+		item: "ItemEvent", query: openapi3.Parameters{
+			{Value: openapi3.NewQueryParameter("type").
+				WithDescription("Comma separated event types to stream.").
+				WithSchema(openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema()))},
+			{Value: openapi3.NewQueryParameter("item_type").WithSchema(openapi3.NewStringSchema().
+				WithEnum(db.BookItem, db.MagazineItem))},
+			{Value: openapi3.NewQueryParameter("owner").WithSchema(openapi3.NewStringSchema())},
+			{Value: openapi3.NewQueryParameter("last_event_id").
+				WithDescription("Resumes the stream after this event.").
+				WithSchema(openapi3.NewInt64Schema())},
+			{Value: openapi3.NewHeaderParameter("Last-Event-ID").
+				WithDescription("Resumes the stream after this event.").
+				WithSchema(openapi3.NewInt64Schema())},
+		},
+		responses: openapi3.Responses{
+			"200": {Value: openapi3.NewResponse().WithDescription("A stream of item events.").
+				WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{"text/event-stream"}))},
+		}},
+	{method: "POST", path: "/graphql", id: "GraphQL", summary: "Query users, books and magazines with GraphQL",
+		body: "GraphQLRequest", responses: openapi3.Responses{
+			"200": {Value: openapi3.NewResponse().WithDescription("The result of the query.").
+				WithJSONSchemaRef(schemaRef("GraphQLResponse"))},
+		}},
+	{method: "POST", path: "/import", id: "Import", summary: "Import books and magazines in bulk",
+		item: "ImportResult", requestBody: &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().
+			WithDescription("A CSV file with a header row, or JSON Lines, with the columns item_type, id, name, " +
+				"author, isbn, publisher, year, edition, issue_number, condition, language, tags, owner_id " +
+				"and status. Tags are separated by semicolons in CSV.").
+			WithRequired(true).WithContent(catalogueContent)},
+		query: openapi3.Parameters{
+			{Value: openapi3.NewQueryParameter("dry_run").
+				WithDescription("Only validates the rows.").
+				WithSchema(openapi3.NewBoolSchema())},
+			{Value: openapi3.NewQueryParameter("batch").
+				WithDescription("The number of rows imported per transaction.").
+				WithSchema(openapi3.NewIntegerSchema().WithMin(1).WithMax(maxImportBatch))},
+		}},
+	{method: "GET", path: "/export", id: "Export", summary: "Export the books and magazines in bulk",
+		item: "Book", query: openapi3.Parameters{
+			{Value: openapi3.NewQueryParameter("format").WithSchema(openapi3.NewStringSchema().
+				WithEnum("csv", "jsonl"))},
+			{Value: openapi3.NewQueryParameter("type").WithSchema(openapi3.NewStringSchema().
+				WithEnum(db.BookItem, db.MagazineItem))},
+		},
+		responses: openapi3.Responses{
+			"200": {Value: openapi3.NewResponse().WithDescription("The catalogue as CSV or JSON Lines.").
+				WithContent(catalogueContent)},
+		}},
+	{method: "GET", path: "/communities", id: "ListCommunities", summary: "List the communities",
+		item: "Community", global: true},
+	{method: "POST", path: "/communities", id: "CommunityUpsert", summary: "Create or update a community",
+		item: "Community", body: "Community", query: openapi3.Parameters{adminParam}, global: true},
+	{method: "POST", path: "/reports", id: "ReportCreate", summary: "Report an item or a user to the admins",
+		item: "Report", body: "Report", query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/books/{id}/hold", id: "BookHold", summary: "Hold a book while deciding whether to swap it",
+		item: "Hold", query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/magazines/{id}/hold", id: "MagazineHold",
+		summary: "Hold a magazine while deciding whether to swap it", item: "Hold",
+		query: openapi3.Parameters{userParam}},
+	{method: "GET", path: "/users/{id}/holds", id: "ListUserHolds", summary: "List a user's active holds",
+		item: "Hold", list: true},
+	{method: "POST", path: "/holds/{id}/release", id: "HoldRelease",
+		summary: "Release a hold and put the item back on the catalogue", item: "Hold",
+		query: openapi3.Parameters{userParam}},
+	{method: "POST", path: "/holds/{id}/swap", id: "HoldSwap", summary: "Swap a held item to its holder",
+		item: "Hold", query: openapi3.Parameters{userParam}},
+	{method: "GET", path: "/admin/reports", id: "ListReports", summary: "List the reports of users", item: "Report",
+		query: openapi3.Parameters{adminParam, {Value: openapi3.NewQueryParameter("status").
+			WithSchema(openapi3.NewStringSchema().WithEnum(db.ReportOpen, db.ReportResolved))}},
+		list: true, global: true},
+	{method: "POST", path: "/admin/reports/{id}/resolve", id: "ReportResolve", summary: "Resolve a report",
+		item: "Report", body: "ModerationNote", query: openapi3.Parameters{adminParam}, global: true},
+	{method: "GET", path: "/admin/books", id: "AdminListBooks", summary: "List the books, whatever their status",
+		item: "Book", query: openapi3.Parameters{adminParam, flaggedParam}, list: true, global: true},
+	{method: "POST", path: "/admin/books/{id}/flag", id: "BookFlag",
+		summary: "Hide a book from the catalogue until it is moderated", item: "Book", body: "ModerationNote",
+		query: openapi3.Parameters{adminParam}, global: true},
+	{method: "POST", path: "/admin/books/{id}/unflag", id: "BookUnflag", summary: "Put a flagged book back",
+		item: "Book", body: "ModerationNote", query: openapi3.Parameters{adminParam}, global: true},
+	{method: "POST", path: "/admin/books/{id}/remove", id: "BookRemove", summary: "Take a book down for good",
+		item: "Book", body: "ModerationNote", query: openapi3.Parameters{adminParam}, global: true},
+	{method: "GET", path: "/admin/magazines", id: "AdminListMagazines",
+		summary: "List the magazines, whatever their status", item: "Magazine",
+		query: openapi3.Parameters{adminParam, flaggedParam}, list: true, global: true},
+	{method: "POST", path: "/admin/magazines/{id}/flag", id: "MagazineFlag",
+		summary: "Hide a magazine from the catalogue until it is moderated", item: "Magazine",
+		body: "ModerationNote", query: openapi3.Parameters{adminParam}, global: true},
+	{method: "POST", path: "/admin/magazines/{id}/unflag", id: "MagazineUnflag",
+		summary: "Put a flagged magazine back", item: "Magazine", body: "ModerationNote",
+		query: openapi3.Parameters{adminParam}, global: true},
+	{method: "POST", path: "/admin/magazines/{id}/remove", id: "MagazineRemove",
+		summary: "Take a magazine down for good", item: "Magazine", body: "ModerationNote",
+		query: openapi3.Parameters{adminParam}, global: true},
+	{method: "POST", path: "/admin/users/{id}/suspend", id: "UserSuspend",
+		summary: "Stop a user listing and swapping items", item: "User", body: "ModerationNote",
+		query: openapi3.Parameters{adminParam}, global: true},
+	{method: "POST", path: "/admin/users/{id}/reactivate", id: "UserReactivate",
+		summary: "Let a suspended user list and swap items again", item: "User", body: "ModerationNote",
+		query: openapi3.Parameters{adminParam}, global: true},
+	{method: "GET", path: "/admin/actions", id: "ListAdminActions", summary: "List the audit log of admin actions",
+		item: "AdminAction", query: openapi3.Parameters{adminParam, {Value: openapi3.NewQueryParameter("subject").
+			WithDescription("Only lists the actions taken on this item, user, report or community.").
+			WithSchema(openapi3.NewStringSchema())}}, list: true, global: true},
+	{method: "GET", path: "/openapi.json", id: "OpenAPI", summary: "This document", responses: openapi3.Responses{
+		"200": {Value: openapi3.NewResponse().WithDescription("The OpenAPI document of the API.").
+			WithJSONSchema(openapi3.NewObjectSchema())},
+	}, global: true},
+}
+
+// schemaTypes contains the types which are documented as component schemas.
+var schemaTypes = map[string]any{
+	"Book":                db.Book{},
+	"Magazine":            db.Magazine{},
+	"User":                db.User{},
+	"ItemEvent":           db.ItemEvent{},
+	"WishlistItem":        db.WishlistItem{},
+	"Notification":        db.Notification{},
+	"WebhookSubscription": db.WebhookSubscription{},
+	"WebhookDelivery":     db.WebhookDelivery{},
+	"ImportResult":        db.ImportResult{},
+	"ShippingQuote":       db.ShippingQuote{},
+	"CreditAccount":       db.CreditAccount{},
+	"CreditAdjustment":    db.CreditAdjustment{},
+	"Review":              db.Review{},
+	"ReviewFlag":          db.ReviewFlag{},
+	"Reputation":          db.Reputation{},
+	"Community":           db.Community{},
+	"Report":              db.Report{},
+	"ModerationNote":      db.ModerationNote{},
+	"AdminAction":         db.AdminAction{},
+	"Hold":                db.Hold{},
+}
+
+// enums contains the values of the string types which only take known values.
+var enums = map[reflect.Type][]any{
+	reflect.TypeOf(db.ItemType("")): {db.BookItem, db.MagazineItem},
+	reflect.TypeOf(db.ItemCondition("")): {db.ConditionNew, db.ConditionLikeNew, db.ConditionGood,
+		db.ConditionFair, db.ConditionPoor},
+	reflect.TypeOf(db.NotificationType("")): {db.WishlistMatch, db.SwapRequested, db.SwapAccepted,
+		db.SwapPosted},
+	reflect.TypeOf(db.DeliveryStatus("")): {db.DeliveryPending, db.DeliverySent, db.DeliveryFailed,
+		db.DeliverySkipped},
+	reflect.TypeOf(db.CreditReason("")): {db.CreditGranted, db.CreditEarned, db.CreditSpent, db.CreditRefunded,
+		db.CreditReversed, db.CreditAdjusted},
+	reflect.TypeOf(db.SubjectType("")): {db.BookSubject, db.MagazineSubject, db.UserSubject, db.ReportSubject,
+		db.CommunitySubject},
+	reflect.TypeOf(db.ReportStatus("")): {db.ReportOpen, db.ReportResolved},
+	reflect.TypeOf(db.HoldStatus("")):   {db.HoldActive, db.HoldReleased, db.HoldExpired, db.HoldConverted},
+	reflect.TypeOf(db.AdminActionType("")): {db.ActionFlagged, db.ActionUnflagged, db.ActionRemoved,
+		db.ActionSuspended, db.ActionReactivated, db.ActionResolved, db.ActionCreditsAdjusted,
+		db.ActionCommunitySaved},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+	openAPIErr  error
+)
+
+// OpenAPI is invoked by HTTP GET /openapi.json.
+func (h *Handler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		var doc *openapi3.T
+		doc, openAPIErr = newOpenAPI()
+		if openAPIErr == nil {
+			openAPIDoc, openAPIErr = json.Marshal(doc)
+		}
+	})
+	if openAPIErr != nil {
+		writeResponse(w, http.StatusInternalServerError, &Response[db.Book]{
+			Error: openAPIErr.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Write(openAPIDoc)
+}
+
+// newOpenAPI generates the OpenAPI document of the API from its operations
+// and the types that they read and write.
+func newOpenAPI() (*openapi3.T, error) {
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "BookSwap",
+			Version: "1.0.0",
+		},
+		Paths: openapi3.Paths{},
+		Components: &openapi3.Components{
+			Schemas: openapi3.Schemas{},
+		},
+	}
+
+	for name, v := range schemaTypes {
+		ref, err := openapi3gen.NewSchemaRefForValue(v, nil, openapi3gen.SchemaCustomizer(customizeSchema))
+		if err != nil {
+			return nil, fmt.Errorf("schema %s:%v", name, err)
+		}
+		doc.Components.Schemas[name] = ref
+		doc.Components.Schemas[name+"Response"] = openapi3.NewSchemaRef("", responseSchema(name))
+	}
+	doc.Components.Schemas["GraphQLRequest"] = openapi3.NewSchemaRef("", openapi3.NewObjectSchema().
+		WithProperty("query", openapi3.NewStringSchema()).
+		WithProperty("operationName", openapi3.NewStringSchema()).
+		WithProperty("variables", openapi3.NewObjectSchema()))
+	doc.Components.Schemas["GraphQLRequest"].Value.Required = []string{"query"}
+	doc.Components.Schemas["GraphQLResponse"] = openapi3.NewSchemaRef("", openapi3.NewObjectSchema().
+		WithProperty("data", openapi3.NewObjectSchema().WithNullable()).
+		WithProperty("errors", openapi3.NewArraySchema().WithItems(openapi3.NewObjectSchema())))
+
+	for _, op := range operations {
+		o := openapi3.NewOperation()
+		o.OperationID = op.id
+		o.Summary = op.summary
+		for _, name := range pathParams(op.path) {
+			o.AddParameter(openapi3.NewPathParameter(name).WithSchema(openapi3.NewStringSchema()))
+		}
+		o.Parameters = append(o.Parameters, op.query...)
+		if !op.global {
+			o.AddParameter(communityParam)
+		}
+		if op.body != "" {
+			o.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().WithRequired(true).
+				WithJSONSchemaRef(schemaRef(op.body))}
+		}
+		if op.requestBody != nil {
+			o.RequestBody = op.requestBody
+		}
+		o.Responses = openapi3.Responses{}
+		for status, r := range op.responses {
+			o.Responses[status] = r
+		}
+		if len(o.Responses) == 0 {
+			o.Responses = openapi3.Responses{
+				"200": {Value: openapi3.NewResponse().WithDescription("OK").
+					WithJSONSchemaRef(schemaRef(op.item + "Response"))},
+			}
+		}
+		if op.list {
+			content := o.Responses["200"].Value.Content
+			content[csvContentType] = openapi3.NewMediaType().WithSchema(openapi3.NewStringSchema())
+			content[msgpackContentType] = openapi3.NewMediaType().
+				WithSchema(openapi3.NewStringSchema().WithFormat("binary"))
+			o.Responses["406"] = &openapi3.ResponseRef{Value: openapi3.NewResponse().
+				WithDescription("None of the accepted content types is supported.").
+				WithJSONSchemaRef(schemaRef(op.item + "Response"))}
+		}
+		if op.item != "" {
+			o.Responses["default"] = &openapi3.ResponseRef{Value: openapi3.NewResponse().
+				WithDescription("The error which occurred.").
+				WithJSONSchemaRef(schemaRef(op.item + "Response"))}
+		}
+
+		item := doc.Paths[op.path]
+		if item == nil {
+			item = &openapi3.PathItem{}
+			doc.Paths[op.path] = item
+		}
+		item.SetOperation(op.method, o)
+	}
+
+	return doc, nil
+}
+
+// customizeSchema documents statuses by name and lists the values of enumerated types.
+// Objects are closed, so that responses which do not match their schema are caught.
+func customizeSchema(name string, t reflect.Type, tag reflect.StructTag, schema *openapi3.Schema) error {
+	switch {
+	case t == reflect.TypeOf(db.BookStatus(0)):
+		*schema = *openapi3.NewStringSchema()
+		for _, s := range db.BookStatusNames() {
+			schema.Enum = append(schema.Enum, s)
+		}
+	case t == reflect.TypeOf(db.ItemEventType("")):
+		for _, et := range db.ItemEventTypes() {
+			schema.Enum = append(schema.Enum, et)
+		}
+	case enums[t] != nil:
+		schema.Enum = enums[t]
+	case schema.Type == openapi3.TypeObject:
+		schema.WithoutAdditionalProperties()
+	}
+	return nil
+}
+
+// responseSchema describes the Response written for items of the named schema.
+func responseSchema(item string) *openapi3.Schema {
+	s := openapi3.NewObjectSchema().
+		WithProperty("message", openapi3.NewStringSchema()).
+		WithProperty("error", openapi3.NewStringSchema()).
+		WithPropertyRef("items", openapi3.NewSchemaRef("", &openapi3.Schema{
+			Type:  openapi3.TypeArray,
+			Items: schemaRef(item),
+		})).
+		WithPropertyRef("user", schemaRef("User")).
+		WithPropertyRef("reputation", schemaRef("Reputation"))
+	return s.WithoutAdditionalProperties()
+}
+
+func schemaRef(name string) *openapi3.SchemaRef {
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, nil)
+}
+
+// pathParams returns the names of the variables in a route path.
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, strings.Trim(segment, "{}"))
+		}
+	}
+	return names
+}
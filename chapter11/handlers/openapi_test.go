@@ -0,0 +1,387 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/events"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/handlers"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadOpenAPI reads the OpenAPI document served by the router.
+func loadOpenAPI(t *testing.T, router http.Handler) *openapi3.T {
+	t.Helper()
+	req, err := http.NewRequest("GET", "/openapi.json", nil)
+	require.Nil(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	doc, err := openapi3.NewLoader().LoadFromData(rr.Body.Bytes())
+	require.Nil(t, err)
+	return doc
+}
+
+func TestOpenAPI(t *testing.T) {
+	// Arrange
+	router := handlers.ConfigureServer(handlers.NewHandler(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil))
+
+	// Act
+	doc := loadOpenAPI(t, router)
+
+	// Assert
+	require.Nil(t, doc.Validate(context.Background()))
+
+	t.Run("every route is documented", func(t *testing.T) {
+		routed := map[string]bool{}
+		err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			path, err := route.GetPathTemplate()
+			require.Nil(t, err)
+			methods, err := route.GetMethods()
+			require.Nil(t, err)
+			for _, method := range methods {
+				routed[method+" "+path] = true
+				item := doc.Paths.Find(path)
+				require.NotNil(t, item, "%s %s is not documented", method, path)
+				assert.NotNil(t, item.GetOperation(method), "%s %s is not documented", method, path)
+			}
+			return nil
+		})
+		require.Nil(t, err)
+
+		for path, item := range doc.Paths {
+			for method := range item.Operations() {
+				assert.True(t, routed[method+" "+path], "%s %s is documented but not routed", method, path)
+			}
+		}
+	})
+
+	t.Run("statuses are documented by name", func(t *testing.T) {
+		status := doc.Components.Schemas["Book"].Value.Properties["status"].Value
+		assert.Equal(t, openapi3.TypeString, status.Type)
+		assert.Contains(t, status.Enum, db.InTransit.String())
+	})
+}
+
+// contract drives a router, validating every request and response against its OpenAPI document.
+type contract struct {
+	t       *testing.T
+	router  http.Handler
+	routes  routers.Router
+	covered map[string]bool
+}
+
+// do sends a request through the router and returns its response, once both have been validated.
+func (c *contract) do(ctx context.Context, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	c.t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, r)
+	require.Nil(c.t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	route, pathParams, err := c.routes.FindRoute(req)
+	require.Nil(c.t, err, "%s %s", method, path)
+	c.covered[route.Operation.OperationID] = true
+	reqInput := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+	}
+	require.Nil(c.t, openapi3filter.ValidateRequest(ctx, reqInput), "%s %s", method, path)
+
+	rr := httptest.NewRecorder()
+	c.router.ServeHTTP(rr, req)
+	respInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: reqInput,
+		Status:                 rr.Code,
+		Header:                 rr.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(rr.Body.Bytes())),
+	}
+	require.Nil(c.t, openapi3filter.ValidateResponse(context.Background(), respInput),
+		"%s %s responded %d %s", method, path, rr.Code, rr.Body.String())
+	return rr
+}
+
+// items decodes the items of a response.
+func items[T handlers.ResponseItemType](t *testing.T, rr *httptest.ResponseRecorder) []T {
+	t.Helper()
+	var resp handlers.Response[T]
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return resp.Items
+}
+
+// ids returns the IDs of the given books.
+func ids(books []db.Book) []string {
+	ids := make([]string, 0, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+	return ids
+}
+
+func TestOpenAPIContractIntegration(t *testing.T) {
+	if os.Getenv("LONG") == "" {
+		t.Skip("Skipping TestOpenAPIContractIntegration in short mode.")
+	}
+	testDB, cleaner := db.OpenDB(t)
+	defer cleaner()
+	// Arrange
+	openapi3filter.RegisterBodyDecoder("text/event-stream", openapi3filter.FileBodyDecoder)
+	defer openapi3filter.UnregisterBodyDecoder("text/event-stream")
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", openapi3filter.FileBodyDecoder)
+	defer openapi3filter.UnregisterBodyDecoder("application/x-ndjson")
+	openapi3filter.RegisterBodyDecoder("image/png", openapi3filter.FileBodyDecoder)
+	defer openapi3filter.UnregisterBodyDecoder("image/png")
+	openapi3filter.RegisterBodyDecoder("image/gif", openapi3filter.FileBodyDecoder)
+	defer openapi3filter.UnregisterBodyDecoder("image/gif")
+	openapi3filter.RegisterBodyDecoder("application/msgpack", openapi3filter.FileBodyDecoder)
+	defer openapi3filter.UnregisterBodyDecoder("application/msgpack")
+	ps := db.NewPostingService()
+	eb := events.NewBroker(events.DefaultBuffer)
+	bs := db.NewBookService(testDB, ps, eb, nil, nil, nil)
+	ms := db.NewMagazineService(testDB, ps, eb, nil, nil)
+	us := db.NewUserService(testDB, bs, ms, nil, nil)
+	admin, err := us.Upsert(db.User{Name: "Admin"})
+	require.Nil(t, err)
+	store, err := db.NewFileBlobStore(t.TempDir())
+	require.Nil(t, err)
+	router := handlers.ConfigureServer(handlers.NewHandler(bs, us, ms, db.NewHistoryService(testDB, nil, nil),
+		db.NewWishlistService(testDB, nil, nil), db.NewNotificationService(testDB, nil, nil),
+		db.NewWebhookService(testDB, nil, nil), db.NewCatalogueService(testDB, bs, ms, nil, nil),
+		db.NewShippingService(testDB, loadShippingRates(t), nil, nil),
+		db.NewCreditService(testDB, []string{admin.ID}, nil, nil), db.NewReviewService(testDB, nil, nil),
+		db.NewImageService(testDB, bs, ms, store, nil, nil), db.NewCommunityService(testDB, []string{admin.ID}, nil, nil),
+		db.NewModerationService(testDB, bs, ms, []string{admin.ID}, nil, nil),
+		db.NewHoldService(testDB, bs, ms, time.Hour, nil, nil), eb))
+	doc := loadOpenAPI(t, router)
+	routes, err := gorillamux.NewRouter(doc)
+	require.Nil(t, err)
+	c := &contract{t: t, router: router, routes: routes, covered: map[string]bool{}}
+	ctx := context.Background()
+	var resp handlers.Response[db.Book]
+
+	// Act & Assert
+	c.do(ctx, "GET", "/openapi.json", "", nil)
+	rr := c.do(ctx, "POST", "/users", `{"name":"Owner","email":"owner@example.com","post_code":"N1","country":"GB"}`, nil)
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	owner := resp.User
+	rr = c.do(ctx, "POST", "/users", `{"name":"Swapper","address":"1 Main St","country":"FR"}`, nil)
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	swapper := resp.User
+
+	rr = c.do(ctx, "POST", "/webhooks", `{"url":"http://localhost/hook","event_types":["CREATED"]}`, nil)
+	subscriptions := items[db.WebhookSubscription](t, rr)
+	require.Equal(t, 1, len(subscriptions))
+	webhook := subscriptions[0].ID
+	c.do(ctx, "GET", "/webhooks", "", nil)
+	c.do(ctx, "GET", "/webhooks/"+webhook, "", nil)
+
+	rr = c.do(ctx, "POST", "/books", fmt.Sprintf(`{"name":"Dune","author":"Frank Herbert","owner_id":%q,`+
+		`"edition":"First","condition":"like_new","language":"EN","tags":["Sci-Fi","classic"]}`, owner.ID), nil)
+	books := items[db.Book](t, rr)
+	require.Equal(t, 1, len(books))
+	assert.Equal(t, db.ConditionLikeNew, books[0].Condition)
+	assert.Equal(t, db.Tags{"classic", "sci-fi"}, books[0].Tags)
+	book := books[0].ID
+	rr = c.do(ctx, "POST", "/magazines", fmt.Sprintf(`{"name":"Wired","issue_number":7,"owner_id":%q}`, owner.ID), nil)
+	mags := items[db.Magazine](t, rr)
+	require.Equal(t, 1, len(mags))
+	mag := mags[0].ID
+
+	pngHeader := http.Header{"Content-Type": {"image/png"}}
+	rr = c.do(ctx, "POST", "/books/"+book+"/images?user="+owner.ID, string(testPNG(t, 40, 20)), pngHeader)
+	books = items[db.Book](t, rr)
+	require.Equal(t, 1, len(books[0].Images))
+	c.do(ctx, "GET", books[0].Images[0].URL, "", nil)
+	c.do(ctx, "GET", books[0].Images[0].ThumbnailURL, "", nil)
+	rr = c.do(ctx, "POST", "/magazines/"+mag+"/images?user="+owner.ID, string(testPNG(t, 20, 40)), pngHeader)
+	assert.Equal(t, 1, len(items[db.Magazine](t, rr)[0].Images))
+	assert.Equal(t, http.StatusUnsupportedMediaType, c.do(ctx, "POST", "/books/"+book+"/images?user="+owner.ID,
+		"GIF89a", http.Header{"Content-Type": {"image/gif"}}).Code)
+
+	rr = c.do(ctx, "POST", "/import?dry_run=true", "item_type,name,owner_id\nBOOK,Emma,"+owner.ID+"\n",
+		http.Header{"Content-Type": {"text/csv"}})
+	assert.Equal(t, 1, len(items[db.ImportResult](t, rr)))
+	c.do(ctx, "GET", "/export", "", nil)
+	rr = c.do(ctx, "GET", "/export?format=jsonl&type=MAGAZINE", "", nil)
+	assert.Contains(t, rr.Body.String(), mag)
+
+	c.do(ctx, "GET", "/", "", nil)
+	c.do(ctx, "GET", "/books", "", nil)
+	c.do(ctx, "GET", "/books?isbn=0-441-17271-7", "", nil)
+	c.do(ctx, "GET", "/magazines", "", nil)
+	c.do(ctx, "GET", "/magazines", "", http.Header{"Accept": {"text/csv"}})
+	c.do(ctx, "GET", "/books", "", http.Header{"Accept": {"application/msgpack"}})
+	assert.Equal(t, http.StatusNotAcceptable,
+		c.do(ctx, "GET", "/books", "", http.Header{"Accept": {"application/xml"}}).Code)
+	assert.Contains(t, items[db.Book](t, c.do(ctx, "GET", "/books?ship_to="+swapper.ID, "", nil)), books[0])
+	c.do(ctx, "GET", "/magazines?ship_to="+swapper.ID, "", nil)
+	quotes := items[db.ShippingQuote](t, c.do(ctx, "GET", "/books/"+book+"/quote?user="+swapper.ID, "", nil))
+	require.Equal(t, 1, len(quotes))
+	assert.Equal(t, "FR", quotes[0].To)
+	assert.Equal(t, http.StatusBadRequest, c.do(ctx, "GET", "/books/"+book+"/quote?user="+owner.ID, "", nil).Code)
+	assert.Contains(t, items[db.User](t, c.do(ctx, "GET", "/users", "", nil)), *owner)
+	c.do(ctx, "GET", "/books/"+book, "", nil)
+	c.do(ctx, "GET", "/books/"+book+"?at="+time.Now().UTC().Format(time.RFC3339), "", nil)
+	c.do(ctx, "GET", "/magazines/"+mag, "", nil)
+	c.do(ctx, "GET", "/users/"+owner.ID+"/books", "", nil)
+	c.do(ctx, "GET", "/users/"+owner.ID+"/magazines", "", nil)
+	assert.Equal(t, http.StatusNotFound, c.do(ctx, "GET", "/books/unknown", "", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, c.do(ctx, "POST", "/books/"+book+"?user=unknown", "", nil).Code)
+
+	// Swapping both items costs more than the starting credits.
+	rr = c.do(ctx, "POST", "/users/"+swapper.ID+"/credits?user="+admin.ID, `{"amount":1,"note":"Contract test"}`, nil)
+	accounts := items[db.CreditAccount](t, rr)
+	require.Equal(t, 1, len(accounts))
+	assert.Equal(t, db.StartingCredits+1, accounts[0].Balance)
+	assert.Equal(t, http.StatusForbidden, c.do(ctx, "POST", "/users/"+swapper.ID+"/credits?user="+swapper.ID,
+		`{"amount":1,"note":"Contract test"}`, nil).Code)
+	for _, item := range []string{"/books/" + book, "/magazines/" + mag} {
+		for _, action := range []string{"", "/delivery", "/relist", "/withdraw"} {
+			rr := c.do(ctx, "POST", item+action+"?user="+swapper.ID, "", nil)
+			assert.Equal(t, http.StatusOK, rr.Code, "%s%s", item, action)
+		}
+		assert.Equal(t, http.StatusConflict, c.do(ctx, "POST", item+"/delivery?user="+swapper.ID, "", nil).Code)
+	}
+	c.do(ctx, "GET", "/books/"+book+"/history", "", nil)
+	c.do(ctx, "GET", "/magazines/"+mag+"/history", "", nil)
+	c.do(ctx, "GET", "/users/"+owner.ID+"/history", "", nil)
+	accounts = items[db.CreditAccount](t, c.do(ctx, "GET", "/users/"+owner.ID+"/credits", "", nil))
+	require.Equal(t, 1, len(accounts))
+	assert.Equal(t, db.StartingCredits+2*db.SwapCredits, accounts[0].Balance)
+	rr = c.do(ctx, "GET", "/users/"+owner.ID+"/notifications", "", nil)
+	assert.NotEmpty(t, items[db.Notification](t, rr))
+
+	// Both sides of the delivered swaps review each other.
+	review := `{"rating":5,"comment":"Well packed"}`
+	rr = c.do(ctx, "POST", "/books/"+book+"/reviews?user="+swapper.ID, review, nil)
+	reviews := items[db.Review](t, rr)
+	require.Equal(t, 1, len(reviews))
+	assert.Equal(t, owner.ID, reviews[0].RevieweeID)
+	assert.Equal(t, http.StatusConflict,
+		c.do(ctx, "POST", "/books/"+book+"/reviews?user="+swapper.ID, review, nil).Code)
+	rr = c.do(ctx, "POST", "/magazines/"+mag+"/reviews?user="+owner.ID, `{"rating":2}`, nil)
+	reviews = items[db.Review](t, rr)
+	require.Equal(t, 1, len(reviews))
+	rr = c.do(ctx, "GET", "/users/"+owner.ID+"/reviews", "", nil)
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, &db.Reputation{Average: 5, Count: 1}, resp.Reputation)
+	assert.Equal(t, 1, len(items[db.Review](t, rr)))
+	assert.Empty(t, items[db.Book](t, c.do(ctx, "GET", "/books?min_rating=4", "", nil)))
+	c.do(ctx, "GET", "/magazines?min_rating=1", "", nil)
+	assert.Equal(t, http.StatusBadRequest, c.do(ctx, "GET", "/books?min_rating=9", "", nil).Code)
+	rr = c.do(ctx, "POST", "/reviews/"+reviews[0].ID+"/flag?user="+swapper.ID, `{"reason":"Not true"}`, nil)
+	assert.True(t, items[db.Review](t, rr)[0].Flagged)
+	assert.Empty(t, items[db.Review](t, c.do(ctx, "GET", "/users/"+swapper.ID+"/reviews", "", nil)))
+
+	rr = c.do(ctx, "POST", "/users/"+swapper.ID+"/wishlist", `{"item_type":"BOOK","name":"Emma"}`, nil)
+	wishes := items[db.WishlistItem](t, rr)
+	require.Equal(t, 1, len(wishes))
+	c.do(ctx, "GET", "/users/"+swapper.ID+"/wishlist", "", nil)
+	c.do(ctx, "DELETE", "/users/"+swapper.ID+"/wishlist/"+wishes[0].ID, "", nil)
+
+	rr = c.do(ctx, "GET", "/webhooks/"+webhook+"/deliveries", "", nil)
+	deliveries := items[db.WebhookDelivery](t, rr)
+	require.Equal(t, 2, len(deliveries))
+	c.do(ctx, "POST", "/webhooks/"+webhook+"/deliveries/"+deliveries[0].ID+"/replay", "", nil)
+	c.do(ctx, "DELETE", "/webhooks/"+webhook, "", nil)
+
+	// Requests without a community header are served by the default community.
+	rr = c.do(ctx, "POST", "/communities?user="+admin.ID,
+		fmt.Sprintf(`{"slug":"contract-%s","name":"Contract test"}`, uuid.NewString()[:8]), nil)
+	communities := items[db.Community](t, rr)
+	require.Equal(t, 1, len(communities))
+	var slugs []string
+	for _, cm := range items[db.Community](t, c.do(ctx, "GET", "/communities", "", nil)) {
+		slugs = append(slugs, cm.Slug)
+	}
+	assert.Contains(t, slugs, communities[0].Slug)
+	assert.Empty(t, items[db.Book](t, c.do(ctx, "GET", "/books", "",
+		http.Header{handlers.CommunityHeader: {communities[0].Slug}})))
+	assert.Equal(t, http.StatusForbidden, c.do(ctx, "POST", "/communities?user="+owner.ID,
+		`{"slug":"contract","name":"Contract test"}`, nil).Code)
+
+	// Users report items to the admins, who flag, remove and suspend with a reason.
+	rr = c.do(ctx, "POST", "/books", fmt.Sprintf(`{"name":"Emma","owner_id":%q}`, owner.ID), nil)
+	flaggable := items[db.Book](t, rr)[0].ID
+	reports := items[db.Report](t, c.do(ctx, "POST", "/reports?user="+swapper.ID,
+		fmt.Sprintf(`{"subject_type":"BOOK","subject_id":%q,"reason":"Counterfeit"}`, flaggable), nil))
+	require.Equal(t, 1, len(reports))
+	assert.Contains(t, items[db.Report](t, c.do(ctx, "GET", "/admin/reports?status=OPEN&user="+admin.ID, "", nil)),
+		reports[0])
+	assert.Equal(t, http.StatusForbidden, c.do(ctx, "GET", "/admin/reports?user="+owner.ID, "", nil).Code)
+	note := `{"reason":"Contract test"}`
+	rr = c.do(ctx, "POST", "/admin/books/"+flaggable+"/flag?user="+admin.ID, note, nil)
+	assert.True(t, items[db.Book](t, rr)[0].Flagged)
+	assert.NotContains(t, ids(items[db.Book](t, c.do(ctx, "GET", "/books", "", nil))), flaggable)
+	assert.Contains(t, ids(items[db.Book](t, c.do(ctx, "GET", "/admin/books?flagged=true&user="+admin.ID, "",
+		nil))), flaggable)
+	c.do(ctx, "POST", "/admin/books/"+flaggable+"/unflag?user="+admin.ID, note, nil)
+	rr = c.do(ctx, "POST", "/admin/books/"+flaggable+"/remove?user="+admin.ID, note, nil)
+	assert.Equal(t, db.Removed, items[db.Book](t, rr)[0].Status)
+	rr = c.do(ctx, "POST", "/admin/reports/"+reports[0].ID+"/resolve?user="+admin.ID, note, nil)
+	assert.Equal(t, db.ReportResolved, items[db.Report](t, rr)[0].Status)
+	c.do(ctx, "GET", "/admin/magazines?user="+admin.ID, "", nil)
+	c.do(ctx, "POST", "/admin/magazines/"+mag+"/flag?user="+admin.ID, note, nil)
+	c.do(ctx, "POST", "/admin/magazines/"+mag+"/unflag?user="+admin.ID, note, nil)
+	c.do(ctx, "POST", "/admin/magazines/"+mag+"/remove?user="+admin.ID, note, nil)
+	rr = c.do(ctx, "POST", "/admin/users/"+swapper.ID+"/suspend?user="+admin.ID, note, nil)
+	assert.True(t, items[db.User](t, rr)[0].Suspended)
+	assert.Equal(t, http.StatusForbidden, c.do(ctx, "POST", "/books",
+		fmt.Sprintf(`{"name":"Persuasion","owner_id":%q}`, swapper.ID), nil).Code)
+	c.do(ctx, "POST", "/admin/users/"+swapper.ID+"/reactivate?user="+admin.ID, note, nil)
+	actions := items[db.AdminAction](t, c.do(ctx, "GET", "/admin/actions?subject="+flaggable+"&user="+admin.ID,
+		"", nil))
+	require.Equal(t, 3, len(actions))
+	assert.Equal(t, db.ActionRemoved, actions[0].Action)
+
+	// Holds keep items reserved until they are released or converted into a swap.
+	c.do(ctx, "POST", "/books/"+book+"/relist?user="+swapper.ID, "", nil)
+	held := items[db.Hold](t, c.do(ctx, "POST", "/books/"+book+"/hold?user="+owner.ID, "", nil))
+	require.Equal(t, 1, len(held))
+	assert.Equal(t, http.StatusConflict, c.do(ctx, "POST", "/books/"+book+"?user="+admin.ID, "", nil).Code)
+	assert.Contains(t, items[db.Hold](t, c.do(ctx, "GET", "/users/"+owner.ID+"/holds", "", nil)), held[0])
+	rr = c.do(ctx, "POST", "/holds/"+held[0].ID+"/release?user="+owner.ID, "", nil)
+	assert.Equal(t, db.HoldReleased, items[db.Hold](t, rr)[0].Status)
+	rr = c.do(ctx, "POST", "/magazines", fmt.Sprintf(`{"name":"Byte","owner_id":%q}`, swapper.ID), nil)
+	rr = c.do(ctx, "POST", "/magazines/"+items[db.Magazine](t, rr)[0].ID+"/hold?user="+owner.ID, "", nil)
+	rr = c.do(ctx, "POST", "/holds/"+items[db.Hold](t, rr)[0].ID+"/swap?user="+owner.ID, "", nil)
+	assert.Equal(t, db.HoldConverted, items[db.Hold](t, rr)[0].Status)
+
+	query := fmt.Sprintf(`{"query":"{ user(id: \"%s\") { name books { id status } } }"}`, owner.ID)
+	c.do(ctx, "POST", "/graphql", query, nil)
+	streamCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	rr = c.do(streamCtx, "GET", "/events?type=created,swapped&item_type=BOOK", "", http.Header{"Last-Event-ID": {"0"}})
+	assert.Contains(t, rr.Body.String(), "event: item-created")
+
+	for path, item := range doc.Paths {
+		for method, op := range item.Operations() {
+			assert.True(t, c.covered[op.OperationID], "%s %s is not covered by the contract test", method, path)
+		}
+	}
+}
@@ -1,22 +1,38 @@
 package handlers
 
 import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
 	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/ugorji/go/codec"
 )
+
 type ResponseItemType interface {
-	db.Book | db.Magazine
+	db.Book | db.Magazine | db.User | db.ItemEvent | db.WishlistItem | db.Notification |
+		db.WebhookSubscription | db.WebhookDelivery | db.ImportResult | db.ShippingQuote | db.CreditAccount |
+		db.Review | db.Community | db.Report | db.AdminAction | db.Hold
 }
 
 // Response contains all the response types of our handlers.
 type Response[T ResponseItemType] struct {
-	Message string   `json:"message,omitempty"`
-	Error   string   `json:"error,omitempty"`
-	Items   []T      `json:"items,omitempty"`
-	User    *db.User `json:"user,omitempty"`
+	Message    string         `json:"message,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	Items      []T            `json:"items,omitempty"`
+	User       *db.User       `json:"user,omitempty"`
+	Reputation *db.Reputation `json:"reputation,omitempty"`
 }
 
 // writeResponse is a helper method that allows to write the HTTP status & response
@@ -29,3 +45,289 @@ func writeResponse[T ResponseItemType](w http.ResponseWriter, status int, resp *
 		fmt.Fprintf(w, "error encoding resp %v:%s", resp, err)
 	}
 }
+
+const (
+	jsonContentType    = "application/json"
+	msgpackContentType = "application/msgpack"
+)
+
+// listContentTypes are the content types which list routes can write, in order of preference.
+var listContentTypes = []string{jsonContentType, csvContentType, msgpackContentType}
+
+// contentTypeAliases maps the other names which clients use for list content types to them.
+var contentTypeAliases = map[string]string{
+	"application/x-msgpack": msgpackContentType,
+}
+
+var (
+	// msgpackHandle encodes MessagePack with the str and bin formats of the current spec and sorted map keys.
+	msgpackHandle = &codec.MsgpackHandle{WriteExt: true}
+	// jsonValueHandle decodes JSON into maps with string keys, slices and scalars.
+	jsonValueHandle = &codec.JsonHandle{}
+)
+
+func init() {
+	msgpackHandle.Canonical = true
+	jsonValueHandle.MapType = reflect.TypeOf(map[string]any(nil))
+	jsonValueHandle.SignedInteger = true
+}
+
+// acceptRange is a media range or content coding of an Accept or Accept-Encoding header, with its quality.
+type acceptRange struct {
+	value string
+	q     float64
+}
+
+// parseAccept parses the comma-separated values of an Accept or Accept-Encoding header.
+// Values without a quality have a quality of 1, while invalid values are skipped.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		value, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{value: value, q: q})
+	}
+	return ranges
+}
+
+// mediaQuality returns the quality of a content type, taken from the most specific media range which matches it.
+func mediaQuality(ranges []acceptRange, contentType string) float64 {
+	q, specificity := 0.0, 0
+	for _, r := range ranges {
+		value := r.value
+		if alias, ok := contentTypeAliases[value]; ok {
+			value = alias
+		}
+		s := 0
+		switch {
+		case value == contentType:
+			s = 3
+		case strings.HasSuffix(value, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(value, "*")):
+			s = 2
+		case value == "*/*":
+			s = 1
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// negotiateList returns the list content type preferred by the Accept header of a request, which defaults to JSON.
+// It writes a 406 Not Acceptable response and returns false if the request accepts none of them.
+func negotiateList[T ResponseItemType](w http.ResponseWriter, r *http.Request) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+	header := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(header) == "" {
+		return jsonContentType, true
+	}
+	ranges := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, contentType := range listContentTypes {
+		if q := mediaQuality(ranges, contentType); q > bestQ {
+			best, bestQ = contentType, q
+		}
+	}
+	if best == "" {
+		writeResponse(w, http.StatusNotAcceptable, &Response[T]{
+			Error: fmt.Sprintf("unsupported accept %q, use %s", header, strings.Join(listContentTypes, ", ")),
+		})
+		return "", false
+	}
+	return best, true
+}
+
+// writeList writes a list response in the content type returned by negotiateList.
+// The items are encoded one at a time rather than buffering the whole response,
+// and CSV only contains the items, under a header row naming their fields.
+func writeList[T ResponseItemType](w http.ResponseWriter, contentType string, resp *Response[T]) {
+	var err error
+	switch contentType {
+	case csvContentType:
+		w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+		err = writeCSVList(w, resp.Items)
+	case msgpackContentType:
+		w.Header().Set("Content-Type", msgpackContentType)
+		err = writeMsgpackList(w, resp)
+	default:
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		err = writeJSONList(w, resp)
+	}
+	if err != nil {
+		// The status has already been sent, so the error can only be logged.
+		log.Printf("writing %s list:%v", contentType, err)
+	}
+}
+
+// writeJSONList writes the same JSON as writeResponse, with the items last.
+func writeJSONList[T ResponseItemType](w io.Writer, resp *Response[T]) error {
+	head := *resp
+	head.Items = nil
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if len(resp.Items) == 0 {
+		bw.Write(data)
+		bw.WriteByte('\n')
+		return bw.Flush()
+	}
+	// Open the object of the other fields back up to append the items to it.
+	bw.Write(data[:len(data)-1])
+	if len(data) > len("{}") {
+		bw.WriteByte(',')
+	}
+	bw.WriteString(`"items":[`)
+	for i, item := range resp.Items {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		bw.Write(data)
+	}
+	bw.WriteString("]}\n")
+	return bw.Flush()
+}
+
+// writeCSVList writes items as CSV, with a column for each of their JSON fields.
+func writeCSVList[T ResponseItemType](w io.Writer, items []T) error {
+	var zero T
+	cols := csvColumns(reflect.TypeOf(zero))
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+	record := make([]string, len(cols))
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		for i, col := range cols {
+			record[i] = csvCell(fields[col])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvColumns returns the names of the JSON fields of a struct, in order.
+func csvColumns(t reflect.Type) []string {
+	var cols []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		cols = append(cols, name)
+	}
+	return cols
+}
+
+// csvCell formats a JSON value as a CSV cell. Strings are unquoted, lists of strings are separated like tags
+// and missing values are empty, while other values are kept as JSON.
+func csvCell(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return strings.Join(list, tagSeparator)
+	}
+	return string(raw)
+}
+
+// writeMsgpackList writes a list response as a MessagePack map of the same fields and values as its JSON.
+func writeMsgpackList[T ResponseItemType](w io.Writer, resp *Response[T]) error {
+	head := *resp
+	head.Items = nil
+	var fields map[string]any
+	if err := jsonValue(head, &fields); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	n := len(fields)
+	if len(resp.Items) > 0 {
+		n++
+	}
+
+	bw := bufio.NewWriter(w)
+	// The encoder flushes every value to bw, so that the headers written in between stay in order.
+	enc := codec.NewEncoder(bw, msgpackHandle)
+	bw.Write(msgpackHeader(0x80, 0xde, 0xdf, n))
+	for _, k := range keys {
+		if err := enc.Encode(k); err != nil {
+			return err
+		}
+		if err := enc.Encode(fields[k]); err != nil {
+			return err
+		}
+	}
+	if len(resp.Items) > 0 {
+		if err := enc.Encode("items"); err != nil {
+			return err
+		}
+		bw.Write(msgpackHeader(0x90, 0xdc, 0xdd, len(resp.Items)))
+		for _, item := range resp.Items {
+			var v any
+			if err := jsonValue(item, &v); err != nil {
+				return err
+			}
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// jsonValue converts a value to the maps, slices and scalars of its JSON,
+// so that other formats have the same field names and values as JSON.
+func jsonValue(v any, out any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return codec.NewDecoderBytes(data, jsonValueHandle).Decode(out)
+}
+
+// msgpackHeader returns the header of a MessagePack map or array of n elements,
+// given the first bytes of its fix, 16-bit and 32-bit formats.
+func msgpackHeader(fix, f16, f32 byte, n int) []byte {
+	switch {
+	case n < 16:
+		return []byte{fix | byte(n)}
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16([]byte{f16}, uint16(n))
+	}
+	return binary.BigEndian.AppendUint32([]byte{f32}, uint32(n))
+}
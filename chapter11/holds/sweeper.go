@@ -0,0 +1,51 @@
+// Package holds releases the holds on items once they expire, putting the items back on the catalogue.
+package holds
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// HoldReleaser is the store of holds the Sweeper releases once they expire.
+type HoldReleaser interface {
+	ReleaseExpired() ([]db.Hold, error)
+}
+
+// Sweeper releases the holds which have expired, so that held items do not stay off the catalogue
+// after their holders have lost interest.
+type Sweeper struct {
+	holds HoldReleaser
+}
+
+// NewSweeper initialises a Sweeper given its dependencies.
+func NewSweeper(holds HoldReleaser) *Sweeper {
+	return &Sweeper{
+		holds: holds,
+	}
+}
+
+// Run sweeps at the given interval until the context is done.
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.SweepOnce(); err != nil {
+			log.Printf("holds: sweep:%v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SweepOnce releases the holds which have expired and returns how many were released.
+// Holds are released one at a time, so some may have been released even if an error is returned.
+func (s *Sweeper) SweepOnce() (int, error) {
+	released, err := s.holds.ReleaseExpired()
+	return len(released), err
+}
@@ -0,0 +1,72 @@
+package holds_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/holds"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSweepOnce(t *testing.T) {
+	expired := []db.Hold{{ID: "h1", Status: db.HoldExpired}, {ID: "h2", Status: db.HoldExpired}}
+	tests := map[string]struct {
+		released []db.Hold
+		err      error
+		want     int
+	}{
+		"nothing expired": {released: []db.Hold{}},
+		"expired holds":   {released: expired, want: 2},
+		"partial failure": {released: expired[:1], err: errors.New("db down"), want: 1},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			releaser := mocks.NewHoldReleaser(t)
+			releaser.On("ReleaseExpired").Return(tc.released, tc.err).Once()
+
+			// Act
+			n, err := holds.NewSweeper(releaser).SweepOnce()
+
+			// Assert
+			assert.Equal(t, tc.want, n)
+			assert.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	// Arrange
+	releaser := mocks.NewHoldReleaser(t)
+	swept := make(chan struct{})
+	releaser.On("ReleaseExpired").Return([]db.Hold{}, nil).Run(func(mock.Arguments) {
+		select {
+		case swept <- struct{}{}:
+		default:
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	// Act
+	go func() {
+		holds.NewSweeper(releaser).Run(ctx, time.Millisecond)
+		close(done)
+	}()
+	// The sweeper sweeps straight away and then at every tick.
+	<-swept
+	<-swept
+	cancel()
+
+	// Assert
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sweeper did not stop once its context was done")
+	}
+}
@@ -0,0 +1,31 @@
+// Package notify delivers user notifications through external channels, such as email and webhooks.
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// ErrNoAddress is returned by channels which cannot reach the recipient of a message.
+// Deliveries failing with it are skipped rather than retried.
+var ErrNoAddress = errors.New("recipient has no address for channel")
+
+// ErrUnknownChannel is recorded for deliveries queued for a channel which is no longer configured.
+// They are skipped, so that they do not stay queued ahead of the deliveries which can be sent.
+var ErrUnknownChannel = errors.New("channel is not configured")
+
+// Message is a rendered notification, ready to be sent to its recipient.
+type Message struct {
+	Subject      string
+	Body         string
+	Notification db.Notification
+	User         db.User
+}
+
+// Channel is a way of delivering messages to users.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
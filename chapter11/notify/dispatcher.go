@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+const (
+	defaultBatchSize   = 50
+	defaultMaxAttempts = 5
+	defaultBackoff     = 30 * time.Second
+)
+
+// DeliveryStore is the persistent queue the Dispatcher takes its deliveries from.
+type DeliveryStore interface {
+	Enqueue(channels []string) error
+	Pending(limit int) ([]db.QueuedDelivery, error)
+	RecordAttempt(id string, status db.DeliveryStatus, sendErr error, retryAt time.Time) error
+}
+
+// Dispatcher sends queued notifications through its channels, retrying failed deliveries
+// with exponential backoff until they run out of attempts.
+type Dispatcher struct {
+	store       DeliveryStore
+	channels    map[string]Channel
+	names       []string
+	batchSize   int
+	maxAttempts int
+	backoff     time.Duration
+	clock       db.Clock
+}
+
+// NewDispatcher initialises a Dispatcher given its dependencies.
+// Retries are scheduled by the given clock, or the system clock if it is nil.
+func NewDispatcher(store DeliveryStore, clock db.Clock, channels ...Channel) *Dispatcher {
+	if clock == nil {
+		clock = db.SystemClock{}
+	}
+	d := &Dispatcher{
+		store:       store,
+		channels:    make(map[string]Channel, len(channels)),
+		batchSize:   defaultBatchSize,
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultBackoff,
+		clock:       clock,
+	}
+	for _, c := range channels {
+		d.channels[c.Name()] = c
+		d.names = append(d.names, c.Name())
+	}
+	sort.Strings(d.names)
+	return d
+}
+
+// WithRetries configures how many times a delivery is attempted and the delay before the first retry.
+func (d *Dispatcher) WithRetries(maxAttempts int, backoff time.Duration) *Dispatcher {
+	d.maxAttempts = maxAttempts
+	d.backoff = backoff
+	return d
+}
+
+// Run dispatches notifications at the given interval until the context is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.DispatchOnce(ctx); err != nil {
+			log.Printf("notify: dispatch:%v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce queues deliveries for new notifications and attempts the ones which are due.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) error {
+	if err := d.store.Enqueue(d.names); err != nil {
+		return err
+	}
+	pending, err := d.store.Pending(d.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, q := range pending {
+		err := d.send(ctx, q)
+		status, retryAt := d.outcome(q.Delivery, err)
+		if rerr := d.store.RecordAttempt(q.Delivery.ID, status, err, retryAt); rerr != nil {
+			return rerr
+		}
+	}
+
+	return nil
+}
+
+// send renders a queued notification and sends it through the channel of its delivery.
+func (d *Dispatcher) send(ctx context.Context, q db.QueuedDelivery) error {
+	c, ok := d.channels[q.Delivery.Channel]
+	if !ok {
+		return ErrUnknownChannel
+	}
+	msg, err := Render(q.Notification, q.User)
+	if err != nil {
+		return err
+	}
+	return c.Send(ctx, msg)
+}
+
+// outcome works out the status of a delivery after an attempt to send it
+// and, if it is to be retried, when.
+func (d *Dispatcher) outcome(dl db.Delivery, err error) (db.DeliveryStatus, time.Time) {
+	now := d.clock.Now()
+	switch {
+	case err == nil:
+		return db.DeliverySent, now
+	case errors.Is(err, ErrNoAddress), errors.Is(err, ErrUnknownChannel):
+		return db.DeliverySkipped, now
+	case dl.Attempts+1 >= d.maxAttempts:
+		return db.DeliveryFailed, now
+	}
+	return db.DeliveryPending, now.Add(d.backoff << dl.Attempts)
+}
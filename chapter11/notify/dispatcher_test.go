@@ -0,0 +1,118 @@
+package notify_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatchOnce(t *testing.T) {
+	sendErr := errors.New("send error")
+	backoff := time.Minute
+	clock := db.NewFakeClock(time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC))
+	tests := map[string]struct {
+		channel    string
+		attempts   int
+		sendErr    error
+		wantErr    error
+		wantStatus db.DeliveryStatus
+		wantRetry  time.Duration
+	}{
+		"sent":            {channel: "EMAIL", wantStatus: db.DeliverySent},
+		"no address":      {channel: "EMAIL", sendErr: notify.ErrNoAddress, wantStatus: db.DeliverySkipped},
+		"first failure":   {channel: "EMAIL", sendErr: sendErr, wantStatus: db.DeliveryPending, wantRetry: backoff},
+		"third failure":   {channel: "EMAIL", attempts: 2, sendErr: sendErr, wantStatus: db.DeliveryPending, wantRetry: 4 * backoff},
+		"out of attempts": {channel: "EMAIL", attempts: 3, sendErr: sendErr, wantStatus: db.DeliveryFailed},
+		"unknown channel": {channel: "SMS", wantErr: notify.ErrUnknownChannel, wantStatus: db.DeliverySkipped},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := db.QueuedDelivery{
+				Delivery:     db.Delivery{ID: "delivery-id", Channel: tc.channel, Attempts: tc.attempts},
+				Notification: db.Notification{Type: db.SwapPosted, ItemType: db.BookItem, ItemName: "Dune"},
+				User:         db.User{Name: "Ann", Email: "ann@example.com"},
+			}
+			store := mocks.NewDeliveryStore(t)
+			store.On("Enqueue", []string{"EMAIL"}).Return(nil).Once()
+			store.On("Pending", mock.AnythingOfType("int")).Return([]db.QueuedDelivery{q}, nil).Once()
+			ch := mocks.NewChannel(t)
+			ch.On("Name").Return("EMAIL")
+			wantErr := tc.sendErr
+			if tc.wantErr != nil {
+				wantErr = tc.wantErr
+			} else {
+				ch.On("Send", mock.Anything, mock.MatchedBy(func(msg notify.Message) bool {
+					return msg.Subject == "Dune is on its way" && msg.User.Email == "ann@example.com"
+				})).Return(tc.sendErr).Once()
+			}
+			store.On("RecordAttempt", "delivery-id", tc.wantStatus, wantErr, clock.Now().Add(tc.wantRetry)).
+				Return(nil).Once()
+
+			d := notify.NewDispatcher(store, clock, ch).WithRetries(4, backoff)
+			require.Nil(t, d.DispatchOnce(context.Background()))
+		})
+	}
+
+	t.Run("more deliveries for unknown channels than a batch", func(t *testing.T) {
+		// The store returns the oldest deliveries first, so the delivery which can be sent
+		// only comes up once the deliveries for unknown channels ahead of it are out of the queue.
+		var queue []db.QueuedDelivery
+		for i := 0; i < 60; i++ {
+			queue = append(queue, db.QueuedDelivery{Delivery: db.Delivery{ID: fmt.Sprintf("sms-%d", i), Channel: "SMS"}})
+		}
+		queue = append(queue, db.QueuedDelivery{
+			Delivery:     db.Delivery{ID: "email-id", Channel: "EMAIL"},
+			Notification: db.Notification{Type: db.SwapPosted, ItemType: db.BookItem, ItemName: "Dune"},
+			User:         db.User{Name: "Ann", Email: "ann@example.com"},
+		})
+		store := mocks.NewDeliveryStore(t)
+		store.On("Enqueue", []string{"EMAIL"}).Return(nil)
+		store.On("Pending", mock.AnythingOfType("int")).Return(func(limit int) []db.QueuedDelivery {
+			if limit > len(queue) {
+				limit = len(queue)
+			}
+			return append([]db.QueuedDelivery(nil), queue[:limit]...)
+		}, nil)
+		store.On("RecordAttempt", mock.AnythingOfType("string"), mock.Anything, mock.Anything,
+			mock.AnythingOfType("time.Time")).Run(func(args mock.Arguments) {
+			for i, q := range queue {
+				if q.Delivery.ID == args.String(0) {
+					queue = append(queue[:i], queue[i+1:]...)
+					break
+				}
+			}
+		}).Return(nil)
+		ch := mocks.NewChannel(t)
+		ch.On("Name").Return("EMAIL")
+		ch.On("Send", mock.Anything, mock.AnythingOfType("notify.Message")).Return(nil).Once()
+		d := notify.NewDispatcher(store, clock, ch)
+
+		for i := 0; i < 2; i++ {
+			require.Nil(t, d.DispatchOnce(context.Background()))
+		}
+
+		assert.Empty(t, queue)
+		store.AssertCalled(t, "RecordAttempt", "sms-0", db.DeliverySkipped, notify.ErrUnknownChannel, clock.Now())
+		store.AssertCalled(t, "RecordAttempt", "email-id", db.DeliverySent, nil, clock.Now())
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		storeErr := errors.New("store error")
+		store := mocks.NewDeliveryStore(t)
+		store.On("Enqueue", []string{"EMAIL"}).Return(storeErr).Once()
+		ch := mocks.NewChannel(t)
+		ch.On("Name").Return("EMAIL")
+
+		err := notify.NewDispatcher(store, nil, ch).DispatchOnce(context.Background())
+		assert.Equal(t, storeErr, err)
+	})
+}
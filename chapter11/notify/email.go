@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// EmailChannel sends messages by email through an SMTP server.
+type EmailChannel struct {
+	addr  string
+	from  string
+	auth  smtp.Auth
+	clock db.Clock
+}
+
+// NewEmailChannel initialises an EmailChannel sending from the given address through
+// the SMTP server at addr. The auth is optional. Emails are dated by the given clock,
+// or the system clock if it is nil.
+func NewEmailChannel(addr, from string, auth smtp.Auth, clock db.Clock) *EmailChannel {
+	if clock == nil {
+		clock = db.SystemClock{}
+	}
+	return &EmailChannel{
+		addr:  addr,
+		from:  from,
+		auth:  auth,
+		clock: clock,
+	}
+}
+
+// Name returns the name of the channel.
+func (ec *EmailChannel) Name() string {
+	return "EMAIL"
+}
+
+// Send emails a message to its recipient.
+func (ec *EmailChannel) Send(ctx context.Context, msg Message) error {
+	if msg.User.Email == "" {
+		return fmt.Errorf("user %s:%w", msg.User.ID, ErrNoAddress)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", ec.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.User.Email)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", ec.clock.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.Body)
+
+	return smtp.SendMail(ec.addr, ec.auth, ec.from, []string{msg.User.Email}, b.Bytes())
+}
@@ -0,0 +1,59 @@
+package notify_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/notify"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/notify/smtptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailChannel(t *testing.T) {
+	srv := smtptest.NewServer()
+	defer srv.Close()
+	clock := db.NewFakeClock(time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC))
+	ec := notify.NewEmailChannel(srv.Addr, "noreply@bookswap.test", nil, clock)
+	msg := notify.Message{
+		Subject: "The Hobbit is on its way",
+		Body:    "Hi Ann,\nIt has been posted.\n",
+		User:    db.User{ID: "user-id", Name: "Ann", Email: "ann@example.com"},
+	}
+
+	t.Run("sent", func(t *testing.T) {
+		require.Nil(t, ec.Send(context.Background(), msg))
+		msgs := srv.Messages()
+		require.Equal(t, 1, len(msgs))
+		assert.Equal(t, "noreply@bookswap.test", msgs[0].From)
+		assert.Equal(t, []string{"ann@example.com"}, msgs[0].To)
+		assert.Equal(t, msg.Subject, msgs[0].Header("Subject"))
+		assert.Equal(t, "ann@example.com", msgs[0].Header("To"))
+		assert.Equal(t, "Wed, 01 Mar 2023 12:00:00 +0000", msgs[0].Header("Date"))
+		assert.Equal(t, msg.Body, msgs[0].Body())
+	})
+
+	t.Run("no email address", func(t *testing.T) {
+		noEmail := msg
+		noEmail.User.Email = ""
+		err := ec.Send(context.Background(), noEmail)
+		assert.ErrorIs(t, err, notify.ErrNoAddress)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		srv.RejectRecipients(true)
+		defer srv.RejectRecipients(false)
+		err := ec.Send(context.Background(), msg)
+		assert.NotNil(t, err)
+		assert.NotErrorIs(t, err, notify.ErrNoAddress)
+	})
+
+	t.Run("server down", func(t *testing.T) {
+		down := smtptest.NewServer()
+		down.Close()
+		err := notify.NewEmailChannel(down.Addr, "noreply@bookswap.test", nil, nil).Send(context.Background(), msg)
+		assert.NotNil(t, err)
+	})
+}
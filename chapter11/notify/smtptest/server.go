@@ -0,0 +1,159 @@
+// Package smtptest provides an in-process SMTP server for testing code which sends emails.
+package smtptest
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message is an email received by the Server.
+type Message struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Server is a fake SMTP server listening on a local port, which records every message it receives.
+// It speaks just enough of the protocol for net/smtp clients without TLS or authentication.
+type Server struct {
+	Addr string
+
+	l        net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	messages []Message
+	reject   bool
+}
+
+// NewServer starts and returns a new Server. The caller should call Close when finished.
+func NewServer() *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("smtptest: failed to listen on a port: " + err.Error())
+	}
+	s := &Server{
+		Addr: l.Addr().String(),
+		l:    l,
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close shuts down the server and waits for its connections to finish.
+func (s *Server) Close() {
+	s.l.Close()
+	s.wg.Wait()
+}
+
+// Messages returns the messages received so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// RejectRecipients makes the server reject, or stop rejecting, every recipient with a temporary failure.
+func (s *Server) RejectRecipients(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = reject
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(textproto.NewConn(conn))
+		}()
+	}
+}
+
+func (s *Server) handle(c *textproto.Conn) {
+	var msg Message
+	c.PrintfLine("220 localhost smtptest ready")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 localhost")
+		case "MAIL":
+			msg = Message{From: address(arg)}
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			if s.rejecting() {
+				c.PrintfLine("451 Try again later")
+				continue
+			}
+			msg.To = append(msg.To, address(arg))
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "RSET":
+			msg = Message{}
+			c.PrintfLine("250 OK")
+		case "NOOP":
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *Server) rejecting() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reject
+}
+
+// address extracts the address from a MAIL FROM:<...> or RCPT TO:<...> argument.
+func address(arg string) string {
+	start := strings.Index(arg, "<")
+	end := strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+// Header returns the value of a header of a received message.
+func (m Message) Header(key string) string {
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(m.Data)))
+	h, err := r.ReadMIMEHeader()
+	if err != nil && len(h) == 0 {
+		return ""
+	}
+	return h.Get(key)
+}
+
+// Body returns the body of a received message, without its headers.
+func (m Message) Body() string {
+	_, body, _ := strings.Cut(m.Data, "\n\n")
+	return body
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// messageTemplate contains the templates for the subject and body of a notification type.
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// templateData is what the templates are rendered with.
+type templateData struct {
+	User         db.User
+	Notification db.Notification
+	Kind         string
+}
+
+const signature = `
+Happy swapping,
+The BookSwap team
+`
+
+var templates = map[db.NotificationType]messageTemplate{
+	db.WishlistMatch: newMessageTemplate(
+		`{{.Notification.ItemName}} is now available`,
+		`Hi {{.User.Name}},
+
+Good news! The {{.Kind}} "{{.Notification.ItemName}}" from your wishlist is now available for swapping.
+`),
+	db.SwapRequested: newMessageTemplate(
+		`Your {{.Kind}} {{.Notification.ItemName}} has been requested`,
+		`Hi {{.User.Name}},
+
+Another BookSwap user has requested your {{.Kind}} "{{.Notification.ItemName}}".
+We will arrange for it to be collected and posted to them.
+`),
+	db.SwapAccepted: newMessageTemplate(
+		`Your swap for {{.Notification.ItemName}} has been accepted`,
+		`Hi {{.User.Name}},
+
+Your swap for the {{.Kind}} "{{.Notification.ItemName}}" has been accepted.
+We will let you know as soon as it has been posted.
+`),
+	db.SwapPosted: newMessageTemplate(
+		`{{.Notification.ItemName}} is on its way`,
+		`Hi {{.User.Name}},
+
+The {{.Kind}} "{{.Notification.ItemName}}" has been posted to you.
+Please confirm its delivery once it arrives.
+`),
+}
+
+func newMessageTemplate(subject, body string) messageTemplate {
+	return messageTemplate{
+		subject: template.Must(template.New("subject").Parse(subject)),
+		body:    template.Must(template.New("body").Parse(body + signature)),
+	}
+}
+
+// Render renders the message for a notification to a given user.
+func Render(n db.Notification, u db.User) (Message, error) {
+	t, ok := templates[n.Type]
+	if !ok {
+		return Message{}, fmt.Errorf("no template for notification type %s", n.Type)
+	}
+	data := templateData{
+		User:         u,
+		Notification: n,
+		Kind:         strings.ToLower(string(n.ItemType)),
+	}
+	var subject, body bytes.Buffer
+	if err := t.subject.Execute(&subject, data); err != nil {
+		return Message{}, err
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject:      subject.String(),
+		Body:         body.String(),
+		Notification: n,
+		User:         u,
+	}, nil
+}
@@ -0,0 +1,40 @@
+package notify_test
+
+import (
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	u := db.User{Name: "Ann"}
+	tests := map[db.NotificationType]struct {
+		wantSubject string
+		wantBody    string
+	}{
+		db.WishlistMatch: {wantSubject: "Dune is now available", wantBody: `The book "Dune" from your wishlist`},
+		db.SwapRequested: {wantSubject: "Your book Dune has been requested", wantBody: `requested your book "Dune"`},
+		db.SwapAccepted:  {wantSubject: "Your swap for Dune has been accepted", wantBody: `Your swap for the book "Dune"`},
+		db.SwapPosted:    {wantSubject: "Dune is on its way", wantBody: `The book "Dune" has been posted to you`},
+	}
+	for nt, tc := range tests {
+		t.Run(string(nt), func(t *testing.T) {
+			n := db.Notification{Type: nt, ItemType: db.BookItem, ItemName: "Dune"}
+			msg, err := notify.Render(n, u)
+			require.Nil(t, err)
+			assert.Equal(t, tc.wantSubject, msg.Subject)
+			assert.Contains(t, msg.Body, "Hi Ann,")
+			assert.Contains(t, msg.Body, tc.wantBody)
+			assert.Equal(t, n, msg.Notification)
+			assert.Equal(t, u, msg.User)
+		})
+	}
+
+	t.Run("unknown type", func(t *testing.T) {
+		_, err := notify.Render(db.Notification{Type: "UNKNOWN"}, u)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+// WebhookPayload is the body posted to the webhook for every message.
+type WebhookPayload struct {
+	Subject      string          `json:"subject"`
+	Body         string          `json:"body"`
+	Notification db.Notification `json:"notification"`
+}
+
+// WebhookChannel sends messages by posting them to a URL.
+type WebhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookChannel initialises a WebhookChannel posting to the given URL.
+func NewWebhookChannel(url string, client *http.Client) *WebhookChannel {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookChannel{
+		url:    url,
+		client: client,
+	}
+}
+
+// Name returns the name of the channel.
+func (wc *WebhookChannel) Name() string {
+	return "WEBHOOK"
+}
+
+// Send posts a message to the webhook, expecting a successful response.
+func (wc *WebhookChannel) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(WebhookPayload{
+		Subject:      msg.Subject,
+		Body:         msg.Body,
+		Notification: msg.Notification,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wc.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := wc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookChannel(t *testing.T) {
+	msg := notify.Message{
+		Subject:      "Your swap for Dune has been accepted",
+		Body:         "Hi Ann",
+		Notification: db.Notification{ID: "notification-id", Type: db.SwapAccepted, ItemName: "Dune"},
+	}
+	tests := map[string]struct {
+		status  int
+		wantErr bool
+	}{
+		"accepted":     {status: http.StatusNoContent},
+		"server error": {status: http.StatusInternalServerError, wantErr: true},
+		"not found":    {status: http.StatusNotFound, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got notify.WebhookPayload
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				require.Nil(t, json.NewDecoder(r.Body).Decode(&got))
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			err := notify.NewWebhookChannel(srv.URL, srv.Client()).Send(context.Background(), msg)
+			if tc.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, msg.Subject, got.Subject)
+			assert.Equal(t, msg.Body, got.Body)
+			assert.Equal(t, msg.Notification.ID, got.Notification.ID)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+)
+
+const (
+	defaultBatchSize   = 50
+	defaultMaxAttempts = 8
+	defaultBackoff     = 30 * time.Second
+)
+
+// DeliveryQueue is the persistent queue the Dispatcher takes its deliveries from.
+type DeliveryQueue interface {
+	Pending(limit int) ([]db.QueuedWebhookDelivery, error)
+	RecordAttempt(id string, status db.DeliveryStatus, responseStatus int, sendErr error, retryAt time.Time) error
+}
+
+// Dispatcher posts queued deliveries to their webhooks, retrying failed deliveries
+// with exponential backoff until they run out of attempts.
+type Dispatcher struct {
+	queue       DeliveryQueue
+	client      *http.Client
+	batchSize   int
+	maxAttempts int
+	backoff     time.Duration
+	clock       db.Clock
+}
+
+// NewDispatcher initialises a Dispatcher given its dependencies.
+// Retries are scheduled by the given clock, or the system clock if it is nil.
+func NewDispatcher(queue DeliveryQueue, client *http.Client, clock db.Clock) *Dispatcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if clock == nil {
+		clock = db.SystemClock{}
+	}
+	return &Dispatcher{
+		queue:       queue,
+		client:      client,
+		batchSize:   defaultBatchSize,
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultBackoff,
+		clock:       clock,
+	}
+}
+
+// NewPublicClient returns an HTTP client with the given timeout which refuses to connect to
+// private and loopback addresses, even if the host of a webhook resolves to one after it was subscribed.
+func NewPublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !db.IsPublicIP(ip) {
+				return fmt.Errorf("webhook address %s is not public", address)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// WithRetries configures how many times a delivery is attempted and the delay before the first retry.
+func (d *Dispatcher) WithRetries(maxAttempts int, backoff time.Duration) *Dispatcher {
+	d.maxAttempts = maxAttempts
+	d.backoff = backoff
+	return d
+}
+
+// Run dispatches deliveries at the given interval until the context is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.DispatchOnce(ctx); err != nil {
+			log.Printf("webhooks: dispatch:%v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce attempts the deliveries which are due.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) error {
+	pending, err := d.queue.Pending(d.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, q := range pending {
+		responseStatus, err := d.post(ctx, q)
+		status, retryAt := d.outcome(q.Delivery, err)
+		if rerr := d.queue.RecordAttempt(q.Delivery.ID, status, responseStatus, err, retryAt); rerr != nil {
+			return rerr
+		}
+	}
+
+	return nil
+}
+
+// post sends a signed delivery to its webhook, returning the status it responded with.
+func (d *Dispatcher) post(ctx context.Context, q db.QueuedWebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.Subscription.URL, bytes.NewReader(q.Delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(q.Subscription.Secret, q.Delivery.Payload))
+	req.Header.Set(EventHeader, string(q.Delivery.EventType))
+	req.Header.Set(DeliveryHeader, q.Delivery.ID)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// outcome works out the status of a delivery after an attempt to send it
+// and, if it is to be retried, when.
+func (d *Dispatcher) outcome(dl db.WebhookDelivery, err error) (db.DeliveryStatus, time.Time) {
+	now := d.clock.Now()
+	switch {
+	case err == nil:
+		return db.DeliverySent, now
+	case dl.Attempts+1 >= d.maxAttempts:
+		return db.DeliveryFailed, now
+	}
+	return db.DeliveryPending, now.Add(d.backoff << dl.Attempts)
+}
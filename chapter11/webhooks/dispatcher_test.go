@@ -0,0 +1,142 @@
+package webhooks_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/db"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/mocks"
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a partner's webhook, which fails a number of requests before accepting them.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestNewPublicClient(t *testing.T) {
+	srv := httptest.NewServer(&receiver{})
+	defer srv.Close()
+
+	_, err := webhooks.NewPublicClient(time.Second).Get(srv.URL)
+
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "is not public")
+}
+
+func TestDispatchOnce(t *testing.T) {
+	backoff := time.Minute
+	payload := []byte(`{"id":42,"type":"SWAPPED"}`)
+	newQueued := func(url string, attempts int) db.QueuedWebhookDelivery {
+		return db.QueuedWebhookDelivery{
+			Delivery: db.WebhookDelivery{
+				ID:        "delivery-id",
+				EventID:   42,
+				EventType: db.ItemSwapped,
+				Payload:   payload,
+				Attempts:  attempts,
+			},
+			Subscription: db.WebhookSubscription{URL: url, Secret: "secret"},
+		}
+	}
+
+	t.Run("signed delivery", func(t *testing.T) {
+		rc := &receiver{}
+		srv := httptest.NewServer(rc)
+		defer srv.Close()
+		queue := mocks.NewDeliveryQueue(t)
+		queue.On("Pending", mock.AnythingOfType("int")).Return([]db.QueuedWebhookDelivery{newQueued(srv.URL, 0)}, nil).Once()
+		queue.On("RecordAttempt", "delivery-id", db.DeliverySent, http.StatusNoContent, nil, mock.AnythingOfType("time.Time")).
+			Return(nil).Once()
+
+		require.Nil(t, webhooks.NewDispatcher(queue, srv.Client(), nil).DispatchOnce(context.Background()))
+		require.Equal(t, 1, len(rc.requests))
+		r := rc.requests[0]
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, string(db.ItemSwapped), r.Header.Get(webhooks.EventHeader))
+		assert.Equal(t, "delivery-id", r.Header.Get(webhooks.DeliveryHeader))
+		assert.Equal(t, payload, rc.bodies[0])
+		assert.True(t, webhooks.Verify("secret", rc.bodies[0], r.Header.Get(webhooks.SignatureHeader)))
+	})
+
+	t.Run("retried with backoff", func(t *testing.T) {
+		rc := &receiver{failures: 2}
+		srv := httptest.NewServer(rc)
+		defer srv.Close()
+		queue := mocks.NewDeliveryQueue(t)
+		clock := db.NewFakeClock(time.Date(2023, time.March, 1, 12, 0, 0, 0, time.UTC))
+		d := webhooks.NewDispatcher(queue, srv.Client(), clock).WithRetries(5, backoff)
+		for attempts, wantRetry := range []time.Duration{backoff, 2 * backoff} {
+			queue.On("Pending", mock.AnythingOfType("int")).Return([]db.QueuedWebhookDelivery{newQueued(srv.URL, attempts)}, nil).Once()
+			queue.On("RecordAttempt", "delivery-id", db.DeliveryPending, http.StatusServiceUnavailable,
+				mock.Anything, clock.Now().Add(wantRetry)).Return(nil).Once()
+			require.Nil(t, d.DispatchOnce(context.Background()))
+			clock.Advance(wantRetry)
+		}
+		queue.On("Pending", mock.AnythingOfType("int")).Return([]db.QueuedWebhookDelivery{newQueued(srv.URL, 2)}, nil).Once()
+		queue.On("RecordAttempt", "delivery-id", db.DeliverySent, http.StatusNoContent, nil, mock.AnythingOfType("time.Time")).
+			Return(nil).Once()
+		require.Nil(t, d.DispatchOnce(context.Background()))
+		assert.Equal(t, 3, len(rc.requests))
+	})
+
+	t.Run("out of attempts", func(t *testing.T) {
+		rc := &receiver{failures: 1}
+		srv := httptest.NewServer(rc)
+		defer srv.Close()
+		queue := mocks.NewDeliveryQueue(t)
+		queue.On("Pending", mock.AnythingOfType("int")).Return([]db.QueuedWebhookDelivery{newQueued(srv.URL, 4)}, nil).Once()
+		queue.On("RecordAttempt", "delivery-id", db.DeliveryFailed, http.StatusServiceUnavailable,
+			mock.Anything, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		d := webhooks.NewDispatcher(queue, srv.Client(), nil).WithRetries(5, backoff)
+		require.Nil(t, d.DispatchOnce(context.Background()))
+	})
+
+	t.Run("receiver down", func(t *testing.T) {
+		srv := httptest.NewServer(&receiver{})
+		srv.Close()
+		queue := mocks.NewDeliveryQueue(t)
+		queue.On("Pending", mock.AnythingOfType("int")).Return([]db.QueuedWebhookDelivery{newQueued(srv.URL, 0)}, nil).Once()
+		queue.On("RecordAttempt", "delivery-id", db.DeliveryPending, 0, mock.Anything, mock.AnythingOfType("time.Time")).
+			Return(nil).Once()
+
+		require.Nil(t, webhooks.NewDispatcher(queue, nil, nil).DispatchOnce(context.Background()))
+	})
+
+	t.Run("queue error", func(t *testing.T) {
+		queueErr := errors.New("queue error")
+		queue := mocks.NewDeliveryQueue(t)
+		queue.On("Pending", mock.AnythingOfType("int")).Return(nil, queueErr).Once()
+
+		err := webhooks.NewDispatcher(queue, nil, nil).DispatchOnce(context.Background())
+		assert.Equal(t, queueErr, err)
+	})
+}
@@ -0,0 +1,36 @@
+// Package webhooks delivers item events to the webhooks partners have subscribed.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// SignatureHeader contains the HMAC-SHA256 signature of the payload, keyed with the subscription's secret.
+	SignatureHeader = "X-BookSwap-Signature"
+	// EventHeader contains the type of the delivered event.
+	EventHeader = "X-BookSwap-Event"
+	// DeliveryHeader contains the ID of the delivery, which stays the same across retries.
+	DeliveryHeader = "X-BookSwap-Delivery"
+
+	signaturePrefix = "sha256="
+)
+
+// Sign returns the signature of a payload, in the format sent in the SignatureHeader.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns whether a signature matches the payload it was sent with.
+// Receivers should use it to check that deliveries come from BookSwap.
+func Verify(secret string, payload []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, payload)), []byte(signature))
+}
@@ -0,0 +1,30 @@
+package webhooks_test
+
+import (
+	"testing"
+
+	"github.com/PacktPublishing/Test-Driven-Development-in-Go/chapter11/webhooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	payload := []byte(`{"id":1,"type":"CREATED"}`)
+	signature := webhooks.Sign("secret", payload)
+	tests := map[string]struct {
+		secret    string
+		payload   []byte
+		signature string
+		want      bool
+	}{
+		"valid":             {secret: "secret", payload: payload, signature: signature, want: true},
+		"wrong secret":      {secret: "other", payload: payload, signature: signature},
+		"tampered payload":  {secret: "secret", payload: []byte(`{"id":2,"type":"CREATED"}`), signature: signature},
+		"missing prefix":    {secret: "secret", payload: payload, signature: signature[len("sha256="):]},
+		"missing signature": {secret: "secret", payload: payload},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, webhooks.Verify(tc.secret, tc.payload, tc.signature))
+		})
+	}
+}